@@ -0,0 +1,113 @@
+/*
+Copyright © 2023 containeroo hello©containeroo.ch
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+	"vimbin/internal/audit"
+	"vimbin/internal/config"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	auditBin       string
+	auditTokenName string
+	auditAction    string
+	auditSince     string
+	auditLimit     int
+	auditJSON      bool
+)
+
+// auditCmd represents the 'audit' command for querying the audit log.
+var auditCmd = &cobra.Command{
+	Use:   "audit",
+	Short: "Queries the audit log of content mutations",
+	Long: `The 'audit' command lists who changed the content of which bin and when, as recorded
+in the audit log of the vimbin server. It requires a token granted the admin scope and a
+server which writes the audit log to a file.
+
+Every entry shows the action, the bin, the token and client IP which made the change, the
+change of the size in bytes and the hashes of the content before and after the change.
+The content itself is never recorded.
+
+Examples:
+  - List the last 100 changes:
+    vimbin audit --url http://example.com
+  - List the changes of a bin made by a token during the last day:
+    vimbin audit --bin notes --token-name ci --since 24h --url http://example.com
+  - Print all deletions as JSON lines:
+    vimbin audit --action delete --limit 0 --json --url http://example.com`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		query := url.Values{}
+		for key, value := range map[string]string{"bin": auditBin, "token": auditTokenName, "action": auditAction, "since": auditSince} {
+			if value != "" {
+				query.Set(key, value)
+			}
+		}
+		query.Set("limit", strconv.Itoa(auditLimit))
+
+		_, body := sendAPIRequest(newAPIRequest("GET", "/api/audit?"+query.Encode(), nil))
+
+		var response struct {
+			Entries []audit.Entry `json:"entries"`
+		}
+		if err := json.Unmarshal(body, &response); err != nil {
+			log.Fatal().Msgf("Error decoding JSON: %s", err)
+		}
+
+		if auditJSON {
+			encoder := json.NewEncoder(os.Stdout)
+			for _, entry := range response.Entries {
+				if err := encoder.Encode(entry); err != nil {
+					log.Fatal().Msgf("Error encoding JSON: %s", err)
+				}
+			}
+			return
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "TIME\tACTION\tBIN\tTOKEN\tCLIENT\tDELTA\tBEFORE\tAFTER")
+		for _, entry := range response.Entries {
+			fmt.Fprintf(w, "%s\t%s\t%s\t%s\t%s\t%+d\t%.12s\t%.12s\n", entry.Time.Local().Format(time.RFC3339), entry.Action, entry.Bin,
+				entry.Token, entry.ClientIP, entry.ByteDelta, entry.HashBefore, entry.HashAfter)
+		}
+		w.Flush()
+	},
+}
+
+func init() {
+	// Add 'auditCmd' to the root command
+	rootCmd.AddCommand(auditCmd)
+
+	// Define command-line flags for 'auditCmd'
+	auditCmd.PersistentFlags().StringVarP(&config.App.Server.Api.Address, "url", "u", "", "The URL of the vimbin server")
+	auditCmd.PersistentFlags().BoolVarP(&config.App.Server.Api.SkipInsecureVerify, "insecure-skip-verify", "i", false, "Skip TLS certificate verification")
+	auditCmd.PersistentFlags().StringVarP(&auditBin, "bin", "b", "", "Only list the changes of this bin")
+	auditCmd.PersistentFlags().StringVarP(&auditTokenName, "token-name", "", "", "Only list the changes made by the token with this name")
+	auditCmd.PersistentFlags().StringVarP(&auditAction, "action", "", "", "Only list changes of this action (save, append, patch, diff, restore, edit, reload or delete)")
+	auditCmd.PersistentFlags().StringVarP(&auditSince, "since", "s", "", "Only list changes after this time, e.g. '24h' or '2024-01-02T15:04:05Z'")
+	auditCmd.PersistentFlags().IntVarP(&auditLimit, "limit", "l", 100, "The maximum number of changes to list, the most recent are kept. 0 lists all")
+	auditCmd.PersistentFlags().BoolVarP(&auditJSON, "json", "", false, "Print the entries as JSON lines")
+}
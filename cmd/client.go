@@ -0,0 +1,266 @@
+/*
+Copyright © 2023 containeroo hello©containeroo.ch
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"strings"
+	"vimbin/internal/config"
+	"vimbin/internal/e2e"
+	"vimbin/internal/server"
+	"vimbin/internal/storage"
+	"vimbin/internal/utils"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+// addClientFlags adds the flags shared by all commands talking to a vimbin server.
+//
+// Parameters:
+//   - cmd: *cobra.Command
+//     The command to add the flags to.
+//   - bin: *string
+//     The variable to store the value of the '--bin' flag in.
+func addClientFlags(cmd *cobra.Command, bin *string) {
+	cmd.PersistentFlags().StringVarP(&config.App.Server.Api.Address, "url", "u", "", "The URL of the vimbin server")
+	cmd.PersistentFlags().BoolVarP(&config.App.Server.Api.SkipInsecureVerify, "insecure-skip-verify", "i", false, "Skip TLS certificate verification")
+	cmd.PersistentFlags().StringVarP(bin, "bin", "b", "", "The name of the bin. Defaults to the default bin")
+}
+
+var (
+	encryptionKey     string
+	encryptionKeyFile string
+)
+
+// addKeyFlags adds the flags for the key of end-to-end encrypted bins.
+//
+// Parameters:
+//   - cmd: *cobra.Command
+//     The command to add the flags to.
+func addKeyFlags(cmd *cobra.Command) {
+	cmd.PersistentFlags().StringVarP(&encryptionKey, "key", "k", "", "The key of an encrypted bin, or the link containing it")
+	cmd.PersistentFlags().StringVarP(&encryptionKeyFile, "key-file", "", "", "Read the key of an encrypted bin from this file")
+}
+
+// loadKey returns the key of an encrypted bin passed with '--key' or '--key-file'.
+//
+// Parameters:
+//   - generate: bool
+//     If true, a new key is generated if none was passed. A missing key file is created with it.
+//
+// Returns:
+//   - []byte
+//     The key.
+func loadKey(generate bool) []byte {
+	encoded := encryptionKey
+	switch {
+	case encoded != "" && encryptionKeyFile != "":
+		log.Fatal().Msg("Either --key or --key-file, not both.")
+	case encoded != "":
+	case encryptionKeyFile != "":
+		data, err := os.ReadFile(encryptionKeyFile)
+		if err == nil {
+			encoded = string(data)
+			break
+		}
+		if !generate || !errors.Is(err, fs.ErrNotExist) {
+			log.Fatal().Msgf("Unable to read key file: %s", err)
+		}
+		encoded = generateKey()
+		// Only the owner may read the key, and an existing key file is never overwritten
+		file, err := os.OpenFile(encryptionKeyFile, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0o600)
+		if err != nil {
+			log.Fatal().Msgf("Unable to create key file: %s", err)
+		}
+		if _, err := fmt.Fprintln(file, encoded); err != nil {
+			log.Fatal().Msgf("Unable to write key file: %s", err)
+		}
+		if err := file.Close(); err != nil {
+			log.Fatal().Msgf("Unable to write key file: %s", err)
+		}
+		log.Debug().Msgf("Wrote new key to '%s'", encryptionKeyFile)
+	case generate:
+		encoded = generateKey()
+	default:
+		log.Fatal().Msg("The bin is end-to-end encrypted. Pass its key with --key or --key-file.")
+	}
+
+	key, err := e2e.ParseKey(encoded)
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+
+	return key
+}
+
+// generateKey generates a new key for an encrypted bin.
+func generateKey() string {
+	encoded, err := e2e.GenerateKey()
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+	return encoded
+}
+
+// decryptContent decrypts the content of a bin if it is end-to-end encrypted.
+//
+// Parameters:
+//   - content: string
+//     The content as stored on the server.
+//
+// Returns:
+//   - string
+//     The plain content.
+//   - []byte
+//     The key the content was decrypted with, or nil if the content is not encrypted.
+func decryptContent(content string) (string, []byte) {
+	if !e2e.IsEncrypted(content) {
+		return content, nil
+	}
+
+	key := loadKey(false)
+	plain, err := e2e.Decrypt(content, key)
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+
+	return plain, key
+}
+
+// binURL returns the URL of the editor for a bin.
+//
+// Parameters:
+//   - bin: string
+//     The name of the bin. An empty name addresses the default bin.
+//
+// Returns:
+//   - string
+//     The URL of the editor.
+func binURL(bin string) string {
+	url := strings.TrimSuffix(config.App.Server.Api.Address, "/")
+	if bin == "" {
+		return url + "/"
+	}
+	return url + "/b/" + bin
+}
+
+// binAPIPath returns the API path for a bin.
+//
+// Parameters:
+//   - bin: string
+//     The name of the bin. An empty name addresses the default bin.
+//   - defaultPath: string
+//     The path used for the default bin (e.g. "/api/history").
+//   - suffix: string
+//     The suffix appended to '/api/bins/{name}' for a named bin (e.g. "/history").
+//
+// Returns:
+//   - string
+//     The API path.
+func binAPIPath(bin, defaultPath, suffix string) string {
+	if bin == "" {
+		return defaultPath
+	}
+	if !storage.IsValidBinName(bin) {
+		log.Fatal().Msgf("Invalid bin name '%s'", bin)
+	}
+
+	return "/api/bins/" + bin + suffix
+}
+
+// newAPIRequest creates an authenticated HTTP request against the vimbin server.
+//
+// Parameters:
+//   - method: string
+//     The HTTP method.
+//   - apiPath: string
+//     The path of the API endpoint.
+//   - body: io.Reader
+//     The request body, may be nil.
+//
+// Returns:
+//   - *http.Request
+//     The HTTP request with the X-API-Token header set.
+func newAPIRequest(method, apiPath string, body io.Reader) *http.Request {
+	url := strings.TrimSuffix(config.App.Server.Api.Address, "/")
+	if url == "" {
+		log.Fatal().Msg("URL is empty")
+	}
+	url += apiPath
+	log.Debug().Msgf("URL: %s", url)
+
+	apiToken := config.App.Server.Api.Token.Get()
+	if apiToken == "" {
+		log.Fatal().Msg("API token is empty")
+	}
+	if server.HashAlgorithm(apiToken) != "" {
+		log.Fatal().Msg("API token is a hash, which only the server can use. Pass the token itself with --token")
+	}
+
+	req, err := http.NewRequest(method, url, body)
+	if err != nil {
+		log.Fatal().Msgf("Error creating HTTP request: %v", err)
+	}
+	req.Header.Set("X-API-Token", apiToken)
+
+	return req
+}
+
+// sendAPIRequest sends a request to the vimbin server and reads the response body.
+//
+// Parameters:
+//   - req: *http.Request
+//     The HTTP request to send.
+//   - expectedStatusCodes: ...int
+//     The accepted status codes. Defaults to 200 OK.
+//
+// Returns:
+//   - *http.Response
+//     The HTTP response. The body is already closed.
+//   - []byte
+//     The response body.
+func sendAPIRequest(req *http.Request, expectedStatusCodes ...int) (*http.Response, []byte) {
+	if len(expectedStatusCodes) == 0 {
+		expectedStatusCodes = []int{http.StatusOK}
+	}
+
+	httpClient := utils.CreateHTTPClient(config.App.Server.Api.SkipInsecureVerify)
+	response, err := httpClient.Do(req)
+	if err != nil {
+		log.Fatal().Msgf("Error making %s request: %s", req.Method, err)
+	}
+	defer response.Body.Close()
+
+	body, err := io.ReadAll(response.Body)
+	if err != nil {
+		log.Fatal().Msgf("Error reading response body: %s", err)
+	}
+
+	for _, code := range expectedStatusCodes {
+		if response.StatusCode == code {
+			return response, body
+		}
+	}
+	log.Fatal().Msgf("Unexpected status code %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+
+	return nil, nil
+}
@@ -0,0 +1,90 @@
+/*
+Copyright © 2023 containeroo hello©containeroo.ch
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"vimbin/internal/config"
+	"vimbin/internal/diff"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	diffBin     string
+	diffContext int
+)
+
+// diffCmd represents the 'diff' command for comparing a local file with the content of a bin.
+var diffCmd = &cobra.Command{
+	Use:   "diff FILE",
+	Short: "Shows the differences between a local file and a bin",
+	Long: `The 'diff' command prints a unified diff, which changes the content of a bin on the
+vimbin server into the content of a local file. Use '-' to read the file from stdin.
+
+Encrypted bins are decrypted locally with the key passed with --key or --key-file.
+Like 'diff', it exits with status 1 if there are differences.
+Diffs can be applied to a bin with 'vimbin push --diff', which only changes the lines
+touched by the diff and keeps changes others made in the meantime.
+
+Examples:
+  - Review local changes before pushing them:
+    vimbin diff notes.md --bin notes --url http://example.com
+  - Propose your changes to a copy pulled earlier, without overwriting others' edits:
+    diff -u notes.orig notes.md | vimbin push --diff --bin notes --url http://example.com`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var local []byte
+		var err error
+		if args[0] == "-" {
+			local, err = io.ReadAll(os.Stdin)
+		} else {
+			local, err = os.ReadFile(args[0])
+		}
+		if err != nil {
+			log.Fatal().Msgf("Unable to read file: %s", err)
+		}
+
+		content, _ := pullContent(diffBin)
+		content, _ = decryptContent(content)
+
+		name := diffBin
+		if name == "" {
+			name = config.DefaultBin
+		}
+
+		patch := diff.Unified(content, string(local), "vimbin/"+name, args[0], diffContext)
+		if patch == "" {
+			return
+		}
+
+		fmt.Print(patch)
+		os.Exit(1)
+	},
+}
+
+func init() {
+	// Add 'diffCmd' to the root command
+	rootCmd.AddCommand(diffCmd)
+
+	// Define command-line flags for 'diffCmd'
+	addClientFlags(diffCmd, &diffBin)
+	addKeyFlags(diffCmd)
+	diffCmd.PersistentFlags().IntVarP(&diffContext, "unified", "U", 3, "Number of unchanged lines shown around every change")
+}
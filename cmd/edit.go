@@ -0,0 +1,154 @@
+/*
+Copyright © 2023 containeroo hello©containeroo.ch
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/exec"
+	"strings"
+	"vimbin/internal/e2e"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var editBin string
+
+// editCmd represents the 'edit' command for editing a bin in a local editor.
+var editCmd = &cobra.Command{
+	Use:   "edit",
+	Short: "Edits a bin in your editor",
+	Long: `The 'edit' command pulls the content of a bin, opens it in $VISUAL or $EDITOR
+(falling back to vi) and saves it back to the vimbin server if it was changed.
+
+If the content on the server was changed while editing, nothing is overwritten and
+the edited file is kept, so the changes can be merged by hand.
+Encrypted bins are decrypted with the key passed with --key or --key-file and
+encrypted again before saving, so the server never sees their content.
+Exiting the editor with an error (e.g. ':cq' in vim) discards the changes.
+
+Examples:
+  - Edit the default bin:
+    vimbin edit --url http://example.com
+  - Edit a named bin with nano:
+    EDITOR=nano vimbin edit --bin notes --url http://example.com`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		storedContent, etag := pullContent(editBin)
+		content, key := decryptContent(storedContent)
+
+		file, err := os.CreateTemp("", "vimbin-*.txt")
+		if err != nil {
+			log.Fatal().Msgf("Unable to create temporary file: %s", err)
+		}
+		path := file.Name()
+
+		if _, err := file.WriteString(content); err != nil {
+			os.Remove(path)
+			log.Fatal().Msgf("Unable to write temporary file: %s", err)
+		}
+		if err := file.Close(); err != nil {
+			os.Remove(path)
+			log.Fatal().Msgf("Unable to write temporary file: %s", err)
+		}
+
+		if err := runEditor(path); err != nil {
+			os.Remove(path)
+			log.Fatal().Msgf("Editor failed, discarding changes: %s", err)
+		}
+
+		edited, err := os.ReadFile(path)
+		if err != nil {
+			log.Fatal().Msgf("Unable to read edited file '%s': %s", path, err)
+		}
+		editedContent := string(edited)
+
+		// Most editors add a final newline on save, which is not a change made by the user
+		if !strings.HasSuffix(content, "\n") && editedContent == content+"\n" {
+			editedContent = content
+		}
+
+		if editedContent == content {
+			os.Remove(path)
+			fmt.Fprintln(os.Stderr, "No changes")
+			return
+		}
+
+		// Encrypted bins stay encrypted with the same key
+		if key != nil {
+			editedContent, err = e2e.Encrypt(editedContent, key)
+			if err != nil {
+				log.Fatal().Msg(err.Error())
+			}
+		}
+
+		// Only save if nobody changed the content while editing
+		req := newAPIRequest("POST", binAPIPath(editBin, "/save", ""), strings.NewReader(editedContent))
+		req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		req.Header.Set("If-Match", etag)
+
+		log.Debug().Msgf("Saving edited file '%s'", path)
+		response, body := sendAPIRequest(req, http.StatusOK, http.StatusPreconditionFailed)
+
+		if response.StatusCode == http.StatusPreconditionFailed {
+			log.Fatal().Msgf("Content was changed on the server while editing. Your changes were not saved and are kept in '%s'.", path)
+		}
+
+		os.Remove(path)
+
+		// Print the response to the console
+		fmt.Println(string(body))
+	},
+}
+
+// runEditor opens a file in the editor configured with $VISUAL or $EDITOR and waits until it exits.
+//
+// Parameters:
+//   - path: string
+//     The path of the file to edit.
+//
+// Returns:
+//   - error
+//     An error if the editor cannot be started or exits with an error.
+func runEditor(path string) error {
+	editor := os.Getenv("VISUAL")
+	if editor == "" {
+		editor = os.Getenv("EDITOR")
+	}
+	if editor == "" {
+		editor = "vi"
+	}
+
+	// Editors may be configured with arguments, e.g. 'code --wait'
+	parts := strings.Fields(editor)
+	command := exec.Command(parts[0], append(parts[1:], path)...)
+	command.Stdin = os.Stdin
+	command.Stdout = os.Stdout
+	command.Stderr = os.Stderr
+
+	return command.Run()
+}
+
+func init() {
+	// Add 'editCmd' to the root command
+	rootCmd.AddCommand(editCmd)
+
+	// Define command-line flags for 'editCmd'
+	addClientFlags(editCmd, &editBin)
+	addKeyFlags(editCmd)
+}
@@ -0,0 +1,181 @@
+/*
+Copyright © 2023 containeroo hello©containeroo.ch
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"bufio"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"os/signal"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+	"vimbin/internal/config"
+	"vimbin/internal/utils"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	followMinBackoff = 1 * time.Second  // followMinBackoff is the delay before the first reconnect.
+	followMaxBackoff = 30 * time.Second // followMaxBackoff is the maximum delay between reconnects.
+)
+
+// followEvent is an event of the follow stream of the server.
+type followEvent struct {
+	Type    string `json:"-"`       // Type is 'append' or 'reset'.
+	ID      int64  `json:"-"`       // ID is the offset to resume from.
+	Offset  int64  `json:"offset"`  // Offset is the byte offset of the content in the bin.
+	Content string `json:"content"` // Content is the content starting at Offset.
+}
+
+// followContent prints the content of a bin appended after an offset like 'tail -f'.
+// It reconnects with exponential backoff when the connection is lost and only returns on interrupt.
+//
+// Parameters:
+//   - bin: string
+//     The name of the bin. An empty name addresses the default bin.
+//   - since: int64
+//     The byte offset to start from. 0 prints the complete content first.
+func followContent(bin string, since int64) {
+	var offset atomic.Int64
+	offset.Store(since)
+
+	// Tell how to resume when interrupted
+	interrupt := make(chan os.Signal, 1)
+	signal.Notify(interrupt, os.Interrupt)
+	go func() {
+		<-interrupt
+		fmt.Fprintf(os.Stderr, "\nResume with --since %d\n", offset.Load())
+		os.Exit(0)
+	}()
+
+	backoff := followMinBackoff
+	for {
+		received, err := followOnce(bin, &offset)
+		if received {
+			backoff = followMinBackoff
+		}
+
+		// Logs are written to stdout, which only gets the content
+		fmt.Fprintf(os.Stderr, "Lost connection: %s. Reconnecting in %s\n", err, backoff)
+		time.Sleep(backoff)
+		backoff = min(backoff*2, followMaxBackoff)
+	}
+}
+
+// followOnce connects to the follow stream and prints the received content until the connection is lost.
+// Errors which cannot be solved by reconnecting are fatal.
+//
+// Parameters:
+//   - bin: string
+//     The name of the bin.
+//   - offset: *atomic.Int64
+//     The offset to resume from, which is updated with every received event.
+//
+// Returns:
+//   - bool
+//     True if the server accepted the connection.
+//   - error
+//     The reason the connection was lost.
+func followOnce(bin string, offset *atomic.Int64) (bool, error) {
+	req := newAPIRequest("GET", binAPIPath(bin, "/follow", "/follow")+"?since="+strconv.FormatInt(offset.Load(), 10), nil)
+	req.Header.Set("Accept", "text/event-stream")
+
+	response, err := utils.CreateHTTPClient(config.App.Server.Api.SkipInsecureVerify).Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer response.Body.Close()
+
+	switch {
+	case response.StatusCode >= http.StatusInternalServerError:
+		return false, fmt.Errorf("Unexpected status code %d", response.StatusCode)
+	case response.StatusCode != http.StatusOK:
+		body, _ := io.ReadAll(response.Body)
+		log.Fatal().Msgf("Unexpected status code %d: %s", response.StatusCode, strings.TrimSpace(string(body)))
+	}
+
+	err = readFollowEvents(response.Body, func(event followEvent) {
+		if event.Type == "reset" {
+			fmt.Fprintln(os.Stderr, "--- content was replaced ---")
+		}
+		fmt.Print(event.Content)
+		offset.Store(event.ID)
+	})
+	if err == nil {
+		err = io.ErrUnexpectedEOF
+	}
+
+	return true, err
+}
+
+// readFollowEvents parses the server-sent events of the follow stream.
+//
+// Parameters:
+//   - stream: io.Reader
+//     The body of the follow stream.
+//   - handle: func(followEvent)
+//     Called for every received event.
+//
+// Returns:
+//   - error
+//     An error if the stream cannot be read or an event cannot be decoded, nil at the end of the stream.
+func readFollowEvents(stream io.Reader, handle func(followEvent)) error {
+	reader := bufio.NewReader(stream)
+	event := followEvent{}
+	var data strings.Builder
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err == io.EOF {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+		line = strings.TrimRight(line, "\r\n")
+
+		field, value, _ := strings.Cut(line, ":")
+		value = strings.TrimPrefix(value, " ")
+
+		switch field {
+		case "":
+			// An empty line dispatches the event, comments are ignored
+			if line != "" || data.Len() == 0 {
+				continue
+			}
+			if err := json.Unmarshal([]byte(data.String()), &event); err != nil {
+				return fmt.Errorf("Unable to decode event: %s", err)
+			}
+			handle(event)
+			event = followEvent{}
+			data.Reset()
+		case "event":
+			event.Type = value
+		case "id":
+			if event.ID, err = strconv.ParseInt(value, 10, 64); err != nil {
+				return fmt.Errorf("Invalid event ID '%s'", value)
+			}
+		case "data":
+			data.WriteString(value)
+		}
+	}
+}
@@ -0,0 +1,99 @@
+/*
+Copyright © 2023 containeroo hello©containeroo.ch
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"strconv"
+	"text/tabwriter"
+	"time"
+	"vimbin/internal/storage"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var historyBin string
+
+// historyCmd represents the 'history' command for listing the revisions of a bin.
+var historyCmd = &cobra.Command{
+	Use:   "history [revision]",
+	Short: "Lists the revisions of a bin",
+	Long: `The 'history' command lists all revisions of a bin stored on the vimbin server.
+Every save, append and restore creates a new revision. If a revision number is
+given, the content of this revision is printed instead.
+
+Examples:
+  - List all revisions of the default bin:
+    vimbin history --url http://example.com
+  - Print the content of revision 3 of a named bin:
+    vimbin history 3 --bin notes --url http://example.com`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		if len(args) == 1 {
+			revision := parseRevision(args[0])
+			apiPath := binAPIPath(historyBin, "/api/history/", "/history/") + strconv.Itoa(revision)
+
+			_, body := sendAPIRequest(newAPIRequest("GET", apiPath, nil))
+			fmt.Println(string(body))
+			return
+		}
+
+		_, body := sendAPIRequest(newAPIRequest("GET", binAPIPath(historyBin, "/api/history", "/history"), nil))
+
+		var history struct {
+			Revisions []storage.Revision `json:"revisions"`
+		}
+		if err := json.Unmarshal(body, &history); err != nil {
+			log.Fatal().Msgf("Error decoding JSON: %s", err)
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "REVISION\tTIME\tSIZE\tHASH\tAUTHOR")
+		for _, revision := range history.Revisions {
+			fmt.Fprintf(w, "%d\t%s\t%d\t%.12s\t%s\n", revision.ID, revision.Time.Local().Format(time.RFC3339), revision.Size, revision.Hash, revision.Author)
+		}
+		w.Flush()
+	},
+}
+
+// parseRevision parses a revision number given on the command line.
+//
+// Parameters:
+//   - arg: string
+//     The revision number.
+//
+// Returns:
+//   - int
+//     The parsed revision number.
+func parseRevision(arg string) int {
+	revision, err := strconv.Atoi(arg)
+	if err != nil || revision < 1 {
+		log.Fatal().Msgf("Invalid revision '%s'. Must be a positive number", arg)
+	}
+
+	return revision
+}
+
+func init() {
+	// Add 'historyCmd' to the root command
+	rootCmd.AddCommand(historyCmd)
+
+	// Define command-line flags for 'historyCmd'
+	addClientFlags(historyCmd, &historyBin)
+}
@@ -17,77 +17,87 @@ package cmd
 
 import (
 	"fmt"
-	"io"
 	"net/http"
-	"strings"
-	"vimbin/internal/config"
-	"vimbin/internal/utils"
+	"os"
 
 	"github.com/rs/zerolog/log"
 	"github.com/spf13/cobra"
 )
 
+var (
+	pullBin    string
+	printETag  bool
+	followFlag bool
+	since      int64
+)
+
 // pullCmd represents the 'fetch' command for retrieving the latest data from the vimbin server.
 var pullCmd = &cobra.Command{
 	Use:   "pull",
 	Short: "Pulls the latest data from the vimbin server",
 	Long: `The 'pull' command retrieves the latest content from the vimbin server specified by the provided URL.
 It makes a GET request to the server and prints the response body to the console.
-
-Example:
-  vimbin pull --url http://example.com`,
+Encrypted bins are decrypted locally with the key passed with --key or --key-file.
+
+Examples:
+  - Pull the default bin:
+    vimbin pull --url http://example.com
+  - Pull a named bin:
+    vimbin pull --bin notes --url http://example.com
+  - Print content appended by jobs like 'tail -f':
+    vimbin pull --follow --url http://example.com
+  - Pull an encrypted bin, decrypting it with the key from the link printed by 'push --encrypt':
+    vimbin pull --bin secret --key 'http://example.com/b/secret#key=...' --url http://example.com
+  - Resume following after the first 1024 bytes:
+    vimbin pull --follow --since 1024 --url http://example.com`,
 	Run: func(cmd *cobra.Command, args []string) {
-		url := strings.TrimSuffix(config.App.Server.Api.Address, "/")
-
-		if url == "" {
-			log.Fatal().Msg("URL is empty")
-		}
-		url += "/fetch"
-		log.Debug().Msgf("URL: %s", url)
-
-		apiToken := config.App.Server.Api.Token.Get()
-		if apiToken == "" {
-			log.Fatal().Msg("API token is empty")
-		}
-
-		httpClient := utils.CreateHTTPClient(config.App.Server.Api.SkipInsecureVerify)
-		req, err := http.NewRequest("GET", url, nil)
-		if err != nil {
-			log.Fatal().Msgf("Error creating HTTP request: %v", err)
-		}
-
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-API-Token", apiToken)
-
-		// Make a GET request to the vimbin server
-		response, err := httpClient.Do(req)
-		if err != nil {
-			log.Error().Msgf("Error making GET request: %s", err)
+		if followFlag {
+			followContent(pullBin, since)
 			return
 		}
-		defer response.Body.Close()
-
-		// Check for successful response
-		if response.StatusCode != http.StatusOK {
-			log.Fatal().Msgf("Unexpected status code %d", response.StatusCode)
+		if since != 0 {
+			log.Fatal().Msg("--since requires --follow")
 		}
 
-		// Read the response body
-		body, err := io.ReadAll(response.Body)
-		if err != nil {
-			log.Fatal().Msgf("Error reading response body: %s", err)
+		content, etag := pullContent(pullBin)
+		content, _ = decryptContent(content)
+
+		// Print the ETag to stderr, so it can be passed to 'push --if-match'
+		if printETag {
+			fmt.Fprintln(os.Stderr, etag)
 		}
 
 		// Print the content to the console
-		fmt.Println(string(body))
+		fmt.Println(content)
 	},
 }
 
+// pullContent fetches the content of a bin from the vimbin server.
+//
+// Parameters:
+//   - bin: string
+//     The name of the bin. An empty name addresses the default bin.
+//
+// Returns:
+//   - string
+//     The content of the bin.
+//   - string
+//     The ETag of the content.
+func pullContent(bin string) (string, string) {
+	// An empty bin is answered with 204 No Content
+	response, body := sendAPIRequest(newAPIRequest("GET", binAPIPath(bin, "/fetch", ""), nil), http.StatusOK, http.StatusNoContent)
+
+	return string(body), response.Header.Get("ETag")
+}
+
 func init() {
 	// Add 'pullCmd' to the root command
 	rootCmd.AddCommand(pullCmd)
 
 	// Define command-line flags for 'pullCmd'
-	pullCmd.PersistentFlags().StringVarP(&config.App.Server.Api.Address, "url", "u", "", "The URL of the vimbin server")
-	pullCmd.PersistentFlags().BoolVarP(&config.App.Server.Api.SkipInsecureVerify, "insecure-skip-verify", "i", false, "Skip TLS certificate verification")
+	addClientFlags(pullCmd, &pullBin)
+	addKeyFlags(pullCmd)
+	pullCmd.PersistentFlags().BoolVarP(&printETag, "etag", "e", false, "Print the ETag of the content to stderr")
+	pullCmd.PersistentFlags().BoolVarP(&followFlag, "follow", "f", false, "Keep printing content appended to the bin, like 'tail -f'")
+	pullCmd.PersistentFlags().Int64VarP(&since, "since", "", 0, "With --follow, start printing at this byte offset")
 }
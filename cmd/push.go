@@ -16,13 +16,19 @@ limitations under the License.
 package cmd
 
 import (
+	"bufio"
 	"bytes"
 	"encoding/json"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
+	"net/url"
+	"os"
+	"strconv"
 	"strings"
-	"vimbin/internal/config"
+	"time"
+	"vimbin/internal/e2e"
 	"vimbin/internal/utils"
 
 	"github.com/rs/zerolog/log"
@@ -30,92 +36,433 @@ import (
 	"github.com/spf13/cobra"
 )
 
-var appendFlag bool
+var (
+	appendFlag bool
+	pushBin    string
+	ifMatch    string
+	forceFlag  bool
+	pushFile   string
+	maxSize    string
+
+	atLine       int
+	replaceLines string
+	deleteLines  string
+	diffFlag     bool
+
+	expire   string
+	burnFlag bool
+
+	encryptFlag bool
+	allowEmpty  bool
+)
+
+// errMaxSizeExceeded is returned when the pushed content is larger than '--max-size'.
+var errMaxSizeExceeded = errors.New("Content exceeds --max-size")
 
 // pushCmd represents the 'push' command for sending data to the vimbin server.
 var pushCmd = &cobra.Command{
-	Use:   "push",
+	Use:   "push [text...]",
 	Short: "Pushes data to the vimbin server",
 	Long: `Push sends data to the vimbin server, allowing you to store text content.
 It supports two modes: 'save' and 'append'. In 'save' mode, the entire content is
 replaced, while in 'append' mode, new content is added to the existing content.
 
+The content is taken from the arguments, joined with newlines, from a file, or
+from stdin if no arguments are given or the only argument is '-'. Files and stdin
+are streamed to the server.
+
 Examples:
   - Save content:
     vimbin push "Your text content" --url http://example.com
+  - Save the output of a command:
+    some-command | vimbin push --url http://example.com
+  - Save a file:
+    vimbin push -f report.log --url http://example.com
   - Append content:
-    vimbin push --append "Additional content" --url http://example.com`,
+    vimbin push --append "Additional content" --url http://example.com
+  - Save content to a named bin:
+    vimbin push --bin notes "Your text content" --url http://example.com
+  - Replace lines 3 to 5 with the output of a command:
+    some-command | vimbin push --replace-lines 3-5 --url http://example.com
+  - Insert a line before line 1:
+    vimbin push --at-line 1 "# Title" --url http://example.com
+  - Apply a unified diff, keeping changes others made in the meantime:
+    git diff notes.md | vimbin push --diff --bin notes --url http://example.com
+  - Share a one-time secret, which is removed after it was pulled once:
+    vimbin push --bin onboarding --burn "s3cr3t" --url http://example.com
+  - Save a secret encrypted with a new key, which is printed as part of the link to the bin:
+    vimbin push --bin secret --encrypt "s3cr3t" --url http://example.com
+  - Save content encrypted with the key in a local key file, which is created if missing:
+    vimbin push --bin notes --encrypt --key-file ~/.vimbin.key -f notes.md --url http://example.com
+  - Save content which is removed after a day:
+    vimbin push --bin scratch --expire 24h "Your text content" --url http://example.com
+  - Only save if the content on the server was not changed:
+    vimbin push --if-match '"<etag>"' "Your text content" --url http://example.com`,
 	Run: func(cmd *cobra.Command, args []string) {
-		// Check if at least one character is provided
-		if len(args) < 1 {
-			log.Fatal().Msg("You must push at least one character.")
+		limit, err := utils.ParseSize(maxSize)
+		if err != nil {
+			log.Fatal().Msgf("Invalid --max-size: %s", err)
+		}
+
+		if (expire != "" || burnFlag) && (diffFlag || atLine != 0 || replaceLines != "" || deleteLines != "") {
+			log.Fatal().Msg("--expire and --burn can only be used to save or append content.")
+		}
+		if encryptFlag && (appendFlag || diffFlag || atLine != 0 || replaceLines != "" || deleteLines != "") {
+			log.Fatal().Msg("--encrypt can only be used to save content. Encrypted content can only be replaced as a whole.")
 		}
 
-		url := strings.TrimSuffix(config.App.Server.Api.Address, "/")
-		if url == "" {
-			log.Fatal().Msg("URL is empty")
+		var req *http.Request
+		switch {
+		case diffFlag:
+			req = newDiffRequest(args, limit)
+		case atLine != 0 || replaceLines != "" || deleteLines != "":
+			req = newLineEditRequest(args, limit)
+		default:
+			req = newPushRequest(args, limit)
 		}
 
-		apiToken := config.App.Server.Api.Token.Get()
-		if apiToken == "" {
-			log.Fatal().Msg("API token is empty")
+		// Only write if the content on the server still matches the given ETag
+		if ifMatch != "" && !forceFlag {
+			req.Header.Set("If-Match", ifMatch)
 		}
 
-		// Concatenate input arguments into a single string
-		input := strings.Join(args, "\n")
+		response, responseBody := sendAPIRequest(req, http.StatusOK, http.StatusPreconditionFailed, http.StatusConflict)
 
-		// Build the URL based on the "append" flag
-		if appendFlag {
-			url += "/append"
-			input = "\n" + input
-		} else {
-			url += "/save"
+		// Check if the content was changed on the server
+		if response.StatusCode == http.StatusPreconditionFailed {
+			log.Fatal().Msgf("Content was changed on the server (current ETag %s). Use --force to overwrite it.", response.Header.Get("ETag"))
 		}
 
-		// Prepare content for the POST request
-		content := map[string]string{"content": input}
-		requestBody, err := json.Marshal(content)
-		if err != nil {
-			log.Fatal().Msgf("Error encoding JSON: %s", err)
+		// Show the hunks of the diff which do not apply
+		if response.StatusCode == http.StatusConflict {
+			var conflict struct {
+				Rejected []string `json:"rejected"`
+			}
+			if err := json.Unmarshal(responseBody, &conflict); err != nil {
+				log.Fatal().Msgf("Error decoding JSON: %s", err)
+			}
+			fmt.Fprint(os.Stderr, strings.Join(conflict.Rejected, ""))
+			log.Fatal().Msgf("%d hunks do not apply to the content on the server. Nothing was saved.", len(conflict.Rejected))
 		}
 
-		httpClient := utils.CreateHTTPClient(config.App.Server.Api.SkipInsecureVerify)
-		req, err := http.NewRequest("POST", url, bytes.NewBuffer(requestBody))
-		if err != nil {
-			log.Fatal().Msgf("Error creating HTTP request: %v", err)
+		// Print the content to the console
+		fmt.Println(string(responseBody))
+
+		// Print the link to the bin, which contains the key unless it is kept in a key file
+		if encryptFlag {
+			link := binURL(pushBin)
+			if encryptionKeyFile == "" {
+				link += "#key=" + encryptionKey
+			}
+			fmt.Fprintln(os.Stderr, link)
 		}
+	},
+}
 
-		req.Header.Set("Content-Type", "application/json")
-		req.Header.Set("X-API-Token", apiToken)
+// newPushRequest creates the request which saves or appends the content.
+// The content is streamed as is, so it does not have to be encoded in memory.
+//
+// Parameters:
+//   - args: []string
+//     The arguments of the command.
+//   - limit: int64
+//     The maximum size of the content in bytes. 0 disables the limit.
+//
+// Returns:
+//   - *http.Request
+//     The request to '/save' or '/append'.
+func newPushRequest(args []string, limit int64) *http.Request {
+	body := limitedPushInput(args, limit)
 
-		// Make the POST request to the vimbin server
-		response, err := httpClient.Do(req)
-		if err != nil {
-			log.Fatal().Msgf("Error making POST request: %s", err)
+	// Encrypt the content before it leaves the client
+	if encryptFlag {
+		body = encryptedPushInput(body)
+	}
+
+	// Build the path based on the "bin" and "append" flags
+	apiPath := binAPIPath(pushBin, "/save", "")
+	if appendFlag {
+		apiPath = binAPIPath(pushBin, "/append", "/append")
+		body = io.MultiReader(strings.NewReader("\n"), body)
+	}
+
+	// Set how long the bin is kept
+	query := url.Values{}
+	if expire != "" {
+		if _, err := time.ParseDuration(expire); err != nil {
+			log.Fatal().Msgf("Invalid --expire '%s'. Use a duration like 10m or 24h", expire)
 		}
-		defer response.Body.Close()
+		query.Set("expire", expire)
+	}
+	if burnFlag {
+		query.Set("burn", "true")
+	}
+	if len(query) > 0 {
+		apiPath += "?" + query.Encode()
+	}
+
+	req := newAPIRequest("POST", apiPath, body)
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+
+	return req
+}
+
+// encryptedPushInput encrypts the content to push with the key passed with '--key' or '--key-file',
+// or a new key if none was passed. Unless a key file is used, the encoded key is stored in '--key', so the
+// link printed afterwards contains it.
+//
+// Parameters:
+//   - input: io.Reader
+//     The plain content.
+//
+// Returns:
+//   - io.Reader
+//     The encrypted content.
+func encryptedPushInput(input io.Reader) io.Reader {
+	content, err := io.ReadAll(input)
+	if err != nil {
+		log.Fatal().Msgf("Error reading content: %s", err)
+	}
+
+	key := loadKey(true)
+	if encryptionKeyFile == "" {
+		encryptionKey = e2e.EncodeKey(key)
+	}
 
-		// Check for successful response
-		if response.StatusCode != http.StatusOK {
-			log.Fatal().Msgf("Unexpected status code %d", response.StatusCode)
+	encrypted, err := e2e.Encrypt(string(content), key)
+	if err != nil {
+		log.Fatal().Msg(err.Error())
+	}
+
+	return strings.NewReader(encrypted)
+}
+
+// newLineEditRequest creates the request which inserts, replaces or deletes lines as
+// requested with '--at-line', '--replace-lines' or '--delete-lines'.
+//
+// Parameters:
+//   - args: []string
+//     The arguments of the command.
+//   - limit: int64
+//     The maximum size of the content in bytes. 0 disables the limit.
+//
+// Returns:
+//   - *http.Request
+//     The PATCH request with the line operation.
+func newLineEditRequest(args []string, limit int64) *http.Request {
+	flags := 0
+	for _, set := range []bool{atLine != 0, replaceLines != "", deleteLines != "", appendFlag} {
+		if set {
+			flags++
 		}
+	}
+	if flags > 1 {
+		log.Fatal().Msg("Only one of --append, --at-line, --replace-lines and --delete-lines can be used.")
+	}
 
-		body, err := io.ReadAll(response.Body)
+	var operation lineOperation
+	switch {
+	case atLine != 0:
+		operation = lineOperation{Op: "insert", Line: atLine}
+	case replaceLines != "":
+		operation = lineOperation{Op: "replace"}
+		operation.From, operation.To = parseLineRange(replaceLines)
+	default:
+		operation = lineOperation{Op: "delete"}
+		operation.From, operation.To = parseLineRange(deleteLines)
+		if len(args) > 0 || pushFile != "" {
+			log.Fatal().Msg("--delete-lines does not take content.")
+		}
+	}
+
+	if operation.Op != "delete" {
+		content, err := io.ReadAll(limitedPushInput(args, limit))
 		if err != nil {
-			log.Fatal().Msgf("Error reading response body: %s", err)
+			log.Fatal().Msgf("Error reading content: %s", err)
 		}
+		operation.Content = string(content)
+	}
 
-		// Print the content to the console
-		fmt.Println(string(body))
-	},
+	body, err := json.Marshal(map[string][]lineOperation{"operations": {operation}})
+	if err != nil {
+		log.Fatal().Msgf("Error encoding JSON: %s", err)
+	}
+
+	req := newAPIRequest("PATCH", binAPIPath(pushBin, "/api/content", ""), bytes.NewReader(body))
+	req.Header.Set("Content-Type", "application/json")
+
+	return req
+}
+
+// newDiffRequest creates the request which applies the content as unified diff.
+//
+// Parameters:
+//   - args: []string
+//     The arguments of the command.
+//   - limit: int64
+//     The maximum size of the diff in bytes. 0 disables the limit.
+//
+// Returns:
+//   - *http.Request
+//     The request to the diff endpoint.
+func newDiffRequest(args []string, limit int64) *http.Request {
+	if appendFlag || atLine != 0 || replaceLines != "" || deleteLines != "" {
+		log.Fatal().Msg("--diff cannot be used with --append, --at-line, --replace-lines or --delete-lines.")
+	}
+
+	req := newAPIRequest("POST", binAPIPath(pushBin, "/api/diff", "/diff"), limitedPushInput(args, limit))
+	req.Header.Set("Content-Type", "text/x-diff")
+
+	return req
+}
+
+// lineOperation is a line operation sent to the PATCH endpoint.
+type lineOperation struct {
+	Op      string `json:"op"`                // Op is the type of the operation.
+	Line    int    `json:"line,omitempty"`    // Line is the line to insert before.
+	From    int    `json:"from,omitempty"`    // From is the first line of the range to replace or delete.
+	To      int    `json:"to,omitempty"`      // To is the last line of the range to replace or delete.
+	Content string `json:"content,omitempty"` // Content are the lines to insert.
+}
+
+// parseLineRange parses a range of lines like '3-5' or a single line like '3'.
+func parseLineRange(value string) (int, int) {
+	first, last, isRange := strings.Cut(value, "-")
+	if !isRange {
+		last = first
+	}
+
+	from, err := strconv.Atoi(first)
+	if err != nil {
+		log.Fatal().Msgf("Invalid line range '%s'. Use FROM-TO, e.g. 3-5", value)
+	}
+	to, err := strconv.Atoi(last)
+	if err != nil {
+		log.Fatal().Msgf("Invalid line range '%s'. Use FROM-TO, e.g. 3-5", value)
+	}
+
+	return from, to
+}
+
+// limitedPushInput returns the content to push, which fails to read once it exceeds the limit.
+//
+// Parameters:
+//   - args: []string
+//     The arguments of the command.
+//   - limit: int64
+//     The maximum size of the content in bytes. 0 disables the limit.
+//
+// Returns:
+//   - io.Reader
+//     The content.
+func limitedPushInput(args []string, limit int64) io.Reader {
+	input, size := pushInput(args)
+	if limit > 0 && size > limit {
+		log.Fatal().Msgf("Content has %d bytes, which exceeds --max-size of %d bytes", size, limit)
+	}
+
+	if limit > 0 {
+		return &maxSizeReader{reader: input, remaining: limit}
+	}
+	return input
+}
+
+// pushInput returns the content to push.
+//
+// Parameters:
+//   - args: []string
+//     The arguments of the command.
+//
+// Returns:
+//   - io.ReadCloser
+//     The content. Arguments are joined with newlines, files and stdin are read while sending.
+//   - int64
+//     The size of the content in bytes, or -1 if it is read from stdin.
+//
+// Empty content is refused unless --allow-empty is set, so a command which failed or printed
+// nothing does not wipe the bin when its output is piped into push.
+func pushInput(args []string) (io.ReadCloser, int64) {
+	readStdin := pushFile == "-" || (pushFile == "" && (len(args) == 0 || (len(args) == 1 && args[0] == "-")))
+
+	switch {
+	case pushFile != "" && len(args) > 0:
+		log.Fatal().Msg("Either push arguments or a file, not both.")
+	case readStdin && len(args) == 0 && isTerminal(os.Stdin):
+		log.Fatal().Msg("You must push at least one character. Pass it as argument, with --file or on stdin.")
+	case readStdin:
+		// Stdin is streamed, so only its first byte is read ahead to detect empty content
+		reader := bufio.NewReader(os.Stdin)
+		if _, err := reader.Peek(1); errors.Is(err, io.EOF) && !allowEmpty {
+			log.Fatal().Msg("Stdin is empty. Pass --allow-empty to push empty content.")
+		}
+		return io.NopCloser(reader), -1
+	case pushFile != "":
+		file, err := os.Open(pushFile)
+		if err != nil {
+			log.Fatal().Msgf("Unable to open file: %s", err)
+		}
+		info, err := file.Stat()
+		if err != nil {
+			log.Fatal().Msgf("Unable to read file: %s", err)
+		}
+		if info.Mode().IsRegular() && info.Size() == 0 && !allowEmpty {
+			log.Fatal().Msgf("File '%s' is empty. Pass --allow-empty to push empty content.", pushFile)
+		}
+		return file, info.Size()
+	}
+
+	// Concatenate input arguments into a single string
+	input := strings.Join(args, "\n")
+	if input == "" && !allowEmpty {
+		log.Fatal().Msg("You must push at least one character. Pass --allow-empty to push empty content.")
+	}
+	return io.NopCloser(strings.NewReader(input)), int64(len(input))
+}
+
+// isTerminal checks if a file is a terminal rather than a pipe or a file.
+func isTerminal(file *os.File) bool {
+	info, err := file.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// maxSizeReader reads from a reader until more than a maximum number of bytes were read.
+type maxSizeReader struct {
+	reader    io.Reader
+	remaining int64 // remaining is the number of bytes which may still be read.
+}
+
+// Read reads from the underlying reader and returns errMaxSizeExceeded once the maximum is exceeded,
+// which aborts the request, so the server does not store the truncated content.
+func (m *maxSizeReader) Read(p []byte) (int, error) {
+	if int64(len(p)) > m.remaining+1 {
+		p = p[:m.remaining+1]
+	}
+	n, err := m.reader.Read(p)
+	m.remaining -= int64(n)
+	if m.remaining < 0 {
+		return 0, errMaxSizeExceeded
+	}
+	return n, err
 }
 
 func init() {
-	// Add 'pullCmd' to the root command
+	// Add 'pushCmd' to the root command
 	rootCmd.AddCommand(pushCmd)
 
-	// Define command-line flags for 'pullCmd'
-	pushCmd.PersistentFlags().StringVarP(&config.App.Server.Api.Address, "url", "u", "", "The URL of the vimbin server")
-	pushCmd.PersistentFlags().BoolVarP(&config.App.Server.Api.SkipInsecureVerify, "insecure-skip-verify", "i", false, "Skip TLS certificate verification")
+	// Define command-line flags for 'pushCmd'
+	addClientFlags(pushCmd, &pushBin)
 	pushCmd.PersistentFlags().BoolVarP(&appendFlag, "append", "a", false, "Append content to the existing content")
+	pushCmd.PersistentFlags().StringVarP(&pushFile, "file", "f", "", "Push the content of a file. Use '-' for stdin")
+	pushCmd.PersistentFlags().BoolVarP(&allowEmpty, "allow-empty", "", false, "Push empty content, e.g. to clear the bin. Without it, empty stdin, files and arguments are refused")
+	pushCmd.PersistentFlags().StringVarP(&maxSize, "max-size", "", "10MiB", "Refuse to push content larger than this, e.g. 512KB or 100MiB. 0 disables the limit")
+	pushCmd.PersistentFlags().IntVarP(&atLine, "at-line", "", 0, "Insert the content before this line. The number of lines + 1 inserts after the last line")
+	pushCmd.PersistentFlags().StringVarP(&replaceLines, "replace-lines", "", "", "Replace a range of lines with the content, e.g. 3-5")
+	pushCmd.PersistentFlags().StringVarP(&deleteLines, "delete-lines", "", "", "Delete a range of lines, e.g. 3-5")
+	pushCmd.PersistentFlags().BoolVarP(&diffFlag, "diff", "", false, "Apply the content as unified diff, e.g. from 'diff -u' or 'git diff'")
+	pushCmd.PersistentFlags().StringVarP(&expire, "expire", "", "", "Remove the bin after this duration, e.g. 10m or 24h. 0 keeps it forever")
+	pushCmd.PersistentFlags().BoolVarP(&burnFlag, "burn", "", false, "Remove the bin after its content was pulled once")
+	pushCmd.PersistentFlags().BoolVarP(&encryptFlag, "encrypt", "", false, "Encrypt the content before sending it. The key never reaches the server")
+	addKeyFlags(pushCmd)
+	pushCmd.PersistentFlags().StringVarP(&ifMatch, "if-match", "", "", "Only push if the content on the server matches this ETag")
+	pushCmd.PersistentFlags().BoolVarP(&forceFlag, "force", "", false, "Push even if the content on the server has changed. Overrides --if-match")
 }
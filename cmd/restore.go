@@ -0,0 +1,55 @@
+/*
+Copyright © 2023 containeroo hello©containeroo.ch
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/spf13/cobra"
+)
+
+var restoreBin string
+
+// restoreCmd represents the 'restore' command for restoring a revision of a bin.
+var restoreCmd = &cobra.Command{
+	Use:   "restore <revision>",
+	Short: "Restores a revision of a bin",
+	Long: `The 'restore' command replaces the content of a bin with the content of a previous revision.
+Restoring creates a new revision, so the history is never rewritten.
+Use 'vimbin history' to list the available revisions.
+
+Example:
+  vimbin restore 3 --bin notes --url http://example.com`,
+	Args: cobra.ExactArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		revision := parseRevision(args[0])
+		apiPath := binAPIPath(restoreBin, "/api/restore/", "/restore/") + strconv.Itoa(revision)
+
+		_, body := sendAPIRequest(newAPIRequest("POST", apiPath, nil))
+
+		// Print the response to the console
+		fmt.Println(string(body))
+	},
+}
+
+func init() {
+	// Add 'restoreCmd' to the root command
+	rootCmd.AddCommand(restoreCmd)
+
+	// Define command-line flags for 'restoreCmd'
+	addClientFlags(restoreCmd, &restoreBin)
+}
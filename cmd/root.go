@@ -69,7 +69,8 @@ var rootCmd = &cobra.Command{
 		// Configure zerolog
 		zerolog.TimeFieldFormat = zerolog.TimeFormatUnix
 		zerolog.SetGlobalLevel(zerolog.InfoLevel)
-		output := zerolog.ConsoleWriter{Out: os.Stdout, TimeFormat: time.RFC3339}
+		// Log to stderr, so stdout only carries content and the audit log
+		output := zerolog.ConsoleWriter{Out: os.Stderr, TimeFormat: time.RFC3339}
 		log.Logger = zerolog.New(output).With().Timestamp().Logger()
 
 		// Retrieve debug and trace flags from environment variables if not explicitly set
@@ -125,7 +126,7 @@ func init() {
 	// Define command-line flags for the root command
 	rootCmd.PersistentFlags().StringVarP(&cfgFile, "config", "c", "", "Path to the configuration file.")
 	rootCmd.PersistentFlags().BoolVarP(&debug, "debug", "", false, "Activates debug output for detailed logging.")
-	rootCmd.PersistentFlags().StringP("token", "t", "", "Token to use for authentication. If not set, a random token will be generated.")
+	rootCmd.PersistentFlags().StringP("token", "t", "", "Token to use for authentication. If not set, a random token is generated and printed once to stderr at startup.")
 	rootCmd.PersistentFlags().BoolVarP(&trace, "trace", "", false, "Enables trace mode. This will show the content in the logs!")
 	rootCmd.MarkFlagsMutuallyExclusive("debug", "trace") // Ensure that debug and trace flags are mutually exclusive
 
@@ -16,12 +16,15 @@ limitations under the License.
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"html/template"
 	"os"
-	"text/template"
+	"strings"
 	"vimbin/internal/config"
 	"vimbin/internal/handlers"
 	"vimbin/internal/server"
+	"vimbin/internal/storage"
 	"vimbin/internal/utils"
 
 	"github.com/rs/zerolog/log"
@@ -29,6 +32,9 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// watchConfig reloads the config file whenever it changes.
+var watchConfig bool
+
 // serveCmd represents the serve command.
 var serveCmd = &cobra.Command{
 	Use:   "serve",
@@ -47,19 +53,46 @@ var serveCmd = &cobra.Command{
 		// Read the HTML template file
 		htmlTemplate, err := template.ParseFS(server.StaticFS, "web/templates/index.html")
 		if err != nil {
-			log.Fatal().Err(err)
+			log.Fatal().Msg(err.Error())
 		}
 
 		config.App.HtmlTemplate = htmlTemplate
 
 		// Parse the configuration
 		if err := config.App.Parse(); err != nil {
-			log.Fatal().Err(err)
+			log.Fatal().Msg(err.Error())
+		}
+
+		// Reload the config file on SIGHUP and, if requested, whenever it changes
+		var reload func() (server.Auth, error)
+		if cfgFile != "" {
+			token := cmd.Flag("token").Value.String()
+			reload = func() (server.Auth, error) {
+				return config.App.Reload(cfgFile, token)
+			}
+			if watchConfig {
+				server.RegisterService("config", func(ctx context.Context) {
+					if err := config.WatchFile(ctx, cfgFile, server.RequestReload); err != nil {
+						log.Error().Msgf("Changes of the config file are not reloaded: %v", err)
+					}
+				})
+			}
 		}
 
 		// Collect handlers and start the server
 		handlers.Collect()
-		server.Run(config.App.Server.Web.Address, config.App.Server.Api.Token.Get())
+		tlsConfig := config.App.Server.Web.TLS
+		server.Run(config.App.Server.Web.Address, config.App.Server.Auth(), server.TLS{
+			CertFile:          tlsConfig.CertFile,
+			KeyFile:           tlsConfig.KeyFile,
+			ClientCAFile:      tlsConfig.ClientCAFile,
+			RequireClientCert: tlsConfig.RequireClientCert,
+		}, config.App.Server.Web.AdminAddress, config.App.Server.Web.ShutdownDelay, reload)
+
+		// Pending edits were written when the server shut down
+		if err := config.App.Storage.Close(); err != nil {
+			log.Error().Msg(err.Error())
+		}
 	},
 }
 
@@ -70,15 +103,35 @@ func init() {
 	// Define command-line flags for the serve command
 	serveCmd.PersistentFlags().StringVarP(&config.App.Server.Web.Address, "listen-address", "a", ":8080", "The address to listen on for HTTP requests.")
 
+	serveCmd.PersistentFlags().StringVarP(&config.App.Server.Web.AdminAddress, "admin-address", "", "", "The address to serve metrics on. If not set, metrics are served on the listen address.")
+
 	serveCmd.PersistentFlags().StringVarP(&config.App.Server.Web.Theme, "theme", "", "auto", fmt.Sprintf("The theme to use. Can be %s.", config.SupportedThemes))
 	serveCmd.RegisterFlagCompletionFunc("theme", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return config.SupportedThemes, cobra.ShellCompDirectiveDefault
 	})
 
-	serveCmd.PersistentFlags().StringVarP(&config.App.Server.Web.Theme, "dark-theme", "", "frappe", fmt.Sprintf("When theme set to auto, use this as dark theme. Can be %s.", config.DarkThemes))
+	serveCmd.PersistentFlags().StringVarP(&config.App.Server.Web.DarkTheme, "dark-theme", "", "frappe", fmt.Sprintf("When theme set to auto, use this as dark theme. Can be %s.", config.DarkThemes))
 	serveCmd.RegisterFlagCompletionFunc("dark-theme", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
 		return config.DarkThemes, cobra.ShellCompDirectiveDefault
 	})
+	serveCmd.PersistentFlags().DurationVarP(&config.App.Server.Web.PersistInterval, "persist-interval", "", config.DefaultPersistInterval, "The interval in which edits made in the editor are written to the storage backend.")
+	serveCmd.PersistentFlags().DurationVarP(&config.App.Server.Web.ShutdownDelay, "shutdown-delay", "", config.DefaultShutdownDelay, "How long the readiness probe fails before the server stops accepting connections on shutdown.")
+	serveCmd.PersistentFlags().BoolVarP(&watchConfig, "watch-config", "", false, "Reload the config file whenever it changes. It is always reloaded on SIGHUP.")
+	serveCmd.PersistentFlags().BoolVarP(&config.App.Server.Web.RequireAuth, "require-auth", "", false, "Require logging in with an API token to open the editor. Always required if any token is restricted to specific bins.")
+	serveCmd.PersistentFlags().DurationVarP(&config.App.Server.Web.SessionTTL, "session-ttl", "", config.DefaultSessionTTL, "How long a browser stays logged in.")
+	serveCmd.PersistentFlags().StringVarP(&config.App.Server.Web.MaxBodySize, "max-body-size", "", config.DefaultMaxBodySize, "The maximum size of request bodies, e.g. 512KB or 100MiB. Larger requests are refused. 0 disables the limit.")
+	serveCmd.PersistentFlags().StringVarP(&config.App.Server.Web.TLS.CertFile, "tls-cert", "", "", "Path to the TLS certificate. Serves HTTPS if set. Reloaded when the file changes.")
+	serveCmd.PersistentFlags().StringVarP(&config.App.Server.Web.TLS.KeyFile, "tls-key", "", "", "Path to the private key of the TLS certificate.")
+	serveCmd.PersistentFlags().StringVarP(&config.App.Server.Web.TLS.ClientCAFile, "tls-client-ca", "", "", "Path to the CA bundle client certificates are verified against.")
+	serveCmd.PersistentFlags().BoolVarP(&config.App.Server.Web.TLS.RequireClientCert, "tls-require-client-cert", "", false, "Reject connections without a valid client certificate.")
 	serveCmd.PersistentFlags().StringVarP(&config.App.Storage.Directory, "directory", "d", "$(pwd)", "The path to the storage directory. Defaults to the current working directory.")
 	serveCmd.PersistentFlags().StringVarP(&config.App.Storage.Name, "name", "n", ".vimbin", "The name of the file to save.")
+	serveCmd.PersistentFlags().StringVarP(&config.App.Storage.Backend, "backend", "", storage.BackendFile, fmt.Sprintf("The storage backend to use. Can be %s.", strings.Join(storage.SupportedBackends, ", ")))
+	serveCmd.RegisterFlagCompletionFunc("backend", func(cmd *cobra.Command, args []string, toComplete string) ([]string, cobra.ShellCompDirective) {
+		return storage.SupportedBackends, cobra.ShellCompDirectiveDefault
+	})
+	serveCmd.PersistentFlags().StringVarP(&config.App.Storage.Encryption.KeyFile, "encryption-key-file", "", "", "Path to the file with the keys to encrypt the storage at rest with. Can also be set with VIMBIN_ENCRYPTION_KEY.")
+	serveCmd.PersistentFlags().StringVarP(&config.App.Storage.Audit.Output, "audit-log", "", "", "Record every change of the content in an audit log. Can be 'stdout', 'syslog' or the path of a file.")
+	serveCmd.PersistentFlags().IntVarP(&config.App.Storage.History.MaxRevisions, "history-max-revisions", "", 100, "The maximum number of revisions kept per bin. 0 keeps all revisions.")
+	serveCmd.PersistentFlags().DurationVarP(&config.App.Storage.History.MaxAge, "history-max-age", "", 0, "The maximum age of revisions, e.g. 720h. 0 keeps revisions forever.")
 }
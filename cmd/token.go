@@ -0,0 +1,125 @@
+/*
+Copyright © 2023 containeroo hello©containeroo.ch
+
+Licensed under the Apache License, Version 2.0 (the "License");
+you may not use this file except in compliance with the License.
+You may obtain a copy of the License at
+
+	http://www.apache.org/licenses/LICENSE-2.0
+
+Unless required by applicable law or agreed to in writing, software
+distributed under the License is distributed on an "AS IS" BASIS,
+WITHOUT WARRANTIES OR CONDITIONS OF ANY KIND, either express or implied.
+See the License for the specific language governing permissions and
+limitations under the License.
+*/
+package cmd
+
+import (
+	"fmt"
+	"io"
+	"os"
+	"strings"
+	"vimbin/internal/server"
+	"vimbin/internal/utils"
+
+	"github.com/rs/zerolog/log"
+	"github.com/spf13/cobra"
+)
+
+var (
+	tokenLength    int
+	tokenHash      string
+	tokenAlgorithm string
+)
+
+// tokenCmd represents the 'token' command for creating API tokens.
+var tokenCmd = &cobra.Command{
+	Use:   "token",
+	Short: "Generates and hashes API tokens",
+	Long: `The 'token' command creates API tokens for the vimbin server.
+
+The server accepts hashes of API tokens in place of the tokens themselves, so the config file
+does not need to contain any secret. Clients still send the token itself.`,
+}
+
+// tokenGenerateCmd represents the 'token generate' command for generating random API tokens.
+var tokenGenerateCmd = &cobra.Command{
+	Use:   "generate",
+	Short: "Generates a random API token",
+	Long: `The 'token generate' command prints a random API token.
+
+With --hash, the hash of the token is printed on a second line. Store the hash in the config
+file of the server and hand the token to the clients.
+
+Examples:
+  - Generate a token:
+    vimbin token generate
+  - Generate a token and its argon2id hash:
+    vimbin token generate --hash argon2id`,
+	Args: cobra.NoArgs,
+	Run: func(cmd *cobra.Command, args []string) {
+		token, err := utils.GenerateRandomToken(tokenLength)
+		if err != nil {
+			log.Fatal().Msgf("Unable to generate API token: %s", err)
+		}
+		fmt.Println(token)
+
+		if tokenHash == "" {
+			return
+		}
+		hash, err := server.HashToken(token, tokenHash)
+		if err != nil {
+			log.Fatal().Msg(err.Error())
+		}
+		fmt.Println(hash)
+	},
+}
+
+// tokenHashCmd represents the 'token hash' command for hashing API tokens.
+var tokenHashCmd = &cobra.Command{
+	Use:   "hash [TOKEN]",
+	Short: "Hashes an API token",
+	Long: `The 'token hash' command prints the hash of an API token, which can be used in the config
+file of the server in place of the token. Without an argument, the token is read from stdin, which
+keeps it out of the shell history.
+
+Supported algorithms are argon2id (default), bcrypt and sha256. Argon2id and bcrypt are slow by
+design and only computed once per token by the server. Use sha256 only for long random tokens,
+like the ones created with 'vimbin token generate'.
+
+Examples:
+  - Hash a token read from stdin:
+    vimbin token hash < token.txt
+  - Hash a token with bcrypt:
+    vimbin token hash --algorithm bcrypt my-secret-token`,
+	Args: cobra.MaximumNArgs(1),
+	Run: func(cmd *cobra.Command, args []string) {
+		var token string
+		if len(args) == 1 {
+			token = args[0]
+		} else {
+			input, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				log.Fatal().Msgf("Error reading token from stdin: %v", err)
+			}
+			token = strings.TrimRight(string(input), "\r\n")
+		}
+
+		hash, err := server.HashToken(token, tokenAlgorithm)
+		if err != nil {
+			log.Fatal().Msg(err.Error())
+		}
+		fmt.Println(hash)
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(tokenCmd)
+	tokenCmd.AddCommand(tokenGenerateCmd)
+	tokenCmd.AddCommand(tokenHashCmd)
+
+	tokenGenerateCmd.Flags().IntVarP(&tokenLength, "length", "l", 32, "Length of the generated token.")
+	tokenGenerateCmd.Flags().StringVarP(&tokenHash, "hash", "", "", fmt.Sprintf("Also print the hash of the token computed with the given algorithm (%s).", strings.Join(server.SupportedHashes, ", ")))
+	tokenHashCmd.Flags().StringVarP(&tokenAlgorithm, "algorithm", "a", server.HashArgon2id, fmt.Sprintf("Algorithm to hash the token with (%s).", strings.Join(server.SupportedHashes, ", ")))
+}
@@ -0,0 +1,242 @@
+package audit
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"log/syslog"
+	"os"
+	"sync"
+	"time"
+)
+
+// Outputs which are not a file path.
+const (
+	OutputStdout = "stdout" // OutputStdout writes the audit log to stdout.
+	OutputSyslog = "syslog" // OutputSyslog writes the audit log to the local syslog daemon.
+)
+
+// Actions recorded in the audit log.
+const (
+	ActionSave    = "save"    // ActionSave replaces the content of a bin.
+	ActionAppend  = "append"  // ActionAppend appends content to a bin.
+	ActionPatch   = "patch"   // ActionPatch changes lines of a bin.
+	ActionDiff    = "diff"    // ActionDiff applies a unified diff to a bin.
+	ActionRestore = "restore" // ActionRestore restores a revision of a bin.
+	ActionEdit    = "edit"    // ActionEdit writes collaborative edits made in the editor.
+	ActionReload  = "reload"  // ActionReload loads content changed outside of vimbin.
+	ActionDelete  = "delete"  // ActionDelete removes a bin.
+)
+
+// ErrNotQueryable is returned by Query if the audit log is not written to a file.
+var ErrNotQueryable = errors.New("The audit log can only be queried if it is written to a file")
+
+// Entry is a content mutation recorded in the audit log. It never contains the content itself.
+type Entry struct {
+	Time        time.Time `json:"time"`               // Time is when the content was changed.
+	Action      string    `json:"action"`             // Action is how the content was changed, one of the Action* constants.
+	Bin         string    `json:"bin"`                // Bin is the name of the changed bin.
+	Token       string    `json:"token,omitempty"`    // Token is the name of the token which made the change, or the author of the revision, like 'editor'.
+	ClientIP    string    `json:"clientIP,omitempty"` // ClientIP is the IP of the client which made the change, if any.
+	Revision    int       `json:"revision,omitempty"` // Revision is the revision created by the change, if any.
+	Reason      string    `json:"reason,omitempty"`   // Reason is why a bin was deleted.
+	BytesBefore int       `json:"bytesBefore"`        // BytesBefore is the size of the content before the change.
+	BytesAfter  int       `json:"bytesAfter"`         // BytesAfter is the size of the content after the change.
+	ByteDelta   int       `json:"byteDelta"`          // ByteDelta is the change of the size in bytes.
+	HashBefore  string    `json:"hashBefore"`         // HashBefore is the hex encoded hash of the content before the change.
+	HashAfter   string    `json:"hashAfter"`          // HashAfter is the hex encoded hash of the content after the change.
+}
+
+// NewEntry creates an entry for a change of the content of a bin, with the sizes and hashes of the content.
+//
+// Parameters:
+//   - action: string
+//     How the content was changed, one of the Action* constants.
+//   - bin: string
+//     The name of the changed bin.
+//   - before: string
+//     The content before the change.
+//   - after: string
+//     The content after the change.
+//   - hash: func(string) string
+//     Hashes the content, e.g. Log.Hash.
+//
+// Returns:
+//   - Entry
+//     The entry. Token, client IP, revision and reason are left for the caller to set.
+func NewEntry(action, bin, before, after string, hash func(string) string) Entry {
+	return Entry{
+		Time:        time.Now().UTC(),
+		Action:      action,
+		Bin:         bin,
+		BytesBefore: len(before),
+		BytesAfter:  len(after),
+		ByteDelta:   len(after) - len(before),
+		HashBefore:  hash(before),
+		HashAfter:   hash(after),
+	}
+}
+
+// Filter selects entries of the audit log.
+type Filter struct {
+	Bin    string    // Bin selects the entries of a bin. Empty selects all bins.
+	Token  string    // Token selects the entries of a token. Empty selects all tokens.
+	Action string    // Action selects the entries of an action. Empty selects all actions.
+	Since  time.Time // Since selects the entries at or after the time. Zero selects all entries.
+	Limit  int       // Limit is the maximum number of entries, the most recent are kept. Zero or less keeps all.
+}
+
+// matches checks if an entry is selected by the filter.
+func (f Filter) matches(entry Entry) bool {
+	return (f.Bin == "" || entry.Bin == f.Bin) &&
+		(f.Token == "" || entry.Token == f.Token) &&
+		(f.Action == "" || entry.Action == f.Action) &&
+		!entry.Time.Before(f.Since)
+}
+
+// Log is an append-only log of content mutations, written as one JSON object per line.
+type Log struct {
+	writer io.WriteCloser      // writer receives the entries.
+	path   string              // path is the file the entries are written to, or empty if not written to a file.
+	hash   func(string) string // hash computes the keyed hashes of the content.
+	mutex  sync.Mutex
+}
+
+// Open opens the audit log.
+//
+// Parameters:
+//   - output: string
+//     'stdout', 'syslog' or the path of a file. Entries are appended to the file, which is created if needed.
+//   - hash: func(string) string
+//     Computes the keyed hashes of the content, e.g. KeyedHash.
+//
+// Returns:
+//   - *Log
+//     The audit log.
+//   - error
+//     An error if the file or syslog cannot be opened.
+func Open(output string, hash func(string) string) (*Log, error) {
+	switch output {
+	case "":
+		return nil, errors.New("No audit log output configured")
+	case OutputStdout:
+		return &Log{writer: nopCloser{os.Stdout}, hash: hash}, nil
+	case OutputSyslog:
+		writer, err := syslog.New(syslog.LOG_INFO|syslog.LOG_AUTHPRIV, "vimbin")
+		if err != nil {
+			return nil, fmt.Errorf("Unable to connect to syslog: %s", err)
+		}
+		return &Log{writer: writer, hash: hash}, nil
+	}
+
+	file, err := os.OpenFile(output, os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open audit log: %s", err)
+	}
+	return &Log{writer: file, path: output, hash: hash}, nil
+}
+
+// Hash returns the keyed hash of the content as recorded in the entries.
+//
+// Parameters:
+//   - content: string
+//     The content to hash.
+//
+// Returns:
+//   - string
+//     The hex encoded keyed hash.
+func (l *Log) Hash(content string) string {
+	return l.hash(content)
+}
+
+// Record appends an entry to the audit log.
+//
+// Parameters:
+//   - entry: Entry
+//     The entry to append.
+//
+// Returns:
+//   - error
+//     An error if the entry cannot be written.
+func (l *Log) Record(entry Entry) error {
+	line, err := json.Marshal(entry)
+	if err != nil {
+		return fmt.Errorf("Unable to encode audit log entry: %s", err)
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	// Write every entry at once, so concurrent entries are never interleaved
+	if _, err := l.writer.Write(append(line, '\n')); err != nil {
+		return fmt.Errorf("Unable to write audit log entry: %s", err)
+	}
+	return nil
+}
+
+// Query reads the entries selected by a filter from the audit log file.
+//
+// Parameters:
+//   - filter: Filter
+//     Selects the entries.
+//
+// Returns:
+//   - []Entry
+//     The selected entries, oldest first.
+//   - error
+//     ErrNotQueryable if the audit log is not written to a file, or an error if the file cannot be read.
+func (l *Log) Query(filter Filter) ([]Entry, error) {
+	if l.path == "" {
+		return nil, ErrNotQueryable
+	}
+
+	file, err := os.Open(l.path)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open audit log: %s", err)
+	}
+	defer file.Close()
+
+	entries := []Entry{}
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 0, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		var entry Entry
+		// Lines cut off by a crash are skipped
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			continue
+		}
+		if !filter.matches(entry) {
+			continue
+		}
+		entries = append(entries, entry)
+		if filter.Limit > 0 && len(entries) > filter.Limit {
+			entries = entries[1:]
+		}
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, fmt.Errorf("Unable to read audit log: %s", err)
+	}
+
+	return entries, nil
+}
+
+// Close closes the audit log.
+//
+// Returns:
+//   - error
+//     An error if the file or syslog connection cannot be closed.
+func (l *Log) Close() error {
+	return l.writer.Close()
+}
+
+// nopCloser keeps stdout open when the audit log is closed.
+type nopCloser struct {
+	io.Writer
+}
+
+// Close does nothing.
+func (nopCloser) Close() error {
+	return nil
+}
@@ -0,0 +1,98 @@
+package audit
+
+import (
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestNewEntry(t *testing.T) {
+	entry := NewEntry(ActionSave, "notes", "old", "new content", func(content string) string { return "hash:" + content })
+
+	assert.Equal(t, ActionSave, entry.Action)
+	assert.Equal(t, "notes", entry.Bin)
+	assert.Equal(t, 3, entry.BytesBefore)
+	assert.Equal(t, 11, entry.BytesAfter)
+	assert.Equal(t, 8, entry.ByteDelta)
+	assert.Equal(t, "hash:old", entry.HashBefore)
+	assert.Equal(t, "hash:new content", entry.HashAfter)
+	assert.WithinDuration(t, time.Now(), entry.Time, time.Second)
+}
+
+func TestLog(t *testing.T) {
+	t.Run("Entries are appended and queried", func(t *testing.T) {
+		logPath := path.Join(t.TempDir(), "audit.log")
+		auditLog, err := Open(logPath, KeyedHash(nil))
+		assert.NoError(t, err)
+
+		now := time.Now()
+		for i, entry := range []Entry{
+			{Time: now.Add(-time.Hour), Action: ActionSave, Bin: "notes", Token: "ci"},
+			{Time: now.Add(-time.Minute), Action: ActionAppend, Bin: "logs", Token: "ci"},
+			{Time: now, Action: ActionDelete, Bin: "notes", Reason: "expired"},
+		} {
+			assert.NoError(t, auditLog.Record(entry), i)
+		}
+		assert.NoError(t, auditLog.Close())
+
+		// Reopening appends to the existing entries
+		auditLog, err = Open(logPath, KeyedHash(nil))
+		assert.NoError(t, err)
+		assert.NoError(t, auditLog.Record(Entry{Time: now, Action: ActionSave, Bin: "logs", Token: "admin"}))
+
+		entries, err := auditLog.Query(Filter{})
+		assert.NoError(t, err)
+		assert.Len(t, entries, 4)
+
+		entries, _ = auditLog.Query(Filter{Bin: "notes"})
+		assert.Len(t, entries, 2)
+		entries, _ = auditLog.Query(Filter{Token: "ci", Action: ActionAppend})
+		assert.Len(t, entries, 1)
+		entries, _ = auditLog.Query(Filter{Since: now.Add(-2 * time.Minute)})
+		assert.Len(t, entries, 3)
+
+		// The most recent entries are kept
+		entries, _ = auditLog.Query(Filter{Limit: 2})
+		assert.Len(t, entries, 2)
+		assert.Equal(t, ActionDelete, entries[0].Action)
+		assert.Equal(t, "admin", entries[1].Token)
+
+		info, err := os.Stat(logPath)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+	})
+
+	t.Run("Incomplete lines are skipped", func(t *testing.T) {
+		logPath := path.Join(t.TempDir(), "audit.log")
+		assert.NoError(t, os.WriteFile(logPath, []byte("{\"action\":\"save\"}\n{\"action\":\"ap"), 0600))
+
+		auditLog, err := Open(logPath, KeyedHash(nil))
+		assert.NoError(t, err)
+		assert.NoError(t, auditLog.Record(Entry{Action: ActionAppend}))
+
+		entries, err := auditLog.Query(Filter{})
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, ActionSave, entries[0].Action)
+	})
+
+	t.Run("Only files can be queried", func(t *testing.T) {
+		auditLog, err := Open(OutputStdout, KeyedHash(nil))
+		assert.NoError(t, err)
+
+		_, err = auditLog.Query(Filter{})
+		assert.ErrorIs(t, err, ErrNotQueryable)
+		assert.NoError(t, auditLog.Close())
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := Open("", KeyedHash(nil))
+		assert.Error(t, err)
+
+		_, err = Open(path.Join(t.TempDir(), "missing", "audit.log"), KeyedHash(nil))
+		assert.ErrorContains(t, err, "Unable to open audit log")
+	})
+}
@@ -0,0 +1,82 @@
+package audit
+
+import (
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+)
+
+// keySize is the size of the key the hashes of unencrypted content are keyed with.
+const keySize = 32
+
+// LoadKey reads the key the hashes of the content are keyed with, so they cannot be used to confirm a
+// guess of the content. The key is generated and written to the file with mode 0600 on first use.
+//
+// Parameters:
+//   - keyPath: string
+//     The path of the file holding the hex encoded key.
+//
+// Returns:
+//   - []byte
+//     The key.
+//   - error
+//     An error if the file cannot be read or written, or does not contain a valid key.
+func LoadKey(keyPath string) ([]byte, error) {
+	data, err := os.ReadFile(keyPath)
+	if errors.Is(err, os.ErrNotExist) {
+		return createKey(keyPath)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read audit key: %s", err)
+	}
+
+	key, err := hex.DecodeString(strings.TrimSpace(string(data)))
+	if err != nil || len(key) != keySize {
+		return nil, fmt.Errorf("Audit key file '%s' must contain %d hex encoded bytes", keyPath, keySize)
+	}
+	return key, nil
+}
+
+// createKey generates a random key and writes it to a new file.
+func createKey(keyPath string) ([]byte, error) {
+	key := make([]byte, keySize)
+	if _, err := rand.Read(key); err != nil {
+		return nil, fmt.Errorf("Unable to generate audit key: %s", err)
+	}
+
+	file, err := os.OpenFile(keyPath, os.O_WRONLY|os.O_CREATE|os.O_EXCL, 0600)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create audit key: %s", err)
+	}
+	if _, err := file.WriteString(hex.EncodeToString(key) + "\n"); err != nil {
+		file.Close()
+		return nil, fmt.Errorf("Unable to write audit key: %s", err)
+	}
+	if err := file.Close(); err != nil {
+		return nil, fmt.Errorf("Unable to write audit key: %s", err)
+	}
+
+	return key, nil
+}
+
+// KeyedHash returns a function computing the hex encoded HMAC-SHA256 of the content.
+//
+// Parameters:
+//   - key: []byte
+//     The key, e.g. read with LoadKey.
+//
+// Returns:
+//   - func(string) string
+//     Hashes the content with the key.
+func KeyedHash(key []byte) func(string) string {
+	return func(content string) string {
+		mac := hmac.New(sha256.New, key)
+		mac.Write([]byte(content))
+		return hex.EncodeToString(mac.Sum(nil))
+	}
+}
@@ -0,0 +1,42 @@
+package audit
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestLoadKey(t *testing.T) {
+	t.Run("Key is generated once", func(t *testing.T) {
+		keyPath := path.Join(t.TempDir(), "audit.key")
+
+		key, err := LoadKey(keyPath)
+		assert.NoError(t, err)
+		assert.Len(t, key, keySize)
+
+		info, err := os.Stat(keyPath)
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		loaded, err := LoadKey(keyPath)
+		assert.NoError(t, err)
+		assert.Equal(t, key, loaded)
+	})
+
+	t.Run("Invalid key", func(t *testing.T) {
+		keyPath := path.Join(t.TempDir(), "audit.key")
+		assert.NoError(t, os.WriteFile(keyPath, []byte("not hex"), 0600))
+
+		_, err := LoadKey(keyPath)
+		assert.EqualError(t, err, "Audit key file '"+keyPath+"' must contain 32 hex encoded bytes")
+	})
+}
+
+func TestKeyedHash(t *testing.T) {
+	hash := KeyedHash([]byte("key"))
+	assert.Equal(t, hash("content"), hash("content"))
+	assert.NotEqual(t, hash("content"), KeyedHash([]byte("other key"))("content"))
+	assert.Len(t, hash("content"), 64)
+}
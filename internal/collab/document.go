@@ -0,0 +1,232 @@
+package collab
+
+import (
+	"errors"
+	"sync"
+	"time"
+)
+
+// historyLimit is the number of changes kept to transform operations of lagging editors.
+const historyLimit = 1000
+
+// ErrUnknownRevision is returned when an operation is based on a revision which is not known (anymore).
+var ErrUnknownRevision = errors.New("Unknown revision")
+
+// Selection is the cursor position or selection of an editor.
+type Selection struct {
+	Anchor int `json:"anchor"` // Anchor is the position where the selection starts.
+	Head   int `json:"head"`   // Head is the position of the cursor.
+}
+
+// Change is an operation applied to a document.
+type Change struct {
+	Revision  int       // Revision is the revision of the document created by the change.
+	Operation Operation // Operation is the operation, transformed to apply to the previous revision.
+	Client    string    // Client is the ID of the editor which sent the operation, empty for changes made by the server.
+}
+
+// Document is the server-side model of a bin edited by several editors at the same time.
+//
+// Editors send operations based on the last revision they know. The document transforms
+// them against all changes the editor has not seen yet, applies them and assigns the next
+// revision, so all editors converge to the same content.
+type Document struct {
+	content   string      // content is the content at the current revision.
+	revision  int         // revision is the current revision.
+	changes   []Change    // changes are the latest changes, ordered by revision.
+	persisted int         // persisted is the revision which was last written to the storage backend.
+	timer     *time.Timer // timer is the pending persistence, if any.
+	mutex     sync.Mutex  // mutex serializes changes of the document.
+}
+
+// NewDocument creates a document with the given content at revision 0.
+//
+// Parameters:
+//   - content: string
+//     The initial content.
+//
+// Returns:
+//   - *Document
+//     The new document.
+func NewDocument(content string) *Document {
+	return &Document{content: Normalize(content)}
+}
+
+// Snapshot returns the current content and revision.
+//
+// Returns:
+//   - string
+//     The current content.
+//   - int
+//     The current revision.
+func (d *Document) Snapshot() (string, int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.content, d.revision
+}
+
+// Receive applies an operation sent by an editor.
+//
+// Parameters:
+//   - client: string
+//     The ID of the editor which sent the operation.
+//   - revision: int
+//     The revision the operation is based on.
+//   - operation: Operation
+//     The operation.
+//   - notify: func(Change)
+//     Called with the applied change before any other change can be applied,
+//     so changes are published in the order of their revisions. May be nil.
+//
+// Returns:
+//   - Change
+//     The applied change.
+//   - error
+//     ErrUnknownRevision if the revision is unknown, or ErrLengthMismatch if
+//     the operation does not fit the document.
+func (d *Document) Receive(client string, revision int, operation Operation, notify func(Change)) (Change, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	concurrent, err := d.since(revision)
+	if err != nil {
+		return Change{}, err
+	}
+
+	// Transform the operation against all changes the editor did not know about
+	for _, change := range concurrent {
+		if operation, _, err = Transform(operation, change.Operation); err != nil {
+			return Change{}, err
+		}
+	}
+
+	content, err := operation.Apply(d.content)
+	if err != nil {
+		return Change{}, err
+	}
+
+	d.content = content
+	d.revision++
+	change := Change{Revision: d.revision, Operation: operation, Client: client}
+	d.changes = append(d.changes, change)
+	if len(d.changes) > historyLimit {
+		d.changes = append([]Change(nil), d.changes[len(d.changes)-historyLimit:]...)
+	}
+
+	if notify != nil {
+		notify(change)
+	}
+
+	return change, nil
+}
+
+// Changes returns all changes after a revision.
+//
+// Parameters:
+//   - revision: int
+//     The last revision known by the editor.
+//
+// Returns:
+//   - []Change
+//     The changes after the revision, ordered by revision.
+//   - error
+//     ErrUnknownRevision if the changes are not known (anymore).
+func (d *Document) Changes(revision int) ([]Change, error) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	changes, err := d.since(revision)
+	if err != nil {
+		return nil, err
+	}
+
+	return append([]Change(nil), changes...), nil
+}
+
+// since returns the changes after a revision. The caller must hold the lock.
+func (d *Document) since(revision int) ([]Change, error) {
+	oldest := d.revision - len(d.changes)
+	if revision < oldest || revision > d.revision {
+		return nil, ErrUnknownRevision
+	}
+
+	return d.changes[revision-oldest:], nil
+}
+
+// Reset replaces the content of the document, dropping all known changes.
+// Editors must load the new content, as their operations cannot be transformed anymore.
+//
+// Parameters:
+//   - content: string
+//     The new content.
+//
+// Returns:
+//   - int
+//     The new revision.
+func (d *Document) Reset(content string) int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	d.content = Normalize(content)
+	d.revision++
+	d.changes = nil
+	d.persisted = d.revision
+
+	return d.revision
+}
+
+// Persisted returns the revision which was last written to the storage backend.
+func (d *Document) Persisted() int {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.persisted
+}
+
+// MarkPersisted records that a revision was written to the storage backend.
+//
+// Parameters:
+//   - revision: int
+//     The written revision.
+func (d *Document) MarkPersisted(revision int) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if revision > d.persisted {
+		d.persisted = revision
+	}
+}
+
+// Dirty returns true if the document has changes which were not written to the storage backend.
+func (d *Document) Dirty() bool {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	return d.revision != d.persisted
+}
+
+// SchedulePersist calls persist after the given delay, unless a call is already scheduled.
+// This limits writes to the storage backend to one per delay while editors are typing.
+//
+// Parameters:
+//   - delay: time.Duration
+//     The delay before persist is called.
+//   - persist: func()
+//     The function writing the document to the storage backend.
+func (d *Document) SchedulePersist(delay time.Duration, persist func()) {
+	d.mutex.Lock()
+	defer d.mutex.Unlock()
+
+	if d.timer != nil {
+		return
+	}
+
+	d.timer = time.AfterFunc(delay, func() {
+		d.mutex.Lock()
+		d.timer = nil
+		d.mutex.Unlock()
+
+		persist()
+	})
+}
@@ -0,0 +1,221 @@
+package collab
+
+import (
+	"fmt"
+	"math/rand"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// message is an operation sent from an editor to the server.
+type message struct {
+	revision  int
+	operation Operation
+}
+
+// editor simulates the browser editor: at most one operation awaits confirmation,
+// local edits made in the meantime are sent once it is confirmed.
+type editor struct {
+	id          string
+	content     string    // content is the content shown in the editor.
+	pendingText string    // pendingText is the content after the pending operation, without unsent edits.
+	pending     Operation // pending is the operation awaiting confirmation.
+	revision    int       // revision is the last revision received from the server.
+	outbox      []message // outbox holds operations on their way to the server.
+	inbox       []Change  // inbox holds changes on their way to the editor.
+}
+
+func newEditor(id, content string, revision int) *editor {
+	return &editor{id: id, content: content, pendingText: content, revision: revision}
+}
+
+func (e *editor) edit(rng *rand.Rand) error {
+	content, err := randomOperation(rng, e.content).Apply(e.content)
+	e.content = content
+	return err
+}
+
+func (e *editor) send() {
+	if e.pending != nil {
+		return
+	}
+	unsent := Diff(e.pendingText, e.content)
+	if unsent.IsNoop() {
+		return
+	}
+	e.pending = unsent
+	e.pendingText = e.content
+	e.outbox = append(e.outbox, message{revision: e.revision, operation: unsent})
+}
+
+func (e *editor) receive(change Change) error {
+	e.revision = change.Revision
+	if change.Client == e.id {
+		e.pending = nil
+		return nil
+	}
+
+	unsent := Diff(e.pendingText, e.content)
+	operation := change.Operation
+	var err error
+	if e.pending != nil {
+		if e.pending, operation, err = Transform(e.pending, operation); err != nil {
+			return err
+		}
+	}
+	if e.pendingText, err = operation.Apply(e.pendingText); err != nil {
+		return err
+	}
+	if _, operation, err = Transform(unsent, operation); err != nil {
+		return err
+	}
+	e.content, err = operation.Apply(e.content)
+	return err
+}
+
+// simulate runs editors with a random interleaving of edits and message deliveries
+// and checks that all editors converge to the content of the document.
+func simulate(t *testing.T, seed int64, editors, steps int) {
+	t.Helper()
+
+	rng := rand.New(rand.NewSource(seed))
+	document := NewDocument("shared scratchpad")
+	content, revision := document.Snapshot()
+
+	clients := make([]*editor, editors)
+	for i := range clients {
+		clients[i] = newEditor(fmt.Sprintf("editor-%d", i), content, revision)
+	}
+
+	broadcast := func(change Change) {
+		for _, client := range clients {
+			client.inbox = append(client.inbox, change)
+		}
+	}
+
+	deliver := func(client *editor, toServer bool) {
+		if toServer && len(client.outbox) > 0 {
+			msg := client.outbox[0]
+			client.outbox = client.outbox[1:]
+			_, err := document.Receive(client.id, msg.revision, msg.operation, broadcast)
+			assert.NoError(t, err)
+		}
+		if !toServer && len(client.inbox) > 0 {
+			change := client.inbox[0]
+			client.inbox = client.inbox[1:]
+			assert.NoError(t, client.receive(change))
+		}
+	}
+
+	for step := 0; step < steps; step++ {
+		client := clients[rng.Intn(len(clients))]
+		switch rng.Intn(4) {
+		case 0:
+			assert.NoError(t, client.edit(rng))
+		case 1:
+			client.send()
+		case 2:
+			deliver(client, true)
+		default:
+			deliver(client, false)
+		}
+	}
+
+	// Deliver everything until all editors are idle
+	for busy := true; busy; {
+		busy = false
+		for _, client := range clients {
+			client.send()
+			if len(client.outbox) > 0 || len(client.inbox) > 0 || client.pending != nil {
+				busy = true
+			}
+			deliver(client, true)
+			deliver(client, false)
+		}
+	}
+
+	content, revision = document.Snapshot()
+	for _, client := range clients {
+		assert.Equal(t, content, client.content, "seed %d, %s", seed, client.id)
+		assert.Equal(t, revision, client.revision, "seed %d, %s", seed, client.id)
+	}
+}
+
+func TestDocument(t *testing.T) {
+	t.Run("Concurrent editors converge", func(t *testing.T) {
+		for seed := int64(1); seed <= 50; seed++ {
+			simulate(t, seed, 3, 300)
+		}
+	})
+
+	t.Run("Operations are transformed against unknown changes", func(t *testing.T) {
+		document := NewDocument("hello")
+
+		_, err := document.Receive("a", 0, Operation{}.Retain(5).Insert(" world"), nil)
+		assert.NoError(t, err)
+		change, err := document.Receive("b", 0, Operation{}.Insert(">> ").Retain(5), nil)
+		assert.NoError(t, err)
+
+		content, revision := document.Snapshot()
+		assert.Equal(t, ">> hello world", content)
+		assert.Equal(t, 2, revision)
+		assert.Equal(t, Operation{}.Insert(">> ").Retain(11), change.Operation)
+	})
+
+	t.Run("Unknown revisions are refused", func(t *testing.T) {
+		document := NewDocument("")
+		for i := 0; i < historyLimit+1; i++ {
+			_, err := document.Receive("a", i, Operation{}.Retain(i).Insert("x"), nil)
+			assert.NoError(t, err)
+		}
+
+		_, err := document.Receive("a", 0, Operation{}.Insert("x"), nil)
+		assert.ErrorIs(t, err, ErrUnknownRevision)
+		_, err = document.Changes(historyLimit + 2)
+		assert.ErrorIs(t, err, ErrUnknownRevision)
+
+		changes, err := document.Changes(historyLimit - 1)
+		assert.NoError(t, err)
+		assert.Len(t, changes, 2)
+	})
+
+	t.Run("Operations not fitting the document are refused", func(t *testing.T) {
+		document := NewDocument("abc")
+		_, err := document.Receive("a", 0, Operation{}.Retain(5), nil)
+		assert.ErrorIs(t, err, ErrLengthMismatch)
+	})
+
+	t.Run("Persistence state", func(t *testing.T) {
+		document := NewDocument("abc")
+		assert.False(t, document.Dirty())
+
+		change, err := document.Receive("a", 0, Operation{}.Retain(3).Insert("d"), nil)
+		assert.NoError(t, err)
+		assert.True(t, document.Dirty())
+
+		document.MarkPersisted(change.Revision)
+		assert.False(t, document.Dirty())
+		assert.Equal(t, 1, document.Persisted())
+
+		revision := document.Reset("new")
+		content, _ := document.Snapshot()
+		assert.Equal(t, "new", content)
+		assert.Equal(t, revision, document.Persisted())
+	})
+
+	t.Run("Persistence is scheduled once", func(t *testing.T) {
+		document := NewDocument("")
+		calls := make(chan struct{}, 2)
+		document.SchedulePersist(10*time.Millisecond, func() { calls <- struct{}{} })
+		document.SchedulePersist(10*time.Millisecond, func() { calls <- struct{}{} })
+
+		<-calls
+		select {
+		case <-calls:
+			t.Fatal("persist was called twice")
+		case <-time.After(50 * time.Millisecond):
+		}
+	})
+}
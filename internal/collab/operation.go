@@ -0,0 +1,369 @@
+package collab
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"unicode/utf16"
+)
+
+// ErrLengthMismatch is returned when an operation does not fit the document or operation it is combined with.
+var ErrLengthMismatch = errors.New("Operation length does not match")
+
+// Component is a single step of an operation. Exactly one of its fields is set.
+//
+// Lengths and positions are counted in UTF-16 code units, the same way JavaScript counts
+// string lengths, so the server and the browser editors agree on every position.
+type Component struct {
+	Retain int    // Retain skips the given number of characters.
+	Insert string // Insert inserts the given text.
+	Delete int    // Delete deletes the given number of characters.
+}
+
+// MarshalJSON encodes a component like ot.js: retain as a positive number,
+// delete as a negative number and insert as a string.
+func (c Component) MarshalJSON() ([]byte, error) {
+	switch {
+	case c.Retain > 0:
+		return json.Marshal(c.Retain)
+	case c.Delete > 0:
+		return json.Marshal(-c.Delete)
+	default:
+		return json.Marshal(c.Insert)
+	}
+}
+
+// UnmarshalJSON decodes a component encoded with MarshalJSON.
+func (c *Component) UnmarshalJSON(data []byte) error {
+	var value interface{}
+	if err := json.Unmarshal(data, &value); err != nil {
+		return err
+	}
+
+	switch v := value.(type) {
+	case string:
+		if v == "" {
+			return fmt.Errorf("Invalid component: empty insert")
+		}
+		*c = Component{Insert: v}
+	case float64:
+		n := int(v)
+		if float64(n) != v || n == 0 {
+			return fmt.Errorf("Invalid component: %v", v)
+		}
+		if n > 0 {
+			*c = Component{Retain: n}
+		} else {
+			*c = Component{Delete: -n}
+		}
+	default:
+		return fmt.Errorf("Invalid component: %s", data)
+	}
+
+	return nil
+}
+
+// Operation is a sequence of components which transforms a document of length
+// BaseLength into a document of length TargetLength. Operations are built with
+// Retain, Insert and Delete, which keep them in a canonical form.
+type Operation []Component
+
+// Retain appends a component skipping n characters.
+//
+// Parameters:
+//   - n: int
+//     The number of characters to skip.
+//
+// Returns:
+//   - Operation
+//     The extended operation.
+func (o Operation) Retain(n int) Operation {
+	if n <= 0 {
+		return o
+	}
+	if last := len(o) - 1; last >= 0 && o[last].Retain > 0 {
+		o[last].Retain += n
+		return o
+	}
+	return append(o, Component{Retain: n})
+}
+
+// Insert appends a component inserting text.
+//
+// Parameters:
+//   - text: string
+//     The text to insert.
+//
+// Returns:
+//   - Operation
+//     The extended operation.
+func (o Operation) Insert(text string) Operation {
+	if text == "" {
+		return o
+	}
+
+	last := len(o) - 1
+	switch {
+	case last >= 0 && o[last].Insert != "":
+		o[last].Insert += text
+		return o
+	case last >= 0 && o[last].Delete > 0:
+		// Inserts always come before deletes, so equal operations have equal components
+		if last > 0 && o[last-1].Insert != "" {
+			o[last-1].Insert += text
+			return o
+		}
+		o = append(o, o[last])
+		o[last] = Component{Insert: text}
+		return o
+	default:
+		return append(o, Component{Insert: text})
+	}
+}
+
+// Delete appends a component deleting n characters.
+//
+// Parameters:
+//   - n: int
+//     The number of characters to delete.
+//
+// Returns:
+//   - Operation
+//     The extended operation.
+func (o Operation) Delete(n int) Operation {
+	if n <= 0 {
+		return o
+	}
+	if last := len(o) - 1; last >= 0 && o[last].Delete > 0 {
+		o[last].Delete += n
+		return o
+	}
+	return append(o, Component{Delete: n})
+}
+
+// BaseLength returns the length of the document the operation can be applied to.
+func (o Operation) BaseLength() int {
+	length := 0
+	for _, c := range o {
+		length += c.Retain + c.Delete
+	}
+	return length
+}
+
+// TargetLength returns the length of the document after applying the operation.
+func (o Operation) TargetLength() int {
+	length := 0
+	for _, c := range o {
+		length += c.Retain + Length(c.Insert)
+	}
+	return length
+}
+
+// IsNoop returns true if the operation does not change the document.
+func (o Operation) IsNoop() bool {
+	return len(o) == 0 || (len(o) == 1 && o[0].Retain > 0)
+}
+
+// Apply applies the operation to a document.
+//
+// Parameters:
+//   - document: string
+//     The document to change.
+//
+// Returns:
+//   - string
+//     The changed document.
+//   - error
+//     ErrLengthMismatch if the operation does not fit the document.
+func (o Operation) Apply(document string) (string, error) {
+	text := encode(document)
+	if len(text) != o.BaseLength() {
+		return "", ErrLengthMismatch
+	}
+
+	result := make([]uint16, 0, o.TargetLength())
+	index := 0
+	for _, c := range o {
+		switch {
+		case c.Retain > 0:
+			result = append(result, text[index:index+c.Retain]...)
+			index += c.Retain
+		case c.Insert != "":
+			result = append(result, encode(c.Insert)...)
+		case c.Delete > 0:
+			index += c.Delete
+		}
+	}
+
+	return decode(result), nil
+}
+
+// Transform transforms two concurrent operations a and b, which apply to the same document,
+// into a' and b' such that applying a then b' results in the same document as applying b then a'.
+//
+// If both operations insert at the same position, the insert of a is placed first.
+//
+// Parameters:
+//   - a: Operation
+//     The first operation.
+//   - b: Operation
+//     The second operation.
+//
+// Returns:
+//   - Operation
+//     a transformed against b.
+//   - Operation
+//     b transformed against a.
+//   - error
+//     ErrLengthMismatch if the operations do not apply to the same document.
+func Transform(a, b Operation) (Operation, Operation, error) {
+	if a.BaseLength() != b.BaseLength() {
+		return nil, nil, ErrLengthMismatch
+	}
+
+	var aPrime, bPrime Operation
+	ia, ib := 0, 0
+	var ca, cb Component
+	nextA := func() {
+		if ia < len(a) {
+			ca = a[ia]
+			ia++
+		} else {
+			ca = Component{}
+		}
+	}
+	nextB := func() {
+		if ib < len(b) {
+			cb = b[ib]
+			ib++
+		} else {
+			cb = Component{}
+		}
+	}
+	nextA()
+	nextB()
+
+	for !isEmpty(ca) || !isEmpty(cb) {
+		// Inserts are copied to the other side as retains
+		if ca.Insert != "" {
+			aPrime = aPrime.Insert(ca.Insert)
+			bPrime = bPrime.Retain(Length(ca.Insert))
+			nextA()
+			continue
+		}
+		if cb.Insert != "" {
+			aPrime = aPrime.Retain(Length(cb.Insert))
+			bPrime = bPrime.Insert(cb.Insert)
+			nextB()
+			continue
+		}
+		if isEmpty(ca) || isEmpty(cb) {
+			return nil, nil, ErrLengthMismatch
+		}
+
+		var n int
+		switch {
+		case ca.Retain > 0 && cb.Retain > 0:
+			n = min(ca.Retain, cb.Retain)
+			aPrime = aPrime.Retain(n)
+			bPrime = bPrime.Retain(n)
+			ca.Retain -= n
+			cb.Retain -= n
+		case ca.Delete > 0 && cb.Delete > 0:
+			// Both delete the same text, nothing is left to do
+			n = min(ca.Delete, cb.Delete)
+			ca.Delete -= n
+			cb.Delete -= n
+		case ca.Delete > 0 && cb.Retain > 0:
+			n = min(ca.Delete, cb.Retain)
+			aPrime = aPrime.Delete(n)
+			ca.Delete -= n
+			cb.Retain -= n
+		case ca.Retain > 0 && cb.Delete > 0:
+			n = min(ca.Retain, cb.Delete)
+			bPrime = bPrime.Delete(n)
+			ca.Retain -= n
+			cb.Delete -= n
+		}
+
+		if isEmpty(ca) {
+			nextA()
+		}
+		if isEmpty(cb) {
+			nextB()
+		}
+	}
+
+	return aPrime, bPrime, nil
+}
+
+// Diff returns an operation which changes one document into another.
+//
+// The operation replaces the text between the common prefix and the common suffix
+// of both documents, which is exact for a single contiguous change like an append.
+//
+// Parameters:
+//   - from: string
+//     The original document.
+//   - to: string
+//     The changed document.
+//
+// Returns:
+//   - Operation
+//     The operation changing from into to.
+func Diff(from, to string) Operation {
+	a, b := encode(from), encode(to)
+
+	start := 0
+	for start < len(a) && start < len(b) && a[start] == b[start] {
+		start++
+	}
+	end := 0
+	for end < len(a)-start && end < len(b)-start && a[len(a)-1-end] == b[len(b)-1-end] {
+		end++
+	}
+
+	return Operation{}.
+		Retain(start).
+		Insert(decode(b[start : len(b)-end])).
+		Delete(len(a) - start - end).
+		Retain(end)
+}
+
+// Length returns the length of a text in UTF-16 code units.
+//
+// Parameters:
+//   - text: string
+//     The text to measure.
+//
+// Returns:
+//   - int
+//     The length of the text as counted by JavaScript.
+func Length(text string) int {
+	length := 0
+	for _, r := range text {
+		length += utf16.RuneLen(r)
+	}
+	return length
+}
+
+// Normalize returns the text as it is stored in a document. Invalid UTF-8 sequences are replaced
+// with the Unicode replacement character, as they cannot be represented in UTF-16.
+func Normalize(text string) string {
+	return decode(encode(text))
+}
+
+// isEmpty returns true if the component is the zero value, which marks the end of an operation.
+func isEmpty(c Component) bool {
+	return c.Retain == 0 && c.Insert == "" && c.Delete == 0
+}
+
+// encode converts a text into UTF-16 code units.
+func encode(text string) []uint16 {
+	return utf16.Encode([]rune(text))
+}
+
+// decode converts UTF-16 code units into a text.
+func decode(text []uint16) string {
+	return string(utf16.Decode(text))
+}
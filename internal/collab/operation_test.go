@@ -0,0 +1,142 @@
+package collab
+
+import (
+	"encoding/json"
+	"math/rand"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// randomText returns a short random text, including characters outside the Basic Multilingual Plane.
+func randomText(rng *rand.Rand) string {
+	alphabet := []rune("ab\nä😀")
+	text := make([]rune, 1+rng.Intn(4))
+	for i := range text {
+		text[i] = alphabet[rng.Intn(len(alphabet))]
+	}
+	return string(text)
+}
+
+// randomOperation returns a random operation applicable to the document.
+func randomOperation(rng *rand.Rand, document string) Operation {
+	text := encode(document)
+	var operation Operation
+	for index := 0; index < len(text); {
+		n := 1 + rng.Intn(len(text)-index)
+		switch rng.Intn(3) {
+		case 0:
+			operation = operation.Retain(n)
+		case 1:
+			operation = operation.Delete(n)
+		default:
+			operation = operation.Insert(randomText(rng)).Retain(n)
+		}
+		index += n
+	}
+	if rng.Intn(2) == 0 {
+		operation = operation.Insert(randomText(rng))
+	}
+	return operation
+}
+
+func TestOperation(t *testing.T) {
+	t.Run("Builder keeps operations canonical", func(t *testing.T) {
+		operation := Operation{}.Retain(1).Retain(2).Delete(1).Insert("a").Insert("b").Delete(2)
+		assert.Equal(t, Operation{{Retain: 3}, {Insert: "ab"}, {Delete: 3}}, operation)
+	})
+
+	t.Run("JSON encoding", func(t *testing.T) {
+		operation := Operation{}.Retain(2).Insert("hi").Delete(3)
+
+		data, err := json.Marshal(operation)
+		assert.NoError(t, err)
+		assert.JSONEq(t, `[2, "hi", -3]`, string(data))
+
+		var decoded Operation
+		assert.NoError(t, json.Unmarshal(data, &decoded))
+		assert.Equal(t, operation, decoded)
+
+		assert.Error(t, json.Unmarshal([]byte(`[0]`), &decoded))
+		assert.Error(t, json.Unmarshal([]byte(`[1.5]`), &decoded))
+		assert.Error(t, json.Unmarshal([]byte(`[""]`), &decoded))
+		assert.Error(t, json.Unmarshal([]byte(`[{}]`), &decoded))
+	})
+
+	t.Run("Apply", func(t *testing.T) {
+		content, err := Operation{}.Retain(6).Delete(5).Insert("vimbin").Apply("hello world")
+		assert.NoError(t, err)
+		assert.Equal(t, "hello vimbin", content)
+
+		_, err = Operation{}.Retain(3).Apply("hello")
+		assert.ErrorIs(t, err, ErrLengthMismatch)
+	})
+
+	t.Run("Lengths are counted in UTF-16 code units", func(t *testing.T) {
+		assert.Equal(t, 2, Length("😀"))
+		assert.Equal(t, 1, Length("ä"))
+
+		content, err := Operation{}.Retain(2).Insert("!").Retain(1).Apply("😀a")
+		assert.NoError(t, err)
+		assert.Equal(t, "😀!a", content)
+	})
+
+	t.Run("Diff", func(t *testing.T) {
+		assert.Equal(t, Operation{{Retain: 5}, {Insert: " world"}}, Diff("hello", "hello world"))
+		assert.Equal(t, Operation{{Retain: 1}, {Insert: "u"}, {Delete: 1}, {Retain: 3}}, Diff("hallo", "hullo"))
+		assert.True(t, Diff("same", "same").IsNoop())
+
+		rng := rand.New(rand.NewSource(1))
+		for i := 0; i < 200; i++ {
+			from, to := randomText(rng)+randomText(rng), randomText(rng)+randomText(rng)
+			content, err := Diff(from, to).Apply(from)
+			assert.NoError(t, err)
+			assert.Equal(t, to, content)
+		}
+	})
+}
+
+func TestTransform(t *testing.T) {
+	t.Run("Concurrent inserts at the same position", func(t *testing.T) {
+		a := Operation{}.Retain(1).Insert("a")
+		b := Operation{}.Retain(1).Insert("b")
+
+		aPrime, bPrime, err := Transform(a, b)
+		assert.NoError(t, err)
+
+		left, _ := a.Apply("x")
+		left, _ = bPrime.Apply(left)
+		right, _ := b.Apply("x")
+		right, _ = aPrime.Apply(right)
+		assert.Equal(t, "xab", left)
+		assert.Equal(t, "xab", right)
+	})
+
+	t.Run("Mismatching operations", func(t *testing.T) {
+		_, _, err := Transform(Operation{}.Retain(1), Operation{}.Retain(2))
+		assert.ErrorIs(t, err, ErrLengthMismatch)
+	})
+
+	t.Run("Random operations converge", func(t *testing.T) {
+		rng := rand.New(rand.NewSource(42))
+		for i := 0; i < 1000; i++ {
+			document := randomText(rng) + randomText(rng) + randomText(rng)
+			a, b := randomOperation(rng, document), randomOperation(rng, document)
+
+			aPrime, bPrime, err := Transform(a, b)
+			assert.NoError(t, err)
+
+			left, err := a.Apply(document)
+			assert.NoError(t, err)
+			left, err = bPrime.Apply(left)
+			assert.NoError(t, err)
+
+			right, err := b.Apply(document)
+			assert.NoError(t, err)
+			right, err = aPrime.Apply(right)
+			assert.NoError(t, err)
+
+			assert.Equal(t, left, right, "document %q, a %v, b %v", document, a, b)
+		}
+	})
+}
@@ -0,0 +1,48 @@
+package config
+
+import (
+	"fmt"
+	"path"
+	"vimbin/internal/audit"
+	"vimbin/internal/storage"
+
+	"github.com/rs/zerolog/log"
+)
+
+// auditKeySuffix is appended to the name of the storage file to get the path of the audit key.
+const auditKeySuffix = ".audit.key"
+
+// openAudit opens the audit log of content mutations, if configured.
+//
+// The hashes of the content are keyed, so the entries cannot be used to confirm a guess of the content. If the
+// storage is encrypted, they are the keyed hashes recorded in the history. Otherwise they are keyed with a key
+// generated once and kept next to the storage file.
+//
+// Returns:
+//   - error
+//     An error if the audit key cannot be read or the audit log cannot be opened.
+func (s *Storage) openAudit() error {
+	if s.Audit.Output == "" {
+		return nil
+	}
+
+	var hash func(string) string
+	if hasher, ok := s.Store.(storage.ContentHasher); ok {
+		hash = hasher.HashContent
+	} else {
+		key, err := audit.LoadKey(path.Join(s.Directory, s.Name+auditKeySuffix))
+		if err != nil {
+			return fmt.Errorf("Unable to open audit log: %s", err)
+		}
+		hash = audit.KeyedHash(key)
+	}
+
+	auditLog, err := audit.Open(s.Audit.Output, hash)
+	if err != nil {
+		return err
+	}
+	s.Audit.Log = auditLog
+	log.Info().Msgf("Recording content mutations in the audit log '%s'", s.Audit.Output)
+
+	return nil
+}
@@ -0,0 +1,42 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+	"vimbin/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestOpenAudit(t *testing.T) {
+	t.Run("Hashes are keyed with a generated key", func(t *testing.T) {
+		tempDir := t.TempDir()
+		s := Storage{Name: ".vimbin", Directory: tempDir, Store: storage.NewMemory(), Audit: Audit{Output: path.Join(tempDir, "audit.log")}}
+		assert.NoError(t, s.openAudit())
+		hash := s.Audit.Log.Hash("secret")
+		assert.NotEqual(t, storage.Hash("secret"), hash)
+		assert.NoError(t, s.Close())
+
+		info, err := os.Stat(path.Join(tempDir, ".vimbin.audit.key"))
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(0600), info.Mode().Perm())
+
+		// The key is kept across restarts
+		assert.NoError(t, s.openAudit())
+		assert.Equal(t, hash, s.Audit.Log.Hash("secret"))
+		assert.NoError(t, s.Close())
+	})
+
+	t.Run("Hashes match the history if the storage is encrypted", func(t *testing.T) {
+		tempDir := t.TempDir()
+		store, err := storage.NewEncrypted(storage.NewMemory(), [][]byte{make([]byte, 32)})
+		assert.NoError(t, err)
+		s := Storage{Name: ".vimbin", Directory: tempDir, Store: store, Audit: Audit{Output: path.Join(tempDir, "audit.log")}}
+		assert.NoError(t, s.openAudit())
+		assert.Equal(t, store.HashContent("secret"), s.Audit.Log.Hash("secret"))
+		assert.NoError(t, s.Close())
+
+		assert.NoFileExists(t, path.Join(tempDir, ".vimbin.audit.key"))
+	})
+}
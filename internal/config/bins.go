@@ -0,0 +1,274 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"sort"
+	"sync"
+	"time"
+	"vimbin/internal/collab"
+	"vimbin/internal/storage"
+)
+
+// DefaultBin is the name of the bin served on the root routes ("/", "/save", "/append" and "/fetch").
+const DefaultBin = storage.DefaultBin
+
+// Bin represents a single named paste with its own storage file and in-memory content.
+type Bin struct {
+	Name     string           // Name is the name of the bin.
+	Content  Content          // Content represents the content stored in the storage backend.
+	Document *collab.Document // Document is the content edited collaboratively, which is periodically written to the storage backend.
+	mutex    sync.Mutex       // mutex serializes modifications of the bin.
+
+	metadata      storage.Metadata // metadata is the metadata stored in the storage backend.
+	metadataMutex sync.RWMutex     // metadataMutex protects metadata.
+	removed       bool             // removed is set once the bin was removed. It is protected by mutex.
+}
+
+// Metadata returns the metadata of the bin.
+//
+// Returns:
+//   - storage.Metadata
+//     The expiry and burn-after-read setting of the bin.
+func (b *Bin) Metadata() storage.Metadata {
+	b.metadataMutex.RLock()
+	defer b.metadataMutex.RUnlock()
+	return b.metadata
+}
+
+// SetMetadata sets the in-memory metadata of the bin. The caller writes it to the storage backend.
+//
+// Parameters:
+//   - metadata: storage.Metadata
+//     The new metadata.
+func (b *Bin) SetMetadata(metadata storage.Metadata) {
+	b.metadataMutex.Lock()
+	defer b.metadataMutex.Unlock()
+	b.metadata = metadata
+}
+
+// Removed reports whether the bin was removed, e.g. by a request which held the lock before.
+// The caller must hold the lock of the bin.
+//
+// Returns:
+//   - bool
+//     True if the bin was removed from the storage backend.
+func (b *Bin) Removed() bool {
+	return b.removed
+}
+
+// Lock locks the bin for modification.
+//
+// Modifications of the storage backend and the in-memory content must happen while
+// holding this lock, so both stay in sync when concurrent requests modify the same bin.
+func (b *Bin) Lock() {
+	b.mutex.Lock()
+}
+
+// Unlock unlocks the bin after a modification.
+func (b *Bin) Unlock() {
+	b.mutex.Unlock()
+}
+
+// Bins is a thread-safe collection of loaded bins.
+type Bins struct {
+	items map[string]*Bin // items maps the bin name to the bin.
+	mutex sync.RWMutex    // mutex is a read-write mutex for concurrent access control.
+}
+
+// Get retrieves a loaded bin by name.
+//
+// Parameters:
+//   - name: string
+//     The name of the bin.
+//
+// Returns:
+//   - *Bin
+//     The bin, or nil if it is not loaded.
+//   - bool
+//     True if the bin is loaded.
+func (b *Bins) Get(name string) (*Bin, bool) {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	bin, ok := b.items[name]
+	return bin, ok
+}
+
+// Add adds a bin to the collection. If a bin with the same name is already loaded,
+// the existing bin is returned instead.
+//
+// Parameters:
+//   - bin: *Bin
+//     The bin to add.
+//
+// Returns:
+//   - *Bin
+//     The bin stored in the collection.
+func (b *Bins) Add(bin *Bin) *Bin {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.items == nil {
+		b.items = make(map[string]*Bin)
+	}
+	if existing, ok := b.items[bin.Name]; ok {
+		return existing
+	}
+	b.items[bin.Name] = bin
+	return bin
+}
+
+// remove removes a bin from the collection, unless it was replaced by another bin with the same name.
+func (b *Bins) remove(bin *Bin) {
+	b.mutex.Lock()
+	defer b.mutex.Unlock()
+	if b.items[bin.Name] == bin {
+		delete(b.items, bin.Name)
+	}
+}
+
+// Names returns the sorted names of all loaded bins.
+//
+// Returns:
+//   - []string
+//     The names of all loaded bins.
+func (b *Bins) Names() []string {
+	b.mutex.RLock()
+	defer b.mutex.RUnlock()
+	names := make([]string, 0, len(b.items))
+	for name := range b.items {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Bin retrieves a bin by name, loading it from the storage backend if needed.
+//
+// Parameters:
+//   - name: string
+//     The name of the bin.
+//   - create: bool
+//     If true, a missing bin is created with empty content instead of returning storage.ErrNotFound.
+//     The bin is only written to the storage backend on the first save.
+//
+// Returns:
+//   - *Bin
+//     The requested bin.
+//   - error
+//     storage.ErrNotFound if the bin does not exist and create is false, or an error if the name
+//     is invalid or the bin cannot be read.
+func (s *Storage) Bin(name string, create bool) (*Bin, error) {
+	if !storage.IsValidBinName(name) {
+		return nil, fmt.Errorf("Invalid bin name '%s'", name)
+	}
+
+	if bin, ok := s.Bins.Get(name); ok {
+		return bin, nil
+	}
+
+	bin := &Bin{Name: name}
+
+	content, err := s.Store.Get(name)
+	switch {
+	case err == nil:
+		bin.Content.Set(content)
+	case errors.Is(err, storage.ErrNotFound) && create:
+		// Bin is created on first save
+	case errors.Is(err, storage.ErrNotFound):
+		return nil, err
+	default:
+		return nil, fmt.Errorf("Cannot read bin '%s'. %s", name, err)
+	}
+	metadata, err := s.Store.GetMetadata(name)
+	if err != nil {
+		return nil, fmt.Errorf("Cannot read metadata of bin '%s'. %s", name, err)
+	}
+	bin.metadata = metadata
+	bin.Document = collab.NewDocument(bin.Content.Get())
+
+	return s.Bins.Add(bin), nil
+}
+
+// Remove removes a bin together with its metadata and revisions from the storage backend and from memory.
+// Afterwards the bin is empty and Removed reports true. The caller must hold the lock of the bin.
+//
+// Parameters:
+//   - bin: *Bin
+//     The bin to remove.
+//
+// Returns:
+//   - error
+//     An error if the bin cannot be removed from the storage backend.
+func (s *Storage) Remove(bin *Bin) error {
+	if err := s.Store.Purge(bin.Name); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("Unable to remove bin '%s': %s", bin.Name, err)
+	}
+
+	bin.removed = true
+	bin.Content.Set("")
+	bin.SetMetadata(storage.Metadata{})
+	s.Bins.remove(bin)
+
+	return nil
+}
+
+// ExpiredBins returns the names of all bins which have expired, whether loaded into memory or not.
+//
+// Parameters:
+//   - now: time.Time
+//     The time to check the expiry against.
+//
+// Returns:
+//   - []string
+//     The sorted names of the expired bins.
+//   - error
+//     An error if the bins or their metadata cannot be read.
+func (s *Storage) ExpiredBins(now time.Time) ([]string, error) {
+	names, err := s.ListBins()
+	if err != nil {
+		return nil, err
+	}
+
+	expired := []string{}
+	for _, name := range names {
+		metadata, err := s.Store.GetMetadata(name)
+		if bin, ok := s.Bins.Get(name); ok {
+			metadata, err = bin.Metadata(), nil
+		}
+		if err != nil {
+			return nil, fmt.Errorf("Cannot read metadata of bin '%s'. %s", name, err)
+		}
+		if metadata.Expired(now) {
+			expired = append(expired, name)
+		}
+	}
+
+	return expired, nil
+}
+
+// ListBins returns the sorted names of all bins, whether loaded into memory or only present in the storage backend.
+//
+// Returns:
+//   - []string
+//     The names of all bins.
+//   - error
+//     An error if the storage backend cannot be listed.
+func (s *Storage) ListBins() ([]string, error) {
+	stored, err := s.Store.List()
+	if err != nil {
+		return nil, fmt.Errorf("Unable to list bins: %s", err)
+	}
+
+	seen := make(map[string]bool)
+	for _, name := range append(stored, s.Bins.Names()...) {
+		seen[name] = true
+	}
+
+	names := make([]string, 0, len(seen))
+	for name := range seen {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
@@ -0,0 +1,106 @@
+package config
+
+import (
+	"testing"
+	"time"
+	"vimbin/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageBin(t *testing.T) {
+	t.Run("Load existing bin from storage", func(t *testing.T) {
+		s := &Storage{Store: storage.NewMemory()}
+		assert.NoError(t, s.Store.Put("notes", "notes content"))
+
+		bin, err := s.Bin("notes", false)
+		assert.NoError(t, err)
+		assert.Equal(t, "notes", bin.Name)
+		assert.Equal(t, "notes content", bin.Content.Get())
+
+		// The second lookup must return the loaded bin
+		again, err := s.Bin("notes", false)
+		assert.NoError(t, err)
+		assert.Same(t, bin, again)
+	})
+
+	t.Run("Missing bin", func(t *testing.T) {
+		s := &Storage{Store: storage.NewMemory()}
+
+		_, err := s.Bin("missing", false)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+
+		bin, err := s.Bin("missing", true)
+		assert.NoError(t, err)
+		assert.Equal(t, "", bin.Content.Get())
+	})
+
+	t.Run("Invalid bin name", func(t *testing.T) {
+		s := &Storage{Store: storage.NewMemory()}
+
+		_, err := s.Bin("../escape", true)
+		assert.EqualError(t, err, "Invalid bin name '../escape'")
+	})
+
+	t.Run("List bins", func(t *testing.T) {
+		s := &Storage{Store: storage.NewMemory()}
+		assert.NoError(t, s.Store.Put(DefaultBin, "content"))
+		assert.NoError(t, s.Store.Put("notes", "content"))
+
+		_, err := s.Bin("unsaved", true)
+		assert.NoError(t, err)
+
+		names, err := s.ListBins()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{DefaultBin, "notes", "unsaved"}, names)
+	})
+	t.Run("Metadata is loaded with the bin", func(t *testing.T) {
+		s := &Storage{Store: storage.NewMemory()}
+		assert.NoError(t, s.Store.Put("secret", "password"))
+		assert.NoError(t, s.Store.PutMetadata("secret", storage.Metadata{BurnAfterRead: true}))
+
+		bin, err := s.Bin("secret", false)
+		assert.NoError(t, err)
+		assert.True(t, bin.Metadata().BurnAfterRead)
+	})
+
+	t.Run("Remove bin", func(t *testing.T) {
+		s := &Storage{Store: storage.NewMemory()}
+		assert.NoError(t, s.Store.Put("secret", "password"))
+		_, err := s.Store.AddRevision("secret", storage.NewRevision("password", "ci"), "password")
+		assert.NoError(t, err)
+
+		bin, err := s.Bin("secret", false)
+		assert.NoError(t, err)
+
+		bin.Lock()
+		assert.NoError(t, s.Remove(bin))
+		assert.True(t, bin.Removed())
+		bin.Unlock()
+
+		assert.Equal(t, "", bin.Content.Get())
+		_, err = s.Bin("secret", false)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+		revisions, err := s.Store.Revisions("secret")
+		assert.NoError(t, err)
+		assert.Empty(t, revisions)
+	})
+
+	t.Run("Expired bins", func(t *testing.T) {
+		s := &Storage{Store: storage.NewMemory()}
+		now := time.Now()
+		for name, expiresAt := range map[string]time.Time{"expired": now.Add(-time.Minute), "valid": now.Add(time.Minute), "forever": {}} {
+			assert.NoError(t, s.Store.Put(name, "content"))
+			assert.NoError(t, s.Store.PutMetadata(name, storage.Metadata{ExpiresAt: expiresAt}))
+		}
+
+		// The metadata of loaded bins is taken from memory
+		loaded, err := s.Bin("valid", false)
+		assert.NoError(t, err)
+		loaded.SetMetadata(storage.Metadata{ExpiresAt: now.Add(-time.Second)})
+
+		expired, err := s.ExpiredBins(now)
+		assert.NoError(t, err)
+		assert.Equal(t, []string{"expired", "valid"}, expired)
+	})
+}
@@ -0,0 +1,71 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"vimbin/internal/storage"
+
+	"github.com/rs/zerolog/log"
+)
+
+// keys returns the keys the storage is encrypted with, read from the key file or the
+// VIMBIN_ENCRYPTION_KEY environment variable. The first key encrypts, all keys decrypt.
+//
+// Returns:
+//   - [][]byte
+//     The keys, or none if encryption at rest is not configured.
+//   - error
+//     An error if the key file cannot be read or contains invalid keys.
+func (e *Encryption) keys() ([][]byte, error) {
+	value := os.Getenv("VIMBIN_ENCRYPTION_KEY")
+	if e.KeyFile == "" {
+		return storage.ParseEncryptionKeys(value)
+	}
+	if value != "" {
+		return nil, errors.New("Either a key file or VIMBIN_ENCRYPTION_KEY, not both")
+	}
+
+	data, err := os.ReadFile(e.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read key file: %s", err)
+	}
+	keys, err := storage.ParseEncryptionKeys(string(data))
+	if err != nil {
+		return nil, err
+	}
+	if len(keys) == 0 {
+		return nil, fmt.Errorf("Key file '%s' contains no key", e.KeyFile)
+	}
+
+	return keys, nil
+}
+
+// encrypt wraps the storage backend, so the content and revisions of all bins are encrypted at rest,
+// and checks that the configured keys can decrypt the stored content.
+//
+// Returns:
+//   - error
+//     An error if the keys are invalid or cannot decrypt the content of a bin.
+func (s *Storage) encrypt() error {
+	keys, err := s.Encryption.keys()
+	if err != nil {
+		return err
+	}
+	if len(keys) == 0 {
+		return nil
+	}
+
+	encrypted, err := storage.NewEncrypted(s.Store, keys)
+	if err != nil {
+		return err
+	}
+	if err := encrypted.Check(); err != nil {
+		return err
+	}
+	s.Store = encrypted
+
+	log.Debug().Msgf("Encrypting storage at rest with %d keys", len(keys))
+
+	return nil
+}
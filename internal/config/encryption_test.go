@@ -0,0 +1,104 @@
+package config
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"os"
+	"path"
+	"testing"
+	"vimbin/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeKeyFile writes a key file with new random keys and returns its path.
+func writeKeyFile(t *testing.T, count int) string {
+	var content string
+	for i := 0; i < count; i++ {
+		key := make([]byte, 32)
+		_, err := rand.Read(key)
+		assert.NoError(t, err)
+		content += base64.StdEncoding.EncodeToString(key) + "\n"
+	}
+
+	keyFile := path.Join(t.TempDir(), "vimbin.key")
+	assert.NoError(t, os.WriteFile(keyFile, []byte(content), 0600))
+	return keyFile
+}
+
+// encryptedConfig returns a configuration storing the default bin in a directory, encrypted with the keys in keyFile.
+func encryptedConfig(directory, keyFile string) *Config {
+	return &Config{
+		Storage: Storage{
+			Directory:  directory,
+			Name:       ".vimbin",
+			Encryption: Encryption{KeyFile: keyFile},
+		},
+		Server: Server{Web: Web{Address: "localhost:8080"}},
+	}
+}
+
+func TestParseEncryption(t *testing.T) {
+	t.Run("New storage file is encrypted", func(t *testing.T) {
+		directory := t.TempDir()
+		cfg := encryptedConfig(directory, writeKeyFile(t, 1))
+		assert.NoError(t, cfg.Parse())
+
+		data, err := os.ReadFile(path.Join(directory, ".vimbin"))
+		assert.NoError(t, err)
+		assert.True(t, storage.IsEncrypted(string(data)))
+
+		bin, err := cfg.Storage.Bin(DefaultBin, false)
+		assert.NoError(t, err)
+		assert.Equal(t, defaultExample, bin.Content.Get())
+	})
+
+	t.Run("Existing plain storage file is encrypted on next write", func(t *testing.T) {
+		directory := t.TempDir()
+		assert.NoError(t, os.WriteFile(path.Join(directory, ".vimbin"), []byte("plain"), 0644))
+
+		cfg := encryptedConfig(directory, writeKeyFile(t, 1))
+		assert.NoError(t, cfg.Parse())
+		assert.NoError(t, cfg.Storage.Store.Put(DefaultBin, "still secret"))
+
+		data, err := os.ReadFile(path.Join(directory, ".vimbin"))
+		assert.NoError(t, err)
+		assert.NotContains(t, string(data), "secret")
+	})
+
+	t.Run("Refuses to start with the wrong key", func(t *testing.T) {
+		directory := t.TempDir()
+		assert.NoError(t, encryptedConfig(directory, writeKeyFile(t, 1)).Parse())
+
+		err := encryptedConfig(directory, writeKeyFile(t, 1)).Parse()
+		assert.ErrorContains(t, err, "unknown key")
+	})
+
+	t.Run("Refuses to start without a key", func(t *testing.T) {
+		directory := t.TempDir()
+		assert.NoError(t, encryptedConfig(directory, writeKeyFile(t, 1)).Parse())
+
+		err := encryptedConfig(directory, "").Parse()
+		assert.ErrorContains(t, err, "encrypted at rest")
+	})
+
+	t.Run("Invalid key file", func(t *testing.T) {
+		keyFile := path.Join(t.TempDir(), "vimbin.key")
+		assert.NoError(t, os.WriteFile(keyFile, []byte("# no keys yet\n"), 0600))
+
+		assert.Error(t, encryptedConfig(t.TempDir(), keyFile).Parse())
+		assert.Error(t, encryptedConfig(t.TempDir(), path.Join(t.TempDir(), "missing")).Parse())
+	})
+
+	t.Run("Key from environment variable", func(t *testing.T) {
+		key := make([]byte, 32)
+		_, _ = rand.Read(key)
+		t.Setenv("VIMBIN_ENCRYPTION_KEY", base64.StdEncoding.EncodeToString(key))
+
+		cfg := encryptedConfig(t.TempDir(), "")
+		assert.NoError(t, cfg.Parse())
+		assert.IsType(t, &storage.Encrypted{}, cfg.Storage.Store)
+
+		assert.Error(t, encryptedConfig(t.TempDir(), writeKeyFile(t, 1)).Parse())
+	})
+}
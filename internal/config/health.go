@@ -0,0 +1,65 @@
+package config
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"vimbin/internal/storage"
+)
+
+// CheckAccess checks if the storage backend can be read and written.
+//
+// For the file backend the storage file of the default bin is opened for reading and writing
+// without modifying it. The other backends are checked by reading the default bin.
+//
+// Returns:
+//   - error
+//     An error if the storage backend is not accessible.
+func (s *Storage) CheckAccess() error {
+	if s.Backend == storage.BackendFile {
+		f, err := os.OpenFile(s.Path, os.O_RDWR, filePermission)
+		if err != nil {
+			return fmt.Errorf("Unable to open storage file: %s", err)
+		}
+		return f.Close()
+	}
+
+	if _, err := s.Store.Stat(DefaultBin); err != nil && !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("Unable to read storage backend: %s", err)
+	}
+
+	return nil
+}
+
+// CheckConsistency checks if the content of every loaded bin matches the storage backend.
+//
+// Bins which were created but not saved yet match if their content is empty. Edits of the
+// collaborative document which are not written yet are not compared.
+//
+// Returns:
+//   - error
+//     An error naming the first bin whose content differs from the storage backend.
+func (s *Storage) CheckConsistency() error {
+	for _, name := range s.Bins.Names() {
+		bin, ok := s.Bins.Get(name)
+		if !ok {
+			continue
+		}
+
+		// Hold the lock, so a modification in progress is not reported as difference
+		bin.Lock()
+		stored, err := s.Store.Get(name)
+		content := bin.Content.Get()
+		bin.Unlock()
+
+		switch {
+		case errors.Is(err, storage.ErrNotFound) && content == "":
+		case err != nil:
+			return fmt.Errorf("Unable to read bin '%s': %s", name, err)
+		case stored != content:
+			return fmt.Errorf("Content of bin '%s' differs from the storage backend", name)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,79 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+	"vimbin/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestCheckAccess(t *testing.T) {
+	t.Run("Storage file is readable and writable", func(t *testing.T) {
+		directory := t.TempDir()
+		s := &Storage{Backend: storage.BackendFile, Path: path.Join(directory, ".vimbin")}
+		assert.NoError(t, os.WriteFile(s.Path, []byte("content"), 0644))
+
+		assert.NoError(t, s.CheckAccess())
+	})
+
+	t.Run("Missing storage file", func(t *testing.T) {
+		s := &Storage{Backend: storage.BackendFile, Path: path.Join(t.TempDir(), ".vimbin")}
+
+		assert.ErrorContains(t, s.CheckAccess(), "Unable to open storage file")
+	})
+
+	t.Run("Read-only storage file", func(t *testing.T) {
+		if os.Geteuid() == 0 {
+			t.Skip("root can write read-only files")
+		}
+		s := &Storage{Backend: storage.BackendFile, Path: path.Join(t.TempDir(), ".vimbin")}
+		assert.NoError(t, os.WriteFile(s.Path, []byte("content"), 0444))
+
+		assert.ErrorContains(t, s.CheckAccess(), "Unable to open storage file")
+	})
+
+	t.Run("Other backends", func(t *testing.T) {
+		s := &Storage{Backend: storage.BackendMemory, Store: storage.NewMemory()}
+
+		assert.NoError(t, s.CheckAccess())
+	})
+}
+
+func TestCheckConsistency(t *testing.T) {
+	t.Run("Content matches the storage backend", func(t *testing.T) {
+		s := &Storage{Store: storage.NewMemory()}
+		assert.NoError(t, s.Store.Put(DefaultBin, "content"))
+		_, err := s.Bin(DefaultBin, false)
+		assert.NoError(t, err)
+
+		// Unsaved bins are not in the storage backend yet
+		_, err = s.Bin("unsaved", true)
+		assert.NoError(t, err)
+
+		assert.NoError(t, s.CheckConsistency())
+	})
+
+	t.Run("Content was changed in the storage backend", func(t *testing.T) {
+		s := &Storage{Store: storage.NewMemory()}
+		assert.NoError(t, s.Store.Put("notes", "content"))
+		_, err := s.Bin("notes", false)
+		assert.NoError(t, err)
+
+		assert.NoError(t, s.Store.Put("notes", "changed"))
+
+		assert.EqualError(t, s.CheckConsistency(), "Content of bin 'notes' differs from the storage backend")
+	})
+
+	t.Run("Bin was deleted from the storage backend", func(t *testing.T) {
+		s := &Storage{Store: storage.NewMemory()}
+		assert.NoError(t, s.Store.Put("notes", "content"))
+		_, err := s.Bin("notes", false)
+		assert.NoError(t, err)
+
+		assert.NoError(t, s.Store.Delete("notes"))
+
+		assert.ErrorContains(t, s.CheckConsistency(), "Unable to read bin 'notes'")
+	})
+}
@@ -1,67 +1,166 @@
 package config
 
 import (
+	"errors"
 	"fmt"
+	"io"
 	"os"
 	"path"
+	"vimbin/internal/storage"
 	"vimbin/internal/utils"
 
 	"github.com/rs/zerolog/log"
 )
 
+// generatedTokenOutput receives the API token generated by Parse if none is configured.
+var generatedTokenOutput io.Writer = os.Stderr
+
 // Parse reads and processes the configuration settings.
 //
 // This method handles various configuration-related tasks, such as setting the working directory,
-// expanding environment variables, checking the storage file, reading the content file,
-// and validating the hostname and port.
+// expanding environment variables, creating the storage backend, encrypting it at rest,
+// recovering interrupted writes, checking the storage file, loading the default bin,
+// validating the hostname and port, and validating the API tokens.
 //
 // Returns:
 //   - err: error
 //     An error if any of the configuration tasks fail.
 func (c *Config) Parse() (err error) {
-	// Get the working directory
-	if c.Storage.Directory == "" || c.Storage.Directory == "$(pwd)" {
-		c.Storage.Directory, err = os.Getwd()
-		if err != nil {
-			return fmt.Errorf("Unable to get working directory: %s", err)
-		}
+	// Get the working directory and expand environment variables in the storage directory
+	if c.Storage.Directory, err = storageDirectory(c.Storage.Directory); err != nil {
+		return err
 	}
 
-	// Expand environment variables in the storage directory
-	c.Storage.Directory = os.ExpandEnv(c.Storage.Directory)
-
-	// Set the full path to the storage file
+	// Set the full path to the storage file of the default bin
 	c.Storage.Path = path.Join(c.Storage.Directory, c.Storage.Name)
 
-	// Check if the storage file is valid
-	if err := checkStorageFile(c.Storage.Path); err != nil {
+	// Create the storage backend
+	if c.Storage.Backend == "" {
+		c.Storage.Backend = storage.BackendFile
+	}
+	if !utils.IsInList(c.Storage.Backend, storage.SupportedBackends) {
+		return fmt.Errorf("Unsupported storage backend: %s. Supported backends are: %s", c.Storage.Backend, storage.SupportedBackends)
+	}
+	if c.Storage.Store, err = storage.New(c.Storage.Backend, c.Storage.Directory, c.Storage.Name); err != nil {
+		return fmt.Errorf("Unable to create storage backend: %s", err)
+	}
+
+	// Encrypt the storage at rest, refusing to start if the keys cannot decrypt it
+	if err := c.Storage.encrypt(); err != nil {
+		return fmt.Errorf("Unable to encrypt storage: %s", err)
+	}
+
+	// Clean up leftovers of writes interrupted by a crash
+	if recoverer, ok := c.Storage.Store.(storage.Recoverer); ok {
+		recovered, err := recoverer.Recover()
+		if err != nil {
+			return fmt.Errorf("Unable to recover storage: %s", err)
+		}
+		if recovered > 0 {
+			log.Warn().Msgf("Removed %d temporary files left behind by interrupted writes", recovered)
+		}
+	}
+
+	// Check if the storage is valid
+	if err := checkStorage(c.Storage.Backend, c.Storage.Store, c.Storage.Path); err != nil {
 		return fmt.Errorf("Unable to check storage file: %s", err)
 	}
 
-	// Read the content file
-	content, err := os.ReadFile(c.Storage.Path)
+	// Load the default bin
+	bin, err := c.Storage.Bin(DefaultBin, false)
 	if err != nil {
 		return fmt.Errorf("Cannot read storage file. %s", err)
 	}
-	c.Storage.Content.Set(string(content))
+	if _, ok := c.Storage.Store.(*storage.Encrypted); !ok && storage.IsEncrypted(bin.Content.Get()) {
+		return errors.New("The storage is encrypted at rest. Pass its key with --encryption-key-file or VIMBIN_ENCRYPTION_KEY")
+	}
+
+	// Record content mutations separately from the logs
+	if err := c.Storage.openAudit(); err != nil {
+		return err
+	}
 
 	// Check if Hostname and Port are valid
 	if _, _, err := utils.ExtractHostAndPort(c.Server.Web.Address); err != nil {
 		return fmt.Errorf("Unable to extract hostname and port: %s", err)
 	}
+	if c.Server.Web.AdminAddress != "" {
+		if _, _, err := utils.ExtractHostAndPort(c.Server.Web.AdminAddress); err != nil {
+			return fmt.Errorf("Unable to extract hostname and port of admin address: %s", err)
+		}
+	}
+
+	// Check if the TLS configuration is complete
+	if err := c.Server.Web.TLS.validate(); err != nil {
+		return fmt.Errorf("Invalid TLS configuration: %s", err)
+	}
 
+	// Generate an API token if none is configured. Reload keeps it until the server restarts
+	if c.Server.Api.Token.Get() == "" && os.Getenv("VIMBIN_TOKEN") == "" {
+		if err := c.Server.Api.Token.Generate(32); err != nil {
+			return fmt.Errorf("Unable to generate API token: %s", err)
+		}
+		// The token is printed once outside of the log, so it never reaches log aggregation
+		fmt.Fprintf(generatedTokenOutput, "Generated API token: %s\n", c.Server.Api.Token.Get())
+		log.Warn().Msg("No API token configured, generated a random one and printed it to stderr. Configure a token to keep it across restarts, e.g. one created with 'vimbin token generate'")
+	}
+
+	// Check the API tokens, browser sessions and themes
+	if err := c.parseServer(); err != nil {
+		return err
+	}
+
+	return nil
+}
+
+// parseServer validates the settings of the server which can be changed by Reload.
+//
+// It applies the API token and themes set as ENV variables, validates the named API tokens and rate limits and sets defaults for the browser sessions,
+// collaborative editing and themes.
+//
+// Returns:
+//   - error
+//     An error if a setting is invalid.
+func (c *Config) parseServer() error {
 	// Check if the API token was set as ENV variable
 	if token := os.Getenv("VIMBIN_TOKEN"); token != "" {
 		c.Server.Api.Token.Set(token)
-		log.Debug().Msgf("Using API token from ENV variable: %s", token)
+		log.Debug().Msg("Using API token from ENV variable")
 	}
 
-	// Check if the API token is valid
+	// Check if the API token is set
 	if c.Server.Api.Token.Get() == "" {
-		if err := c.Server.Api.Token.Generate(32); err != nil {
-			return fmt.Errorf("Unable to generate API token: %s", err)
-		}
-		log.Debug().Msgf("Generated API token: %s", c.Server.Api.Token.Get())
+		return errors.New("No API token configured")
+	}
+
+	// Check if the named API tokens are valid
+	if err := c.Server.Api.validateTokens(); err != nil {
+		return fmt.Errorf("Invalid API tokens: %s", err)
+	}
+
+	// Write collaborative edits at least every few seconds
+	if c.Server.Web.PersistInterval <= 0 {
+		c.Server.Web.PersistInterval = DefaultPersistInterval
+	}
+
+	// Keep browsers logged in for a day
+	if c.Server.Web.SessionTTL <= 0 {
+		c.Server.Web.SessionTTL = DefaultSessionTTL
+	}
+
+	// Limit the size of request bodies, so clients cannot exhaust the memory
+	if c.Server.Web.MaxBodySize == "" {
+		c.Server.Web.MaxBodySize = DefaultMaxBodySize
+	}
+	maxBodyBytes, err := utils.ParseSize(c.Server.Web.MaxBodySize)
+	if err != nil {
+		return fmt.Errorf("Invalid maximum body size: %s", err)
+	}
+	c.Server.Web.MaxBodyBytes = maxBodyBytes
+
+	// Check the rate limits and the lockout after failed authentications
+	if err := c.Server.RateLimit.validate(); err != nil {
+		return fmt.Errorf("Invalid rate limits: %s", err)
 	}
 
 	// Theme defaults
@@ -89,3 +188,26 @@ func (c *Config) Parse() (err error) {
 
 	return nil
 }
+
+// storageDirectory resolves the configured storage directory.
+//
+// Parameters:
+//   - directory: string
+//     The configured storage directory. An empty value or '$(pwd)' selects the working directory.
+//
+// Returns:
+//   - string
+//     The storage directory with environment variables expanded.
+//   - error
+//     An error if the working directory cannot be determined.
+func storageDirectory(directory string) (string, error) {
+	if directory == "" || directory == "$(pwd)" {
+		workingDirectory, err := os.Getwd()
+		if err != nil {
+			return "", fmt.Errorf("Unable to get working directory: %s", err)
+		}
+		return workingDirectory, nil
+	}
+
+	return os.ExpandEnv(directory), nil
+}
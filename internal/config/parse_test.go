@@ -1,9 +1,14 @@
 package config
 
 import (
+	"bytes"
 	"os"
 	"path"
+	"strings"
 	"testing"
+
+	"github.com/rs/zerolog"
+	"github.com/rs/zerolog/log"
 )
 
 // TestParse is a unit test for the Parse method.
@@ -32,6 +37,16 @@ func TestParse(t *testing.T) {
 		},
 	}
 
+	// Capture the log and stderr to check where the generated API token is written
+	var output, printed bytes.Buffer
+	logger := log.Logger
+	log.Logger = zerolog.New(&output)
+	generatedTokenOutput = &printed
+	t.Cleanup(func() {
+		log.Logger = logger
+		generatedTokenOutput = os.Stderr
+	})
+
 	// Run the Parse method
 	err = cfg.Parse()
 
@@ -52,4 +67,18 @@ func TestParse(t *testing.T) {
 	if cfg.Storage.Path != tempStoragePath {
 		t.Errorf("Storage path not set correctly. Expected: %s, Got: %s", tempStoragePath, cfg.Storage.Path)
 	}
+
+	// Request bodies are limited by default
+	if cfg.Server.Web.MaxBodyBytes != 10<<20 {
+		t.Errorf("Maximum body size not set correctly. Expected: %d, Got: %d", 10<<20, cfg.Server.Web.MaxBodyBytes)
+	}
+
+	// A generated API token is printed once, but never logged
+	token := cfg.Server.Api.Token.Get()
+	if token == "" || strings.Count(printed.String(), token) != 1 {
+		t.Errorf("Generated API token not printed once. Got: %s", printed.String())
+	}
+	if strings.Contains(output.String(), token) {
+		t.Errorf("Generated API token was logged: %s", output.String())
+	}
 }
@@ -0,0 +1,122 @@
+package config
+
+import (
+	"fmt"
+	"strings"
+	"time"
+	"vimbin/internal/server"
+)
+
+// Defaults of the rate limits and the lockout if not configured otherwise.
+const (
+	DefaultRequestsPerSecond = 10               // DefaultRequestsPerSecond is the number of requests per second and route a client may make.
+	DefaultBurst             = 50               // DefaultBurst is the number of requests a client may make at once.
+	DefaultMaxFailures       = 10               // DefaultMaxFailures is the number of failed authentications which locks out a client.
+	DefaultLockoutWindow     = 5 * time.Minute  // DefaultLockoutWindow is the time in which failed authentications are counted.
+	DefaultLockoutDuration   = 15 * time.Minute // DefaultLockoutDuration is how long a client is locked out.
+)
+
+// RateLimit represents the rate limit configuration.
+type RateLimit struct {
+	RequestsPerSecond float64      `mapstructure:"requestsPerSecond"` // RequestsPerSecond is the number of requests per second and route a client may make. A negative value disables rate limiting.
+	Burst             int          `mapstructure:"burst"`             // Burst is the number of requests a client may make at once.
+	TrustedProxies    []string     `mapstructure:"trustedProxies"`    // TrustedProxies are the IPs or CIDR ranges of proxies whose X-Forwarded-For header is used.
+	Routes            []RouteLimit `mapstructure:"routes"`            // Routes are the limits of single routes.
+	Lockout           Lockout      `mapstructure:"lockout"`           // Lockout represents the lockout after failed authentications.
+}
+
+// RouteLimit represents the rate limit of a single route.
+type RouteLimit struct {
+	Path              string  `mapstructure:"path"`              // Path is the route, e.g. '/save' or '/api/bins/{name}'.
+	RequestsPerSecond float64 `mapstructure:"requestsPerSecond"` // RequestsPerSecond overrides the default. A negative value disables rate limiting of the route.
+	Burst             int     `mapstructure:"burst"`             // Burst overrides the default.
+}
+
+// Lockout represents the lockout of clients which repeatedly fail to authenticate.
+type Lockout struct {
+	MaxFailures int           `mapstructure:"maxFailures"` // MaxFailures is the number of failed authentications which locks out a client. A negative value disables lockouts.
+	Window      time.Duration `mapstructure:"window"`      // Window is the time in which failed authentications are counted.
+	Duration    time.Duration `mapstructure:"duration"`    // Duration is how long a client is locked out.
+}
+
+// validate checks the rate limits and sets the defaults of settings which are not configured.
+//
+// Returns:
+//   - error
+//     An error describing the first invalid setting, if any.
+func (r *RateLimit) validate() error {
+	if r.RequestsPerSecond == 0 {
+		r.RequestsPerSecond = DefaultRequestsPerSecond
+	}
+	if r.Burst <= 0 {
+		r.Burst = DefaultBurst
+	}
+
+	for _, proxy := range r.TrustedProxies {
+		if _, err := server.ParseTrustedProxy(proxy); err != nil {
+			return err
+		}
+	}
+
+	paths := map[string]bool{}
+	for i, route := range r.Routes {
+		if !strings.HasPrefix(route.Path, "/") {
+			return fmt.Errorf("Route limit #%d must have a path starting with '/'", i+1)
+		}
+		if paths[route.Path] {
+			return fmt.Errorf("Route '%s' is limited more than once", route.Path)
+		}
+		paths[route.Path] = true
+	}
+
+	if r.Lockout.MaxFailures == 0 {
+		r.Lockout.MaxFailures = DefaultMaxFailures
+	}
+	if r.Lockout.Window <= 0 {
+		r.Lockout.Window = DefaultLockoutWindow
+	}
+	if r.Lockout.Duration <= 0 {
+		r.Lockout.Duration = DefaultLockoutDuration
+	}
+
+	return nil
+}
+
+// Limits returns the rate limits applied by the server.
+//
+// Routes without requests per second or burst of their own use the defaults.
+//
+// Returns:
+//   - server.Limits
+//     The rate limits and lockout settings.
+func (r *RateLimit) Limits() server.Limits {
+	limits := server.Limits{
+		Default: server.RateLimit{RequestsPerSecond: r.RequestsPerSecond, Burst: r.Burst},
+		Routes:  make(map[string]server.RateLimit, len(r.Routes)),
+		Lockout: server.Lockout{
+			MaxFailures: r.Lockout.MaxFailures,
+			Window:      r.Lockout.Window,
+			Duration:    r.Lockout.Duration,
+		},
+	}
+
+	for _, route := range r.Routes {
+		limit := limits.Default
+		if route.RequestsPerSecond != 0 {
+			limit.RequestsPerSecond = route.RequestsPerSecond
+		}
+		if route.Burst > 0 {
+			limit.Burst = route.Burst
+		}
+		limits.Routes[route.Path] = limit
+	}
+
+	// Invalid proxies were rejected by validate
+	for _, proxy := range r.TrustedProxies {
+		if prefix, err := server.ParseTrustedProxy(proxy); err == nil {
+			limits.TrustedProxies = append(limits.TrustedProxies, prefix)
+		}
+	}
+
+	return limits
+}
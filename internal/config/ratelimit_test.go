@@ -0,0 +1,67 @@
+package config
+
+import (
+	"testing"
+	"time"
+	"vimbin/internal/server"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestRateLimit(t *testing.T) {
+	t.Run("Defaults", func(t *testing.T) {
+		rateLimit := RateLimit{}
+		assert.NoError(t, rateLimit.validate())
+
+		limits := rateLimit.Limits()
+		assert.Equal(t, server.RateLimit{RequestsPerSecond: DefaultRequestsPerSecond, Burst: DefaultBurst}, limits.Default)
+		assert.Equal(t, server.Lockout{MaxFailures: DefaultMaxFailures, Window: DefaultLockoutWindow, Duration: DefaultLockoutDuration}, limits.Lockout)
+		assert.Empty(t, limits.Routes)
+		assert.Empty(t, limits.TrustedProxies)
+	})
+
+	t.Run("Negative values disable limits", func(t *testing.T) {
+		rateLimit := RateLimit{RequestsPerSecond: -1, Lockout: Lockout{MaxFailures: -1}}
+		assert.NoError(t, rateLimit.validate())
+
+		limits := rateLimit.Limits()
+		assert.False(t, limits.Default.Enabled())
+		assert.Equal(t, -1, limits.Lockout.MaxFailures)
+	})
+
+	t.Run("Routes inherit unset values", func(t *testing.T) {
+		rateLimit := RateLimit{
+			RequestsPerSecond: 5,
+			Burst:             10,
+			TrustedProxies:    []string{"10.0.0.0/8", "192.0.2.1"},
+			Routes: []RouteLimit{
+				{Path: "/save", RequestsPerSecond: 1},
+				{Path: "/login", Burst: 3},
+				{Path: "/api/bins/{name}", RequestsPerSecond: -1},
+			},
+			Lockout: Lockout{MaxFailures: 3, Window: time.Minute, Duration: time.Hour},
+		}
+		assert.NoError(t, rateLimit.validate())
+
+		limits := rateLimit.Limits()
+		assert.Equal(t, map[string]server.RateLimit{
+			"/save":            {RequestsPerSecond: 1, Burst: 10},
+			"/login":           {RequestsPerSecond: 5, Burst: 3},
+			"/api/bins/{name}": {RequestsPerSecond: -1, Burst: 10},
+		}, limits.Routes)
+		assert.Equal(t, server.Lockout{MaxFailures: 3, Window: time.Minute, Duration: time.Hour}, limits.Lockout)
+		assert.Len(t, limits.TrustedProxies, 2)
+		assert.Equal(t, "192.0.2.1/32", limits.TrustedProxies[1].String())
+	})
+
+	t.Run("Invalid settings", func(t *testing.T) {
+		rateLimit := RateLimit{TrustedProxies: []string{"proxy.local"}}
+		assert.ErrorContains(t, rateLimit.validate(), "Invalid trusted proxy 'proxy.local'")
+
+		rateLimit = RateLimit{Routes: []RouteLimit{{Path: "save"}}}
+		assert.EqualError(t, rateLimit.validate(), "Route limit #1 must have a path starting with '/'")
+
+		rateLimit = RateLimit{Routes: []RouteLimit{{Path: "/save"}, {Path: "/save"}}}
+		assert.EqualError(t, rateLimit.validate(), "Route '/save' is limited more than once")
+	})
+}
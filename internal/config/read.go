@@ -3,7 +3,7 @@ package config
 import (
 	"fmt"
 
-	"github.com/mitchellh/mapstructure"
+	"github.com/go-viper/mapstructure/v2"
 
 	"github.com/spf13/viper"
 )
@@ -31,7 +31,8 @@ func (c *Config) Read(configPath string) error {
 	if err := viper.Unmarshal(c, func(d *mapstructure.DecoderConfig) {
 		d.ZeroFields = true // Zero out any existing fields
 		d.DecodeHook = mapstructure.ComposeDecodeHookFunc(
-			customTokenDecodeHook, // Custom decoder hook for the Token field
+			customTokenDecodeHook,                       // Custom decoder hook for the Token field
+			mapstructure.StringToTimeDurationHookFunc(), // Decode durations like "720h"
 		)
 	}); err != nil {
 		return fmt.Errorf("Failed to unmarshal config file: %v", err)
@@ -64,6 +64,33 @@ server:
 		assert.Equal(t, "token", config.Server.Api.Token.Get())
 	})
 
+	t.Run("Named tokens", func(t *testing.T) {
+		content := `
+server:
+  api:
+    token: token
+    tokens:
+      - name: ci
+        token: ci-token
+        scopes: [append]
+        bins: [logs]
+`
+		filePath, err := createTempFile(content)
+		if err != nil {
+			t.Fatalf("Failed to create temp file: %v", err)
+		}
+		defer os.Remove(filePath.Name())
+
+		config := &Config{}
+		err = config.Read(filePath.Name())
+		assert.NoError(t, err)
+		assert.Len(t, config.Server.Api.Tokens, 1)
+		assert.Equal(t, "ci", config.Server.Api.Tokens[0].Name)
+		assert.Equal(t, "ci-token", config.Server.Api.Tokens[0].Token.Get())
+		assert.Equal(t, []string{"append"}, config.Server.Api.Tokens[0].Scopes)
+		assert.Equal(t, []string{"logs"}, config.Server.Api.Tokens[0].Bins)
+	})
+
 	// Test reading a non-existent file
 	t.Run("Invalid file path", func(t *testing.T) {
 		config := &Config{}
@@ -86,6 +113,6 @@ server:
 		// Run the test
 		cfg := &Config{}
 		err = cfg.Read(filePath.Name())
-		assert.EqualError(t, err, "Failed to unmarshal config file: 1 error(s) decoding:\n\n* cannot parse 'server.api.skipInsecureVerify' as bool: strconv.ParseBool: parsing \"not_a_boolean\": invalid syntax")
+		assert.EqualError(t, err, "Failed to unmarshal config file: decoding failed due to the following error(s):\n\n'server.api.skipInsecureVerify' cannot parse value as 'bool': strconv.ParseBool: invalid syntax")
 	})
 }
@@ -0,0 +1,270 @@
+package config
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"slices"
+	"time"
+	"vimbin/internal/server"
+	"vimbin/internal/storage"
+	"vimbin/internal/utils"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/rs/zerolog/log"
+)
+
+// watchDelay is how long WatchFile waits for further changes of the config file before reporting it,
+// so a file written in several steps is only read once it is complete.
+const watchDelay = 200 * time.Millisecond
+
+// setting is a setting compared by Reload.
+type setting struct {
+	name    string // name is the key of the setting in the config file.
+	current any    // current is the value of the running server.
+	next    any    // next is the value of the reloaded config file.
+}
+
+// Reload reads the config file again while the server runs and applies the API tokens, themes,
+// browser sessions, rate limits, collaborative editing and history settings.
+//
+// The new configuration is validated like on startup. If it is invalid, nothing is applied and the
+// running server keeps its configuration. Settings removed from the config file keep their current
+// value, except named API tokens, which are revoked. Settings which are only read on startup, like
+// the listen address, TLS and storage, are kept as well and a restart is logged to be required.
+// Every applied change is logged, but never the value of a token.
+//
+// Reload must not be called concurrently.
+//
+// Parameters:
+//   - configPath: string
+//     The path to the config file.
+//   - token: string
+//     The API token given on the command line, which takes precedence over the config file like on startup.
+//
+// Returns:
+//   - server.Auth
+//     The authentication configuration to serve from now on.
+//   - error
+//     An error if the config file cannot be read or is invalid.
+func (c *Config) Reload(configPath, token string) (server.Auth, error) {
+	c.mutex.RLock()
+	next := &Config{
+		Server: c.Server,
+		Storage: Storage{
+			Name:       c.Storage.Name,
+			Directory:  c.Storage.Directory,
+			Backend:    c.Storage.Backend,
+			History:    c.Storage.History,
+			Encryption: c.Storage.Encryption,
+			Audit:      c.Storage.Audit,
+		},
+	}
+	currentToken := c.Server.Api.Token.Get()
+	c.mutex.RUnlock()
+
+	// Named tokens are only configured in the config file, so tokens removed from it are revoked
+	next.Server.Api.Tokens = nil
+
+	if err := next.Read(configPath); err != nil {
+		return server.Auth{}, err
+	}
+	if token != "" {
+		next.Server.Api.Token.Set(token)
+	}
+	// Without a token in the config file, the current token, e.g. the generated one, stays valid
+	if next.Server.Api.Token.Get() == "" {
+		next.Server.Api.Token.Set(currentToken)
+	}
+	if !utils.IsInList(next.Server.Web.Theme, SupportedThemes) {
+		return server.Auth{}, fmt.Errorf("Unsupported theme: %s. Supported themes are: %s", next.Server.Web.Theme, SupportedThemes)
+	}
+	if err := next.parseServer(); err != nil {
+		return server.Auth{}, err
+	}
+
+	var err error
+	if next.Storage.Directory, err = storageDirectory(next.Storage.Directory); err != nil {
+		return server.Auth{}, err
+	}
+	if next.Storage.Backend == "" {
+		next.Storage.Backend = storage.BackendFile
+	}
+
+	// Settings used to start the server and to open the storage cannot be changed while it runs
+	for _, setting := range []setting{
+		{"server.web.address", c.Server.Web.Address, next.Server.Web.Address},
+		{"server.web.adminAddress", c.Server.Web.AdminAddress, next.Server.Web.AdminAddress},
+		{"server.web.shutdownDelay", c.Server.Web.ShutdownDelay, next.Server.Web.ShutdownDelay},
+		{"server.web.tls", c.Server.Web.TLS, next.Server.Web.TLS},
+		{"storage.name", c.Storage.Name, next.Storage.Name},
+		{"storage.directory", c.Storage.Directory, next.Storage.Directory},
+		{"storage.backend", c.Storage.Backend, next.Storage.Backend},
+		{"storage.encryption.keyFile", c.Storage.Encryption.KeyFile, next.Storage.Encryption.KeyFile},
+		{"storage.audit.output", c.Storage.Audit.Output, next.Storage.Audit.Output},
+	} {
+		if setting.current != setting.next {
+			log.Warn().Msgf("Changing '%s' requires a restart, keeping %v", setting.name, setting.current)
+		}
+	}
+	next.Server.Web.Address = c.Server.Web.Address
+	next.Server.Web.AdminAddress = c.Server.Web.AdminAddress
+	next.Server.Web.ShutdownDelay = c.Server.Web.ShutdownDelay
+	next.Server.Web.TLS = c.Server.Web.TLS
+
+	changes := diffSettings(c, next)
+
+	c.mutex.Lock()
+	c.Server = next.Server
+	c.Storage.History = next.Storage.History
+	auth := c.Server.Auth()
+	c.mutex.Unlock()
+
+	if len(changes) == 0 {
+		log.Info().Msg("Reloaded the configuration, nothing changed")
+	}
+	for _, change := range changes {
+		log.Info().Msgf("Reloaded the configuration: %s", change)
+	}
+
+	return auth, nil
+}
+
+// diffSettings describes the changes of the settings applied by Reload. Token values are never included.
+//
+// Parameters:
+//   - current: *Config
+//     The configuration of the running server.
+//   - next: *Config
+//     The reloaded configuration.
+//
+// Returns:
+//   - []string
+//     A description of every change, e.g. 'server.web.theme: auto -> latte'.
+func diffSettings(current, next *Config) []string {
+	changes := []string{}
+
+	for _, setting := range []setting{
+		{"server.web.theme", current.Server.Web.Theme, next.Server.Web.Theme},
+		{"server.web.darkTheme", current.Server.Web.DarkTheme, next.Server.Web.DarkTheme},
+		{"server.web.lightTheme", current.Server.Web.LightTheme, next.Server.Web.LightTheme},
+		{"server.web.requireAuth", current.Server.Web.RequireAuth, next.Server.Web.RequireAuth},
+		{"server.web.sessionTTL", current.Server.Web.SessionTTL, next.Server.Web.SessionTTL},
+		{"server.web.persistInterval", current.Server.Web.PersistInterval, next.Server.Web.PersistInterval},
+		{"server.web.maxBodySize", current.Server.Web.MaxBodySize, next.Server.Web.MaxBodySize},
+		{"server.api.skipInsecureVerify", current.Server.Api.SkipInsecureVerify, next.Server.Api.SkipInsecureVerify},
+		{"server.api.address", current.Server.Api.Address, next.Server.Api.Address},
+		{"server.rateLimit.requestsPerSecond", current.Server.RateLimit.RequestsPerSecond, next.Server.RateLimit.RequestsPerSecond},
+		{"server.rateLimit.burst", current.Server.RateLimit.Burst, next.Server.RateLimit.Burst},
+		{"server.rateLimit.trustedProxies", fmt.Sprint(current.Server.RateLimit.TrustedProxies), fmt.Sprint(next.Server.RateLimit.TrustedProxies)},
+		{"server.rateLimit.routes", fmt.Sprint(current.Server.RateLimit.Routes), fmt.Sprint(next.Server.RateLimit.Routes)},
+		{"server.rateLimit.lockout.maxFailures", current.Server.RateLimit.Lockout.MaxFailures, next.Server.RateLimit.Lockout.MaxFailures},
+		{"server.rateLimit.lockout.window", current.Server.RateLimit.Lockout.Window, next.Server.RateLimit.Lockout.Window},
+		{"server.rateLimit.lockout.duration", current.Server.RateLimit.Lockout.Duration, next.Server.RateLimit.Lockout.Duration},
+		{"storage.history.maxRevisions", current.Storage.History.MaxRevisions, next.Storage.History.MaxRevisions},
+		{"storage.history.maxAge", current.Storage.History.MaxAge, next.Storage.History.MaxAge},
+	} {
+		if setting.current != setting.next {
+			changes = append(changes, fmt.Sprintf("%s: %v -> %v", setting.name, setting.current, setting.next))
+		}
+	}
+
+	if current.Server.Api.Token.Get() != next.Server.Api.Token.Get() {
+		changes = append(changes, "server.api.token: changed")
+	}
+
+	tokens := make(map[string]NamedToken, len(current.Server.Api.Tokens))
+	for _, token := range current.Server.Api.Tokens {
+		tokens[token.Name] = token
+	}
+	for _, token := range next.Server.Api.Tokens {
+		previous, ok := tokens[token.Name]
+		delete(tokens, token.Name)
+		if !ok {
+			changes = append(changes, fmt.Sprintf("server.api.tokens: added '%s' with scopes %v", token.Name, token.Scopes))
+			continue
+		}
+		if previous.Token.Get() != token.Token.Get() {
+			changes = append(changes, fmt.Sprintf("server.api.tokens: changed the value of '%s'", token.Name))
+		}
+		if !slices.Equal(previous.Scopes, token.Scopes) {
+			changes = append(changes, fmt.Sprintf("server.api.tokens: changed the scopes of '%s': %v -> %v", token.Name, previous.Scopes, token.Scopes))
+		}
+		if !slices.Equal(previous.Bins, token.Bins) {
+			changes = append(changes, fmt.Sprintf("server.api.tokens: changed the bins of '%s': %v -> %v", token.Name, previous.Bins, token.Bins))
+		}
+	}
+	for _, token := range current.Server.Api.Tokens {
+		if _, removed := tokens[token.Name]; removed {
+			changes = append(changes, fmt.Sprintf("server.api.tokens: removed '%s'", token.Name))
+		}
+	}
+
+	return changes
+}
+
+// WatchFile calls changed whenever the content of the config file changes, until the context is canceled.
+//
+// The directory of the config file is watched instead of the file, so files replaced by editors or
+// updated through symlinks, like Kubernetes ConfigMaps, are noticed as well.
+//
+// Parameters:
+//   - ctx: context.Context
+//     The context which stops watching when canceled.
+//   - configPath: string
+//     The path to the config file.
+//   - changed: func()
+//     The function called after the config file changed. It is called from another goroutine.
+//
+// Returns:
+//   - error
+//     An error if the directory of the config file cannot be watched.
+func WatchFile(ctx context.Context, configPath string, changed func()) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("Unable to create file watcher: %s", err)
+	}
+	if err := watcher.Add(filepath.Dir(configPath)); err != nil {
+		watcher.Close()
+		return fmt.Errorf("Unable to watch config directory: %s", err)
+	}
+
+	content, _ := os.ReadFile(configPath)
+
+	go func() {
+		defer watcher.Close()
+
+		// Compare the content once the directory was not changed for watchDelay
+		timer := time.NewTimer(watchDelay)
+		timer.Stop()
+		defer timer.Stop()
+
+		for {
+			select {
+			case _, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				timer.Reset(watchDelay)
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				timer.Reset(watchDelay)
+			case <-timer.C:
+				current, err := os.ReadFile(configPath)
+				if err != nil || bytes.Equal(current, content) {
+					continue
+				}
+				content = current
+				changed()
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
@@ -0,0 +1,178 @@
+package config
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newReloadConfig returns a parsed configuration and the path to a config file to reload it from.
+func newReloadConfig(t *testing.T) (*Config, string) {
+	t.Helper()
+
+	c := &Config{
+		Server: Server{Web: Web{Address: "localhost:8080", Theme: "auto"}},
+		Storage: Storage{
+			Directory: t.TempDir(),
+			Name:      ".vimbin",
+			History:   History{MaxRevisions: 100},
+		},
+	}
+	c.Server.Api.Token.Set("token")
+	c.Server.Api.Tokens = []NamedToken{{Name: "ci", Scopes: []string{"read"}}}
+	c.Server.Api.Tokens[0].Token.Set("ci-token")
+	assert.NoError(t, c.Parse())
+
+	return c, path.Join(t.TempDir(), "config.yaml")
+}
+
+func TestReload(t *testing.T) {
+	t.Run("Settings are applied", func(t *testing.T) {
+		c, configPath := newReloadConfig(t)
+		assert.NoError(t, os.WriteFile(configPath, []byte(`
+server:
+  web:
+    darkTheme: mocha
+    sessionTTL: 1h
+  api:
+    token: rotated
+    tokens:
+      - name: deploy
+        token: deploy-token
+        scopes: [write]
+storage:
+  history:
+    maxRevisions: 10
+`), 0644))
+
+		auth, err := c.Reload(configPath, "")
+		assert.NoError(t, err)
+
+		assert.Equal(t, "mocha", c.Web().DarkTheme)
+		assert.Equal(t, time.Hour, c.Web().SessionTTL)
+		assert.Equal(t, 10, c.History().MaxRevisions)
+		assert.Equal(t, time.Hour, auth.SessionTTL)
+
+		// The named token removed from the config file is revoked
+		assert.Len(t, auth.Tokens, 2)
+		assert.Equal(t, "rotated", auth.Tokens[0].Value)
+		assert.Equal(t, "deploy", auth.Tokens[1].Name)
+	})
+
+	t.Run("Invalid configuration is rejected", func(t *testing.T) {
+		c, configPath := newReloadConfig(t)
+
+		for name, content := range map[string]string{
+			"theme":         "server:\n  web:\n    theme: neon\n",
+			"token":         "server:\n  api:\n    tokens:\n      - name: ci\n        token: token\n        scopes: [read]\n",
+			"scope":         "server:\n  api:\n    tokens:\n      - name: ci\n        token: other\n        scopes: [root]\n",
+			"syntax":        "server: [",
+			"missing scope": "server:\n  api:\n    tokens:\n      - name: ci\n        token: other\n",
+		} {
+			assert.NoError(t, os.WriteFile(configPath, []byte(content), 0644))
+			_, err := c.Reload(configPath, "")
+			assert.Error(t, err, name)
+		}
+
+		_, err := c.Reload(path.Join(t.TempDir(), "missing.yaml"), "")
+		assert.Error(t, err)
+
+		assert.Equal(t, "token", c.Server.Api.Token.Get())
+		assert.Equal(t, "ci", c.Server.Api.Tokens[0].Name)
+	})
+
+	t.Run("Settings requiring a restart are kept", func(t *testing.T) {
+		c, configPath := newReloadConfig(t)
+		directory := c.Storage.Directory
+		assert.NoError(t, os.WriteFile(configPath, []byte(`
+server:
+  web:
+    address: localhost:9090
+    persistInterval: 1s
+storage:
+  directory: /elsewhere
+`), 0644))
+
+		_, err := c.Reload(configPath, "")
+		assert.NoError(t, err)
+
+		assert.Equal(t, "localhost:8080", c.Web().Address)
+		assert.Equal(t, time.Second, c.Web().PersistInterval)
+		assert.Equal(t, directory, c.Storage.Directory)
+	})
+
+	t.Run("Token given on the command line takes precedence", func(t *testing.T) {
+		c, configPath := newReloadConfig(t)
+		assert.NoError(t, os.WriteFile(configPath, []byte("server:\n  api:\n    token: from-file\n"), 0644))
+
+		auth, err := c.Reload(configPath, "from-flag")
+		assert.NoError(t, err)
+		assert.Equal(t, "from-flag", auth.Tokens[0].Value)
+	})
+
+	t.Run("Token is kept if the config file has none", func(t *testing.T) {
+		c, configPath := newReloadConfig(t)
+		assert.NoError(t, os.WriteFile(configPath, []byte("server:\n  api:\n    token: \"\"\n"), 0644))
+
+		auth, err := c.Reload(configPath, "")
+		assert.NoError(t, err)
+		assert.Equal(t, "token", auth.Tokens[0].Value)
+		assert.Equal(t, "token", c.Server.Api.Token.Get())
+	})
+}
+
+func TestDiffSettings(t *testing.T) {
+	current := &Config{}
+	current.Server.Web.Theme = "auto"
+	current.Server.Api.Token.Set("secret-1")
+	current.Server.Api.Tokens = []NamedToken{{Name: "ci", Scopes: []string{"read"}}, {Name: "old", Scopes: []string{"read"}}}
+
+	next := &Config{}
+	next.Server.Web.Theme = "latte"
+	next.Server.Api.Token.Set("secret-2")
+	next.Server.Api.Tokens = []NamedToken{{Name: "ci", Scopes: []string{"read", "append"}}, {Name: "new", Scopes: []string{"write"}}}
+	next.Server.Api.Tokens[0].Token.Set("secret-3")
+
+	changes := diffSettings(current, next)
+	assert.Equal(t, []string{
+		"server.web.theme: auto -> latte",
+		"server.api.token: changed",
+		"server.api.tokens: changed the value of 'ci'",
+		"server.api.tokens: changed the scopes of 'ci': [read] -> [read append]",
+		"server.api.tokens: added 'new' with scopes [write]",
+		"server.api.tokens: removed 'old'",
+	}, changes)
+	for _, change := range changes {
+		assert.NotContains(t, change, "secret")
+	}
+}
+
+func TestWatchFile(t *testing.T) {
+	configPath := path.Join(t.TempDir(), "config.yaml")
+	assert.NoError(t, os.WriteFile(configPath, []byte("server: {}\n"), 0644))
+
+	changed := make(chan struct{}, 10)
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	assert.NoError(t, WatchFile(ctx, configPath, func() { changed <- struct{}{} }))
+
+	// Files next to the config file and writes without changes are ignored
+	assert.NoError(t, os.WriteFile(path.Join(path.Dir(configPath), "other"), []byte("content"), 0644))
+	assert.NoError(t, os.WriteFile(configPath, []byte("server: {}\n"), 0644))
+	select {
+	case <-changed:
+		t.Fatal("Unchanged config file was reported")
+	case <-time.After(3 * watchDelay):
+	}
+
+	assert.NoError(t, os.WriteFile(configPath, []byte("server:\n  web: {}\n"), 0644))
+	select {
+	case <-changed:
+	case <-time.After(5 * time.Second):
+		t.Fatal("Change was not reported")
+	}
+}
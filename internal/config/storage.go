@@ -0,0 +1,29 @@
+package config
+
+import (
+	"fmt"
+	"io"
+)
+
+// Close closes the audit log and the storage backend, if it holds resources like an open database.
+// Nothing may be read or written afterwards.
+//
+// Returns:
+//   - error
+//     An error if the audit log or the storage backend cannot be closed.
+func (s *Storage) Close() error {
+	if s.Audit.Log != nil {
+		if err := s.Audit.Log.Close(); err != nil {
+			return fmt.Errorf("Unable to close audit log: %s", err)
+		}
+		s.Audit.Log = nil
+	}
+
+	if closer, ok := s.Store.(io.Closer); ok {
+		if err := closer.Close(); err != nil {
+			return fmt.Errorf("Unable to close storage backend: %s", err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,35 @@
+package config
+
+import (
+	"path"
+	"testing"
+	"vimbin/internal/audit"
+	"vimbin/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestStorageClose(t *testing.T) {
+	t.Run("Database and audit log are closed", func(t *testing.T) {
+		tempDir := t.TempDir()
+		store, err := storage.New(storage.BackendBolt, tempDir, ".vimbin")
+		assert.NoError(t, err)
+		auditLog, err := audit.Open(path.Join(tempDir, "audit.log"), audit.KeyedHash(nil))
+		assert.NoError(t, err)
+
+		s := Storage{Store: store, Audit: Audit{Log: auditLog}}
+		assert.NoError(t, s.Close())
+		assert.Nil(t, s.Audit.Log)
+		assert.Error(t, store.Put(DefaultBin, "content"), "the database is closed")
+
+		// The database can be opened again
+		store, err = storage.New(storage.BackendBolt, tempDir, ".vimbin")
+		assert.NoError(t, err)
+		assert.NoError(t, (&Storage{Store: store}).Close())
+	})
+
+	t.Run("Backends without resources", func(t *testing.T) {
+		s := Storage{Store: storage.NewMemory()}
+		assert.NoError(t, s.Close())
+	})
+}
@@ -1,8 +1,11 @@
 package config
 
 import (
+	"html/template"
 	"sync"
-	"text/template"
+	"time"
+	"vimbin/internal/audit"
+	"vimbin/internal/storage"
 	"vimbin/internal/utils"
 )
 
@@ -15,17 +18,57 @@ type Config struct {
 	HtmlTemplate *template.Template `mapstructure:"-"`       // HtmlTemplate contains the HTML template content.
 	Server       Server             `mapstructure:"server"`  // Server represents the server configuration.
 	Storage      Storage            `mapstructure:"storage"` // Storage represents the storage configuration.
+	mutex        sync.RWMutex       // mutex protects the settings changed by Reload while the server runs.
+}
+
+// Web returns the web configuration. It is safe for concurrent use with Reload.
+//
+// Returns:
+//   - Web
+//     A copy of the web configuration.
+func (c *Config) Web() Web {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.Server.Web
+}
+
+// History returns the revision history configuration. It is safe for concurrent use with Reload.
+//
+// Returns:
+//   - History
+//     A copy of the revision history configuration.
+func (c *Config) History() History {
+	c.mutex.RLock()
+	defer c.mutex.RUnlock()
+	return c.Storage.History
 }
 
 // Web represents the web configuration.
 type Web struct {
-	Theme      string `mapstructure:"server"`     // Theme is the theme to use for the web interface.
-	DarkTheme  string `mapstructure:"darkTheme"`  // DarkTheme is the theme to use for the web interface when dark mode is enabled.
-	LightTheme string `mapstructure:"lightTheme"` // LightTheme is the theme to use for the web interface when light mode is enabled.
-	Address    string `mapstructure:"address"`    // Address is the address to listen on for HTTP requests.
+	Theme           string        `mapstructure:"theme"`           // Theme is the theme to use for the web interface.
+	DarkTheme       string        `mapstructure:"darkTheme"`       // DarkTheme is the theme to use for the web interface when dark mode is enabled.
+	LightTheme      string        `mapstructure:"lightTheme"`      // LightTheme is the theme to use for the web interface when light mode is enabled.
+	Address         string        `mapstructure:"address"`         // Address is the address to listen on for HTTP requests.
+	AdminAddress    string        `mapstructure:"adminAddress"`    // AdminAddress is the address to serve metrics on. If empty, metrics are served on Address.
+	PersistInterval time.Duration `mapstructure:"persistInterval"` // PersistInterval is the interval in which collaborative edits are written to the storage backend.
+	ShutdownDelay   time.Duration `mapstructure:"shutdownDelay"`   // ShutdownDelay is how long the readiness probe fails before the server stops on shutdown.
+	RequireAuth     bool          `mapstructure:"requireAuth"`     // RequireAuth requires logging in to open the editor.
+	SessionTTL      time.Duration `mapstructure:"sessionTTL"`      // SessionTTL is how long a browser stays logged in.
+	MaxBodySize     string        `mapstructure:"maxBodySize"`     // MaxBodySize is the maximum size of request bodies, e.g. '10MiB'. '0' disables the limit.
+	MaxBodyBytes    int64         `mapstructure:"-"`               // MaxBodyBytes is MaxBodySize in bytes, or 0 if request bodies are not limited.
+	TLS             TLS           `mapstructure:"tls"`             // TLS represents the TLS configuration.
 }
 
-// Token represents the API token.
+// TLS represents the TLS configuration.
+type TLS struct {
+	CertFile          string `mapstructure:"certFile"`          // CertFile is the path to the certificate. HTTPS is served if set.
+	KeyFile           string `mapstructure:"keyFile"`           // KeyFile is the path to the private key of the certificate.
+	ClientCAFile      string `mapstructure:"clientCAFile"`      // ClientCAFile is the path to the CA bundle client certificates are verified against.
+	RequireClientCert bool   `mapstructure:"requireClientCert"` // RequireClientCert rejects connections without a valid client certificate.
+}
+
+// Token represents the API token. The value is either the token itself or a hash of it,
+// see server.HashToken.
 type Token struct {
 	value string
 }
@@ -69,23 +112,47 @@ func (t *Token) Generate(len int) error {
 
 // Api represents the api configuration.
 type Api struct {
-	Token              Token  `mapstructure:"token"`              // Token is the API token.
-	SkipInsecureVerify bool   `mapstructure:"skipInsecureVerify"` // SkipInsecureVerify skips the verification of TLS certificates.
-	Address            string `mapstructure:"address"`            // Address is the address to push/fetch content from.
+	Token              Token        `mapstructure:"token"`              // Token is the API token. It is granted the admin scope.
+	Tokens             []NamedToken `mapstructure:"tokens"`             // Tokens are additional API tokens with restricted scopes.
+	SkipInsecureVerify bool         `mapstructure:"skipInsecureVerify"` // SkipInsecureVerify skips the verification of TLS certificates.
+	Address            string       `mapstructure:"address"`            // Address is the address to push/fetch content from.
 }
 
 // Server represents the server configuration.
 type Server struct {
-	Web Web `mapstructure:"web"` // Web represents the web configuration.
-	Api Api `mapstructure:"api"` // Api represents the api configuration.
+	Web       Web       `mapstructure:"web"`       // Web represents the web configuration.
+	Api       Api       `mapstructure:"api"`       // Api represents the api configuration.
+	RateLimit RateLimit `mapstructure:"rateLimit"` // RateLimit represents the rate limit configuration.
 }
 
 // Storage represents the storage configuration.
 type Storage struct {
-	Name      string  `mapstructure:"name"`      // Name is the name of the storage file.
-	Directory string  `mapstructure:"directory"` // Directory is the directory path for storage file.
-	Path      string  `mapstructure:"-"`         // Path is the full path to the storage file.
-	Content   Content `mapstructure:"-"`         // Content represents the content stored in the storage file.
+	Name       string          `mapstructure:"name"`       // Name is the name of the storage file.
+	Directory  string          `mapstructure:"directory"`  // Directory is the directory path for storage file.
+	Backend    string          `mapstructure:"backend"`    // Backend is the storage backend to use (file, bolt, sqlite or memory).
+	Path       string          `mapstructure:"-"`          // Path is the full path to the storage file of the default bin.
+	Store      storage.Storage `mapstructure:"-"`          // Store is the storage backend selected with Backend.
+	History    History         `mapstructure:"history"`    // History represents the revision history configuration.
+	Encryption Encryption      `mapstructure:"encryption"` // Encryption represents the encryption at rest configuration.
+	Audit      Audit           `mapstructure:"audit"`      // Audit represents the audit log configuration.
+	Bins       Bins            `mapstructure:"-"`          // Bins holds all bins loaded into memory.
+}
+
+// Encryption represents the encryption at rest configuration.
+type Encryption struct {
+	KeyFile string `mapstructure:"keyFile"` // KeyFile is the path to the file with the keys the storage is encrypted with.
+}
+
+// Audit represents the audit log configuration.
+type Audit struct {
+	Output string     `mapstructure:"output"` // Output is 'stdout', 'syslog' or the path of a file. Empty disables the audit log.
+	Log    *audit.Log `mapstructure:"-"`      // Log records the content mutations, or is nil if the audit log is disabled.
+}
+
+// History represents the revision history configuration.
+type History struct {
+	MaxRevisions int           `mapstructure:"maxRevisions"` // MaxRevisions is the maximum number of revisions kept per bin. 0 keeps all revisions.
+	MaxAge       time.Duration `mapstructure:"maxAge"`       // MaxAge is the maximum age of revisions. 0 keeps revisions forever.
 }
 
 // Content represents the content stored in the storage with thread-safe methods.
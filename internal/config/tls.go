@@ -0,0 +1,34 @@
+package config
+
+import (
+	"fmt"
+	"os"
+)
+
+// validate checks that the TLS configuration is complete and its files exist.
+//
+// Returns:
+//   - error
+//     An error describing the first problem found, if any.
+func (t *TLS) validate() error {
+	if (t.CertFile == "") != (t.KeyFile == "") {
+		return fmt.Errorf("Both a certificate and a key file are required")
+	}
+	if t.ClientCAFile != "" && t.CertFile == "" {
+		return fmt.Errorf("Client certificates require a server certificate")
+	}
+	if t.RequireClientCert && t.ClientCAFile == "" {
+		return fmt.Errorf("Requiring client certificates requires a client CA bundle")
+	}
+
+	for _, file := range []string{t.CertFile, t.KeyFile, t.ClientCAFile} {
+		if file == "" {
+			continue
+		}
+		if _, err := os.Stat(file); err != nil {
+			return fmt.Errorf("Unable to read '%s': %s", file, err)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,38 @@
+package config
+
+import (
+	"os"
+	"path"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestValidateTLS(t *testing.T) {
+	dir := t.TempDir()
+	certFile := path.Join(dir, "cert.pem")
+	keyFile := path.Join(dir, "key.pem")
+	assert.NoError(t, os.WriteFile(certFile, []byte("cert"), 0600))
+	assert.NoError(t, os.WriteFile(keyFile, []byte("key"), 0600))
+
+	t.Run("Plain HTTP", func(t *testing.T) {
+		assert.NoError(t, (&TLS{}).validate())
+	})
+
+	t.Run("Certificate and key", func(t *testing.T) {
+		assert.NoError(t, (&TLS{CertFile: certFile, KeyFile: keyFile}).validate())
+	})
+
+	t.Run("Certificate without key", func(t *testing.T) {
+		assert.EqualError(t, (&TLS{CertFile: certFile}).validate(), "Both a certificate and a key file are required")
+	})
+
+	t.Run("Required client certificates without CA bundle", func(t *testing.T) {
+		assert.EqualError(t, (&TLS{CertFile: certFile, KeyFile: keyFile, RequireClientCert: true}).validate(), "Requiring client certificates requires a client CA bundle")
+	})
+
+	t.Run("Missing CA bundle", func(t *testing.T) {
+		err := (&TLS{CertFile: certFile, KeyFile: keyFile, ClientCAFile: path.Join(dir, "ca.pem")}).validate()
+		assert.ErrorContains(t, err, "ca.pem")
+	})
+}
@@ -0,0 +1,127 @@
+package config
+
+import (
+	"fmt"
+	"vimbin/internal/server"
+	"vimbin/internal/storage"
+)
+
+// NamedToken represents an additional API token with restricted permissions.
+type NamedToken struct {
+	Name   string   `mapstructure:"name"`   // Name identifies the token in logs and revisions.
+	Token  Token    `mapstructure:"token"`  // Token is the secret sent in the X-API-Token header.
+	Scopes []string `mapstructure:"scopes"` // Scopes are the permissions granted to the token (read, append, write or admin).
+	Bins   []string `mapstructure:"bins"`   // Bins restricts the token to the given bins. An empty list allows all bins.
+}
+
+// validateTokens checks the named API tokens.
+//
+// Names and token values must be unique and must not clash with the default token,
+// every token needs at least one supported scope, and bin restrictions must be valid bin names.
+// Hashed tokens, including the default token, must be valid hashes.
+//
+// Returns:
+//   - error
+//     An error describing the first invalid token, if any.
+func (a *Api) validateTokens() error {
+	if err := server.ValidateTokenHash(a.Token.Get()); err != nil {
+		return fmt.Errorf("Token '%s': %s", server.DefaultTokenName, err)
+	}
+
+	names := map[string]bool{server.DefaultTokenName: true}
+	values := map[string]bool{a.Token.Get(): true}
+
+	for i, token := range a.Tokens {
+		if token.Name == "" {
+			return fmt.Errorf("Token #%d has no name", i+1)
+		}
+		if names[token.Name] {
+			return fmt.Errorf("Token name '%s' is used more than once or is reserved", token.Name)
+		}
+		names[token.Name] = true
+
+		if token.Token.Get() == "" {
+			return fmt.Errorf("Token '%s' has no value", token.Name)
+		}
+		if values[token.Token.Get()] {
+			return fmt.Errorf("Token '%s' has the same value as another token", token.Name)
+		}
+		values[token.Token.Get()] = true
+
+		if err := server.ValidateTokenHash(token.Token.Get()); err != nil {
+			return fmt.Errorf("Token '%s': %s", token.Name, err)
+		}
+
+		if len(token.Scopes) == 0 {
+			return fmt.Errorf("Token '%s' has no scopes", token.Name)
+		}
+		for _, scope := range token.Scopes {
+			if !isSupportedScope(scope) {
+				return fmt.Errorf("Token '%s' has unsupported scope '%s'. Supported scopes are: %s", token.Name, scope, server.SupportedScopes)
+			}
+		}
+
+		for _, bin := range token.Bins {
+			if !storage.IsValidBinName(bin) {
+				return fmt.Errorf("Token '%s' has invalid bin name '%s'", token.Name, bin)
+			}
+		}
+	}
+
+	return nil
+}
+
+// AccessTokens returns all API tokens accepted by the server.
+//
+// The token configured with 'server.api.token' is named 'default' and granted the admin scope.
+//
+// Returns:
+//   - []server.Token
+//     The default token followed by the named tokens.
+func (a *Api) AccessTokens() []server.Token {
+	tokens := []server.Token{{
+		Name:   server.DefaultTokenName,
+		Value:  a.Token.Get(),
+		Scopes: []server.Scope{server.ScopeAdmin},
+	}}
+
+	for _, token := range a.Tokens {
+		scopes := make([]server.Scope, 0, len(token.Scopes))
+		for _, scope := range token.Scopes {
+			scopes = append(scopes, server.Scope(scope))
+		}
+
+		tokens = append(tokens, server.Token{
+			Name:   token.Name,
+			Value:  token.Token.Get(),
+			Scopes: scopes,
+			Bins:   token.Bins,
+		})
+	}
+
+	return tokens
+}
+
+// Auth returns the authentication configuration of the server.
+//
+// Returns:
+//   - server.Auth
+//     The API tokens, browser session settings and rate limits.
+func (s *Server) Auth() server.Auth {
+	return server.Auth{
+		Tokens:      s.Api.AccessTokens(),
+		RequireAuth: s.Web.RequireAuth,
+		SessionTTL:  s.Web.SessionTTL,
+		Limits:      s.RateLimit.Limits(),
+	}
+}
+
+// isSupportedScope checks if a scope can be granted to an API token.
+func isSupportedScope(scope string) bool {
+	for _, supported := range server.SupportedScopes {
+		if scope == string(supported) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,82 @@
+package config
+
+import (
+	"testing"
+	"vimbin/internal/server"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newNamedToken creates a named token for tests.
+func newNamedToken(name, value string, scopes ...string) NamedToken {
+	token := NamedToken{Name: name, Scopes: scopes}
+	token.Token.Set(value)
+	return token
+}
+
+func TestValidateTokens(t *testing.T) {
+	newApi := func(tokens ...NamedToken) *Api {
+		api := &Api{Tokens: tokens}
+		api.Token.Set("admin-token")
+		return api
+	}
+
+	t.Run("Valid tokens", func(t *testing.T) {
+		api := newApi(newNamedToken("ci", "ci-token", "append"), newNamedToken("dashboard", "dashboard-token", "read"))
+		assert.NoError(t, api.validateTokens())
+	})
+
+	t.Run("Missing name", func(t *testing.T) {
+		api := newApi(newNamedToken("", "ci-token", "append"))
+		assert.EqualError(t, api.validateTokens(), "Token #1 has no name")
+	})
+
+	t.Run("Reserved name", func(t *testing.T) {
+		api := newApi(newNamedToken("default", "ci-token", "append"))
+		assert.EqualError(t, api.validateTokens(), "Token name 'default' is used more than once or is reserved")
+	})
+
+	t.Run("Duplicate value", func(t *testing.T) {
+		api := newApi(newNamedToken("ci", "admin-token", "append"))
+		assert.EqualError(t, api.validateTokens(), "Token 'ci' has the same value as another token")
+	})
+
+	t.Run("Unsupported scope", func(t *testing.T) {
+		api := newApi(newNamedToken("ci", "ci-token", "delete"))
+		assert.EqualError(t, api.validateTokens(), "Token 'ci' has unsupported scope 'delete'. Supported scopes are: [read append write admin]")
+	})
+
+	t.Run("Invalid bin name", func(t *testing.T) {
+		token := newNamedToken("ci", "ci-token", "append")
+		token.Bins = []string{"../logs"}
+		assert.EqualError(t, newApi(token).validateTokens(), "Token 'ci' has invalid bin name '../logs'")
+	})
+
+	t.Run("Hashed tokens", func(t *testing.T) {
+		hash, err := server.HashToken("ci-token", server.HashSHA256)
+		assert.NoError(t, err)
+		assert.NoError(t, newApi(newNamedToken("ci", hash, "append")).validateTokens())
+
+		api := newApi(newNamedToken("ci", "sha256:abc", "append"))
+		assert.EqualError(t, api.validateTokens(), "Token 'ci': Invalid sha256 hash: must be 64 hexadecimal characters")
+
+		api = newApi()
+		api.Token.Set("$argon2id$v=19$m=19456")
+		assert.ErrorContains(t, api.validateTokens(), "Token 'default': Invalid argon2id hash")
+	})
+}
+
+func TestAccessTokens(t *testing.T) {
+	t.Run("Default token is granted the admin scope", func(t *testing.T) {
+		token := newNamedToken("ci", "ci-token", "append")
+		token.Bins = []string{"logs"}
+		api := &Api{Tokens: []NamedToken{token}}
+		api.Token.Set("admin-token")
+
+		tokens := api.AccessTokens()
+		assert.Equal(t, []server.Token{
+			{Name: server.DefaultTokenName, Value: "admin-token", Scopes: []server.Scope{server.ScopeAdmin}},
+			{Name: "ci", Value: "ci-token", Scopes: []server.Scope{server.ScopeAppend}, Bins: []string{"logs"}},
+		}, tokens)
+	})
+}
@@ -1,18 +1,34 @@
 package config
 
 import (
+	"errors"
 	"fmt"
 	"os"
 	"reflect"
 	"strings"
+	"time"
+	"vimbin/internal/storage"
 
-	"github.com/mitchellh/mapstructure"
+	"github.com/go-viper/mapstructure/v2"
 	"github.com/rs/zerolog/log"
 )
 
 // filePermission represents the default file permission used in the application.
 const filePermission = 0644
 
+// DefaultPersistInterval is the default interval in which collaborative edits are written to the storage backend.
+const DefaultPersistInterval = 5 * time.Second
+
+// DefaultShutdownDelay is how long the readiness probe fails before the server stops if not configured otherwise.
+const DefaultShutdownDelay = 5 * time.Second
+
+// DefaultSessionTTL is how long a browser stays logged in if not configured otherwise.
+const DefaultSessionTTL = 24 * time.Hour
+
+// DefaultMaxBodySize is the maximum size of request bodies if not configured otherwise. It matches the default
+// '--max-size' of the push command.
+const DefaultMaxBodySize = "10MiB"
+
 // defaultExample is the default content example used when creating the storage file.
 const defaultExample = `
 #include "syscalls.h"
@@ -85,6 +101,43 @@ func checkStorageFile(filePath string) error {
 	return nil
 }
 
+// checkStorage makes sure the default bin exists in the storage backend.
+//
+// For the file backend the storage file is checked with checkStorageFile, unless it is
+// encrypted at rest. All other backends get the default content stored if the default bin
+// does not exist yet.
+//
+// Parameters:
+//   - backend: string
+//     The name of the storage backend.
+//   - store: storage.Storage
+//     The storage backend.
+//   - filePath: string
+//     The path to the storage file of the default bin, used by the file backend.
+//
+// Returns:
+//   - error
+//     An error if unable to check or create the default bin.
+func checkStorage(backend string, store storage.Storage, filePath string) error {
+	// Encrypted storage files must be created encrypted through the backend
+	if _, encrypted := store.(*storage.Encrypted); backend == storage.BackendFile && !encrypted {
+		return checkStorageFile(filePath)
+	}
+
+	if _, err := store.Stat(storage.DefaultBin); err == nil {
+		return nil
+	} else if !errors.Is(err, storage.ErrNotFound) {
+		return fmt.Errorf("Unable to check default bin: %s", err)
+	}
+
+	log.Debug().Msg("Default bin not found; creating it with default content")
+	if err := store.Put(storage.DefaultBin, defaultExample); err != nil {
+		return fmt.Errorf("Unable to create default bin: %s", err)
+	}
+
+	return nil
+}
+
 // customTokenDecodeHook is a custom mapstructure DecodeHookFunc for decoding YAML data
 // into the Token struct. It converts the data into a string and initializes a Token with it.
 //
@@ -97,6 +97,6 @@ func TestCustomTokenDecodeHook(t *testing.T) {
 		result, err := customTokenDecodeHook(fromType, toType, data)
 		assert.Error(t, err)
 		assert.Nil(t, result)
-		assert.EqualError(t, err, "Unable to decode Token. '' expected type 'string', got unconvertible type 'int', value: '42'")
+		assert.EqualError(t, err, "Unable to decode Token. '' expected type 'string', got unconvertible type 'int'")
 	})
 }
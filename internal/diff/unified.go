@@ -0,0 +1,337 @@
+package diff
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+
+	"github.com/pmezard/go-difflib/difflib"
+)
+
+// noNewlineMarker follows a line of a unified diff which does not end with a newline.
+const noNewlineMarker = `\ No newline at end of file`
+
+// hunkHeader matches the header of a hunk, e.g. '@@ -1,3 +1,4 @@'. Omitted lengths are 1.
+var hunkHeader = regexp.MustCompile(`^@@ -(\d+)(?:,(\d+))? \+(\d+)(?:,(\d+))? @@`)
+
+// Hunk is a change of a range of lines, as found in a unified diff.
+type Hunk struct {
+	OldStart int      // OldStart is the first line of the range in the old content. If OldLines is 0, it is the line before the range.
+	OldLines int      // OldLines is the number of lines of the range in the old content.
+	NewStart int      // NewStart is the first line of the range in the new content. If NewLines is 0, it is the line before the range.
+	NewLines int      // NewLines is the number of lines of the range in the new content.
+	Lines    []string // Lines are the lines prefixed with ' ', '-' or '+'. They end with a newline unless the line has none.
+}
+
+// String formats the hunk like in a unified diff.
+func (h Hunk) String() string {
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "@@ -%s +%s @@\n", formatRange(h.OldStart, h.OldLines), formatRange(h.NewStart, h.NewLines))
+	for _, line := range h.Lines {
+		builder.WriteString(line)
+		if !strings.HasSuffix(line, "\n") {
+			builder.WriteString("\n" + noNewlineMarker + "\n")
+		}
+	}
+	return builder.String()
+}
+
+// side returns the lines of the old or new content covered by the hunk, without their prefix.
+func (h Hunk) side(removed byte) []string {
+	var lines []string
+	for _, line := range h.Lines {
+		if line[0] == ' ' || line[0] == removed {
+			lines = append(lines, line[1:])
+		}
+	}
+	return lines
+}
+
+// Unified creates a unified diff, which changes one content into another.
+//
+// Parameters:
+//   - from: string
+//     The old content.
+//   - to: string
+//     The new content.
+//   - fromName: string
+//     The name of the old content in the '---' header.
+//   - toName: string
+//     The name of the new content in the '+++' header.
+//   - context: int
+//     The number of unchanged lines shown around every change.
+//
+// Returns:
+//   - string
+//     The unified diff, or an empty string if the contents are equal.
+func Unified(from, to, fromName, toName string, context int) string {
+	if from == to {
+		return ""
+	}
+
+	a, b := splitLines(from), splitLines(to)
+	// Without junk heuristics, so frequent lines like empty ones are matched as well
+	matcher := difflib.NewMatcherWithJunk(a, b, false, nil)
+
+	var builder strings.Builder
+	fmt.Fprintf(&builder, "--- %s\n+++ %s\n", fromName, toName)
+
+	for _, group := range matcher.GetGroupedOpCodes(context) {
+		first, last := group[0], group[len(group)-1]
+		hunk := Hunk{
+			OldStart: first.I1 + 1,
+			OldLines: last.I2 - first.I1,
+			NewStart: first.J1 + 1,
+			NewLines: last.J2 - first.J1,
+		}
+		// Empty ranges start at the line before
+		if hunk.OldLines == 0 {
+			hunk.OldStart--
+		}
+		if hunk.NewLines == 0 {
+			hunk.NewStart--
+		}
+
+		for _, code := range group {
+			if code.Tag == 'e' {
+				for _, line := range a[code.I1:code.I2] {
+					hunk.Lines = append(hunk.Lines, " "+line)
+				}
+				continue
+			}
+			for _, line := range a[code.I1:code.I2] {
+				hunk.Lines = append(hunk.Lines, "-"+line)
+			}
+			for _, line := range b[code.J1:code.J2] {
+				hunk.Lines = append(hunk.Lines, "+"+line)
+			}
+		}
+
+		builder.WriteString(hunk.String())
+	}
+
+	return builder.String()
+}
+
+// Parse parses the hunks of a unified diff, as produced by 'diff -u' or 'git diff'.
+// Headers like '---', '+++' or 'diff --git' are skipped. The diff must change a single file.
+//
+// Parameters:
+//   - patch: string
+//     The unified diff.
+//
+// Returns:
+//   - []Hunk
+//     The hunks in the order of the diff.
+//   - error
+//     An error if the diff is malformed, contains no hunks or changes more than one file.
+func Parse(patch string) ([]Hunk, error) {
+	if !strings.HasSuffix(patch, "\n") {
+		patch += "\n"
+	}
+	lines := strings.SplitAfter(patch, "\n")
+	lines = lines[:len(lines)-1]
+
+	var hunks []Hunk
+	files := 0
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+
+		if strings.HasPrefix(line, "--- ") {
+			if files++; files > 1 {
+				return nil, fmt.Errorf("The diff changes more than one file")
+			}
+			continue
+		}
+		if !strings.HasPrefix(line, "@@ ") {
+			// Headers and trailing garbage are ignored, like 'patch' does
+			continue
+		}
+
+		hunk, err := parseHunkHeader(line)
+		if err != nil {
+			return nil, err
+		}
+		oldRemaining, newRemaining := hunk.OldLines, hunk.NewLines
+
+		for oldRemaining > 0 || newRemaining > 0 {
+			if i++; i >= len(lines) {
+				return nil, fmt.Errorf("Hunk #%d is incomplete", len(hunks)+1)
+			}
+			line := lines[i]
+			if line == "\n" {
+				// Some editors strip the space of empty context lines
+				line = " \n"
+			}
+
+			switch line[0] {
+			case ' ':
+				oldRemaining--
+				newRemaining--
+			case '-':
+				oldRemaining--
+			case '+':
+				newRemaining--
+			case '\\':
+				if err := stripNewline(&hunk, len(hunks)); err != nil {
+					return nil, err
+				}
+				continue
+			default:
+				return nil, fmt.Errorf("Hunk #%d: unexpected line '%s'", len(hunks)+1, strings.TrimSuffix(line, "\n"))
+			}
+			if oldRemaining < 0 || newRemaining < 0 {
+				return nil, fmt.Errorf("Hunk #%d has more lines than its header declares", len(hunks)+1)
+			}
+			hunk.Lines = append(hunk.Lines, line)
+		}
+
+		// The last line of the hunk may not end with a newline
+		if i+1 < len(lines) && strings.HasPrefix(lines[i+1], "\\") {
+			i++
+			if err := stripNewline(&hunk, len(hunks)); err != nil {
+				return nil, err
+			}
+		}
+
+		hunks = append(hunks, hunk)
+	}
+
+	if len(hunks) == 0 {
+		return nil, fmt.Errorf("The diff contains no hunks")
+	}
+
+	return hunks, nil
+}
+
+// Apply applies hunks to content.
+//
+// Every hunk is applied where its removed and context lines match the content exactly. If they do not
+// match at the line given in the header, for example because lines were added above it since the diff
+// was created, the nearest matching position after the previous hunk is used.
+//
+// Parameters:
+//   - content: string
+//     The content to change.
+//   - hunks: []Hunk
+//     The hunks to apply, in the order of the content.
+//
+// Returns:
+//   - string
+//     The changed content, or the unchanged content if a hunk was rejected.
+//   - []Hunk
+//     The hunks which do not apply. Either all hunks are applied or none.
+func Apply(content string, hunks []Hunk) (string, []Hunk) {
+	lines := splitLines(content)
+
+	var result []string
+	var rejected []Hunk
+	position := 0 // position is the first line which was not copied to the result yet.
+	offset := 0   // offset is the number of lines the previous hunk was moved by.
+
+	for _, hunk := range hunks {
+		old := hunk.side('-')
+		expected := hunk.OldStart - 1
+		if hunk.OldLines == 0 {
+			expected = hunk.OldStart
+		}
+
+		at := find(lines, old, position, expected+offset)
+		if at < 0 {
+			rejected = append(rejected, hunk)
+			continue
+		}
+
+		result = append(result, lines[position:at]...)
+		result = append(result, hunk.side('+')...)
+		position = at + len(old)
+		offset = at - expected
+	}
+
+	if len(rejected) > 0 {
+		return content, rejected
+	}
+
+	result = append(result, lines[position:]...)
+	return strings.Join(result, ""), nil
+}
+
+// find returns the position of the lines in the content nearest to the expected position, but not before start.
+// Returns -1 if the lines are not found.
+func find(content, lines []string, start, expected int) int {
+	last := len(content) - len(lines)
+	if len(lines) == 0 {
+		// Without context, only the position given in the header is safe
+		if expected < start || expected > last {
+			return -1
+		}
+		return expected
+	}
+
+	for distance := 0; expected-distance >= start || expected+distance <= last; distance++ {
+		for _, at := range []int{expected - distance, expected + distance} {
+			if at >= start && at <= last && matches(content[at:at+len(lines)], lines) {
+				return at
+			}
+		}
+	}
+
+	return -1
+}
+
+// matches reports whether two lists of lines are equal.
+func matches(a, b []string) bool {
+	for i := range a {
+		if a[i] != b[i] {
+			return false
+		}
+	}
+	return true
+}
+
+// parseHunkHeader parses a header like '@@ -1,3 +1,4 @@' into an empty hunk.
+func parseHunkHeader(line string) (Hunk, error) {
+	match := hunkHeader.FindStringSubmatch(line)
+	if match == nil {
+		return Hunk{}, fmt.Errorf("Invalid hunk header '%s'", strings.TrimSuffix(line, "\n"))
+	}
+
+	numbers := make([]int, 4)
+	for i, value := range match[1:] {
+		numbers[i] = 1
+		if value != "" {
+			numbers[i], _ = strconv.Atoi(value)
+		}
+	}
+
+	return Hunk{OldStart: numbers[0], OldLines: numbers[1], NewStart: numbers[2], NewLines: numbers[3]}, nil
+}
+
+// stripNewline removes the newline of the last line of a hunk, following a '\ No newline at end of file' marker.
+func stripNewline(hunk *Hunk, index int) error {
+	if len(hunk.Lines) == 0 {
+		return fmt.Errorf("Hunk #%d starts with '%s'", index+1, noNewlineMarker)
+	}
+	last := len(hunk.Lines) - 1
+	hunk.Lines[last] = strings.TrimSuffix(hunk.Lines[last], "\n")
+	return nil
+}
+
+// formatRange formats a range of a hunk header. The length is omitted if it is 1.
+func formatRange(start, length int) string {
+	if length == 1 {
+		return strconv.Itoa(start)
+	}
+	return fmt.Sprintf("%d,%d", start, length)
+}
+
+// splitLines splits text into lines, which keep their newline.
+func splitLines(text string) []string {
+	lines := strings.SplitAfter(text, "\n")
+	if lines[len(lines)-1] == "" {
+		lines = lines[:len(lines)-1]
+	}
+	return lines
+}
@@ -0,0 +1,100 @@
+package diff
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestUnified(t *testing.T) {
+	t.Run("Changed line with context", func(t *testing.T) {
+		patch := Unified("a\nb\nc\nd\ne\n", "a\nb\nC\nd\ne\n", "old", "new", 1)
+
+		assert.Equal(t, "--- old\n+++ new\n@@ -2,3 +2,3 @@\n b\n-c\n+C\n d\n", patch)
+	})
+
+	t.Run("Missing final newline is marked", func(t *testing.T) {
+		patch := Unified("a\nb", "a\nb\nc", "old", "new", 3)
+
+		assert.Equal(t, "--- old\n+++ new\n@@ -1,2 +1,3 @@\n a\n-b\n\\ No newline at end of file\n+b\n+c\n\\ No newline at end of file\n", patch)
+	})
+
+	t.Run("Content of an empty bin", func(t *testing.T) {
+		patch := Unified("", "new\n", "old", "new", 3)
+
+		assert.Equal(t, "--- old\n+++ new\n@@ -0,0 +1 @@\n+new\n", patch)
+	})
+
+	t.Run("Equal contents", func(t *testing.T) {
+		assert.Equal(t, "", Unified("same\n", "same\n", "old", "new", 3))
+	})
+}
+
+func TestParse(t *testing.T) {
+	t.Run("Git diff with headers", func(t *testing.T) {
+		hunks, err := Parse("diff --git a/notes b/notes\nindex 1234567..89abcde 100644\n--- a/notes\n+++ b/notes\n@@ -1 +1,2 @@\n-old\n+new\n+line\n")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []Hunk{{OldStart: 1, OldLines: 1, NewStart: 1, NewLines: 2, Lines: []string{"-old\n", "+new\n", "+line\n"}}}, hunks)
+	})
+
+	t.Run("Empty context line without space", func(t *testing.T) {
+		hunks, err := Parse("@@ -1,2 +1,2 @@\n\n-old\n+new")
+
+		assert.NoError(t, err)
+		assert.Equal(t, []string{" \n", "-old\n", "+new\n"}, hunks[0].Lines)
+	})
+
+	t.Run("Invalid diffs", func(t *testing.T) {
+		for name, patch := range map[string]string{
+			"no hunks":      "just some text\n",
+			"incomplete":    "@@ -1,2 +1,2 @@\n a\n",
+			"too many":      "@@ -1 +1 @@\n-a\n-b\n+c\n",
+			"unknown":       "@@ -1 +1 @@\n*a\n",
+			"several files": "--- a\n+++ a\n@@ -1 +1 @@\n-a\n+b\n--- b\n+++ b\n@@ -1 +1 @@\n-a\n+b\n",
+		} {
+			_, err := Parse(patch)
+			assert.Error(t, err, name)
+		}
+	})
+}
+
+func TestApply(t *testing.T) {
+	t.Run("Round trip", func(t *testing.T) {
+		for _, contents := range [][2]string{
+			{"a\nb\nc\n", "a\nc\nd\n"},
+			{"a\nb", "a\nb\n"},
+			{"a\nb\n", "a\nb"},
+			{"", "new\ncontent"},
+			{"old\ncontent\n", ""},
+			{"1\n2\n3\n4\n5\n6\n7\n8\n9\n10\n", "0\n1\n2\n3\nfour\n5\n6\n7\n8\n9\nten\n"},
+		} {
+			hunks, err := Parse(Unified(contents[0], contents[1], "old", "new", 3))
+			assert.NoError(t, err)
+
+			content, rejected := Apply(contents[0], hunks)
+			assert.Empty(t, rejected)
+			assert.Equal(t, contents[1], content)
+		}
+	})
+
+	t.Run("Hunk is moved by lines added above", func(t *testing.T) {
+		hunks, err := Parse(Unified("a\nb\nc\n", "a\nB\nc\n", "old", "new", 1))
+		assert.NoError(t, err)
+
+		content, rejected := Apply("new\nlines\na\nb\nc\n", hunks)
+		assert.Empty(t, rejected)
+		assert.Equal(t, "new\nlines\na\nB\nc\n", content)
+	})
+
+	t.Run("Conflicting hunks are rejected and nothing is applied", func(t *testing.T) {
+		hunks, err := Parse(Unified("1\n2\n3\n4\n5\n6\n7\n8\n9\n", "one\n2\n3\n4\n5\n6\n7\n8\nnine\n", "old", "new", 1))
+		assert.NoError(t, err)
+		assert.Len(t, hunks, 2)
+
+		content, rejected := Apply("1\n2\n3\n4\n5\n6\n7\n8\n9 changed\n", hunks)
+		assert.Equal(t, "1\n2\n3\n4\n5\n6\n7\n8\n9 changed\n", content)
+		assert.Equal(t, []Hunk{hunks[1]}, rejected)
+		assert.Equal(t, "@@ -8,2 +8,2 @@\n 8\n-9\n+nine\n", rejected[0].String())
+	})
+}
@@ -0,0 +1,179 @@
+// Package e2e encrypts the content of bins on the client, so the server only ever stores ciphertext.
+//
+// Content is encrypted with AES-256-GCM and stored as text like 'vimbin:e2e:v1:<base64>', where the
+// base64 (standard encoding) data is the 12 byte nonce followed by the ciphertext and its tag.
+// This matches AES-GCM of the Web Crypto API, so the web editor can decrypt the content as well.
+// Keys are 32 random bytes, encoded as unpadded base64url to fit into the fragment of a link.
+package e2e
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"strings"
+)
+
+const (
+	Prefix    = "vimbin:e2e:v1:" // Prefix starts the content of encrypted bins.
+	KeySize   = 32               // KeySize is the size of keys in bytes, selecting AES-256.
+	nonceSize = 12               // nonceSize is the size of the random nonce of every encryption.
+	tagSize   = 16               // tagSize is the size of the authentication tag appended to the ciphertext.
+)
+
+// ErrDecrypt is returned if content cannot be decrypted with the given key.
+var ErrDecrypt = errors.New("Unable to decrypt content. The key is wrong or the content was changed")
+
+// GenerateKey generates a new random key.
+//
+// Returns:
+//   - string
+//     The key, encoded as unpadded base64url.
+//   - error
+//     An error if no random bytes are available.
+func GenerateKey() (string, error) {
+	key := make([]byte, KeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", fmt.Errorf("Unable to generate key: %s", err)
+	}
+
+	return EncodeKey(key), nil
+}
+
+// EncodeKey encodes a key to be passed in a link or stored in a key file.
+//
+// Parameters:
+//   - key: []byte
+//     The key.
+//
+// Returns:
+//   - string
+//     The key, encoded as unpadded base64url.
+func EncodeKey(key []byte) string {
+	return base64.RawURLEncoding.EncodeToString(key)
+}
+
+// ParseKey decodes a key. Links to a bin are accepted as well, the key is taken from
+// the 'key' parameter of their fragment, e.g. 'https://example.com/b/notes#key=...'.
+//
+// Parameters:
+//   - value: string
+//     The encoded key or a link containing it.
+//
+// Returns:
+//   - []byte
+//     The key.
+//   - error
+//     An error if the value is not a valid key.
+func ParseKey(value string) ([]byte, error) {
+	value = strings.TrimSpace(value)
+	if _, fragment, ok := strings.Cut(value, "#"); ok {
+		for _, parameter := range strings.Split(fragment, "&") {
+			if key, ok := strings.CutPrefix(parameter, "key="); ok {
+				value = key
+			}
+		}
+	}
+
+	key, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(value, "="))
+	if err != nil || len(key) != KeySize {
+		return nil, fmt.Errorf("Invalid key. Keys are %d bytes encoded as base64url", KeySize)
+	}
+
+	return key, nil
+}
+
+// IsEncrypted reports whether content was encrypted with Encrypt.
+//
+// Parameters:
+//   - content: string
+//     The content to check.
+//
+// Returns:
+//   - bool
+//     True if the content starts with Prefix followed by data long enough to be encrypted content.
+func IsEncrypted(content string) bool {
+	data, ok := strings.CutPrefix(content, Prefix)
+	if !ok {
+		return false
+	}
+
+	decoded, err := base64.StdEncoding.DecodeString(strings.TrimSpace(data))
+	return err == nil && len(decoded) >= nonceSize+tagSize
+}
+
+// Encrypt encrypts content with a key.
+//
+// Parameters:
+//   - content: string
+//     The plain content.
+//   - key: []byte
+//     The key, as returned by ParseKey.
+//
+// Returns:
+//   - string
+//     The encrypted content, starting with Prefix.
+//   - error
+//     An error if the key is invalid or no random nonce is available.
+func Encrypt(content string, key []byte) (string, error) {
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	nonce := make([]byte, nonceSize, nonceSize+len(content)+tagSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return "", fmt.Errorf("Unable to generate nonce: %s", err)
+	}
+	data := aead.Seal(nonce, nonce, []byte(content), nil)
+
+	return Prefix + base64.StdEncoding.EncodeToString(data), nil
+}
+
+// Decrypt decrypts content encrypted with Encrypt.
+//
+// Parameters:
+//   - content: string
+//     The encrypted content, starting with Prefix.
+//   - key: []byte
+//     The key the content was encrypted with.
+//
+// Returns:
+//   - string
+//     The plain content.
+//   - error
+//     An error if the content is not encrypted, or ErrDecrypt if the key does not match.
+func Decrypt(content string, key []byte) (string, error) {
+	if !IsEncrypted(content) {
+		return "", errors.New("Content is not encrypted")
+	}
+
+	aead, err := newAEAD(key)
+	if err != nil {
+		return "", err
+	}
+
+	data, _ := base64.StdEncoding.DecodeString(strings.TrimSpace(strings.TrimPrefix(content, Prefix)))
+	plain, err := aead.Open(nil, data[:nonceSize], data[nonceSize:], nil)
+	if err != nil {
+		return "", ErrDecrypt
+	}
+
+	return string(plain), nil
+}
+
+// newAEAD creates the AES-256-GCM cipher for a key.
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	if len(key) != KeySize {
+		return nil, fmt.Errorf("Invalid key size %d, expected %d bytes", len(key), KeySize)
+	}
+
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to create cipher: %s", err)
+	}
+
+	return cipher.NewGCM(block)
+}
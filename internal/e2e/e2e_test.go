@@ -0,0 +1,103 @@
+package e2e
+
+import (
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestEncrypt(t *testing.T) {
+	encoded, err := GenerateKey()
+	assert.NoError(t, err)
+	key, err := ParseKey(encoded)
+	assert.NoError(t, err)
+
+	t.Run("Round trip", func(t *testing.T) {
+		for _, content := range []string{"", "s3cr3t", "multiple\nlines\n", "ümlauts and emoji 🔑"} {
+			encrypted, err := Encrypt(content, key)
+			assert.NoError(t, err)
+			assert.True(t, strings.HasPrefix(encrypted, Prefix))
+			assert.True(t, IsEncrypted(encrypted))
+			assert.NotContains(t, encrypted, "s3cr3t")
+
+			decrypted, err := Decrypt(encrypted, key)
+			assert.NoError(t, err)
+			assert.Equal(t, content, decrypted)
+		}
+	})
+
+	t.Run("Every encryption uses a new nonce", func(t *testing.T) {
+		first, _ := Encrypt("same", key)
+		second, _ := Encrypt("same", key)
+		assert.NotEqual(t, first, second)
+	})
+
+	t.Run("Trailing newline is ignored", func(t *testing.T) {
+		encrypted, _ := Encrypt("content", key)
+
+		decrypted, err := Decrypt(encrypted+"\n", key)
+		assert.NoError(t, err)
+		assert.Equal(t, "content", decrypted)
+	})
+
+	t.Run("Wrong key", func(t *testing.T) {
+		encrypted, _ := Encrypt("content", key)
+		other, _ := GenerateKey()
+		otherKey, _ := ParseKey(other)
+
+		_, err := Decrypt(encrypted, otherKey)
+		assert.ErrorIs(t, err, ErrDecrypt)
+	})
+
+	t.Run("Changed content", func(t *testing.T) {
+		encrypted, _ := Encrypt("content", key)
+		data := []byte(encrypted)
+		data[len(Prefix)+20] ^= 1
+
+		_, err := Decrypt(string(data), key)
+		assert.Error(t, err)
+	})
+
+	t.Run("Plain content", func(t *testing.T) {
+		_, err := Decrypt("not encrypted", key)
+		assert.Error(t, err)
+	})
+}
+
+func TestIsEncrypted(t *testing.T) {
+	for content, expected := range map[string]bool{
+		"plain text":                   false,
+		Prefix:                         false,
+		Prefix + "not base64!":         false,
+		Prefix + "c2hvcnQ=":            false,
+		"text before " + Prefix + "AA": false,
+	} {
+		assert.Equal(t, expected, IsEncrypted(content), content)
+	}
+}
+
+func TestParseKey(t *testing.T) {
+	encoded, _ := GenerateKey()
+
+	t.Run("Key", func(t *testing.T) {
+		key, err := ParseKey(encoded + "\n")
+		assert.NoError(t, err)
+		assert.Len(t, key, KeySize)
+	})
+
+	t.Run("Link with key in fragment", func(t *testing.T) {
+		key, err := ParseKey("https://example.com/b/notes#key=" + encoded)
+		assert.NoError(t, err)
+
+		expected, _ := ParseKey(encoded)
+		assert.Equal(t, expected, key)
+	})
+
+	t.Run("Invalid keys", func(t *testing.T) {
+		for _, value := range []string{"", "short", "https://example.com/b/notes", encoded + "AAAA"} {
+			_, err := ParseKey(value)
+			assert.Error(t, err, value)
+		}
+	})
+}
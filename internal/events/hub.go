@@ -0,0 +1,158 @@
+package events
+
+import (
+	"sync"
+	"vimbin/internal/collab"
+)
+
+// Event types sent to subscribers.
+const (
+	TypeContent   = "content"   // TypeContent carries the complete content of a bin, which replaces the content known by the editor.
+	TypeOperation = "operation" // TypeOperation carries a change of the content of a bin.
+	TypeSync      = "sync"      // TypeSync signals that an editor received all changes it missed while it was disconnected.
+	TypeSaved     = "saved"     // TypeSaved signals that the content of a bin was written to the storage backend.
+	TypeCursor    = "cursor"    // TypeCursor carries the cursor position of an editor.
+	TypeLeave     = "leave"     // TypeLeave signals that an editor disconnected.
+)
+
+// subscriberBuffer is the number of events buffered per subscriber.
+const subscriberBuffer = 16
+
+// Event describes a change of a bin.
+type Event struct {
+	Type      string            `json:"type"`                // Type is the type of the event.
+	Bin       string            `json:"bin"`                 // Bin is the name of the bin which changed.
+	Content   string            `json:"content,omitempty"`   // Content is the complete content of the bin.
+	ETag      string            `json:"etag,omitempty"`      // ETag is the ETag of the content in the storage backend.
+	Author    string            `json:"author,omitempty"`    // Author is the name of the token which changed the bin.
+	Revision  int               `json:"revision"`            // Revision is the revision of the collaborative document.
+	Operation collab.Operation  `json:"operation,omitempty"` // Operation is the change of the content.
+	Client    string            `json:"client,omitempty"`    // Client is the ID of the editor which caused the event.
+	Selection *collab.Selection `json:"selection,omitempty"` // Selection is the cursor position of the editor.
+	Written   int               `json:"written,omitempty"`   // Written is the number of bytes written to the storage backend.
+}
+
+// Subscriber receives the events of a single bin.
+type Subscriber struct {
+	Bin    string     // Bin is the name of the subscribed bin.
+	Events chan Event // Events receives the events. It is closed when the subscriber is removed.
+}
+
+// Hub distributes events to all subscribers of a bin.
+type Hub struct {
+	subscribers map[string]map[*Subscriber]struct{} // subscribers maps the bin name to its subscribers.
+	mutex       sync.RWMutex                        // mutex is a read-write mutex for concurrent access control.
+}
+
+// NewHub creates a hub without subscribers.
+//
+// Returns:
+//   - *Hub
+//     The new hub.
+func NewHub() *Hub {
+	return &Hub{subscribers: make(map[string]map[*Subscriber]struct{})}
+}
+
+// Subscribe registers a new subscriber for the events of a bin.
+//
+// Parameters:
+//   - bin: string
+//     The name of the bin.
+//
+// Returns:
+//   - *Subscriber
+//     The subscriber. It must be removed with Unsubscribe when it is no longer used.
+func (h *Hub) Subscribe(bin string) *Subscriber {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	subscriber := &Subscriber{Bin: bin, Events: make(chan Event, subscriberBuffer)}
+	if h.subscribers[bin] == nil {
+		h.subscribers[bin] = make(map[*Subscriber]struct{})
+	}
+	h.subscribers[bin][subscriber] = struct{}{}
+
+	return subscriber
+}
+
+// Unsubscribe removes a subscriber and closes its event channel.
+// Removing a subscriber twice is a no-op.
+//
+// Parameters:
+//   - subscriber: *Subscriber
+//     The subscriber to remove.
+func (h *Hub) Unsubscribe(subscriber *Subscriber) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	h.remove(subscriber)
+}
+
+// remove removes a subscriber. The caller must hold the write lock.
+func (h *Hub) remove(subscriber *Subscriber) {
+	subscribers, ok := h.subscribers[subscriber.Bin]
+	if !ok {
+		return
+	}
+	if _, ok := subscribers[subscriber]; !ok {
+		return
+	}
+
+	delete(subscribers, subscriber)
+	close(subscriber.Events)
+	if len(subscribers) == 0 {
+		delete(h.subscribers, subscriber.Bin)
+	}
+}
+
+// Close removes all subscribers of a bin, e.g. because the bin was removed. Events published
+// before are still delivered, afterwards the closed event channels end the subscriptions.
+//
+// Parameters:
+//   - bin: string
+//     The name of the bin.
+func (h *Hub) Close(bin string) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for subscriber := range h.subscribers[bin] {
+		h.remove(subscriber)
+	}
+}
+
+// Publish sends an event to all subscribers of the bin.
+//
+// Publishing never blocks. Subscribers which cannot keep up are removed, their
+// closed event channel tells them to reconnect and resynchronize.
+//
+// Parameters:
+//   - event: Event
+//     The event to send.
+func (h *Hub) Publish(event Event) {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	for subscriber := range h.subscribers[event.Bin] {
+		select {
+		case subscriber.Events <- event:
+		default:
+			h.remove(subscriber)
+		}
+	}
+}
+
+// Subscribers returns the number of subscribers of a bin.
+//
+// Parameters:
+//   - bin: string
+//     The name of the bin.
+//
+// Returns:
+//   - int
+//     The number of subscribers.
+func (h *Hub) Subscribers(bin string) int {
+	h.mutex.RLock()
+	defer h.mutex.RUnlock()
+
+	return len(h.subscribers[bin])
+}
@@ -0,0 +1,69 @@
+package events
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHub(t *testing.T) {
+	t.Run("Events are delivered to subscribers of the bin", func(t *testing.T) {
+		hub := NewHub()
+		notes := hub.Subscribe("notes")
+		other := hub.Subscribe("other")
+
+		hub.Publish(Event{Type: TypeContent, Bin: "notes", Content: "hello"})
+
+		event := <-notes.Events
+		assert.Equal(t, "hello", event.Content)
+		assert.Empty(t, other.Events)
+	})
+
+	t.Run("Unsubscribe closes the event channel", func(t *testing.T) {
+		hub := NewHub()
+		subscriber := hub.Subscribe("notes")
+		assert.Equal(t, 1, hub.Subscribers("notes"))
+
+		hub.Unsubscribe(subscriber)
+		hub.Unsubscribe(subscriber)
+
+		_, ok := <-subscriber.Events
+		assert.False(t, ok)
+		assert.Equal(t, 0, hub.Subscribers("notes"))
+	})
+
+	t.Run("Close removes all subscribers of the bin", func(t *testing.T) {
+		hub := NewHub()
+		first := hub.Subscribe("notes")
+		second := hub.Subscribe("notes")
+		other := hub.Subscribe("logs")
+
+		hub.Publish(Event{Type: TypeContent, Bin: "notes"})
+		hub.Close("notes")
+
+		for _, subscriber := range []*Subscriber{first, second} {
+			event, ok := <-subscriber.Events
+			assert.True(t, ok)
+			assert.Equal(t, TypeContent, event.Type)
+			_, ok = <-subscriber.Events
+			assert.False(t, ok)
+		}
+		assert.Equal(t, 0, hub.Subscribers("notes"))
+		assert.Equal(t, 1, hub.Subscribers("logs"))
+		hub.Unsubscribe(other)
+	})
+
+	t.Run("Slow subscribers are removed", func(t *testing.T) {
+		hub := NewHub()
+		subscriber := hub.Subscribe("notes")
+
+		for i := 0; i <= subscriberBuffer; i++ {
+			hub.Publish(Event{Type: TypeContent, Bin: "notes"})
+		}
+
+		assert.Equal(t, 0, hub.Subscribers("notes"))
+		for range subscriber.Events {
+			// Drain buffered events until the channel is closed
+		}
+	})
+}
@@ -1,24 +1,23 @@
 package handlers
 
 import (
-	"io"
 	"net/http"
-	"os"
-	"vimbin/internal/config"
+	"vimbin/internal/audit"
 	"vimbin/internal/server"
+	"vimbin/internal/storage"
 
 	"github.com/rs/zerolog/log"
 )
 
 func init() {
-	server.Register("/append", "Append content to storage file", true, Append, "POST")
+	server.Register("/append", "Append content to storage file", server.ScopeAppend, Append, "POST")
+	server.Register("/api/bins/{name}/append", "Append content to a named bin", server.ScopeAppend, Append, "POST")
 }
 
-// Append handles HTTP requests for appending content to a file.
+// Append handles HTTP requests for appending content to a bin.
 //
 // This function creates and injects specific functions for appending content,
-// checking if content has changed, merging old and new content, and updating
-// content in storage. It then calls the handleContentRequest function to process
+// checking if content has changed and merging old and new content. It then calls the handleContentRequest function to process
 // the HTTP request.
 //
 // Parameters:
@@ -27,18 +26,11 @@ func init() {
 //   - r: *http.Request
 //     The HTTP request being processed.
 func Append(w http.ResponseWriter, r *http.Request) {
-	// Define a function for appending content to a file
-	writeFileFunc := func(filePath, content string) error {
-		file, err := os.OpenFile(filePath, os.O_APPEND|os.O_CREATE|os.O_WRONLY, filePermission)
-		if err != nil {
-			return err
-		}
-		defer file.Close()
+	// Define a function for appending content to the storage backend
+	writeFunc := func(store storage.Storage, name, content string) error {
+		log.Trace().Msgf("Appending content to bin '%s': %s", name, content)
 
-		log.Trace().Msgf("Writing content to file '%s': %s", filePath, content)
-
-		_, err = io.WriteString(file, content+"\n")
-		return err
+		return store.Append(name, content)
 	}
 
 	// Define a function to check if content has changed
@@ -57,9 +49,11 @@ func Append(w http.ResponseWriter, r *http.Request) {
 		return mergedContent
 	}
 
-	// Define a function for updating content in storage
-	saveContentFunc := (*config.Content).Append
+	bin, ok := resolveBin(w, r, true)
+	if !ok {
+		return
+	}
 
 	// Process the HTTP request using the defined functions
-	handleContentRequest(w, r, writeFileFunc, hasContentChangedFunc, mergeContentFunc, saveContentFunc)
+	handleContentRequest(w, r, bin, audit.ActionAppend, writeFunc, hasContentChangedFunc, mergeContentFunc)
 }
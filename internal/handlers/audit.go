@@ -0,0 +1,132 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"vimbin/internal/audit"
+	"vimbin/internal/config"
+	"vimbin/internal/metrics"
+	"vimbin/internal/server"
+
+	"github.com/rs/zerolog/log"
+)
+
+// defaultAuditLimit is the number of audit log entries returned if the request sets no limit.
+const defaultAuditLimit = 100
+
+func init() {
+	server.Register("/api/audit", "Query the audit log of content mutations", server.ScopeAdmin, AuditLog, "GET")
+}
+
+// AuditLog handles HTTP requests for querying the audit log.
+//
+// The 'bin', 'token' and 'action' query parameters select the entries of a bin, token or action.
+// 'since' selects the entries after a time, given in RFC 3339 format or as duration like '24h'.
+// 'limit' is the maximum number of entries, the most recent are returned. It defaults to 100, 0 returns all.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+func AuditLog(w http.ResponseWriter, r *http.Request) {
+	log.Trace().Msg(generateHTTPRequestLogEntry(r))
+
+	auditLog := config.App.Storage.Audit.Log
+	if auditLog == nil {
+		msg := "The audit log is disabled"
+		log.Error().Msg(msg)
+		http.Error(w, msg, http.StatusNotFound)
+		return
+	}
+
+	filter, err := auditFilter(r, time.Now())
+	if err != nil {
+		log.Error().Msg(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+
+	entries, err := auditLog.Query(filter)
+	if errors.Is(err, audit.ErrNotQueryable) {
+		log.Error().Msg(err.Error())
+		http.Error(w, err.Error(), http.StatusNotImplemented)
+		return
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error querying audit log: %v", err)
+		log.Error().Msg(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, map[string]interface{}{"entries": entries})
+}
+
+// auditFilter reads the filter of an audit log query from the query parameters.
+//
+// Parameters:
+//   - r: *http.Request
+//     The HTTP request being processed.
+//   - now: time.Time
+//     The current time, which durations given as 'since' are subtracted from.
+//
+// Returns:
+//   - audit.Filter
+//     The filter.
+//   - error
+//     An error if 'since' or 'limit' is invalid.
+func auditFilter(r *http.Request, now time.Time) (audit.Filter, error) {
+	query := r.URL.Query()
+	filter := audit.Filter{
+		Bin:    query.Get("bin"),
+		Token:  query.Get("token"),
+		Action: query.Get("action"),
+		Limit:  defaultAuditLimit,
+	}
+
+	if since := query.Get("since"); since != "" {
+		if duration, err := time.ParseDuration(since); err == nil {
+			filter.Since = now.Add(-duration)
+		} else if filter.Since, err = time.Parse(time.RFC3339, since); err != nil {
+			return audit.Filter{}, fmt.Errorf("Invalid since '%s'. Must be a time like '2024-01-02T15:04:05Z' or a duration like '24h'", since)
+		}
+	}
+
+	if limit := query.Get("limit"); limit != "" {
+		var err error
+		if filter.Limit, err = strconv.Atoi(limit); err != nil || filter.Limit < 0 {
+			return audit.Filter{}, fmt.Errorf("Invalid limit '%s'. Must be a number, 0 returns all entries", limit)
+		}
+	}
+
+	return filter, nil
+}
+
+// auditChange records a change of the content of a bin in the audit log, if it is enabled.
+// Only the sizes and hashes of the content are recorded, never the content itself.
+//
+// Parameters:
+//   - r: *http.Request
+//     The request which changed the content, or nil if the server changed it.
+//   - entry: audit.Entry
+//     The change. The token and client IP are taken from the request.
+func auditChange(r *http.Request, entry audit.Entry) {
+	auditLog := config.App.Storage.Audit.Log
+	if auditLog == nil {
+		return
+	}
+
+	if r != nil {
+		entry.Token = server.TokenName(r)
+		entry.ClientIP = server.ClientIP(r)
+	}
+
+	if err := auditLog.Record(entry); err != nil {
+		metrics.StorageErrors.WithLabelValues(metrics.OperationAudit).Inc()
+		log.Error().Msg(err.Error())
+	}
+}
@@ -0,0 +1,176 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"path"
+	"testing"
+	"time"
+	"vimbin/internal/audit"
+	"vimbin/internal/config"
+	"vimbin/internal/storage"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// setupAudit enables the audit log, written to a temporary file, for a test.
+func setupAudit(t *testing.T) *audit.Log {
+	t.Helper()
+
+	auditLog, err := audit.Open(path.Join(t.TempDir(), "audit.log"), audit.KeyedHash([]byte("audit key")))
+	assert.NoError(t, err)
+	config.App.Storage.Audit.Log = auditLog
+	t.Cleanup(func() {
+		auditLog.Close()
+		config.App.Storage.Audit.Log = nil
+	})
+
+	return auditLog
+}
+
+func TestAuditChange(t *testing.T) {
+	t.Run("Changes are recorded without the content", func(t *testing.T) {
+		setupStorage(t, "")
+		auditLog := setupAudit(t)
+
+		recorder := httptest.NewRecorder()
+		Save(recorder, binRequest("POST", "/api/bins/notes", "notes", `{"content":"secret"}`))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		recorder = httptest.NewRecorder()
+		Append(recorder, binRequest("POST", "/api/bins/notes/append", "notes", `{"content":" value"}`))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		entries, err := auditLog.Query(audit.Filter{})
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+
+		assert.Equal(t, audit.ActionSave, entries[0].Action)
+		assert.Equal(t, "notes", entries[0].Bin)
+		assert.Equal(t, auditLog.Hash(""), entries[0].HashBefore)
+		assert.Equal(t, auditLog.Hash("secret"), entries[0].HashAfter)
+		assert.NotEqual(t, storage.Hash("secret"), entries[0].HashAfter)
+		assert.Equal(t, 6, entries[0].ByteDelta)
+		assert.Equal(t, "192.0.2.1", entries[0].ClientIP)
+
+		assert.Equal(t, audit.ActionAppend, entries[1].Action)
+		assert.Equal(t, auditLog.Hash("secret value"), entries[1].HashAfter)
+		assert.Equal(t, 6, entries[1].BytesBefore)
+		assert.Equal(t, 12, entries[1].BytesAfter)
+
+		recorder = httptest.NewRecorder()
+		AuditLog(recorder, httptest.NewRequest("GET", "/api/audit", nil))
+		assert.NotContains(t, recorder.Body.String(), "secret")
+	})
+
+	t.Run("Burned bins are recorded as deleted", func(t *testing.T) {
+		setupStorage(t, "")
+		auditLog := setupAudit(t)
+
+		recorder := httptest.NewRecorder()
+		Save(recorder, binRequest("POST", "/api/bins/secret?burn", "secret", `{"content":"password"}`))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		recorder = httptest.NewRecorder()
+		Fetch(recorder, binRequest("GET", "/api/bins/secret", "secret", ""))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		entries, err := auditLog.Query(audit.Filter{Action: audit.ActionDelete})
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+		assert.Equal(t, "secret", entries[0].Bin)
+		assert.Equal(t, "burned", entries[0].Reason)
+		assert.Equal(t, 0, entries[0].BytesAfter)
+	})
+
+	t.Run("Nothing is recorded if the audit log is disabled", func(t *testing.T) {
+		setupStorage(t, "")
+
+		recorder := httptest.NewRecorder()
+		Save(recorder, binRequest("POST", "/api/bins/notes", "notes", `{"content":"content"}`))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+}
+
+func TestAuditLog(t *testing.T) {
+	t.Run("Disabled audit log", func(t *testing.T) {
+		setupStorage(t, "")
+
+		recorder := httptest.NewRecorder()
+		AuditLog(recorder, httptest.NewRequest("GET", "/api/audit", nil))
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+	})
+
+	t.Run("Entries are filtered", func(t *testing.T) {
+		setupStorage(t, "")
+		auditLog := setupAudit(t)
+		for _, bin := range []string{"notes", "logs", "notes"} {
+			assert.NoError(t, auditLog.Record(audit.NewEntry(audit.ActionSave, bin, "", bin, storage.Hash)))
+		}
+
+		recorder := httptest.NewRecorder()
+		AuditLog(recorder, httptest.NewRequest("GET", "/api/audit?bin=notes&limit=1", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		var response struct {
+			Entries []audit.Entry `json:"entries"`
+		}
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		assert.Len(t, response.Entries, 1)
+		assert.Equal(t, "notes", response.Entries[0].Bin)
+	})
+
+	t.Run("Invalid filter", func(t *testing.T) {
+		setupStorage(t, "")
+		setupAudit(t)
+
+		recorder := httptest.NewRecorder()
+		AuditLog(recorder, httptest.NewRequest("GET", "/api/audit?since=yesterday", nil))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Contains(t, recorder.Body.String(), "Invalid since 'yesterday'")
+	})
+
+	t.Run("Audit log not written to a file", func(t *testing.T) {
+		setupStorage(t, "")
+		auditLog, err := audit.Open(audit.OutputStdout, audit.KeyedHash(nil))
+		assert.NoError(t, err)
+		config.App.Storage.Audit.Log = auditLog
+		t.Cleanup(func() { config.App.Storage.Audit.Log = nil })
+
+		recorder := httptest.NewRecorder()
+		AuditLog(recorder, httptest.NewRequest("GET", "/api/audit", nil))
+		assert.Equal(t, http.StatusNotImplemented, recorder.Code)
+	})
+}
+
+func TestAuditFilter(t *testing.T) {
+	now := time.Date(2024, 1, 2, 15, 4, 5, 0, time.UTC)
+
+	t.Run("Defaults", func(t *testing.T) {
+		filter, err := auditFilter(httptest.NewRequest("GET", "/api/audit", nil), now)
+		assert.NoError(t, err)
+		assert.Equal(t, audit.Filter{Limit: defaultAuditLimit}, filter)
+	})
+
+	t.Run("All parameters", func(t *testing.T) {
+		filter, err := auditFilter(httptest.NewRequest("GET", "/api/audit?bin=notes&token=ci&action=save&since=24h&limit=0", nil), now)
+		assert.NoError(t, err)
+		assert.Equal(t, audit.Filter{Bin: "notes", Token: "ci", Action: "save", Since: now.Add(-24 * time.Hour)}, filter)
+
+		filter, err = auditFilter(httptest.NewRequest("GET", "/api/audit?since=2024-01-01T00:00:00Z", nil), now)
+		assert.NoError(t, err)
+		assert.Equal(t, time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC), filter.Since)
+	})
+
+	t.Run("Invalid parameters", func(t *testing.T) {
+		_, err := auditFilter(httptest.NewRequest("GET", "/api/audit?since=yesterday", nil), now)
+		assert.Error(t, err)
+
+		_, err = auditFilter(httptest.NewRequest("GET", "/api/audit?limit=-1", nil), now)
+		assert.ErrorContains(t, err, "Invalid limit '-1'")
+
+		_, err = auditFilter(httptest.NewRequest("GET", "/api/audit?limit=many", nil), now)
+		assert.Error(t, err)
+	})
+}
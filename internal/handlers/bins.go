@@ -0,0 +1,63 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"vimbin/internal/config"
+	"vimbin/internal/metrics"
+	"vimbin/internal/server"
+
+	"github.com/rs/zerolog/log"
+)
+
+func init() {
+	server.Register("/api/bins", "List all bins", server.ScopeRead, ListBins, "GET")
+	metrics.RegisterContentSize(contentSizes)
+}
+
+// ListBins handles HTTP requests for listing all bins.
+//
+// This function responds with a JSON object containing the names of all bins,
+// including bins which only exist on disk and are not loaded yet. Bins the
+// token is not allowed to access are left out.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+func ListBins(w http.ResponseWriter, r *http.Request) {
+	log.Trace().Msg(generateHTTPRequestLogEntry(r))
+
+	names, err := config.App.Storage.ListBins()
+	if err != nil {
+		msg := fmt.Sprintf("Error listing bins: %v", err)
+		log.Error().Msg(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	allowed := []string{}
+	for _, name := range names {
+		if server.AllowsBin(r, name) {
+			allowed = append(allowed, name)
+		}
+	}
+
+	writeJSONResponse(w, map[string][]string{"bins": allowed})
+}
+
+// contentSizes returns the content size in bytes of every loaded bin.
+//
+// Returns:
+//   - map[string]int
+//     The content size per bin name.
+func contentSizes() map[string]int {
+	sizes := map[string]int{}
+	for _, name := range config.App.Storage.Bins.Names() {
+		if bin, ok := config.App.Storage.Bins.Get(name); ok {
+			sizes[name] = len(bin.Content.Get())
+		}
+	}
+	return sizes
+}
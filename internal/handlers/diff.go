@@ -0,0 +1,107 @@
+package handlers
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"vimbin/internal/audit"
+	"vimbin/internal/diff"
+	"vimbin/internal/metrics"
+	"vimbin/internal/server"
+
+	"github.com/rs/zerolog/log"
+)
+
+// rejectedResponse is the response to a diff which does not apply.
+type rejectedResponse struct {
+	Status   string   `json:"status"`   // Status is always 'conflict'.
+	Rejected []string `json:"rejected"` // Rejected are the hunks which do not apply, formatted like in the diff.
+}
+
+func init() {
+	server.Register("/api/diff", "Apply a unified diff to the default bin", server.ScopeWrite, ApplyDiff, "POST")
+	server.Register("/api/bins/{name}/diff", "Apply a unified diff to a named bin", server.ScopeWrite, ApplyDiff, "POST")
+}
+
+// ApplyDiff handles HTTP requests for applying a unified diff to a bin.
+//
+// The body is a unified diff, as produced by 'diff -u' or 'git diff'. Hunks are applied where their
+// context matches, even if lines were added or removed above them since the diff was created. If any
+// hunk does not apply, nothing is written and 409 Conflict is returned with the rejected hunks.
+// Like saves, diffs honor the If-Match header. Bins which are end-to-end encrypted are refused.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+func ApplyDiff(w http.ResponseWriter, r *http.Request) {
+	log.Trace().Msg(generateHTTPRequestLogEntry(r))
+
+	limitBody(w, r)
+	body, err := io.ReadAll(r.Body)
+	if err != nil {
+		msg := fmt.Sprintf("Error reading request body: %v", err)
+		log.Error().Msg(msg)
+		http.Error(w, msg, bodyErrorStatus(err))
+		return
+	}
+
+	hunks, err := diff.Parse(string(body))
+	if err != nil {
+		msg := fmt.Sprintf("Invalid diff: %v", err)
+		log.Error().Msg(msg)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	bin, ok := resolveBin(w, r, true)
+	if !ok {
+		return
+	}
+
+	bin.Lock()
+	defer bin.Unlock()
+
+	if refuseEncrypted(w, bin) {
+		return
+	}
+
+	if !persistBeforeModification(w, bin) {
+		return
+	}
+
+	previousContent := bin.Content.Get()
+	if !checkPrecondition(w, r, bin, previousContent) {
+		return
+	}
+
+	content, rejected := diff.Apply(previousContent, hunks)
+	if len(rejected) > 0 {
+		log.Debug().Msgf("Rejected %d of %d hunks for bin '%s'", len(rejected), len(hunks), bin.Name)
+
+		response := rejectedResponse{Status: "conflict"}
+		for _, hunk := range rejected {
+			response.Rejected = append(response.Rejected, hunk.String())
+		}
+		w.Header().Set("ETag", etag(previousContent))
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusConflict)
+		writeJSONResponse(w, response)
+		return
+	}
+
+	w.Header().Set("ETag", etag(content))
+	if previousContent == content {
+		metrics.NoChangeSaves.WithLabelValues(routeLabel(r)).Inc()
+		writeJSONResponse(w, map[string]string{"status": "no changes"})
+		return
+	}
+
+	if !commitContent(w, r, bin, audit.ActionDiff, previousContent, content, nil) {
+		return
+	}
+	log.Debug().Msgf("Applied %d hunks to bin '%s'", len(hunks), bin.Name)
+
+	writeJSONResponse(w, map[string]string{"status": "success"})
+}
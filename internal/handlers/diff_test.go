@@ -0,0 +1,55 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"vimbin/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyDiff(t *testing.T) {
+	t.Run("Diff is applied to the changed content", func(t *testing.T) {
+		bin := setupStorage(t, "added by someone else\n# Notes\nold line\n")
+
+		patch := "--- a/notes\n+++ b/notes\n@@ -1,2 +1,2 @@\n # Notes\n-old line\n+new line\n"
+		recorder := httptest.NewRecorder()
+		ApplyDiff(recorder, httptest.NewRequest("POST", "/api/diff", strings.NewReader(patch)))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, etag("added by someone else\n# Notes\nnew line\n"), recorder.Header().Get("ETag"))
+		assert.Equal(t, "added by someone else\n# Notes\nnew line\n", bin.Content.Get())
+
+		stored, err := config.App.Storage.Store.Get(config.DefaultBin)
+		assert.NoError(t, err)
+		assert.Equal(t, "added by someone else\n# Notes\nnew line\n", stored)
+	})
+
+	t.Run("Conflicting diff returns the rejected hunks", func(t *testing.T) {
+		bin := setupStorage(t, "# Notes\nline changed by someone else\n")
+
+		patch := "@@ -1,2 +1,2 @@\n # Notes\n-old line\n+new line\n"
+		recorder := httptest.NewRecorder()
+		ApplyDiff(recorder, httptest.NewRequest("POST", "/api/diff", strings.NewReader(patch)))
+
+		assert.Equal(t, http.StatusConflict, recorder.Code)
+		assert.Equal(t, "application/json", recorder.Header().Get("Content-Type"))
+		assert.Equal(t, "# Notes\nline changed by someone else\n", bin.Content.Get())
+
+		var response rejectedResponse
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &response))
+		assert.Equal(t, rejectedResponse{Status: "conflict", Rejected: []string{patch}}, response)
+	})
+
+	t.Run("Invalid diff", func(t *testing.T) {
+		setupStorage(t, "content\n")
+
+		recorder := httptest.NewRecorder()
+		ApplyDiff(recorder, httptest.NewRequest("POST", "/api/diff", strings.NewReader("not a diff")))
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+}
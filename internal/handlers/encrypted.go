@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"fmt"
+	"net/http"
+	"vimbin/internal/config"
+	"vimbin/internal/e2e"
+	"vimbin/internal/metrics"
+
+	"github.com/rs/zerolog/log"
+)
+
+// refuseEncrypted responds with 403 Forbidden if the content of the bin is end-to-end encrypted.
+// The server cannot read encrypted content, so it can only be replaced as a whole, not changed
+// line by line, with diffs or by collaborative editing.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - bin: *config.Bin
+//     The bin to check.
+//
+// Returns:
+//   - bool
+//     True if the bin is encrypted and a response has already been written.
+func refuseEncrypted(w http.ResponseWriter, bin *config.Bin) bool {
+	if !bin.Metadata().Encrypted {
+		return false
+	}
+
+	msg := fmt.Sprintf("Bin '%s' is end-to-end encrypted. Its content can only be replaced as a whole", bin.Name)
+	log.Error().Msg(msg)
+	http.Error(w, msg, http.StatusForbidden)
+	return true
+}
+
+// storeEncryption marks a bin as encrypted if its new content was encrypted by the client, or as
+// plain otherwise. The metadata is written before the content, so encrypted content is never stored
+// without being marked. The caller must hold the lock of the bin.
+//
+// Parameters:
+//   - bin: *config.Bin
+//     The bin which is about to be modified.
+//   - content: string
+//     The new content of the bin.
+//
+// Returns:
+//   - error
+//     An error if the metadata cannot be written to the storage backend.
+func storeEncryption(bin *config.Bin, content string) error {
+	metadata := bin.Metadata()
+	encrypted := e2e.IsEncrypted(content)
+	if metadata.Encrypted == encrypted {
+		return nil
+	}
+
+	metadata.Encrypted = encrypted
+	if err := config.App.Storage.Store.PutMetadata(bin.Name, metadata); err != nil {
+		metrics.StorageErrors.WithLabelValues(metrics.OperationWrite).Inc()
+		return fmt.Errorf("Error writing metadata: %v", err)
+	}
+	bin.SetMetadata(metadata)
+	log.Debug().Msgf("Marked bin '%s' as encrypted: %t", bin.Name, encrypted)
+
+	return nil
+}
@@ -0,0 +1,112 @@
+package handlers
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"vimbin/internal/config"
+	"vimbin/internal/e2e"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// encryptedContent returns content encrypted with a new key, as sent by clients.
+func encryptedContent(t *testing.T, content string) string {
+	encoded, err := e2e.GenerateKey()
+	assert.NoError(t, err)
+	key, err := e2e.ParseKey(encoded)
+	assert.NoError(t, err)
+	encrypted, err := e2e.Encrypt(content, key)
+	assert.NoError(t, err)
+
+	return encrypted
+}
+
+// saveEncrypted saves encrypted content to a named bin.
+func saveEncrypted(t *testing.T, name, content string) {
+	body, _ := json.Marshal(map[string]string{"content": content})
+	recorder := httptest.NewRecorder()
+	Save(recorder, binRequest("POST", "/api/bins/"+name, name, string(body)))
+	assert.Equal(t, http.StatusOK, recorder.Code)
+}
+
+func TestEncrypted(t *testing.T) {
+	t.Run("Encrypted content marks the bin", func(t *testing.T) {
+		setupStorage(t, "")
+		encrypted := encryptedContent(t, "password")
+		saveEncrypted(t, "secret", encrypted)
+
+		metadata, err := config.App.Storage.Store.GetMetadata("secret")
+		assert.NoError(t, err)
+		assert.True(t, metadata.Encrypted)
+
+		// The content is served as is
+		recorder := httptest.NewRecorder()
+		Fetch(recorder, binRequest("GET", "/api/bins/secret", "secret", ""))
+		assert.Equal(t, encrypted, recorder.Body.String())
+	})
+
+	t.Run("Patches which encrypt the content mark the bin", func(t *testing.T) {
+		setupStorage(t, "")
+		recorder := httptest.NewRecorder()
+		Save(recorder, binRequest("POST", "/api/bins/secret", "secret", `{"content":"password"}`))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		body, _ := json.Marshal(map[string]interface{}{"operations": []lineOperation{{Op: "replace", From: 1, To: 1, Content: encryptedContent(t, "password")}}})
+		recorder = httptest.NewRecorder()
+		Patch(recorder, binRequest("PATCH", "/api/bins/secret", "secret", string(body)))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		metadata, err := config.App.Storage.Store.GetMetadata("secret")
+		assert.NoError(t, err)
+		assert.True(t, metadata.Encrypted)
+	})
+
+	t.Run("Plain content unmarks the bin", func(t *testing.T) {
+		setupStorage(t, "")
+		saveEncrypted(t, "secret", encryptedContent(t, "password"))
+
+		recorder := httptest.NewRecorder()
+		Save(recorder, binRequest("POST", "/api/bins/secret", "secret", `{"content":"public"}`))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		metadata, err := config.App.Storage.Store.GetMetadata("secret")
+		assert.NoError(t, err)
+		assert.False(t, metadata.Encrypted)
+	})
+
+	t.Run("Encrypted content cannot be changed partially", func(t *testing.T) {
+		setupStorage(t, "")
+		encrypted := encryptedContent(t, "password")
+		saveEncrypted(t, "secret", encrypted)
+
+		for name, test := range map[string]struct {
+			handler http.HandlerFunc
+			request *http.Request
+		}{
+			"append": {Append, binRequest("POST", "/api/bins/secret/append", "secret", `{"content":"more"}`)},
+			"patch":  {Patch, binRequest("PATCH", "/api/bins/secret", "secret", `{"operations":[{"op":"insert","line":1,"content":"more"}]}`)},
+			"diff":   {ApplyDiff, binRequest("POST", "/api/bins/secret/diff", "secret", "@@ -0,0 +1 @@\n+more\n")},
+			"editor": {Events, binRequest("GET", "/b/secret/ws", "secret", "")},
+		} {
+			recorder := httptest.NewRecorder()
+			test.handler(recorder, test.request)
+			assert.Equal(t, http.StatusForbidden, recorder.Code, name)
+		}
+
+		bin, err := config.App.Storage.Bin("secret", false)
+		assert.NoError(t, err)
+		assert.Equal(t, encrypted, bin.Content.Get())
+	})
+
+	t.Run("Encrypted content cannot be appended", func(t *testing.T) {
+		bin := setupStorage(t, "plain")
+
+		body, _ := json.Marshal(map[string]string{"content": encryptedContent(t, "password")})
+		recorder := httptest.NewRecorder()
+		Append(recorder, binRequest("POST", "/append", config.DefaultBin, string(body)))
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+		assert.Equal(t, "plain", bin.Content.Get())
+	})
+}
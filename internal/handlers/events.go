@@ -0,0 +1,448 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+	"vimbin/internal/audit"
+	"vimbin/internal/collab"
+	"vimbin/internal/config"
+	"vimbin/internal/events"
+	"vimbin/internal/metrics"
+	"vimbin/internal/server"
+	"vimbin/internal/storage"
+	"vimbin/internal/utils"
+
+	"github.com/gorilla/websocket"
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	wsWriteWait        = 10 * time.Second      // wsWriteWait is the time allowed to write a message to the client.
+	wsPongWait         = 60 * time.Second      // wsPongWait is the time allowed to read the next pong message from the client.
+	wsPingPeriod       = (wsPongWait * 9) / 10 // wsPingPeriod is the interval of pings sent to the client. Must be less than wsPongWait.
+	wsMaxMessageSize   = 16 * 1024 * 1024      // wsMaxMessageSize is the maximum size of a message sent by the client.
+	editorAuthor       = "editor"              // editorAuthor is the author of revisions created from collaborative edits.
+	closeReasonRemoved = "bin removed"         // closeReasonRemoved closes the connections of editors of a bin which was removed.
+)
+
+// Message types sent by editors.
+const (
+	messageOperation = "operation" // messageOperation sends a change of the content.
+	messageCursor    = "cursor"    // messageCursor sends the cursor position.
+	messageSave      = "save"      // messageSave requests writing the content to the storage backend.
+)
+
+// hub distributes content changes to all connected editors.
+var hub = events.NewHub()
+
+// editing lets the shutdown wait for operations which are being applied, so no operation is
+// confirmed to an editor after the documents were written for the last time.
+var (
+	editing       sync.RWMutex // editing is held for reading while an operation is applied.
+	editingClosed bool         // editingClosed is set on shutdown, afterwards operations are refused.
+)
+
+// upgrader upgrades HTTP connections to WebSocket connections.
+var upgrader = websocket.Upgrader{
+	ReadBufferSize:  1024,
+	WriteBufferSize: 1024,
+}
+
+// editorMessage is a message sent by an editor.
+type editorMessage struct {
+	Type      string            `json:"type"`      // Type is the type of the message.
+	Revision  int               `json:"revision"`  // Revision is the revision the operation or cursor position is based on.
+	Operation collab.Operation  `json:"operation"` // Operation is the change of the content.
+	Selection *collab.Selection `json:"selection"` // Selection is the cursor position.
+}
+
+func init() {
+	server.Register("/ws", "Collaborative editing of the default bin", server.ScopeNone, Events, "GET")
+	server.Register("/b/{name}/ws", "Collaborative editing of a named bin", server.ScopeNone, Events, "GET")
+	server.RegisterShutdownHook("persist", persistDocuments)
+}
+
+// Events handles WebSocket connections of editors.
+//
+// Editors identify themselves with the 'client' query parameter. If they pass the last
+// revision they know in the 'revision' query parameter, the changes they missed are sent,
+// followed by a 'sync' event. Otherwise the current content is sent. Afterwards every change
+// of the bin is sent as 'operation' event, including the changes sent by the editor itself,
+// which confirm them.
+//
+// Editors send operations, cursor positions and save requests. Like the editor itself, this
+// endpoint only requires authentication if it is required for all routes, but only editors
+// logged in with a token granted the write scope may send operations and save requests.
+// Bins which are end-to-end encrypted cannot be edited collaboratively. Only editors granted
+// the write scope may connect to bins which do not exist yet.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+func Events(w http.ResponseWriter, r *http.Request) {
+	log.Trace().Msg(generateHTTPRequestLogEntry(r))
+
+	bin, ok := resolveBin(w, r, server.HasScope(r, server.ScopeWrite))
+	if !ok {
+		return
+	}
+	if refuseBurnAfterRead(w, bin) || refuseEncrypted(w, bin) {
+		return
+	}
+
+	client := r.URL.Query().Get("client")
+	if client == "" {
+		client, _ = utils.GenerateRandomToken(16)
+	}
+	if !storage.IsValidBinName(client) {
+		http.Error(w, fmt.Sprintf("Invalid client '%s'", client), http.StatusBadRequest)
+		return
+	}
+
+	revision := -1
+	if value := r.URL.Query().Get("revision"); value != "" {
+		parsed, err := strconv.Atoi(value)
+		if err != nil {
+			http.Error(w, fmt.Sprintf("Invalid revision '%s'", value), http.StatusBadRequest)
+			return
+		}
+		revision = parsed
+	}
+
+	conn, err := upgrader.Upgrade(w, r, nil)
+	if err != nil {
+		// The upgrader already responded with an error
+		log.Error().Msgf("Error upgrading connection: %v", err)
+		return
+	}
+	defer conn.Close()
+
+	// Subscribe before reading the document, so no change can be missed in between
+	subscriber := hub.Subscribe(bin.Name)
+	defer hub.Unsubscribe(subscriber)
+
+	log.Debug().Msgf("Editor '%s' connected to bin '%s' from %s", client, bin.Name, r.RemoteAddr)
+	defer log.Debug().Msgf("Editor '%s' disconnected from bin '%s'", client, bin.Name)
+	defer hub.Publish(events.Event{Type: events.TypeLeave, Bin: bin.Name, Client: client})
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		readEditorMessages(conn, bin, client, server.HasScope(r, server.ScopeWrite))
+	}()
+
+	if err := sendInitialState(conn, bin, revision); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(wsPingPeriod)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case event, ok := <-subscriber.Events:
+			if !ok {
+				bin.Lock()
+				removed := bin.Removed()
+				bin.Unlock()
+
+				// The subscriber could not keep up, the editor reconnects and catches up, unless the bin was removed
+				message := websocket.FormatCloseMessage(websocket.CloseTryAgainLater, "too slow")
+				if removed {
+					message = websocket.FormatCloseMessage(websocket.CloseNormalClosure, closeReasonRemoved)
+				}
+				_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+				_ = conn.WriteMessage(websocket.CloseMessage, message)
+				return
+			}
+			if err := writeEvent(conn, event); err != nil {
+				return
+			}
+		case <-ticker.C:
+			_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+			if err := conn.WriteMessage(websocket.PingMessage, nil); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		}
+	}
+}
+
+// sendInitialState sends the changes an editor missed since the given revision, followed by a
+// 'sync' event. If the changes are not known (anymore), the current content is sent instead.
+func sendInitialState(conn *websocket.Conn, bin *config.Bin, revision int) error {
+	if revision >= 0 {
+		changes, err := bin.Document.Changes(revision)
+		if err == nil {
+			for _, change := range changes {
+				if err := writeEvent(conn, operationEvent(bin, change, "", "")); err != nil {
+					return err
+				}
+			}
+			if len(changes) > 0 {
+				revision = changes[len(changes)-1].Revision
+			}
+			return writeEvent(conn, events.Event{Type: events.TypeSync, Bin: bin.Name, Revision: revision})
+		}
+	}
+
+	content, current := bin.Document.Snapshot()
+	return writeEvent(conn, events.Event{
+		Type:     events.TypeContent,
+		Bin:      bin.Name,
+		Content:  content,
+		ETag:     etag(bin.Content.Get()),
+		Revision: current,
+	})
+}
+
+// readEditorMessages processes the messages sent by an editor until the connection is closed
+// or the editor sends an operation which cannot be applied. The editor then reconnects and catches up.
+// Editors which may not edit are disconnected when they send an operation or save request, as are
+// editors sending operations for a bin which is end-to-end encrypted.
+func readEditorMessages(conn *websocket.Conn, bin *config.Bin, client string, canEdit bool) {
+	conn.SetReadLimit(wsMaxMessageSize)
+	_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	conn.SetPongHandler(func(string) error {
+		return conn.SetReadDeadline(time.Now().Add(wsPongWait))
+	})
+
+	for {
+		_, data, err := conn.ReadMessage()
+		if err != nil {
+			return
+		}
+		_ = conn.SetReadDeadline(time.Now().Add(wsPongWait))
+
+		var message editorMessage
+		if err := json.Unmarshal(data, &message); err != nil {
+			log.Error().Msgf("Error decoding message of editor '%s': %v", client, err)
+			return
+		}
+
+		if !canEdit && (message.Type == messageOperation || message.Type == messageSave) {
+			log.Error().Msgf("Editor '%s' is not allowed to change bin '%s'", client, bin.Name)
+			return
+		}
+
+		// The bin was encrypted while the editor was connected, its content cannot be edited anymore
+		if message.Type == messageOperation && bin.Metadata().Encrypted {
+			log.Error().Msgf("Editor '%s' cannot edit bin '%s', it is end-to-end encrypted", client, bin.Name)
+			return
+		}
+
+		switch message.Type {
+		case messageOperation:
+			editing.RLock()
+			if editingClosed {
+				// The server shuts down, the editor sends the operation again after reconnecting
+				editing.RUnlock()
+				return
+			}
+			_, err := bin.Document.Receive(client, message.Revision, message.Operation, func(change collab.Change) {
+				hub.Publish(operationEvent(bin, change, "", ""))
+			})
+			editing.RUnlock()
+			if err != nil {
+				log.Error().Msgf("Error applying operation of editor '%s' to bin '%s': %v", client, bin.Name, err)
+				return
+			}
+			bin.Document.SchedulePersist(config.App.Web().PersistInterval, func() {
+				bin.Lock()
+				defer bin.Unlock()
+				if err := persistDocument(bin, ""); err != nil {
+					log.Error().Msgf("Error writing bin '%s': %v", bin.Name, err)
+				}
+			})
+		case messageCursor:
+			hub.Publish(events.Event{
+				Type:      events.TypeCursor,
+				Bin:       bin.Name,
+				Revision:  message.Revision,
+				Client:    client,
+				Selection: message.Selection,
+			})
+		case messageSave:
+			bin.Lock()
+			err := persistDocument(bin, client)
+			bin.Unlock()
+			if err != nil {
+				log.Error().Msgf("Error writing bin '%s': %v", bin.Name, err)
+			}
+		default:
+			log.Debug().Msgf("Ignoring message of unknown type '%s' from editor '%s'", message.Type, client)
+		}
+	}
+}
+
+// writeEvent sends an event as JSON message to a WebSocket connection.
+func writeEvent(conn *websocket.Conn, event events.Event) error {
+	_ = conn.SetWriteDeadline(time.Now().Add(wsWriteWait))
+	if err := conn.WriteJSON(event); err != nil {
+		log.Debug().Msgf("Error sending event to %s: %v", conn.RemoteAddr(), err)
+		return err
+	}
+	return nil
+}
+
+// operationEvent creates the event sent to editors for a change of the document.
+func operationEvent(bin *config.Bin, change collab.Change, author, etag string) events.Event {
+	return events.Event{
+		Type:      events.TypeOperation,
+		Bin:       bin.Name,
+		Revision:  change.Revision,
+		Operation: change.Operation,
+		Client:    change.Client,
+		Author:    author,
+		ETag:      etag,
+	}
+}
+
+// persistDocument writes the collaborative document of a bin to the storage backend if it
+// has changes which were not written yet, and notifies the editors. Removed bins are not written,
+// so editors which were still connected when the bin expired or was burned cannot recreate it.
+// The caller must hold the lock of the bin.
+//
+// Parameters:
+//   - bin: *config.Bin
+//     The bin to write.
+//   - client: string
+//     The ID of the editor which requested the write, or an empty string.
+//
+// Returns:
+//   - error
+//     An error if the content cannot be written to the storage backend.
+func persistDocument(bin *config.Bin, client string) error {
+	if bin.Removed() {
+		return nil
+	}
+
+	document := bin.Document
+	content, revision := document.Snapshot()
+	previousContent := bin.Content.Get()
+
+	written := 0
+	if revision != document.Persisted() && content != previousContent {
+		if err := config.App.Storage.Store.Put(bin.Name, content); err != nil {
+			metrics.StorageErrors.WithLabelValues(metrics.OperationWrite).Inc()
+			return err
+		}
+		bin.Content.Set(content)
+		written = len(content)
+
+		log.Debug().Msgf("Wrote %d bytes of collaborative edits to bin '%s'", written, bin.Name)
+
+		entry := audit.NewEntry(audit.ActionEdit, bin.Name, previousContent, content, contentHash)
+		entry.Token = editorAuthor
+		if revision, err := recordRevision(bin, previousContent, content, editorAuthor); err == nil {
+			entry.Revision = revision.ID
+		}
+		auditChange(nil, entry)
+	}
+	document.MarkPersisted(revision)
+
+	if written > 0 || client != "" {
+		hub.Publish(events.Event{
+			Type:     events.TypeSaved,
+			Bin:      bin.Name,
+			ETag:     etag(bin.Content.Get()),
+			Revision: revision,
+			Client:   client,
+			Written:  written,
+		})
+	}
+
+	return nil
+}
+
+// persistDocuments writes the pending collaborative edits of every loaded bin to the storage
+// backend on shutdown. Editors which send further operations are disconnected.
+func persistDocuments() {
+	editing.Lock()
+	editingClosed = true
+	editing.Unlock()
+
+	for _, name := range config.App.Storage.Bins.Names() {
+		bin, ok := config.App.Storage.Bins.Get(name)
+		if !ok {
+			continue
+		}
+
+		bin.Lock()
+		if err := persistDocument(bin, ""); err != nil {
+			log.Error().Msgf("Error writing bin '%s': %v", bin.Name, err)
+		}
+		bin.Unlock()
+	}
+}
+
+// updateDocument applies a change made through the API to the collaborative document,
+// so connected editors receive it as operation. The caller must hold the lock of the bin
+// and must have persisted the document before making the change.
+//
+// Parameters:
+//   - bin: *config.Bin
+//     The bin which was modified.
+//   - previousContent: string
+//     The content of the bin before the modification.
+//   - content: string
+//     The new content of the bin.
+//   - author: string
+//     The name of the token which modified the bin.
+func updateDocument(bin *config.Bin, previousContent, content, author string) {
+	document := bin.Document
+
+	_, err := document.Receive("", document.Persisted(), collab.Diff(previousContent, content), func(change collab.Change) {
+		hub.Publish(operationEvent(bin, change, author, etag(content)))
+	})
+	if err != nil {
+		// The change cannot be transformed, so the editors have to load the new content
+		log.Warn().Msgf("Resetting collaborative document of bin '%s': %v", bin.Name, err)
+		revision := document.Reset(content)
+		current, _ := document.Snapshot()
+		hub.Publish(events.Event{
+			Type:     events.TypeContent,
+			Bin:      bin.Name,
+			Content:  current,
+			ETag:     etag(content),
+			Author:   author,
+			Revision: revision,
+		})
+		return
+	}
+
+	// Unless editors changed the document in the meantime, it matches the storage backend
+	if current, revision := document.Snapshot(); current == collab.Normalize(content) {
+		document.MarkPersisted(revision)
+	}
+}
+
+// persistBeforeModification writes pending collaborative edits before a bin is modified through the API,
+// so the modification is based on the content the editors see. If the edits cannot be written, an
+// error response is written. The caller must hold the lock of the bin.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - bin: *config.Bin
+//     The bin which is about to be modified.
+//
+// Returns:
+//   - bool
+//     False if the edits could not be written and a response has already been written.
+func persistBeforeModification(w http.ResponseWriter, bin *config.Bin) bool {
+	if err := persistDocument(bin, ""); err != nil {
+		msg := fmt.Sprintf("Error writing collaborative edits: %v", err)
+		log.Error().Msg(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return false
+	}
+	return true
+}
@@ -0,0 +1,225 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"vimbin/internal/collab"
+	"vimbin/internal/config"
+	"vimbin/internal/events"
+	"vimbin/internal/metrics"
+	"vimbin/internal/server"
+	"vimbin/internal/storage"
+
+	"github.com/gorilla/websocket"
+	"github.com/stretchr/testify/assert"
+)
+
+// editorTokens are the tokens accepted by the server started with newEditorServer.
+var editorTokens = []server.Token{{Name: "editor", Value: "editor-token", Scopes: []server.Scope{server.ScopeRead, server.ScopeWrite}}}
+
+// newEditorServer starts a server for the Events handler which, like the router, knows the token of logged in editors.
+func newEditorServer() *httptest.Server {
+	return httptest.NewServer(server.ApiTokenMiddleware(Events, editorTokens, server.ScopeRead))
+}
+
+// connectEditor opens a WebSocket connection to the Events handler like the web editor does.
+// The token is sent in the X-API-Token header instead of the session cookie.
+func connectEditor(t *testing.T, url, query string) *websocket.Conn {
+	t.Helper()
+
+	header := http.Header{"X-API-Token": {"editor-token"}}
+	conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(url, "http")+"?"+query, header)
+	assert.NoError(t, err)
+	_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+
+	return conn
+}
+
+// readEvent reads the next event of the given type, skipping all other events.
+func readEvent(t *testing.T, conn *websocket.Conn, eventType string) events.Event {
+	t.Helper()
+
+	for {
+		var event events.Event
+		if !assert.NoError(t, conn.ReadJSON(&event)) || event.Type == eventType {
+			return event
+		}
+	}
+}
+
+func TestEvents(t *testing.T) {
+	t.Run("Editors receive the current content and changes made through the API", func(t *testing.T) {
+		setupStorage(t, "old")
+
+		httpServer := newEditorServer()
+		defer httpServer.Close()
+
+		conn := connectEditor(t, httpServer.URL, "client=a")
+		defer conn.Close()
+
+		event := readEvent(t, conn, events.TypeContent)
+		assert.Equal(t, "old", event.Content)
+		assert.Equal(t, etag("old"), event.ETag)
+		assert.Equal(t, 0, event.Revision)
+
+		recorder := httptest.NewRecorder()
+		Append(recorder, httptest.NewRequest("POST", "/append", strings.NewReader(`{"content":" new"}`)))
+
+		event = readEvent(t, conn, events.TypeOperation)
+		assert.Equal(t, 1, event.Revision)
+		assert.Equal(t, collab.Operation{}.Retain(3).Insert(" new"), event.Operation)
+		assert.Equal(t, etag("old new"), event.ETag)
+	})
+
+	t.Run("Operations of editors are merged and persisted", func(t *testing.T) {
+		config.App.Server.Web.PersistInterval = time.Hour
+		bin := setupStorage(t, "hello")
+
+		httpServer := newEditorServer()
+		defer httpServer.Close()
+
+		alice := connectEditor(t, httpServer.URL, "client=alice&revision=0")
+		defer alice.Close()
+		bob := connectEditor(t, httpServer.URL, "client=bob&revision=0")
+		defer bob.Close()
+		assert.Equal(t, 0, readEvent(t, alice, events.TypeSync).Revision)
+		assert.Equal(t, 0, readEvent(t, bob, events.TypeSync).Revision)
+
+		// Both editors change revision 0 concurrently
+		assert.NoError(t, alice.WriteJSON(editorMessage{Type: messageOperation, Revision: 0, Operation: collab.Operation{}.Insert("> ").Retain(5)}))
+		assert.Equal(t, "alice", readEvent(t, alice, events.TypeOperation).Client)
+		assert.NoError(t, bob.WriteJSON(editorMessage{Type: messageOperation, Revision: 0, Operation: collab.Operation{}.Retain(5).Insert("!")}))
+
+		event := readEvent(t, alice, events.TypeOperation)
+		assert.Equal(t, "bob", event.Client)
+		assert.Equal(t, 2, event.Revision)
+		assert.Equal(t, collab.Operation{}.Retain(7).Insert("!"), event.Operation)
+
+		content, _ := bin.Document.Snapshot()
+		assert.Equal(t, "> hello!", content)
+		assert.Equal(t, "hello", bin.Content.Get(), "edits are not written before the persist interval")
+
+		assert.NoError(t, bob.WriteJSON(editorMessage{Type: messageSave}))
+		event = readEvent(t, bob, events.TypeSaved)
+		assert.Equal(t, len("> hello!"), event.Written)
+		assert.Equal(t, etag("> hello!"), event.ETag)
+
+		stored, err := config.App.Storage.Store.Get(config.DefaultBin)
+		assert.NoError(t, err)
+		assert.Equal(t, "> hello!", stored)
+		assert.Equal(t, "> hello!", bin.Content.Get())
+	})
+
+	t.Run("Editors without the write scope cannot edit", func(t *testing.T) {
+		bin := setupStorage(t, "hello")
+
+		httpServer := httptest.NewServer(http.HandlerFunc(Events))
+		defer httpServer.Close()
+
+		conn, _, err := websocket.DefaultDialer.Dial("ws"+strings.TrimPrefix(httpServer.URL, "http")+"?client=anonymous&revision=0", nil)
+		assert.NoError(t, err)
+		defer conn.Close()
+		_ = conn.SetReadDeadline(time.Now().Add(5 * time.Second))
+		assert.Equal(t, 0, readEvent(t, conn, events.TypeSync).Revision)
+
+		assert.NoError(t, conn.WriteJSON(editorMessage{Type: messageOperation, Revision: 0, Operation: collab.Operation{}.Retain(5).Insert("!")}))
+
+		// The connection is closed instead of confirming the operation
+		var event events.Event
+		assert.Error(t, conn.ReadJSON(&event))
+		content, _ := bin.Document.Snapshot()
+		assert.Equal(t, "hello", content)
+	})
+
+	t.Run("Saves through the API include pending edits", func(t *testing.T) {
+		config.App.Server.Web.PersistInterval = time.Hour
+		bin := setupStorage(t, "hello")
+
+		_, err := bin.Document.Receive("alice", 0, collab.Operation{}.Retain(5).Insert(" world"), nil)
+		assert.NoError(t, err)
+
+		// The client fetched the content before the edit, so its If-Match is stale
+		request := httptest.NewRequest("POST", "/save", strings.NewReader(`{"content":"new"}`))
+		request.Header.Set("If-Match", etag("hello"))
+		recorder := httptest.NewRecorder()
+		Save(recorder, request)
+
+		assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+		assert.Equal(t, "hello world", bin.Content.Get())
+		assert.False(t, bin.Document.Dirty())
+	})
+	t.Run("Pending edits are written on shutdown", func(t *testing.T) {
+		config.App.Server.Web.PersistInterval = time.Hour
+		bin := setupStorage(t, "hello")
+		t.Cleanup(func() { editingClosed = false })
+
+		httpServer := newEditorServer()
+		defer httpServer.Close()
+
+		conn := connectEditor(t, httpServer.URL, "client=alice&revision=0")
+		defer conn.Close()
+		assert.Equal(t, 0, readEvent(t, conn, events.TypeSync).Revision)
+
+		assert.NoError(t, conn.WriteJSON(editorMessage{Type: messageOperation, Revision: 0, Operation: collab.Operation{}.Retain(5).Insert(" world")}))
+		assert.Equal(t, 1, readEvent(t, conn, events.TypeOperation).Revision)
+
+		persistDocuments()
+
+		stored, err := config.App.Storage.Store.Get(config.DefaultBin)
+		assert.NoError(t, err)
+		assert.Equal(t, "hello world", stored)
+
+		// Later operations are refused instead of being confirmed
+		assert.NoError(t, conn.WriteJSON(editorMessage{Type: messageOperation, Revision: 1, Operation: collab.Operation{}.Retain(11).Insert("!")}))
+		for {
+			var event events.Event
+			if err := conn.ReadJSON(&event); err != nil {
+				break
+			}
+			assert.NotEqual(t, events.TypeOperation, event.Type)
+		}
+		content, _ := bin.Document.Snapshot()
+		assert.Equal(t, "hello world", content)
+	})
+	t.Run("Removed bins are not recreated by connected editors", func(t *testing.T) {
+		config.App.Server.Web.PersistInterval = time.Hour
+		bin := setupStorage(t, "hello")
+
+		httpServer := newEditorServer()
+		defer httpServer.Close()
+
+		conn := connectEditor(t, httpServer.URL, "client=alice&revision=0")
+		defer conn.Close()
+		assert.Equal(t, 0, readEvent(t, conn, events.TypeSync).Revision)
+
+		assert.NoError(t, conn.WriteJSON(editorMessage{Type: messageOperation, Revision: 0, Operation: collab.Operation{}.Retain(5).Insert(" world")}))
+		assert.Equal(t, 1, readEvent(t, conn, events.TypeOperation).Revision)
+
+		bin.Lock()
+		assert.NoError(t, removeBin(nil, bin, metrics.RemovalExpired))
+		bin.Unlock()
+
+		// The editor receives the empty content and is disconnected
+		removed := readEvent(t, conn, events.TypeContent)
+		assert.Equal(t, "", removed.Content)
+		var event events.Event
+		err := conn.ReadJSON(&event)
+		assert.True(t, websocket.IsCloseError(err, websocket.CloseNormalClosure), err)
+		assert.ErrorContains(t, err, closeReasonRemoved)
+
+		// Pending edits and save requests of the editor do not write the bin again
+		_, err = bin.Document.Receive("alice", removed.Revision, collab.Operation{}.Insert("again"), nil)
+		assert.NoError(t, err)
+		bin.Lock()
+		assert.NoError(t, persistDocument(bin, "alice"))
+		bin.Unlock()
+
+		_, err = config.App.Storage.Store.Get(config.DefaultBin)
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+		_, loaded := config.App.Storage.Bins.Get(config.DefaultBin)
+		assert.False(t, loaded)
+	})
+}
@@ -0,0 +1,200 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+	"vimbin/internal/audit"
+	"vimbin/internal/config"
+	"vimbin/internal/events"
+	"vimbin/internal/metrics"
+	"vimbin/internal/server"
+	"vimbin/internal/storage"
+
+	"github.com/rs/zerolog/log"
+)
+
+// expireInterval is the interval in which expired bins are removed in the background.
+// Expired bins are also removed when they are accessed, so they are never served.
+const expireInterval = time.Minute
+
+func init() {
+	server.RegisterTask("expire", expireInterval, removeExpiredBins)
+}
+
+// removeExpiredBins removes all bins whose expiry was reached.
+func removeExpiredBins() {
+	now := time.Now()
+
+	names, err := config.App.Storage.ExpiredBins(now)
+	if err != nil {
+		log.Error().Msgf("Error listing expired bins: %v", err)
+		return
+	}
+
+	for _, name := range names {
+		bin, err := config.App.Storage.Bin(name, false)
+		if err != nil {
+			if !errors.Is(err, storage.ErrNotFound) {
+				log.Error().Msgf("Error loading expired bin '%s': %v", name, err)
+			}
+			continue
+		}
+
+		bin.Lock()
+		if !bin.Removed() && bin.Metadata().Expired(now) {
+			if err := removeBin(nil, bin, metrics.RemovalExpired); err != nil {
+				log.Error().Msg(err.Error())
+			}
+		}
+		bin.Unlock()
+	}
+}
+
+// loadBin retrieves a bin like config.Storage.Bin, but removes it first if it has expired.
+//
+// Parameters:
+//   - name: string
+//     The name of the bin.
+//   - create: bool
+//     If true, a missing or expired bin is created with empty content.
+//
+// Returns:
+//   - *config.Bin
+//     The bin.
+//   - error
+//     storage.ErrNotFound if the bin does not exist or has expired and create is false, or an error
+//     if the bin cannot be read or removed.
+func loadBin(name string, create bool) (*config.Bin, error) {
+	bin, err := config.App.Storage.Bin(name, create)
+	if err != nil || !bin.Metadata().Expired(time.Now()) {
+		return bin, err
+	}
+
+	bin.Lock()
+	if !bin.Removed() {
+		err = removeBin(nil, bin, metrics.RemovalExpired)
+	}
+	bin.Unlock()
+	if err != nil {
+		return nil, err
+	}
+
+	return config.App.Storage.Bin(name, create)
+}
+
+// removeBin removes a bin with its metadata and history, sends the now empty content to
+// connected editors and followers and ends their connections. The caller must hold the lock of the bin.
+//
+// Parameters:
+//   - r: *http.Request
+//     The request which removes the bin, or nil if the server removes it.
+//   - bin: *config.Bin
+//     The bin to remove.
+//   - reason: string
+//     Why the bin is removed, one of the metrics.Removal* reasons.
+//
+// Returns:
+//   - error
+//     An error if the bin cannot be removed from the storage backend.
+func removeBin(r *http.Request, bin *config.Bin, reason string) error {
+	entry := audit.NewEntry(audit.ActionDelete, bin.Name, bin.Content.Get(), "", contentHash)
+	entry.Reason = reason
+
+	if err := config.App.Storage.Remove(bin); err != nil {
+		metrics.StorageErrors.WithLabelValues(metrics.OperationWrite).Inc()
+		return err
+	}
+	metrics.BinsRemoved.WithLabelValues(reason).Inc()
+	log.Info().Msgf("Removed bin '%s' (%s)", bin.Name, reason)
+	auditChange(r, entry)
+
+	// Send the empty content and disconnect the editors, so they do not edit a bin which no longer exists
+	revision := bin.Document.Reset("")
+	hub.Publish(events.Event{Type: events.TypeContent, Bin: bin.Name, Content: "", ETag: etag(""), Revision: revision})
+	hub.Close(bin.Name)
+
+	return nil
+}
+
+// requestMetadata returns the metadata requested with the 'expire' and 'burn' query parameters.
+//
+// 'expire' is a duration like '10m' or '24h' after which the bin is removed, '0' removes the expiry.
+// 'burn' removes the bin after its content was fetched once, 'burn=false' turns this off again.
+//
+// Parameters:
+//   - r: *http.Request
+//     The HTTP request being processed.
+//   - bin: *config.Bin
+//     The bin the request modifies.
+//
+// Returns:
+//   - storage.Metadata
+//     The current metadata of the bin, changed as requested.
+//   - bool
+//     True if one of the parameters was set.
+//   - error
+//     An error if a parameter is invalid or the default bin should expire.
+func requestMetadata(r *http.Request, bin *config.Bin) (storage.Metadata, bool, error) {
+	query := r.URL.Query()
+	metadata := bin.Metadata()
+	if !query.Has("expire") && !query.Has("burn") {
+		return metadata, false, nil
+	}
+
+	if query.Has("expire") {
+		value := query.Get("expire")
+		ttl, err := time.ParseDuration(value)
+		if err != nil || ttl < 0 {
+			return metadata, false, fmt.Errorf("Invalid expiry '%s'. Use a duration like 10m or 24h, 0 never expires", value)
+		}
+		metadata.ExpiresAt = time.Time{}
+		if ttl > 0 {
+			metadata.ExpiresAt = time.Now().Add(ttl).UTC()
+		}
+	}
+
+	if query.Has("burn") {
+		// A parameter without value ('?burn') turns it on
+		value := query.Get("burn")
+		burn, err := strconv.ParseBool(value)
+		if value == "" {
+			burn, err = true, nil
+		}
+		if err != nil {
+			return metadata, false, fmt.Errorf("Invalid value '%s' for burn. Use true or false", value)
+		}
+		metadata.BurnAfterRead = burn
+	}
+
+	if bin.Name == config.DefaultBin && (!metadata.ExpiresAt.IsZero() || metadata.BurnAfterRead) {
+		return metadata, false, errors.New("The default bin cannot expire or be burned after reading. Use a named bin")
+	}
+
+	return metadata, true, nil
+}
+
+// refuseBurnAfterRead responds with 403 Forbidden if the bin is burned after reading,
+// since its content may only be read once with a fetch.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - bin: *config.Bin
+//     The bin to check.
+//
+// Returns:
+//   - bool
+//     True if the bin is burned after reading and a response has already been written.
+func refuseBurnAfterRead(w http.ResponseWriter, bin *config.Bin) bool {
+	if !bin.Metadata().BurnAfterRead {
+		return false
+	}
+
+	msg := fmt.Sprintf("Bin '%s' is burned after reading. Its content can only be fetched once", bin.Name)
+	log.Error().Msg(msg)
+	http.Error(w, msg, http.StatusForbidden)
+	return true
+}
@@ -0,0 +1,155 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+	"vimbin/internal/config"
+	"vimbin/internal/storage"
+
+	"github.com/gorilla/mux"
+	"github.com/stretchr/testify/assert"
+)
+
+// binRequest creates a request for a route of a named bin.
+func binRequest(method, target, name, body string) *http.Request {
+	return mux.SetURLVars(httptest.NewRequest(method, target, strings.NewReader(body)), map[string]string{"name": name})
+}
+
+func TestExpire(t *testing.T) {
+	t.Run("Save sets the expiry", func(t *testing.T) {
+		setupStorage(t, "")
+
+		recorder := httptest.NewRecorder()
+		Save(recorder, binRequest("POST", "/api/bins/temp?expire=1h", "temp", `{"content":"temporary"}`))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		metadata, err := config.App.Storage.Store.GetMetadata("temp")
+		assert.NoError(t, err)
+		assert.WithinDuration(t, time.Now().Add(time.Hour), metadata.ExpiresAt, time.Minute)
+
+		recorder = httptest.NewRecorder()
+		Fetch(recorder, binRequest("GET", "/api/bins/temp", "temp", ""))
+		assert.Equal(t, "temporary", recorder.Body.String())
+		assert.Equal(t, metadata.ExpiresAt.Format(time.RFC3339), recorder.Header().Get("X-Expires-At"))
+	})
+
+	t.Run("Expired bins are not served", func(t *testing.T) {
+		setupStorage(t, "")
+		assert.NoError(t, config.App.Storage.Store.Put("temp", "temporary"))
+		assert.NoError(t, config.App.Storage.Store.PutMetadata("temp", storage.Metadata{ExpiresAt: time.Now().Add(-time.Second)}))
+
+		recorder := httptest.NewRecorder()
+		Fetch(recorder, binRequest("GET", "/api/bins/temp", "temp", ""))
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+		_, err := config.App.Storage.Store.Get("temp")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("Janitor removes expired bins", func(t *testing.T) {
+		setupStorage(t, "")
+		for name, ttl := range map[string]time.Duration{"expired": -time.Second, "valid": time.Hour} {
+			assert.NoError(t, config.App.Storage.Store.Put(name, "content"))
+			assert.NoError(t, config.App.Storage.Store.PutMetadata(name, storage.Metadata{ExpiresAt: time.Now().Add(ttl)}))
+		}
+
+		removeExpiredBins()
+
+		names, err := config.App.Storage.Store.List()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{config.DefaultBin, "valid"}, names)
+	})
+
+	t.Run("Invalid expiry", func(t *testing.T) {
+		setupStorage(t, "")
+
+		recorder := httptest.NewRecorder()
+		Save(recorder, binRequest("POST", "/api/bins/temp?expire=soon", "temp", `{"content":"temporary"}`))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+
+	t.Run("Default bin cannot expire", func(t *testing.T) {
+		bin := setupStorage(t, "content")
+
+		recorder := httptest.NewRecorder()
+		Save(recorder, httptest.NewRequest("POST", "/save?expire=1h", strings.NewReader(`{"content":"new"}`)))
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Equal(t, "content", bin.Content.Get())
+	})
+}
+
+func TestBurnAfterRead(t *testing.T) {
+	t.Run("Content is fetched once", func(t *testing.T) {
+		setupStorage(t, "")
+
+		recorder := httptest.NewRecorder()
+		Save(recorder, binRequest("POST", "/api/bins/secret?burn", "secret", `{"content":"password"}`))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		revisions, err := config.App.Storage.Store.Revisions("secret")
+		assert.NoError(t, err)
+		assert.Empty(t, revisions)
+
+		recorder = httptest.NewRecorder()
+		Fetch(recorder, binRequest("GET", "/api/bins/secret", "secret", ""))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "password", recorder.Body.String())
+		assert.Equal(t, "no-store", recorder.Header().Get("Cache-Control"))
+
+		recorder = httptest.NewRecorder()
+		Fetch(recorder, binRequest("GET", "/api/bins/secret", "secret", ""))
+		assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+		_, err = config.App.Storage.Store.Get("secret")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("Concurrent fetches get the content only once", func(t *testing.T) {
+		setupStorage(t, "")
+		assert.NoError(t, config.App.Storage.Store.Put("secret", "password"))
+		assert.NoError(t, config.App.Storage.Store.PutMetadata("secret", storage.Metadata{BurnAfterRead: true}))
+
+		var wg sync.WaitGroup
+		codes := make(chan int, 10)
+		for range 10 {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				recorder := httptest.NewRecorder()
+				Fetch(recorder, binRequest("GET", "/api/bins/secret", "secret", ""))
+				codes <- recorder.Code
+			}()
+		}
+		wg.Wait()
+		close(codes)
+
+		served := 0
+		for code := range codes {
+			if code == http.StatusOK {
+				served++
+			}
+		}
+		assert.Equal(t, 1, served)
+	})
+
+	t.Run("Content is not shown by other routes", func(t *testing.T) {
+		setupStorage(t, "")
+		assert.NoError(t, config.App.Storage.Store.Put("secret", "password"))
+		assert.NoError(t, config.App.Storage.Store.PutMetadata("secret", storage.Metadata{BurnAfterRead: true}))
+
+		recorder := httptest.NewRecorder()
+		History(recorder, binRequest("GET", "/api/bins/secret/history", "secret", ""))
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+		request := binRequest("POST", "/api/bins/secret", "secret", `{"content":"other"}`)
+		request.Header.Set("If-Match", etag("old"))
+		recorder = httptest.NewRecorder()
+		Save(recorder, request)
+		assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+		assert.NotContains(t, recorder.Body.String(), "password")
+	})
+}
@@ -1,15 +1,19 @@
 package handlers
 
 import (
+	"fmt"
 	"net/http"
+	"time"
 	"vimbin/internal/config"
+	"vimbin/internal/metrics"
 	"vimbin/internal/server"
 
 	"github.com/rs/zerolog/log"
 )
 
 func init() {
-	server.Register("/fetch", "Fetch content from storage file", true, Fetch, "GET")
+	server.Register("/fetch", "Fetch content from storage file", server.ScopeRead, Fetch, "GET")
+	server.Register("/api/bins/{name}", "Fetch content from a named bin", server.ScopeRead, Fetch, "GET")
 }
 
 // Fetch handles HTTP requests for fetching content.
@@ -17,7 +21,11 @@ func init() {
 // This function logs the incoming request and retrieves content from storage.
 // If content is present, it sets the appropriate HTTP headers and writes
 // the content to the response. If no content is found, it returns a
-// HTTP status code of No Content.
+// HTTP status code of No Content. The ETag header is set to the hash of the
+// content, a matching If-None-Match header results in Not Modified.
+//
+// Bins which are burned after reading are removed together with their history
+// before their content is sent, so it can only be fetched once.
 //
 // Parameters:
 //   - w: http.ResponseWriter
@@ -27,9 +35,29 @@ func init() {
 func Fetch(w http.ResponseWriter, r *http.Request) {
 	log.Trace().Msg(generateHTTPRequestLogEntry(r))
 
+	bin, ok := resolveBin(w, r, false)
+	if !ok {
+		return
+	}
+
+	if bin.Metadata().BurnAfterRead {
+		fetchAndBurn(w, r, bin)
+		return
+	}
+
 	w.Header().Set("Content-Type", "application/text")
+	if expiresAt := bin.Metadata().ExpiresAt; !expiresAt.IsZero() {
+		w.Header().Set("X-Expires-At", expiresAt.Format(time.RFC3339))
+	}
+
+	content := bin.Content.Get()
+	w.Header().Set("ETag", etag(content))
+
+	if ifNoneMatch := r.Header.Get("If-None-Match"); ifNoneMatch != "" && etagMatches(ifNoneMatch, content) {
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
 
-	content := config.App.Storage.Content.Get()
 	if len(content) == 0 {
 		w.WriteHeader(http.StatusNoContent)
 		return
@@ -42,3 +70,47 @@ func Fetch(w http.ResponseWriter, r *http.Request) {
 		return
 	}
 }
+
+// fetchAndBurn sends the content of a bin which is burned after reading and removes it.
+//
+// The bin is removed before the content is sent, while holding its lock, so concurrent
+// fetches never get the content twice. If the bin cannot be removed, the content is not sent.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+//   - bin: *config.Bin
+//     The bin to fetch.
+func fetchAndBurn(w http.ResponseWriter, r *http.Request, bin *config.Bin) {
+	bin.Lock()
+	defer bin.Unlock()
+
+	// Another request burned the bin while waiting for the lock
+	if bin.Removed() {
+		http.Error(w, fmt.Sprintf("Bin '%s' not found", bin.Name), http.StatusNotFound)
+		return
+	}
+
+	content := bin.Content.Get()
+	if err := removeBin(r, bin, metrics.RemovalBurned); err != nil {
+		msg := fmt.Sprintf("Error burning bin: %v", err)
+		log.Error().Msg(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/text")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Burned", "true")
+
+	if len(content) == 0 {
+		w.WriteHeader(http.StatusNoContent)
+		return
+	}
+
+	if _, err := w.Write([]byte(content)); err != nil {
+		log.Error().Msgf("Error writing response: %v", err)
+	}
+}
@@ -0,0 +1,167 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+	"vimbin/internal/server"
+
+	"github.com/rs/zerolog/log"
+)
+
+const (
+	followWriteWait     = 10 * time.Second // followWriteWait is the time allowed to write an event to the client.
+	followCheckInterval = 15 * time.Second // followCheckInterval is the interval of keep-alive comments, which also recheck the content.
+)
+
+// Event types of the follow stream.
+const (
+	followAppend = "append" // followAppend carries content appended after the offset the client knows.
+	followReset  = "reset"  // followReset carries the complete content, which replaced the content the client knows.
+)
+
+// followChunk is the data of an event of the follow stream.
+type followChunk struct {
+	Offset  int    `json:"offset"`  // Offset is the byte offset of the content in the bin.
+	Content string `json:"content"` // Content is the content starting at Offset.
+}
+
+func init() {
+	server.Register("/follow", "Follow appended content of the default bin", server.ScopeRead, Follow, "GET")
+	server.Register("/api/bins/{name}/follow", "Follow appended content of a named bin", server.ScopeRead, Follow, "GET")
+}
+
+// Follow handles HTTP requests for following a bin like 'tail -f'.
+//
+// The response is a stream of server-sent events. The client passes the byte offset it already
+// knows in the 'since' query parameter or the 'Last-Event-ID' header and receives the content after
+// it as 'append' event. Every time content is appended to the bin, the new content is sent as
+// 'append' event. If the content is replaced, the complete content is sent as 'reset' event.
+// The ID of every event is the offset to resume from.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+func Follow(w http.ResponseWriter, r *http.Request) {
+	log.Trace().Msg(generateHTTPRequestLogEntry(r))
+
+	bin, ok := resolveBin(w, r, false)
+	if !ok {
+		return
+	}
+	if refuseBurnAfterRead(w, bin) {
+		return
+	}
+
+	since := r.URL.Query().Get("since")
+	if lastEventID := r.Header.Get("Last-Event-ID"); lastEventID != "" {
+		since = lastEventID
+	}
+	offset := 0
+	if since != "" {
+		parsed, err := strconv.Atoi(since)
+		if err != nil || parsed < 0 {
+			msg := fmt.Sprintf("Invalid offset '%s'", since)
+			log.Error().Msg(msg)
+			http.Error(w, msg, http.StatusBadRequest)
+			return
+		}
+		offset = parsed
+	}
+
+	// Subscribe before reading the content, so no change can be missed in between
+	subscriber := hub.Subscribe(bin.Name)
+	defer hub.Unsubscribe(subscriber)
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-store")
+	w.Header().Set("X-Accel-Buffering", "no") // Tell nginx not to buffer the stream
+	w.WriteHeader(http.StatusOK)
+
+	stream := &followStream{w: w, controller: http.NewResponseController(w)}
+	// Send the headers right away, so the client knows it is connected
+	if err := stream.write(""); err != nil {
+		return
+	}
+
+	// The content the client knows, unless it is longer than the content of the bin
+	content := bin.Content.Get()
+	if offset <= len(content) {
+		stream.known = content[:offset]
+	} else {
+		stream.reset = true
+	}
+
+	log.Debug().Msgf("Client %s follows bin '%s' from offset %d", r.RemoteAddr, bin.Name, offset)
+	defer log.Debug().Msgf("Client %s stopped following bin '%s'", r.RemoteAddr, bin.Name)
+
+	if err := stream.send(content); err != nil {
+		return
+	}
+
+	ticker := time.NewTicker(followCheckInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case _, ok := <-subscriber.Events:
+			if !ok {
+				// The client could not keep up, it reconnects and resumes from its offset
+				return
+			}
+		case <-ticker.C:
+			if err := stream.write(": keep-alive\n\n"); err != nil {
+				return
+			}
+		case <-r.Context().Done():
+			return
+		}
+
+		if err := stream.send(bin.Content.Get()); err != nil {
+			return
+		}
+	}
+}
+
+// followStream writes the changes of a bin to a client following it.
+type followStream struct {
+	w          http.ResponseWriter
+	controller *http.ResponseController
+	known      string // known is the content the client knows.
+	reset      bool   // reset forces sending the complete content with the next change.
+}
+
+// send sends the difference between the content known by the client and the current content.
+func (s *followStream) send(content string) error {
+	eventType, offset := followAppend, len(s.known)
+	if s.reset || !strings.HasPrefix(content, s.known) {
+		eventType, offset = followReset, 0
+	}
+	if eventType == followAppend && len(content) == len(s.known) {
+		return nil
+	}
+
+	data, err := json.Marshal(followChunk{Offset: offset, Content: content[offset:]})
+	if err != nil {
+		return err
+	}
+
+	s.known, s.reset = content, false
+	return s.write(fmt.Sprintf("event: %s\nid: %d\ndata: %s\n\n", eventType, len(content), data))
+}
+
+// write writes to the stream and flushes it.
+func (s *followStream) write(message string) error {
+	// Extend the write timeout of the server until after the next keep-alive comment
+	_ = s.controller.SetWriteDeadline(time.Now().Add(followCheckInterval + followWriteWait))
+	if _, err := s.w.Write([]byte(message)); err != nil {
+		log.Debug().Msgf("Error writing follow stream: %v", err)
+		return err
+	}
+	return s.controller.Flush()
+}
@@ -0,0 +1,87 @@
+package handlers
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// followClient reads the events of the follow stream.
+type followClient struct {
+	t      *testing.T
+	reader *bufio.Reader
+}
+
+// next returns the next event of the stream without the trailing empty line.
+func (c *followClient) next() string {
+	c.t.Helper()
+
+	var lines []string
+	for {
+		line, err := c.reader.ReadString('\n')
+		if !assert.NoError(c.t, err) {
+			return ""
+		}
+		line = strings.TrimSuffix(line, "\n")
+		if line == "" && len(lines) > 0 {
+			return strings.Join(lines, "\n")
+		}
+		if line != "" && !strings.HasPrefix(line, ":") {
+			lines = append(lines, line)
+		}
+	}
+}
+
+// follow connects to the Follow handler.
+func follow(t *testing.T, url, since string) *followClient {
+	t.Helper()
+
+	response, err := http.Get(url + "?since=" + since)
+	assert.NoError(t, err)
+	assert.Equal(t, http.StatusOK, response.StatusCode)
+	assert.Equal(t, "text/event-stream", response.Header.Get("Content-Type"))
+	t.Cleanup(func() { response.Body.Close() })
+
+	return &followClient{t: t, reader: bufio.NewReader(response.Body)}
+}
+
+func TestFollow(t *testing.T) {
+	t.Run("Appended content is streamed", func(t *testing.T) {
+		setupStorage(t, "first")
+
+		httpServer := httptest.NewServer(http.HandlerFunc(Follow))
+		t.Cleanup(httpServer.Close)
+
+		client := follow(t, httpServer.URL, "2")
+		assert.Equal(t, "event: append\nid: 5\ndata: {\"offset\":2,\"content\":\"rst\"}", client.next())
+
+		Append(httptest.NewRecorder(), httptest.NewRequest("POST", "/append", strings.NewReader(`{"content":"\nsecond"}`)))
+		assert.Equal(t, "event: append\nid: 12\ndata: {\"offset\":5,\"content\":\"\\nsecond\"}", client.next())
+
+		Save(httptest.NewRecorder(), httptest.NewRequest("POST", "/save", strings.NewReader(`{"content":"new"}`)))
+		assert.Equal(t, "event: reset\nid: 3\ndata: {\"offset\":0,\"content\":\"new\"}", client.next())
+	})
+
+	t.Run("Offset behind the end of the content resets the client", func(t *testing.T) {
+		setupStorage(t, "short")
+
+		httpServer := httptest.NewServer(http.HandlerFunc(Follow))
+		t.Cleanup(httpServer.Close)
+
+		client := follow(t, httpServer.URL, "100")
+		assert.Equal(t, "event: reset\nid: 5\ndata: {\"offset\":0,\"content\":\"short\"}", client.next())
+	})
+
+	t.Run("Invalid offset", func(t *testing.T) {
+		setupStorage(t, "content")
+
+		recorder := httptest.NewRecorder()
+		Follow(recorder, httptest.NewRequest("GET", "/follow?since=-1", nil))
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+	})
+}
@@ -0,0 +1,11 @@
+package handlers
+
+import (
+	"vimbin/internal/config"
+	"vimbin/internal/server"
+)
+
+func init() {
+	server.RegisterReadinessCheck("storage", func() error { return config.App.Storage.CheckAccess() })
+	server.RegisterReadinessCheck("content", func() error { return config.App.Storage.CheckConsistency() })
+}
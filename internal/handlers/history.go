@@ -0,0 +1,249 @@
+package handlers
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"vimbin/internal/audit"
+	"vimbin/internal/config"
+	"vimbin/internal/metrics"
+	"vimbin/internal/server"
+	"vimbin/internal/storage"
+
+	"github.com/gorilla/mux"
+	"github.com/rs/zerolog/log"
+)
+
+// errNoHistory is returned when no revision is recorded, because the bin is burned after reading.
+var errNoHistory = errors.New("Bins which are burned after reading have no history")
+
+func init() {
+	server.Register("/api/history", "List revisions of the default bin", server.ScopeRead, History, "GET")
+	server.Register("/api/history/{rev}", "Fetch a revision of the default bin", server.ScopeRead, HistoryRevision, "GET")
+	server.Register("/api/restore/{rev}", "Restore a revision of the default bin", server.ScopeWrite, Restore, "POST")
+	server.Register("/api/bins/{name}/history", "List revisions of a named bin", server.ScopeRead, History, "GET")
+	server.Register("/api/bins/{name}/history/{rev}", "Fetch a revision of a named bin", server.ScopeRead, HistoryRevision, "GET")
+	server.Register("/api/bins/{name}/restore/{rev}", "Restore a revision of a named bin", server.ScopeWrite, Restore, "POST")
+}
+
+// History handles HTTP requests for listing the revisions of a bin.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+func History(w http.ResponseWriter, r *http.Request) {
+	log.Trace().Msg(generateHTTPRequestLogEntry(r))
+
+	bin, ok := resolveBin(w, r, false)
+	if !ok {
+		return
+	}
+	if refuseBurnAfterRead(w, bin) {
+		return
+	}
+
+	revisions, err := config.App.Storage.Store.Revisions(bin.Name)
+	if err != nil {
+		msg := fmt.Sprintf("Error listing revisions: %v", err)
+		log.Error().Msg(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	writeJSONResponse(w, map[string]interface{}{"bin": bin.Name, "revisions": revisions})
+}
+
+// HistoryRevision handles HTTP requests for fetching the content of a revision.
+//
+// The content is written as the response body, the revision metadata is set
+// in the X-Revision, X-Revision-Time, X-Revision-Hash and X-Revision-Author headers.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+func HistoryRevision(w http.ResponseWriter, r *http.Request) {
+	log.Trace().Msg(generateHTTPRequestLogEntry(r))
+
+	bin, ok := resolveBin(w, r, false)
+	if !ok {
+		return
+	}
+	if refuseBurnAfterRead(w, bin) {
+		return
+	}
+
+	revision, content, ok := resolveRevision(w, r, bin)
+	if !ok {
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/text")
+	setRevisionHeaders(w, revision)
+	if _, err := w.Write([]byte(content)); err != nil {
+		log.Error().Msgf("Error writing response: %v", err)
+	}
+}
+
+// Restore handles HTTP requests for restoring a revision.
+//
+// The content of the revision replaces the current content of the bin. Restoring
+// creates a new revision, so the history stays immutable. An If-Match header is
+// honoured the same way as for saves.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+func Restore(w http.ResponseWriter, r *http.Request) {
+	log.Trace().Msg(generateHTTPRequestLogEntry(r))
+
+	bin, ok := resolveBin(w, r, false)
+	if !ok {
+		return
+	}
+	if refuseBurnAfterRead(w, bin) {
+		return
+	}
+
+	bin.Lock()
+	defer bin.Unlock()
+
+	if !persistBeforeModification(w, bin) {
+		return
+	}
+
+	restored, content, ok := resolveRevision(w, r, bin)
+	if !ok {
+		return
+	}
+
+	previousContent := bin.Content.Get()
+	if !checkPrecondition(w, r, bin, previousContent) {
+		return
+	}
+
+	w.Header().Set("ETag", etag(content))
+	if previousContent == content {
+		metrics.NoChangeSaves.WithLabelValues(routeLabel(r)).Inc()
+		writeJSONResponse(w, map[string]string{"status": "no changes"})
+		return
+	}
+
+	// Revisions of encrypted bins are encrypted as well, commitContent marks the bin
+	if !commitContent(w, r, bin, audit.ActionRestore, previousContent, content, nil) {
+		return
+	}
+	log.Debug().Msgf("Restored revision %d of bin '%s'", restored.ID, bin.Name)
+
+	writeJSONResponse(w, map[string]string{"status": "success"})
+}
+
+// resolveRevision retrieves the revision addressed by the '{rev}' route variable.
+// If the revision cannot be resolved, an error response is written.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+//   - bin: *config.Bin
+//     The bin the revision belongs to.
+//
+// Returns:
+//   - storage.Revision
+//     The revision metadata.
+//   - string
+//     The content of the revision.
+//   - bool
+//     False if the revision could not be resolved and a response has already been written.
+func resolveRevision(w http.ResponseWriter, r *http.Request, bin *config.Bin) (storage.Revision, string, bool) {
+	id, err := strconv.Atoi(mux.Vars(r)["rev"])
+	if err != nil || id < 1 {
+		http.Error(w, fmt.Sprintf("Invalid revision '%s'", mux.Vars(r)["rev"]), http.StatusBadRequest)
+		return storage.Revision{}, "", false
+	}
+
+	revision, content, err := config.App.Storage.Store.Revision(bin.Name, id)
+	if errors.Is(err, storage.ErrRevisionNotFound) {
+		http.Error(w, fmt.Sprintf("Revision %d of bin '%s' not found", id, bin.Name), http.StatusNotFound)
+		return storage.Revision{}, "", false
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error loading revision: %v", err)
+		log.Error().Msg(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return storage.Revision{}, "", false
+	}
+
+	return revision, content, true
+}
+
+// setRevisionHeaders sets the metadata of a revision as response headers.
+func setRevisionHeaders(w http.ResponseWriter, revision storage.Revision) {
+	w.Header().Set("X-Revision", strconv.Itoa(revision.ID))
+	w.Header().Set("X-Revision-Time", revision.Time.Format(http.TimeFormat))
+	w.Header().Set("X-Revision-Hash", revision.Hash)
+	w.Header().Set("X-Revision-Author", revision.Author)
+}
+
+// recordRevision stores the content of a bin as a new revision and prunes old revisions
+// according to the configured retention. The caller must hold the lock of the bin.
+//
+// If the bin has no revisions yet, the previous content is recorded first, so the
+// content which existed before the history was enabled is not lost. Bins which are
+// burned after reading have no history.
+//
+// Parameters:
+//   - bin: *config.Bin
+//     The bin which was modified.
+//   - previousContent: string
+//     The content of the bin before the modification.
+//   - content: string
+//     The new content of the bin.
+//   - author: string
+//     The name of the token which modified the bin.
+//
+// Returns:
+//   - storage.Revision
+//     The created revision.
+//   - error
+//     An error if the revision cannot be stored. The error is already logged.
+func recordRevision(bin *config.Bin, previousContent, content, author string) (storage.Revision, error) {
+	// Content which may only be read once must not be kept anywhere else
+	if bin.Metadata().BurnAfterRead {
+		return storage.Revision{}, errNoHistory
+	}
+
+	store := config.App.Storage.Store
+
+	if ids, err := storage.RevisionIDs(store, bin.Name); err == nil && len(ids) == 0 && previousContent != "" {
+		if _, err := store.AddRevision(bin.Name, storage.NewRevision(previousContent, ""), previousContent); err != nil {
+			metrics.StorageErrors.WithLabelValues(metrics.OperationHistory).Inc()
+			log.Error().Msgf("Error recording previous content of bin '%s': %v", bin.Name, err)
+		}
+	}
+
+	revision, err := store.AddRevision(bin.Name, storage.NewRevision(content, author), content)
+	if err != nil {
+		metrics.StorageErrors.WithLabelValues(metrics.OperationHistory).Inc()
+		log.Error().Msgf("Error recording revision of bin '%s': %v", bin.Name, err)
+		return storage.Revision{}, err
+	}
+	log.Debug().Msgf("Recorded revision %d of bin '%s'", revision.ID, bin.Name)
+
+	history := config.App.History()
+	pruned, err := storage.PruneRevisions(store, bin.Name, history.MaxRevisions, history.MaxAge)
+	if err != nil {
+		log.Error().Msgf("Error pruning revisions of bin '%s': %v", bin.Name, err)
+	} else if pruned > 0 {
+		log.Debug().Msgf("Pruned %d revisions of bin '%s'", pruned, bin.Name)
+	}
+
+	return revision, nil
+}
@@ -9,14 +9,20 @@ import (
 )
 
 func init() {
-	server.Register("/", "Home site with editor", false, Home, "GET")
+	server.Register("/", "Home site with editor", server.ScopeNone, Home, "GET")
+	server.Register("/b/{name}", "Editor for a named bin", server.ScopeNone, Home, "GET")
 }
 
 // Home handles HTTP requests for the home page.
 //
 // This function logs the incoming request, retrieves content from storage,
 // and renders the home page using an HTML template. It sets the page title
-// and content based on the retrieved information from the storage.
+// and content based on the retrieved information from the storage. Requests
+// to '/b/{name}' open the editor for the named bin instead of the default bin.
+// Only visitors who may edit open bins which do not exist yet, so anonymous requests
+// cannot fill the memory with empty bins.
+// The API token is never rendered into the page; browsers log in to edit.
+// Encrypted content is rendered as is and decrypted by the editor with the key in the URL fragment.
 //
 // Parameters:
 //   - w: http.ResponseWriter
@@ -26,13 +32,38 @@ func init() {
 func Home(w http.ResponseWriter, r *http.Request) {
 	log.Trace().Msg(generateHTTPRequestLogEntry(r))
 
+	bin, ok := resolveBin(w, r, server.HasScope(r, server.ScopeWrite))
+	if !ok {
+		return
+	}
+	if refuseBurnAfterRead(w, bin) {
+		return
+	}
+
+	// Write pending collaborative edits, so the content and its ETag match the storage backend
+	bin.Lock()
+	if !persistBeforeModification(w, bin) {
+		bin.Unlock()
+		return
+	}
+	content, revision := bin.Document.Snapshot()
+	bin.Unlock()
+	w.Header().Set("ETag", etag(content))
+
+	web := config.App.Web()
 	page := Page{
 		Title:      "vimbin - a pastebin with vim motion",
-		Content:    config.App.Storage.Content.Get(),
-		Token:      config.App.Server.Api.Token.Get(),
-		Theme:      config.App.Server.Web.Theme,
-		LightTheme: config.App.Server.Web.LightTheme,
-		DarkTheme:  config.App.Server.Web.DarkTheme,
+		Bin:        bin.Name,
+		Content:    content,
+		ETag:       etag(content),
+		Revision:   revision,
+		User:       server.TokenName(r),
+		CSRFToken:  server.CSRFToken(r),
+		CanEdit:    server.HasScope(r, server.ScopeWrite),
+		Encrypted:  bin.Metadata().Encrypted,
+		Theme:      web.Theme,
+		LightTheme: web.LightTheme,
+		DarkTheme:  web.DarkTheme,
 		Version:    config.App.Version,
 	}
 
@@ -0,0 +1,30 @@
+package handlers
+
+import (
+	"html/template"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"vimbin/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHome(t *testing.T) {
+	t.Run("Content is escaped", func(t *testing.T) {
+		setupStorage(t, `</textarea><script>alert(csrfToken)</script>`)
+
+		htmlTemplate, err := template.ParseFiles("../../web/templates/index.html")
+		assert.NoError(t, err)
+		config.App.HtmlTemplate = htmlTemplate
+		t.Cleanup(func() { config.App.HtmlTemplate = nil })
+
+		recorder := httptest.NewRecorder()
+		Home(recorder, httptest.NewRequest("GET", "/", nil))
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		body := recorder.Body.String()
+		assert.NotContains(t, body, "<script>alert")
+		assert.Contains(t, body, `&lt;/textarea&gt;&lt;script&gt;alert(csrfToken)&lt;/script&gt;</textarea>`)
+	})
+}
@@ -0,0 +1,180 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"vimbin/internal/audit"
+	"vimbin/internal/metrics"
+	"vimbin/internal/server"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Line operations supported by Patch.
+const (
+	lineInsert  = "insert"  // lineInsert inserts lines before a line.
+	linePrepend = "prepend" // linePrepend inserts lines before the first line.
+	lineAppend  = "append"  // lineAppend inserts lines after the last line.
+	lineReplace = "replace" // lineReplace replaces a range of lines.
+	lineDelete  = "delete"  // lineDelete deletes a range of lines.
+)
+
+// lineOperation is a change of the content addressed by line numbers, which start at 1.
+type lineOperation struct {
+	Op      string `json:"op"`      // Op is the type of the operation.
+	Line    int    `json:"line"`    // Line is the line to insert before. The number of lines + 1 inserts after the last line.
+	From    int    `json:"from"`    // From is the first line of the range to replace or delete.
+	To      int    `json:"to"`      // To is the last line of the range to replace or delete.
+	Content string `json:"content"` // Content are the lines to insert. A final newline is optional.
+}
+
+// patchRequest is the body of a patch request.
+type patchRequest struct {
+	Operations []lineOperation `json:"operations"` // Operations are applied in order, each to the result of the previous one.
+}
+
+func init() {
+	server.Register("/api/content", "Change lines of the default bin", server.ScopeWrite, Patch, "PATCH")
+	server.Register("/api/bins/{name}", "Change lines of a named bin", server.ScopeWrite, Patch, "PATCH")
+}
+
+// Patch handles HTTP requests for changing lines of a bin.
+//
+// The body is a JSON object with a list of operations, which are applied in order. Either all
+// operations are applied and written at once, or none if one of them is invalid. Like saves,
+// patches honor the If-Match header. Bins which are end-to-end encrypted are refused.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+func Patch(w http.ResponseWriter, r *http.Request) {
+	log.Trace().Msg(generateHTTPRequestLogEntry(r))
+
+	limitBody(w, r)
+	var request patchRequest
+	if err := json.NewDecoder(r.Body).Decode(&request); err != nil {
+		msg := fmt.Sprintf("Error decoding JSON: %v", err)
+		log.Error().Msg(msg)
+		http.Error(w, msg, bodyErrorStatus(err))
+		return
+	}
+	if len(request.Operations) == 0 {
+		msg := "Missing 'operations' in JSON"
+		log.Error().Msg(msg)
+		http.Error(w, msg, http.StatusBadRequest)
+		return
+	}
+
+	bin, ok := resolveBin(w, r, true)
+	if !ok {
+		return
+	}
+
+	bin.Lock()
+	defer bin.Unlock()
+
+	if refuseEncrypted(w, bin) {
+		return
+	}
+
+	if !persistBeforeModification(w, bin) {
+		return
+	}
+
+	previousContent := bin.Content.Get()
+	if !checkPrecondition(w, r, bin, previousContent) {
+		return
+	}
+
+	content, err := applyLineOperations(previousContent, request.Operations)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		http.Error(w, err.Error(), http.StatusUnprocessableEntity)
+		return
+	}
+
+	w.Header().Set("ETag", etag(content))
+	if previousContent == content {
+		metrics.NoChangeSaves.WithLabelValues(routeLabel(r)).Inc()
+		writeJSONResponse(w, map[string]string{"status": "no changes"})
+		return
+	}
+
+	if !commitContent(w, r, bin, audit.ActionPatch, previousContent, content, nil) {
+		return
+	}
+	log.Debug().Msgf("Applied %d line operations to bin '%s'", len(request.Operations), bin.Name)
+
+	writeJSONResponse(w, map[string]string{"status": "success"})
+}
+
+// applyLineOperations applies line operations to content.
+//
+// Parameters:
+//   - content: string
+//     The content to change.
+//   - operations: []lineOperation
+//     The operations, applied in order.
+//
+// Returns:
+//   - string
+//     The changed content. Whether it ends with a newline is kept.
+//   - error
+//     An error describing the first invalid operation.
+func applyLineOperations(content string, operations []lineOperation) (string, error) {
+	lines := splitLines(content)
+	finalNewline := strings.HasSuffix(content, "\n")
+	if content == "" {
+		// Empty content gets a final newline if the inserted content has one
+		finalNewline = strings.HasSuffix(operations[0].Content, "\n")
+	}
+
+	for i, operation := range operations {
+		inserted := splitLines(operation.Content)
+
+		switch operation.Op {
+		case lineInsert, linePrepend, lineAppend:
+			line := operation.Line
+			if operation.Op == linePrepend {
+				line = 1
+			} else if operation.Op == lineAppend {
+				line = len(lines) + 1
+			}
+			if line < 1 || line > len(lines)+1 {
+				return "", fmt.Errorf("Operation #%d: line %d is out of range. The content has %d lines", i+1, line, len(lines))
+			}
+			lines = slices.Insert(lines, line-1, inserted...)
+		case lineReplace, lineDelete:
+			if operation.From < 1 || operation.To < operation.From || operation.To > len(lines) {
+				return "", fmt.Errorf("Operation #%d: lines %d-%d are out of range. The content has %d lines", i+1, operation.From, operation.To, len(lines))
+			}
+			if operation.Op == lineDelete {
+				inserted = nil
+			}
+			lines = slices.Replace(lines, operation.From-1, operation.To, inserted...)
+		default:
+			return "", fmt.Errorf("Operation #%d: unsupported operation '%s'. Supported operations are: %s", i+1, operation.Op,
+				[]string{lineInsert, linePrepend, lineAppend, lineReplace, lineDelete})
+		}
+	}
+
+	result := strings.Join(lines, "\n")
+	if finalNewline && len(lines) > 0 {
+		result += "\n"
+	}
+
+	return result, nil
+}
+
+// splitLines splits text into lines without their newline. A final newline does not start another line.
+func splitLines(text string) []string {
+	if text == "" {
+		return []string{}
+	}
+	return strings.Split(strings.TrimSuffix(text, "\n"), "\n")
+}
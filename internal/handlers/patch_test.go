@@ -0,0 +1,99 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"vimbin/internal/config"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestApplyLineOperations(t *testing.T) {
+	t.Run("Supported operations", func(t *testing.T) {
+		tests := []struct {
+			name      string
+			content   string
+			operation lineOperation
+			expected  string
+		}{
+			{"Insert before a line", "a\nb\nc\n", lineOperation{Op: lineInsert, Line: 2, Content: "x\ny"}, "a\nx\ny\nb\nc\n"},
+			{"Insert after the last line", "a\nb", lineOperation{Op: lineInsert, Line: 3, Content: "c\n"}, "a\nb\nc"},
+			{"Insert an empty line", "a\nb\n", lineOperation{Op: lineInsert, Line: 2, Content: "\n"}, "a\n\nb\n"},
+			{"Prepend", "a\n", lineOperation{Op: linePrepend, Content: "title"}, "title\na\n"},
+			{"Append", "a\n", lineOperation{Op: lineAppend, Content: "z"}, "a\nz\n"},
+			{"Replace a range", "a\nb\nc\nd\n", lineOperation{Op: lineReplace, From: 2, To: 3, Content: "x"}, "a\nx\nd\n"},
+			{"Delete a range", "a\nb\nc\n", lineOperation{Op: lineDelete, From: 1, To: 2}, "c\n"},
+			{"Delete everything", "a\nb\n", lineOperation{Op: lineDelete, From: 1, To: 2}, ""},
+			{"Insert into empty content", "", lineOperation{Op: lineInsert, Line: 1, Content: "a\n"}, "a\n"},
+		}
+
+		for _, test := range tests {
+			content, err := applyLineOperations(test.content, []lineOperation{test.operation})
+			assert.NoError(t, err, test.name)
+			assert.Equal(t, test.expected, content, test.name)
+		}
+	})
+
+	t.Run("Operations are applied in order", func(t *testing.T) {
+		content, err := applyLineOperations("a\nb\nc\n", []lineOperation{
+			{Op: lineDelete, From: 1, To: 1},
+			{Op: lineInsert, Line: 1, Content: "x"},
+		})
+		assert.NoError(t, err)
+		assert.Equal(t, "x\nb\nc\n", content)
+	})
+
+	t.Run("Invalid operations", func(t *testing.T) {
+		_, err := applyLineOperations("a\nb\n", []lineOperation{{Op: lineInsert, Line: 4}})
+		assert.EqualError(t, err, "Operation #1: line 4 is out of range. The content has 2 lines")
+
+		_, err = applyLineOperations("a\nb\n", []lineOperation{{Op: lineAppend}, {Op: lineReplace, From: 2, To: 1}})
+		assert.EqualError(t, err, "Operation #2: lines 2-1 are out of range. The content has 2 lines")
+
+		_, err = applyLineOperations("a\n", []lineOperation{{Op: "move"}})
+		assert.EqualError(t, err, "Operation #1: unsupported operation 'move'. Supported operations are: [insert prepend append replace delete]")
+	})
+}
+
+func TestPatch(t *testing.T) {
+	t.Run("Operations are written at once", func(t *testing.T) {
+		bin := setupStorage(t, "# Runbook\nold step\n")
+
+		body := `{"operations":[{"op":"replace","from":2,"to":2,"content":"new step"},{"op":"append","content":"last step"}]}`
+		recorder := httptest.NewRecorder()
+		Patch(recorder, httptest.NewRequest("PATCH", "/api/content", strings.NewReader(body)))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, etag("# Runbook\nnew step\nlast step\n"), recorder.Header().Get("ETag"))
+		assert.Equal(t, "# Runbook\nnew step\nlast step\n", bin.Content.Get())
+
+		stored, err := config.App.Storage.Store.Get(config.DefaultBin)
+		assert.NoError(t, err)
+		assert.Equal(t, "# Runbook\nnew step\nlast step\n", stored)
+	})
+
+	t.Run("Nothing is written if an operation is invalid", func(t *testing.T) {
+		bin := setupStorage(t, "a\n")
+
+		body := `{"operations":[{"op":"prepend","content":"x"},{"op":"delete","from":5,"to":6}]}`
+		recorder := httptest.NewRecorder()
+		Patch(recorder, httptest.NewRequest("PATCH", "/api/content", strings.NewReader(body)))
+
+		assert.Equal(t, http.StatusUnprocessableEntity, recorder.Code)
+		assert.Equal(t, "a\n", bin.Content.Get())
+	})
+
+	t.Run("Patch with outdated If-Match", func(t *testing.T) {
+		bin := setupStorage(t, "changed\n")
+
+		request := httptest.NewRequest("PATCH", "/api/content", strings.NewReader(`{"operations":[{"op":"prepend","content":"x"}]}`))
+		request.Header.Set("If-Match", etag("old\n"))
+		recorder := httptest.NewRecorder()
+		Patch(recorder, request)
+
+		assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+		assert.Equal(t, "changed\n", bin.Content.Get())
+	})
+}
@@ -0,0 +1,103 @@
+package handlers
+
+import (
+	"context"
+	"errors"
+	"vimbin/internal/audit"
+	"vimbin/internal/config"
+	"vimbin/internal/events"
+	"vimbin/internal/server"
+	"vimbin/internal/storage"
+
+	"github.com/rs/zerolog/log"
+)
+
+// externalAuthor is the author of revisions created from changes made outside of vimbin.
+const externalAuthor = "external"
+
+func init() {
+	server.RegisterService("reload", watchStorage)
+}
+
+// watchStorage reloads bins whose content was changed outside of vimbin, e.g. by editing the
+// storage file or restoring a backup, until the context is canceled. Storage backends which
+// cannot be changed by other programs are not watched.
+//
+// Parameters:
+//   - ctx: context.Context
+//     The context which stops watching when canceled.
+func watchStorage(ctx context.Context) {
+	watcher, ok := config.App.Storage.Store.(storage.Watcher)
+	if !ok {
+		return
+	}
+
+	if err := watcher.Watch(ctx, reloadBin); err != nil {
+		log.Error().Msgf("Changes of the storage made outside of vimbin are not reloaded: %v", err)
+		return
+	}
+	log.Debug().Msg("Watching the storage for changes made outside of vimbin")
+}
+
+// reloadBin replaces the in-memory content of a loaded bin with the content of the storage backend
+// if it was changed outside of vimbin. Bins which are not loaded are read on their next access anyway.
+//
+// The changed content is recorded as revision and replaces the collaborative document, so connected
+// editors load it and pending edits based on the previous content are discarded. Saves based on the
+// previous content fail, as its ETag does not match anymore.
+//
+// Parameters:
+//   - name: string
+//     The name of the bin which may have been changed.
+func reloadBin(name string) {
+	bin, ok := config.App.Storage.Bins.Get(name)
+	if !ok {
+		return
+	}
+
+	bin.Lock()
+	defer bin.Unlock()
+
+	if bin.Removed() {
+		return
+	}
+
+	content, err := config.App.Storage.Store.Get(name)
+	if errors.Is(err, storage.ErrNotFound) {
+		return // Removed outside of vimbin, the next save creates it again
+	}
+	if err != nil {
+		log.Error().Msgf("Error reloading bin '%s': %v", name, err)
+		return
+	}
+
+	// Changes written by vimbin itself are already in memory
+	previousContent := bin.Content.Get()
+	if content == previousContent {
+		return
+	}
+
+	if err := storeEncryption(bin, content); err != nil {
+		log.Error().Msg(err.Error())
+	}
+	bin.Content.Set(content)
+	log.Info().Msgf("Reloaded bin '%s', it was changed outside of vimbin", name)
+
+	entry := audit.NewEntry(audit.ActionReload, name, previousContent, content, contentHash)
+	entry.Token = externalAuthor
+	if revision, err := recordRevision(bin, previousContent, content, externalAuthor); err == nil {
+		entry.Revision = revision.ID
+	}
+	auditChange(nil, entry)
+
+	revision := bin.Document.Reset(content)
+	current, _ := bin.Document.Snapshot()
+	hub.Publish(events.Event{
+		Type:     events.TypeContent,
+		Bin:      bin.Name,
+		Content:  current,
+		ETag:     etag(content),
+		Author:   externalAuthor,
+		Revision: revision,
+	})
+}
@@ -0,0 +1,91 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+	"vimbin/internal/collab"
+	"vimbin/internal/config"
+	"vimbin/internal/events"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestReloadBin(t *testing.T) {
+	t.Run("External changes are reloaded and sent to editors", func(t *testing.T) {
+		bin := setupStorage(t, "old")
+
+		httpServer := newEditorServer()
+		defer httpServer.Close()
+
+		conn := connectEditor(t, httpServer.URL, "client=a")
+		defer conn.Close()
+		readEvent(t, conn, events.TypeContent)
+
+		assert.NoError(t, config.App.Storage.Store.Put(config.DefaultBin, "changed on disk"))
+		reloadBin(config.DefaultBin)
+
+		assert.Equal(t, "changed on disk", bin.Content.Get())
+		event := readEvent(t, conn, events.TypeContent)
+		assert.Equal(t, "changed on disk", event.Content)
+		assert.Equal(t, etag("changed on disk"), event.ETag)
+		assert.Equal(t, externalAuthor, event.Author)
+
+		revisions, err := config.App.Storage.Store.Revisions(config.DefaultBin)
+		assert.NoError(t, err)
+		assert.Equal(t, externalAuthor, revisions[len(revisions)-1].Author)
+	})
+
+	t.Run("Saves based on the previous content are refused", func(t *testing.T) {
+		bin := setupStorage(t, "old")
+
+		assert.NoError(t, config.App.Storage.Store.Put(config.DefaultBin, "changed on disk"))
+		reloadBin(config.DefaultBin)
+
+		request := httptest.NewRequest("POST", "/save", strings.NewReader(`{"content":"stale"}`))
+		request.Header.Set("If-Match", etag("old"))
+		recorder := httptest.NewRecorder()
+		Save(recorder, request)
+
+		assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+		assert.Equal(t, "changed on disk", bin.Content.Get())
+	})
+
+	t.Run("Pending edits of editors are not written over the reloaded content", func(t *testing.T) {
+		config.App.Server.Web.PersistInterval = time.Hour
+		bin := setupStorage(t, "old")
+
+		_, err := bin.Document.Receive("a", 0, collab.Operation{}.Retain(3).Insert(" edit"), nil)
+		assert.NoError(t, err)
+
+		assert.NoError(t, config.App.Storage.Store.Put(config.DefaultBin, "changed on disk"))
+		reloadBin(config.DefaultBin)
+
+		// The edit was based on a revision which is gone
+		_, err = bin.Document.Receive("a", 1, collab.Operation{}.Retain(8).Insert("!"), nil)
+		assert.ErrorIs(t, err, collab.ErrUnknownRevision)
+
+		bin.Lock()
+		assert.NoError(t, persistDocument(bin, ""))
+		bin.Unlock()
+
+		content, _ := config.App.Storage.Store.Get(config.DefaultBin)
+		assert.Equal(t, "changed on disk", content)
+	})
+
+	t.Run("Own writes are ignored", func(t *testing.T) {
+		bin := setupStorage(t, "old")
+
+		recorder := httptest.NewRecorder()
+		Save(recorder, httptest.NewRequest("POST", "/save", strings.NewReader(`{"content":"new"}`)))
+		revisions, _ := config.App.Storage.Store.Revisions(config.DefaultBin)
+
+		reloadBin(config.DefaultBin)
+
+		assert.Equal(t, "new", bin.Content.Get())
+		current, _ := config.App.Storage.Store.Revisions(config.DefaultBin)
+		assert.Equal(t, revisions, current)
+	})
+}
@@ -2,22 +2,22 @@ package handlers
 
 import (
 	"net/http"
-	"os"
-	"vimbin/internal/config"
+	"vimbin/internal/audit"
 	"vimbin/internal/server"
+	"vimbin/internal/storage"
 
 	"github.com/rs/zerolog/log"
 )
 
 func init() {
-	server.Register("/save", "Save content to storage file", true, Save, "POST")
+	server.Register("/save", "Save content to storage file", server.ScopeWrite, Save, "POST")
+	server.Register("/api/bins/{name}", "Save content to a named bin", server.ScopeWrite, Save, "POST")
 }
 
-// Save handles HTTP requests for saving content to a file.
+// Save handles HTTP requests for saving content to a bin.
 //
 // This function creates and injects specific functions for saving content,
-// checking if content has changed, merging old and new content, and updating
-// content in storage. It then calls the handleContentRequest function to process
+// checking if content has changed and merging old and new content. It then calls the handleContentRequest function to process
 // the HTTP request.
 //
 // Parameters:
@@ -26,11 +26,11 @@ func init() {
 //   - r: *http.Request
 //     The HTTP request be processed.
 func Save(w http.ResponseWriter, r *http.Request) {
-	// Define a function for saving content to a file
-	writeFileFunc := func(filePath, content string) error {
-		log.Trace().Msgf("Writing content to file '%s': %s", filePath, content)
+	// Define a function for saving content to the storage backend
+	writeFunc := func(store storage.Storage, name, content string) error {
+		log.Trace().Msgf("Writing content to bin '%s': %s", name, content)
 
-		return os.WriteFile(filePath, []byte(content), filePermission)
+		return store.Put(name, content)
 	}
 
 	// Define a function to check if content has changed
@@ -49,9 +49,11 @@ func Save(w http.ResponseWriter, r *http.Request) {
 		return mergedContent
 	}
 
-	// Define a function for updating content in storage
-	saveContentFunc := (*config.Content).Set
+	bin, ok := resolveBin(w, r, true)
+	if !ok {
+		return
+	}
 
 	// Process the HTTP request using the defined functions
-	handleContentRequest(w, r, writeFileFunc, hasContentChangedFunc, mergeContentFunc, saveContentFunc)
+	handleContentRequest(w, r, bin, audit.ActionSave, writeFunc, hasContentChangedFunc, mergeContentFunc)
 }
@@ -6,8 +6,14 @@ package handlers
 // utilized by the HTML template to render dynamic content.
 type Page struct {
 	Title      string // Title is the title of the page.
+	Bin        string // Bin is the name of the bin shown in the editor.
 	Content    string // Content is the content of the page.
-	Token      string // Token is the API token.
+	ETag       string // ETag is the ETag of the content, sent back as If-Match when saving.
+	Revision   int    // Revision is the revision of the collaborative document matching the content.
+	User       string // User is the name of the token the browser is logged in with.
+	CSRFToken  string // CSRFToken is the CSRF token of the browser session, sent along with saves.
+	CanEdit    bool   // CanEdit indicates if the browser is logged in with a token allowed to change the content.
+	Encrypted  bool   // Encrypted indicates the content is end-to-end encrypted and decrypted by the editor.
 	Theme      string // Theme is the theme of the page.
 	LightTheme string // LightTheme is the light theme of the page.
 	DarkTheme  string // DarkTheme is the dark theme of the page.
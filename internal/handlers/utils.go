@@ -2,12 +2,21 @@ package handlers
 
 import (
 	"encoding/json"
+	"errors"
 	"fmt"
+	"io"
+	"mime"
 	"net/http"
 	"strconv"
 	"strings"
+	"vimbin/internal/audit"
 	"vimbin/internal/config"
+	"vimbin/internal/e2e"
+	"vimbin/internal/metrics"
+	"vimbin/internal/server"
+	"vimbin/internal/storage"
 
+	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
 )
 
@@ -19,9 +28,6 @@ import (
 // before starting the HTTP server to ensure all handlers are registered.
 func Collect() {}
 
-// filePermission represents the default file permission used in the application.
-const filePermission = 0644
-
 // generateHTTPRequestLogEntry generates a log entry for an HTTP request.
 //
 // This function takes an HTTP request as input and creates a formatted log entry
@@ -50,62 +56,452 @@ func generateHTTPRequestLogEntry(req *http.Request) string {
 	return fmt.Sprintf("%s %s%s", req.Method, req.RequestURI, query)
 }
 
+// resolveBin retrieves the bin addressed by the HTTP request.
+//
+// The bin name is taken from the '{name}' route variable. Routes without this variable
+// address the default bin. Expired bins are removed and treated as missing. If the bin
+// cannot be resolved, an error response is written.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+//   - create: bool
+//     If true, a missing bin is created instead of responding with 404 Not Found.
+//
+// Returns:
+//   - *config.Bin
+//     The resolved bin.
+//   - bool
+//     False if the bin could not be resolved and a response has already been written.
+func resolveBin(w http.ResponseWriter, r *http.Request, create bool) (*config.Bin, bool) {
+	name, ok := mux.Vars(r)["name"]
+	if !ok {
+		name = config.DefaultBin
+	}
+
+	if !storage.IsValidBinName(name) {
+		msg := fmt.Sprintf("Invalid bin name '%s'. Only letters, digits, '-' and '_' are allowed", name)
+		log.Error().Msg(msg)
+		http.Error(w, msg, http.StatusBadRequest)
+		return nil, false
+	}
+
+	if !server.AllowsBin(r, name) {
+		metrics.AuthFailures.WithLabelValues(metrics.ReasonBin).Inc()
+		msg := fmt.Sprintf("Token '%s' is not allowed to access bin '%s'", server.TokenName(r), name)
+		log.Error().Msg(msg)
+		http.Error(w, msg, http.StatusForbidden)
+		return nil, false
+	}
+
+	bin, err := loadBin(name, create)
+	if errors.Is(err, storage.ErrNotFound) {
+		http.Error(w, fmt.Sprintf("Bin '%s' not found", name), http.StatusNotFound)
+		return nil, false
+	}
+	if err != nil {
+		msg := fmt.Sprintf("Error loading bin: %v", err)
+		log.Error().Msg(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return nil, false
+	}
+
+	return bin, true
+}
+
+// routeLabel returns the route of a request as used in the metrics, e.g. '/api/bins/{name}'.
+//
+// Parameters:
+//   - r: *http.Request
+//     The HTTP request being processed.
+//
+// Returns:
+//   - string
+//     The path template of the matched route, or the path if the request was not routed.
+func routeLabel(r *http.Request) string {
+	if route := mux.CurrentRoute(r); route != nil {
+		if template, err := route.GetPathTemplate(); err == nil {
+			return template
+		}
+	}
+	return r.URL.Path
+}
+
+// contentHash returns the keyed hash of the content as recorded in the audit log.
+//
+// Parameters:
+//   - content: string
+//     The content to hash.
+//
+// Returns:
+//   - string
+//     The hex encoded hash of the content.
+func contentHash(content string) string {
+	if auditLog := config.App.Storage.Audit.Log; auditLog != nil {
+		return auditLog.Hash(content)
+	}
+	return storage.HashContent(config.App.Storage.Store, content)
+}
+
+// etag returns the ETag of the content, which is the quoted SHA-256 hash of the content.
+//
+// Parameters:
+//   - content: string
+//     The content to create the ETag for.
+//
+// Returns:
+//   - string
+//     The ETag of the content.
+func etag(content string) string {
+	return `"` + storage.Hash(content) + `"`
+}
+
+// etagMatches checks if an If-Match or If-None-Match header value matches the ETag of the content.
+//
+// Parameters:
+//   - header: string
+//     The header value, either '*' or a comma separated list of (weak) ETags.
+//   - content: string
+//     The current content.
+//
+// Returns:
+//   - bool
+//     True if the header matches the current content.
+func etagMatches(header, content string) bool {
+	current := etag(content)
+	for _, value := range strings.Split(header, ",") {
+		value = strings.TrimPrefix(strings.TrimSpace(value), "W/")
+		if value == "*" || value == current {
+			return true
+		}
+	}
+
+	return false
+}
+
+// checkPrecondition verifies the If-Match header of a request against the current content.
+//
+// If the header is set and does not match, the function responds with 412 Precondition Failed,
+// the ETag of the current content and a JSON body containing the current content. The content
+// of bins which are burned after reading is left out, since it may only be read with a fetch.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+//   - bin: *config.Bin
+//     The bin the request modifies.
+//   - content: string
+//     The current content of the bin.
+//
+// Returns:
+//   - bool
+//     False if the precondition failed and a response has already been written.
+func checkPrecondition(w http.ResponseWriter, r *http.Request, bin *config.Bin, content string) bool {
+	ifMatch := r.Header.Get("If-Match")
+	if ifMatch == "" || etagMatches(ifMatch, content) {
+		return true
+	}
+
+	log.Debug().Msgf("Precondition failed: If-Match %s does not match current ETag %s", ifMatch, etag(content))
+
+	response := map[string]string{"status": "conflict", "content": content}
+	if bin.Metadata().BurnAfterRead {
+		delete(response, "content")
+	}
+
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling response: %v", err)
+		log.Error().Msg(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return false
+	}
+
+	w.Header().Set("ETag", etag(content))
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusPreconditionFailed)
+	if _, err := w.Write(jsonResponse); err != nil {
+		log.Error().Msgf("Error writing response: %v", err)
+	}
+
+	return false
+}
+
+// writeJSONResponse marshals the response to JSON and writes it with the matching Content-Type.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - response: interface{}
+//     The value to marshal as JSON.
+func writeJSONResponse(w http.ResponseWriter, response interface{}) {
+	jsonResponse, err := json.Marshal(response)
+	if err != nil {
+		msg := fmt.Sprintf("Error marshalling response: %v", err)
+		log.Error().Msg(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	if _, err := w.Write(jsonResponse); err != nil {
+		log.Error().Msgf("Error writing response: %v", err)
+	}
+}
+
+// readContent reads the content sent in the body of a request.
+//
+// Bodies sent as 'text/plain' or 'application/octet-stream' are the content itself, so
+// clients can stream large content without encoding it. All other bodies must be a JSON
+// object with a 'content' field.
+//
+// Parameters:
+//   - r: *http.Request
+//     The HTTP request being processed.
+//
+// Returns:
+//   - string
+//     The content of the request.
+//   - error
+//     An error if the body cannot be read or decoded, see bodyErrorStatus.
+func readContent(w http.ResponseWriter, r *http.Request) (string, error) {
+	limitBody(w, r)
+
+	mediaType, _, _ := mime.ParseMediaType(r.Header.Get("Content-Type"))
+	if mediaType == "text/plain" || mediaType == "application/octet-stream" {
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			return "", fmt.Errorf("Error reading request body: %w", err)
+		}
+		return string(body), nil
+	}
+
+	// Parse JSON request body
+	var requestData map[string]string
+	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
+		return "", fmt.Errorf("Error decoding JSON: %w", err)
+	}
+
+	content, ok := requestData["content"]
+	if !ok {
+		return "", errors.New("Missing 'content' field in JSON")
+	}
+
+	return content, nil
+}
+
+// limitBody limits the body of a request to the configured maximum size. Reading beyond
+// it fails with an *http.MaxBytesError and closes the connection.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+func limitBody(w http.ResponseWriter, r *http.Request) {
+	if maxBytes := config.App.Web().MaxBodyBytes; maxBytes > 0 {
+		r.Body = http.MaxBytesReader(w, r.Body, maxBytes)
+	}
+}
+
+// bodyErrorStatus returns the status code for an error reading the body of a request.
+//
+// Parameters:
+//   - err: error
+//     The error returned while reading the body.
+//
+// Returns:
+//   - int
+//     413 Request Entity Too Large if the body exceeds the maximum size, 400 Bad Request otherwise.
+func bodyErrorStatus(err error) int {
+	var maxBytesError *http.MaxBytesError
+	if errors.As(err, &maxBytesError) {
+		return http.StatusRequestEntityTooLarge
+	}
+	return http.StatusBadRequest
+}
+
+// commitContent makes new content the current content of a bin. The bin is marked as encrypted
+// if needed, the content is written to the storage backend, kept as revision, recorded in the
+// audit log and sent to connected editors. On success the ETag, X-Revision and X-Bytes-Written
+// headers are set and the caller writes the response.
+//
+// The caller must hold the lock of the bin, must have written pending edits of the editors and
+// must have checked the If-Match header.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request which changes the content.
+//   - bin: *config.Bin
+//     The bin to change.
+//   - action: string
+//     How the request changes the content, one of the audit.Action* constants.
+//   - before: string
+//     The current content of the bin.
+//   - after: string
+//     The new content of the bin.
+//   - write: func() (int, error)
+//     Writes the change to the storage backend and returns the number of bytes written, e.g. only the
+//     appended content. If nil, after replaces the stored content.
+//
+// Returns:
+//   - bool
+//     False if the content could not be written and an error response has already been written.
+func commitContent(w http.ResponseWriter, r *http.Request, bin *config.Bin, action, before, after string, write func() (int, error)) bool {
+	// Mark the bin as encrypted before storing encrypted content
+	if err := storeEncryption(bin, after); err != nil {
+		log.Error().Msg(err.Error())
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return false
+	}
+
+	if write == nil {
+		write = func() (int, error) {
+			return len(after), config.App.Storage.Store.Put(bin.Name, after)
+		}
+	}
+	written, err := write()
+	if err != nil {
+		metrics.StorageErrors.WithLabelValues(metrics.OperationWrite).Inc()
+		msg := fmt.Sprintf("Error writing file: %v", err)
+		log.Error().Msg(msg)
+		http.Error(w, msg, http.StatusInternalServerError)
+		return false
+	}
+
+	// Update the in-memory content of the bin
+	bin.Content.Set(after)
+
+	metrics.BytesWritten.WithLabelValues(routeLabel(r)).Add(float64(written))
+	log.Debug().Msgf("Wrote %d bytes to bin '%s'", written, bin.Name)
+
+	// Keep the new content as immutable revision
+	entry := audit.NewEntry(action, bin.Name, before, after, contentHash)
+	if revision, err := recordRevision(bin, before, after, server.TokenName(r)); err == nil {
+		w.Header().Set("X-Revision", strconv.Itoa(revision.ID))
+		entry.Revision = revision.ID
+	}
+	auditChange(r, entry)
+
+	// Send the change to connected editors
+	updateDocument(bin, before, after, server.TokenName(r))
+
+	w.Header().Set("X-Bytes-Written", strconv.Itoa(written))
+	w.Header().Set("ETag", etag(after))
+
+	return true
+}
+
 // handleContentRequest handles HTTP requests for updating content.
 //
-// This function processes an HTTP request, decodes the JSON body, compares
+// This function processes an HTTP request, reads the content from the body, compares
 // the new content to the old content, and performs the necessary actions
 // based on the provided functions. It logs the request, checks for changes
-// in content, writes content to a file, updates content in storage, and
-// responds with appropriate JSON status messages.
+// in content, writes content to the storage backend, updates the in-memory
+// content of the bin, and responds with appropriate JSON status messages.
+//
+// If the request has an If-Match header which does not match the ETag of the
+// current content, nothing is written and 412 Precondition Failed is returned.
+// The 'expire' and 'burn' query parameters change how long the bin is kept.
+// Content encrypted by the client marks the bin as encrypted and can only be saved, not appended.
 //
 // Parameters:
 //   - w: http.ResponseWriter
 //     The HTTP response writer.
 //   - r: *http.Request
 //     The HTTP request being processed.
-//   - writeFileFunc: func(string, string) error
-//     Function for writing content to a file.
+//   - bin: *config.Bin
+//     The bin to update.
+//   - action: string
+//     How the request changes the content, recorded in the audit log (audit.ActionSave or audit.ActionAppend).
+//   - writeFunc: func(storage.Storage, string, string) error
+//     Function for writing the content of the request to the storage backend.
 //   - hasContentChangedFunc: func(string, string) bool
 //     Function to check if content has changed.
 //   - mergeContentFunc: func(string, string) string
 //     Function to merge old and new content.
-//   - saveContentFunc: func(*config.Content, string)
-//     Function for updating content in storage.
 //
 // Note: The provided functions are injected for flexibility and can be customized
 // based on specific requirements.
 func handleContentRequest(
 	w http.ResponseWriter,
 	r *http.Request,
-	writeFileFunc func(string, string) error,
+	bin *config.Bin,
+	action string,
+	writeFunc func(storage.Storage, string, string) error,
 	hasContentChangedFunc func(string, string) bool,
 	mergeContentFunc func(string, string) string,
-	saveContentFunc func(*config.Content, string),
 ) {
 	log.Trace().Msg(generateHTTPRequestLogEntry(r))
 
-	// Parse JSON request body
-	var requestData map[string]string
-	if err := json.NewDecoder(r.Body).Decode(&requestData); err != nil {
-		msg := fmt.Sprintf("Error decoding JSON: %v", err)
-		log.Error().Msg(msg)
-		http.Error(w, msg, http.StatusBadRequest)
+	newContent, err := readContent(w, r)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		http.Error(w, err.Error(), bodyErrorStatus(err))
 		return
 	}
 
-	oldContent := config.App.Storage.Content.Get()
+	// Serialize modifications of the bin so storage and memory stay in sync
+	bin.Lock()
+	defer bin.Unlock()
 
-	newContent, ok := requestData["content"]
-	if !ok {
-		msg := "Missing 'content' field in JSON"
-		log.Error().Msg(msg)
-		http.Error(w, msg, http.StatusBadRequest)
+	// Write pending edits of the editors first, so the request is based on the content they see
+	if !persistBeforeModification(w, bin) {
+		return
+	}
+
+	oldContent := bin.Content.Get()
+
+	// Refuse to overwrite content which changed since the client fetched it
+	if !checkPrecondition(w, r, bin, oldContent) {
 		return
 	}
 
+	// Encrypted content is opaque to the server, so it can only replace the content as a whole
+	mergedContent := mergeContentFunc(oldContent, newContent) // Use the provided function to append or save the new content
+	if mergedContent != newContent {
+		if refuseEncrypted(w, bin) {
+			return
+		}
+		if e2e.IsEncrypted(newContent) {
+			msg := fmt.Sprintf("Encrypted content cannot be appended to bin '%s'. Save it instead", bin.Name)
+			log.Error().Msg(msg)
+			http.Error(w, msg, http.StatusForbidden)
+			return
+		}
+	}
+
+	// Write the expiry first, so content which must expire is never stored without it
+	metadata, metadataChanged, err := requestMetadata(r, bin)
+	if err != nil {
+		log.Error().Msg(err.Error())
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if metadataChanged {
+		if err := config.App.Storage.Store.PutMetadata(bin.Name, metadata); err != nil {
+			metrics.StorageErrors.WithLabelValues(metrics.OperationWrite).Inc()
+			msg := fmt.Sprintf("Error writing metadata: %v", err)
+			log.Error().Msg(msg)
+			http.Error(w, msg, http.StatusInternalServerError)
+			return
+		}
+		bin.SetMetadata(metadata)
+		log.Debug().Msgf("Set expiry of bin '%s' to '%s' and burn after read to %t", bin.Name, metadata.ExpiresAt, metadata.BurnAfterRead)
+	}
+
 	// Compare the new content to the old content
 	if !hasContentChangedFunc(oldContent, newContent) {
+		metrics.NoChangeSaves.WithLabelValues(routeLabel(r)).Inc()
+
 		// Respond with JSON indicating no changes were made
+		w.Header().Set("ETag", etag(oldContent))
 		w.Header().Set("Content-Type", "application/json")
 		response := map[string]string{"status": "no changes"}
 
@@ -127,27 +523,16 @@ func handleContentRequest(
 		return
 	}
 
-	newContent = mergeContentFunc(oldContent, newContent) // Use the provided function to append or save the new content
-
-	log.Trace().Msgf("Got new content: %s", newContent)
+	log.Trace().Msgf("Got new content: %s", mergedContent)
 
-	// Use the provided function for writing to a file
-	if err := writeFileFunc(config.App.Storage.Path, newContent); err != nil {
-		msg := fmt.Sprintf("Error writing file: %v", err)
-		log.Error().Msg(msg)
-		http.Error(w, msg, http.StatusInternalServerError)
+	// Use the provided function for writing to the storage backend
+	write := func() (int, error) {
+		return len(newContent), writeFunc(config.App.Storage.Store, bin.Name, newContent)
+	}
+	if !commitContent(w, r, bin, action, oldContent, mergedContent, write) {
 		return
 	}
 
-	// Use the provided function for updating the content in storage
-	saveContentFunc(&config.App.Storage.Content, newContent)
-
-	size := strconv.Itoa(len(newContent))
-	log.Debug().Msgf("Wrote %s bytes to file '%s'", size, config.App.Storage.Path)
-
-	// Set the X-Bytes-Written header with the number of bytes written
-	w.Header().Set("X-Bytes-Written", size)
-
 	// Respond with JSON indicating success
 	response := map[string]string{"status": "success"}
 	jsonResponse, err := json.Marshal(response)
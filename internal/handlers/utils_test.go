@@ -0,0 +1,253 @@
+package handlers
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"testing/iotest"
+	"vimbin/internal/config"
+	"vimbin/internal/metrics"
+	"vimbin/internal/server"
+	"vimbin/internal/storage"
+
+	"github.com/gorilla/mux"
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+// setupStorage replaces the global storage with an in-memory storage containing the default bin.
+func setupStorage(t *testing.T, content string) *config.Bin {
+	t.Helper()
+
+	config.App.Storage = config.Storage{Store: storage.NewMemory()}
+	assert.NoError(t, config.App.Storage.Store.Put(config.DefaultBin, content))
+
+	bin, err := config.App.Storage.Bin(config.DefaultBin, false)
+	assert.NoError(t, err)
+
+	return bin
+}
+
+func TestEtagMatches(t *testing.T) {
+	t.Run("Matching ETag", func(t *testing.T) {
+		assert.True(t, etagMatches(etag("content"), "content"))
+		assert.True(t, etagMatches(`"other", `+etag("content"), "content"))
+		assert.True(t, etagMatches("W/"+etag("content"), "content"))
+		assert.True(t, etagMatches("*", "content"))
+	})
+
+	t.Run("Not matching ETag", func(t *testing.T) {
+		assert.False(t, etagMatches(etag("old content"), "content"))
+		assert.False(t, etagMatches(`"garbage"`, "content"))
+	})
+}
+
+func TestSave(t *testing.T) {
+	t.Run("Save with matching If-Match", func(t *testing.T) {
+		bin := setupStorage(t, "old")
+
+		request := httptest.NewRequest("POST", "/save", strings.NewReader(`{"content":"new"}`))
+		request.Header.Set("If-Match", etag("old"))
+		recorder := httptest.NewRecorder()
+		Save(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, etag("new"), recorder.Header().Get("ETag"))
+		assert.Equal(t, "new", bin.Content.Get())
+	})
+
+	t.Run("Save with stale If-Match", func(t *testing.T) {
+		bin := setupStorage(t, "changed by someone else")
+
+		request := httptest.NewRequest("POST", "/save", strings.NewReader(`{"content":"new"}`))
+		request.Header.Set("If-Match", etag("old"))
+		recorder := httptest.NewRecorder()
+		Save(recorder, request)
+
+		assert.Equal(t, http.StatusPreconditionFailed, recorder.Code)
+		assert.Equal(t, etag("changed by someone else"), recorder.Header().Get("ETag"))
+		assert.JSONEq(t, `{"status":"conflict","content":"changed by someone else"}`, recorder.Body.String())
+		assert.Equal(t, "changed by someone else", bin.Content.Get())
+	})
+
+	t.Run("Save without If-Match", func(t *testing.T) {
+		bin := setupStorage(t, "old")
+
+		request := httptest.NewRequest("POST", "/save", strings.NewReader(`{"content":"new"}`))
+		recorder := httptest.NewRecorder()
+		Save(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "new", bin.Content.Get())
+
+		stored, err := config.App.Storage.Store.Get(config.DefaultBin)
+		assert.NoError(t, err)
+		assert.Equal(t, "new", stored)
+	})
+
+	t.Run("Save plain text body", func(t *testing.T) {
+		bin := setupStorage(t, "old")
+
+		request := httptest.NewRequest("POST", "/save", strings.NewReader(`{"content":"not JSON"}`))
+		request.Header.Set("Content-Type", "text/plain; charset=utf-8")
+		recorder := httptest.NewRecorder()
+		Save(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, `{"content":"not JSON"}`, bin.Content.Get())
+	})
+
+	t.Run("Incomplete plain text body is not saved", func(t *testing.T) {
+		bin := setupStorage(t, "old")
+
+		request := httptest.NewRequest("POST", "/save", io.MultiReader(strings.NewReader("partial"), iotest.ErrReader(io.ErrUnexpectedEOF)))
+		request.Header.Set("Content-Type", "application/octet-stream")
+		recorder := httptest.NewRecorder()
+		Save(recorder, request)
+
+		assert.Equal(t, http.StatusBadRequest, recorder.Code)
+		assert.Equal(t, "old", bin.Content.Get())
+	})
+}
+
+func TestSaveMetrics(t *testing.T) {
+	t.Run("Written bytes and saves without changes are counted", func(t *testing.T) {
+		setupStorage(t, "old")
+		written := testutil.ToFloat64(metrics.BytesWritten.WithLabelValues("/save"))
+		noChanges := testutil.ToFloat64(metrics.NoChangeSaves.WithLabelValues("/save"))
+
+		Save(httptest.NewRecorder(), httptest.NewRequest("POST", "/save", strings.NewReader(`{"content":"new"}`)))
+		Save(httptest.NewRecorder(), httptest.NewRequest("POST", "/save", strings.NewReader(`{"content":"new"}`)))
+
+		assert.Equal(t, written+3, testutil.ToFloat64(metrics.BytesWritten.WithLabelValues("/save")))
+		assert.Equal(t, noChanges+1, testutil.ToFloat64(metrics.NoChangeSaves.WithLabelValues("/save")))
+	})
+}
+
+func TestAppend(t *testing.T) {
+	t.Run("Append only writes the new content", func(t *testing.T) {
+		bin := setupStorage(t, "first")
+
+		request := httptest.NewRequest("POST", "/append", strings.NewReader(`{"content":"\nsecond"}`))
+		recorder := httptest.NewRecorder()
+		Append(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "7", recorder.Header().Get("X-Bytes-Written"))
+		assert.Equal(t, "first\nsecond", bin.Content.Get())
+
+		stored, err := config.App.Storage.Store.Get(config.DefaultBin)
+		assert.NoError(t, err)
+		assert.Equal(t, "first\nsecond", stored)
+	})
+}
+
+func TestTokenBins(t *testing.T) {
+	tokens := []server.Token{{Name: "ci", Value: "ci-token", Scopes: []server.Scope{server.ScopeAppend}, Bins: []string{"logs"}}}
+
+	router := mux.NewRouter()
+	router.Handle("/api/bins/{name}/append", server.ApiTokenMiddleware(Append, tokens, server.ScopeAppend))
+	router.Handle("/api/bins", server.ApiTokenMiddleware(ListBins, tokens, server.ScopeAppend))
+
+	t.Run("Token may append to its bins", func(t *testing.T) {
+		setupStorage(t, "")
+
+		request := httptest.NewRequest("POST", "/api/bins/logs/append", strings.NewReader(`{"content":"line"}`))
+		request.Header.Set("X-API-Token", "ci-token")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+
+		stored, err := config.App.Storage.Store.Get("logs")
+		assert.NoError(t, err)
+		assert.Equal(t, "line", stored)
+	})
+
+	t.Run("Token may not access other bins", func(t *testing.T) {
+		setupStorage(t, "")
+
+		request := httptest.NewRequest("POST", "/api/bins/secrets/append", strings.NewReader(`{"content":"line"}`))
+		request.Header.Set("X-API-Token", "ci-token")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+		_, err := config.App.Storage.Store.Get("secrets")
+		assert.ErrorIs(t, err, storage.ErrNotFound)
+	})
+
+	t.Run("Listing only shows allowed bins", func(t *testing.T) {
+		setupStorage(t, "")
+		assert.NoError(t, config.App.Storage.Store.Put("logs", "line"))
+		assert.NoError(t, config.App.Storage.Store.Put("secrets", "hidden"))
+
+		request := httptest.NewRequest("GET", "/api/bins", nil)
+		request.Header.Set("X-API-Token", "ci-token")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.JSONEq(t, `{"bins":["logs"]}`, recorder.Body.String())
+	})
+}
+
+func TestAnonymousRequestsDoNotCreateBins(t *testing.T) {
+	for name, handler := range map[string]http.HandlerFunc{"Editor": Home, "Collaborative editing": Events} {
+		t.Run(name, func(t *testing.T) {
+			setupStorage(t, "")
+
+			recorder := httptest.NewRecorder()
+			handler(recorder, binRequest("GET", "/b/unknown", "unknown", ""))
+			assert.Equal(t, http.StatusNotFound, recorder.Code)
+
+			_, loaded := config.App.Storage.Bins.Get("unknown")
+			assert.False(t, loaded)
+		})
+	}
+}
+
+func TestMaxBodySize(t *testing.T) {
+	config.App.Server.Web.MaxBodyBytes = 16
+	t.Cleanup(func() { config.App.Server.Web.MaxBodyBytes = 0 })
+
+	large := strings.Repeat("a", 32)
+	for name, test := range map[string]struct {
+		handler     http.HandlerFunc
+		contentType string
+		body        string
+	}{
+		"Plain text save": {Save, "text/plain", large},
+		"JSON save":       {Save, "application/json", `{"content":"` + large + `"}`},
+		"Append":          {Append, "text/plain", large},
+		"Patch":           {Patch, "application/json", `{"operations":[{"op":"append","content":"` + large + `"}]}`},
+		"Diff":            {ApplyDiff, "text/x-diff", "--- a\n+++ b\n@@ -1 +1 @@\n-old\n+" + large + "\n"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			bin := setupStorage(t, "old")
+
+			request := httptest.NewRequest("POST", "/save", strings.NewReader(test.body))
+			request.Header.Set("Content-Type", test.contentType)
+			recorder := httptest.NewRecorder()
+			test.handler(recorder, request)
+
+			assert.Equal(t, http.StatusRequestEntityTooLarge, recorder.Code)
+			assert.Equal(t, "old", bin.Content.Get())
+		})
+	}
+
+	t.Run("Bodies within the limit are accepted", func(t *testing.T) {
+		bin := setupStorage(t, "old")
+
+		request := httptest.NewRequest("POST", "/save", strings.NewReader("small"))
+		request.Header.Set("Content-Type", "text/plain")
+		recorder := httptest.NewRecorder()
+		Save(recorder, request)
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "small", bin.Content.Get())
+	})
+}
@@ -0,0 +1,169 @@
+package metrics
+
+import (
+	"net/http"
+
+	"github.com/prometheus/client_golang/prometheus"
+	"github.com/prometheus/client_golang/prometheus/promauto"
+	"github.com/prometheus/client_golang/prometheus/promhttp"
+)
+
+// namespace is the prefix of all metrics.
+const namespace = "vimbin"
+
+// Reasons for authentication failures.
+const (
+	ReasonMissing = "missing" // ReasonMissing means no credentials were sent.
+	ReasonInvalid = "invalid" // ReasonInvalid means the token, session or client certificate is unknown.
+	ReasonCSRF    = "csrf"    // ReasonCSRF means the CSRF token of a session was missing or wrong.
+	ReasonScope   = "scope"   // ReasonScope means the token was not granted the scope of the route.
+	ReasonBin     = "bin"     // ReasonBin means the token may not access the bin.
+	ReasonLogin   = "login"   // ReasonLogin means logging in with an invalid token.
+)
+
+// Reasons for refusing requests with HTTP 429 Too Many Requests.
+const (
+	LimitRate    = "rate"    // LimitRate means the client exceeded the rate limit of the route.
+	LimitLockout = "lockout" // LimitLockout means the client is locked out after failed authentications.
+)
+
+// Operations of the storage backend which can fail.
+const (
+	OperationWrite   = "write"   // OperationWrite is writing the content of a bin.
+	OperationHistory = "history" // OperationHistory is recording a revision.
+	OperationAudit   = "audit"   // OperationAudit is recording a content mutation in the audit log.
+)
+
+// Reasons for removing bins.
+const (
+	RemovalExpired = "expired" // RemovalExpired means the expiry of the bin was reached.
+	RemovalBurned  = "burned"  // RemovalBurned means the content of a burn-after-read bin was fetched.
+)
+
+var (
+	// Requests counts the HTTP requests per route, method and status code.
+	Requests = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "requests_total",
+		Help:      "Number of HTTP requests per route, method and status code.",
+	}, []string{"route", "method", "code"})
+
+	// RequestDuration observes the latency of HTTP requests per route and method.
+	RequestDuration = promauto.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: namespace,
+		Subsystem: "http",
+		Name:      "request_duration_seconds",
+		Help:      "Latency of HTTP requests per route and method.",
+		Buckets:   prometheus.DefBuckets,
+	}, []string{"route", "method"})
+
+	// BytesWritten counts the bytes written to the storage backend per route.
+	BytesWritten = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bytes_written_total",
+		Help:      "Number of bytes written to the storage backend per route.",
+	}, []string{"route"})
+
+	// NoChangeSaves counts saves which were not written because the content did not change.
+	NoChangeSaves = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "no_change_saves_total",
+		Help:      "Number of saves which did not change the content per route.",
+	}, []string{"route"})
+
+	// AuthFailures counts rejected requests per reason.
+	AuthFailures = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "auth_failures_total",
+		Help:      "Number of requests rejected because of missing or insufficient credentials.",
+	}, []string{"reason"})
+
+	// RateLimited counts requests refused because of rate limits or lockouts per route and reason.
+	RateLimited = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "rate_limited_requests_total",
+		Help:      "Number of requests refused because of rate limits or lockouts per route and reason.",
+	}, []string{"route", "reason"})
+
+	// Lockouts counts clients locked out after failed authentications.
+	Lockouts = promauto.NewCounter(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "lockouts_total",
+		Help:      "Number of clients locked out after failed authentications.",
+	})
+
+	// StorageErrors counts failed operations of the storage backend.
+	StorageErrors = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "storage_errors_total",
+		Help:      "Number of failed operations of the storage backend.",
+	}, []string{"operation"})
+
+	// BinsRemoved counts bins removed because they expired or were burned after reading.
+	BinsRemoved = promauto.NewCounterVec(prometheus.CounterOpts{
+		Namespace: namespace,
+		Name:      "bins_removed_total",
+		Help:      "Number of bins removed because they expired or were burned after reading.",
+	}, []string{"reason"})
+)
+
+// InstrumentHandler counts the requests of a route and observes their latency.
+// Hijacked connections like WebSockets keep working.
+//
+// Parameters:
+//   - route: string
+//     The route of the handler, e.g. '/api/bins/{name}'.
+//   - handler: http.Handler
+//     The handler to instrument.
+//
+// Returns:
+//   - http.Handler
+//     The instrumented handler.
+func InstrumentHandler(route string, handler http.Handler) http.Handler {
+	labels := prometheus.Labels{"route": route}
+	return promhttp.InstrumentHandlerCounter(Requests.MustCurryWith(labels),
+		promhttp.InstrumentHandlerDuration(RequestDuration.MustCurryWith(labels), handler))
+}
+
+// Handler returns the handler serving the metrics in the Prometheus text format.
+//
+// Returns:
+//   - http.Handler
+//     The handler for '/metrics'.
+func Handler() http.Handler {
+	return promhttp.Handler()
+}
+
+// RegisterContentSize exposes the current content size of every loaded bin.
+//
+// Parameters:
+//   - sizes: func() map[string]int
+//     Returns the content size in bytes per bin name. It is called on every scrape.
+func RegisterContentSize(sizes func() map[string]int) {
+	prometheus.MustRegister(&contentSizeCollector{sizes: sizes})
+}
+
+// contentSizeDesc describes the content size metric.
+var contentSizeDesc = prometheus.NewDesc(
+	prometheus.BuildFQName(namespace, "", "content_size_bytes"),
+	"Current size of the content per bin.",
+	[]string{"bin"}, nil,
+)
+
+// contentSizeCollector collects the content size of all loaded bins when scraped.
+type contentSizeCollector struct {
+	sizes func() map[string]int
+}
+
+// Describe sends the description of the content size metric.
+func (c *contentSizeCollector) Describe(ch chan<- *prometheus.Desc) {
+	ch <- contentSizeDesc
+}
+
+// Collect sends the content size of every bin.
+func (c *contentSizeCollector) Collect(ch chan<- prometheus.Metric) {
+	for bin, size := range c.sizes() {
+		ch <- prometheus.MustNewConstMetric(contentSizeDesc, prometheus.GaugeValue, float64(size), bin)
+	}
+}
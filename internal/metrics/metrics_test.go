@@ -0,0 +1,42 @@
+package metrics
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestInstrumentHandler(t *testing.T) {
+	t.Run("Requests are counted per route", func(t *testing.T) {
+		handler := InstrumentHandler("/api/bins/{name}", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusNotFound)
+		}))
+
+		before := testutil.ToFloat64(Requests.WithLabelValues("/api/bins/{name}", "get", "404"))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/bins/notes", nil))
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest("GET", "/api/bins/other", nil))
+
+		assert.Equal(t, before+2, testutil.ToFloat64(Requests.WithLabelValues("/api/bins/{name}", "get", "404")))
+		assert.Equal(t, 1, testutil.CollectAndCount(RequestDuration.MustCurryWith(map[string]string{"route": "/api/bins/{name}"})))
+	})
+}
+
+func TestContentSize(t *testing.T) {
+	t.Run("Content size is collected per bin", func(t *testing.T) {
+		collector := &contentSizeCollector{sizes: func() map[string]int {
+			return map[string]int{"default": 5, "notes": 12}
+		}}
+
+		expected := `
+# HELP vimbin_content_size_bytes Current size of the content per bin.
+# TYPE vimbin_content_size_bytes gauge
+vimbin_content_size_bytes{bin="default"} 5
+vimbin_content_size_bytes{bin="notes"} 12
+`
+		assert.NoError(t, testutil.CollectAndCompare(collector, strings.NewReader(expected)))
+	})
+}
@@ -1,40 +1,372 @@
 package server
 
 import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
 	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"vimbin/internal/metrics"
 
 	"github.com/rs/zerolog/log"
 )
 
+// DefaultTokenName is the name of the API token configured with 'server.api.token'.
+const DefaultTokenName = "default"
+
+// Scope is a permission granted to an API token and required by a route.
+type Scope string
+
+// Supported scopes. ScopeAdmin grants all other scopes and ScopeWrite includes ScopeAppend.
+const (
+	ScopeNone   Scope = ""       // ScopeNone marks routes which do not require an API token.
+	ScopeRead   Scope = "read"   // ScopeRead allows fetching content and history.
+	ScopeAppend Scope = "append" // ScopeAppend allows appending content.
+	ScopeWrite  Scope = "write"  // ScopeWrite allows replacing, appending and restoring content.
+	ScopeAdmin  Scope = "admin"  // ScopeAdmin allows everything.
+)
+
+// SupportedScopes is a list of all scopes which can be granted to an API token.
+var SupportedScopes = []Scope{ScopeRead, ScopeAppend, ScopeWrite, ScopeAdmin}
+
+// Token is an API token with the permissions granted to it.
+type Token struct {
+	Name   string   // Name identifies the token in logs and revisions.
+	Value  string   // Value is the secret sent in the X-API-Token header.
+	Scopes []Scope  // Scopes are the permissions granted to the token.
+	Bins   []string // Bins restricts the token to the given bins. An empty list allows all bins.
+}
+
+// HasScope checks if the token was granted a scope.
+//
+// Parameters:
+//   - scope: Scope
+//     The required scope.
+//
+// Returns:
+//   - bool
+//     True if the token was granted the scope or a scope including it.
+func (t Token) HasScope(scope Scope) bool {
+	for _, granted := range t.Scopes {
+		switch {
+		case granted == scope, granted == ScopeAdmin:
+			return true
+		case granted == ScopeWrite && scope == ScopeAppend:
+			return true
+		}
+	}
+	return false
+}
+
+// AllowsBin checks if the token may access a bin.
+//
+// Parameters:
+//   - name: string
+//     The name of the bin.
+//
+// Returns:
+//   - bool
+//     True if the token is not restricted to specific bins or the bin is one of them.
+func (t Token) AllowsBin(name string) bool {
+	if len(t.Bins) == 0 {
+		return true
+	}
+	for _, bin := range t.Bins {
+		if bin == name {
+			return true
+		}
+	}
+	return false
+}
+
+// Auth configures how requests are authenticated and limited.
+type Auth struct {
+	Tokens      []Token       // Tokens are the API tokens accepted by the server.
+	RequireAuth bool          // RequireAuth requires a token or a session for routes registered with ScopeNone. Implied if any token is restricted to specific bins.
+	SessionTTL  time.Duration // SessionTTL is how long a browser session is valid after logging in.
+	Limits      Limits        // Limits are the rate limits of the routes and the lockout after failed authentications.
+}
+
+// contextKey is the type of the keys used to store values in the request context.
+type contextKey string
+
+const (
+	tokenKey   contextKey = "token"   // tokenKey is the context key for the token which authenticated the request.
+	sessionKey contextKey = "session" // sessionKey is the context key for the session of the request.
+)
+
+// Errors returned when authenticating a request.
+var (
+	errMissingCredentials = errors.New("Missing API token. You must provide the API token in the X-API-Token header or log in.")
+	errInvalidCredentials = errors.New("Unauthorized")
+	errInvalidCSRFToken   = errors.New("Missing or invalid CSRF token")
+)
+
+// TokenName returns the name of the token which authenticated the request.
+//
+// Parameters:
+//   - r: *http.Request
+//     The HTTP request being processed.
+//
+// Returns:
+//   - string
+//     The name of the token, or an empty string if the request was not authenticated.
+func TokenName(r *http.Request) string {
+	token, _ := r.Context().Value(tokenKey).(Token)
+	return token.Name
+}
+
+// HasScope checks if the token which authenticated the request was granted a scope.
+//
+// Parameters:
+//   - r: *http.Request
+//     The HTTP request being processed.
+//   - scope: Scope
+//     The required scope.
+//
+// Returns:
+//   - bool
+//     True if the request was authenticated with a token granted the scope.
+func HasScope(r *http.Request, scope Scope) bool {
+	token, ok := r.Context().Value(tokenKey).(Token)
+	return ok && token.HasScope(scope)
+}
+
+// CSRFToken returns the CSRF token of the session of the request.
+//
+// Parameters:
+//   - r: *http.Request
+//     The HTTP request being processed.
+//
+// Returns:
+//   - string
+//     The CSRF token, or an empty string if the request does not belong to a session.
+func CSRFToken(r *http.Request) string {
+	current, _ := r.Context().Value(sessionKey).(session)
+	return current.csrf
+}
+
+// AllowsBin checks if the token which authenticated the request may access a bin.
+// Requests to routes which do not require a token may access every bin.
+//
+// Parameters:
+//   - r: *http.Request
+//     The HTTP request being processed.
+//   - name: string
+//     The name of the bin.
+//
+// Returns:
+//   - bool
+//     True if the bin may be accessed.
+func AllowsBin(r *http.Request, name string) bool {
+	token, ok := r.Context().Value(tokenKey).(Token)
+	if !ok {
+		return true
+	}
+	return token.AllowsBin(name)
+}
+
 // ApiTokenMiddleware is a middleware function that checks for the presence and validity of the API token.
 //
 // Parameters:
 //   - next: http.HandlerFunc
 //     The next HTTP handler in the chain.
-//   - token: string
-//     The expected API token for authentication.
+//   - tokens: []Token
+//     The API tokens accepted by the server.
+//   - scope: Scope
+//     The scope the token must have been granted.
 //
 // Behavior:
 //
-//	The middleware checks the 'X-API-Token' header in the incoming request against the provided token.
-//	If the header is missing or the token is invalid, it responds with an HTTP 401 Unauthorized status.
-//	If the token is valid, it calls the next handler in the chain.
-func ApiTokenMiddleware(next http.HandlerFunc, token string) http.HandlerFunc {
+//	The middleware checks the 'X-API-Token' header in the incoming request against the provided tokens.
+//	Without the header, a verified client certificate or the session cookie of a logged in browser is used instead. Modifying requests
+//	of a session must send the CSRF token of the session in the 'X-CSRF-Token' header.
+//	If the credentials are missing or invalid, it responds with an HTTP 401 Unauthorized status, or
+//	redirects browsers to the login page.
+//	If the CSRF token is invalid or the token was not granted the scope, it responds with an HTTP 403 Forbidden status.
+//	If the token is valid, it stores the token in the request context and calls the next handler in the chain.
+func ApiTokenMiddleware(next http.HandlerFunc, tokens []Token, scope Scope) http.HandlerFunc {
 	return func(w http.ResponseWriter, r *http.Request) {
-		apiToken := r.Header.Get("X-API-Token")
+		token, current, err := authenticate(r, tokens)
+		switch {
+		case errors.Is(err, errMissingCredentials) && isPageRequest(r):
+			http.Redirect(w, r, "/login?next="+url.QueryEscape(r.URL.RequestURI()), http.StatusSeeOther)
+			return
+		case errors.Is(err, errMissingCredentials):
+			metrics.AuthFailures.WithLabelValues(metrics.ReasonMissing).Inc()
+			log.Error().Msg(err.Error())
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		case errors.Is(err, errInvalidCSRFToken):
+			metrics.AuthFailures.WithLabelValues(metrics.ReasonCSRF).Inc()
+			log.Error().Msgf("%s for session of token '%s'", err, token.Name)
+			http.Error(w, err.Error(), http.StatusForbidden)
+			return
+		case err != nil && r.Header.Get("X-API-Token") == "" && r.TLS != nil && len(r.TLS.VerifiedChains) > 0:
+			metrics.AuthFailures.WithLabelValues(metrics.ReasonInvalid).Inc()
+			log.Error().Msgf("No token named like the client certificate '%s'", r.TLS.VerifiedChains[0][0].Subject.CommonName)
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		case err != nil:
+			metrics.AuthFailures.WithLabelValues(metrics.ReasonInvalid).Inc()
+			reportAuthFailure(r)
+			log.Error().Msgf("Unauthorized API token from %s", ClientIP(r))
+			http.Error(w, err.Error(), http.StatusUnauthorized)
+			return
+		}
 
-		if apiToken == "" {
-			msg := "Missing API token. You must provide the API token in the X-API-Token header."
+		if !token.HasScope(scope) {
+			metrics.AuthFailures.WithLabelValues(metrics.ReasonScope).Inc()
+			msg := fmt.Sprintf("Token '%s' is missing the scope '%s'", token.Name, scope)
 			log.Error().Msg(msg)
-			http.Error(w, msg, http.StatusUnauthorized)
+			http.Error(w, msg, http.StatusForbidden)
 			return
 		}
 
-		if apiToken != token {
-			log.Error().Msgf("Unauthorized API token: %s", apiToken)
-			http.Error(w, "Unauthorized", http.StatusUnauthorized)
+		next(w, withCredentials(r, token, current))
+	}
+}
+
+// optionalAuthMiddleware stores the token and session of the request in the request context if
+// the request has valid credentials, but also calls the next handler for anonymous requests.
+//
+// Parameters:
+//   - next: http.HandlerFunc
+//     The next HTTP handler in the chain.
+//   - tokens: []Token
+//     The API tokens accepted by the server.
+func optionalAuthMiddleware(next http.HandlerFunc, tokens []Token) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		token, current, err := authenticate(r, tokens)
+		if err != nil {
+			next(w, r)
 			return
 		}
-		next(w, r)
+
+		next(w, withCredentials(r, token, current))
+	}
+}
+
+// authenticate determines the token of a request from the 'X-API-Token' header, the client certificate
+// or the session cookie.
+//
+// A client certificate verified against the configured CA authenticates the request as the token
+// named like the common name of the certificate.
+//
+// Parameters:
+//   - r: *http.Request
+//     The HTTP request being processed.
+//   - tokens: []Token
+//     The API tokens accepted by the server.
+//
+// Returns:
+//   - Token
+//     The token which authenticated the request.
+//   - *session
+//     The session of the request, or nil if the request was authenticated with the header or a client certificate.
+//   - error
+//     errMissingCredentials, errInvalidCredentials or errInvalidCSRFToken if the request is not authenticated.
+func authenticate(r *http.Request, tokens []Token) (Token, *session, error) {
+	if apiToken := r.Header.Get("X-API-Token"); apiToken != "" {
+		token, ok := findToken(tokens, apiToken)
+		if !ok {
+			return Token{}, nil, errInvalidCredentials
+		}
+		return token, nil, nil
+	}
+
+	if r.TLS != nil && len(r.TLS.VerifiedChains) > 0 {
+		name := r.TLS.VerifiedChains[0][0].Subject.CommonName
+		token, ok := findTokenByName(tokens, name)
+		if !ok {
+			return Token{}, nil, errInvalidCredentials
+		}
+		// Browsers send client certificates along with requests from other sites, too
+		if !isSafeMethod(r.Method) && isCrossSite(r) {
+			return token, nil, errInvalidCSRFToken
+		}
+		return token, nil, nil
+	}
+
+	cookie, err := r.Cookie(SessionCookieName)
+	if err != nil {
+		return Token{}, nil, errMissingCredentials
+	}
+	current, ok := sessions.get(cookie.Value)
+	if !ok {
+		return Token{}, nil, errMissingCredentials
+	}
+
+	// Sessions of tokens removed from the configuration or given a new value are no longer valid
+	token, ok := findTokenByName(tokens, current.token)
+	if !ok || !current.matches(token) {
+		return Token{}, nil, errInvalidCredentials
+	}
+
+	if !isSafeMethod(r.Method) && subtle.ConstantTimeCompare([]byte(r.Header.Get(CSRFHeader)), []byte(current.csrf)) != 1 {
+		return token, nil, errInvalidCSRFToken
+	}
+
+	return token, &current, nil
+}
+
+// withCredentials returns a shallow copy of the request with the token and session stored in its context.
+func withCredentials(r *http.Request, token Token, current *session) *http.Request {
+	ctx := context.WithValue(r.Context(), tokenKey, token)
+	if current != nil {
+		ctx = context.WithValue(ctx, sessionKey, *current)
+	}
+	return r.WithContext(ctx)
+}
+
+// isSafeMethod checks if an HTTP method does not modify anything and therefore needs no CSRF token.
+func isSafeMethod(method string) bool {
+	return method == http.MethodGet || method == http.MethodHead || method == http.MethodOptions
+}
+
+// isCrossSite checks if a browser sent the request from another site.
+func isCrossSite(r *http.Request) bool {
+	if r.Header.Get("Sec-Fetch-Site") == "cross-site" {
+		return true
+	}
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return false
+	}
+	parsed, err := url.Parse(origin)
+	return err != nil || parsed.Host != r.Host
+}
+
+// isPageRequest checks if a request was made by a browser navigating to a page.
+func isPageRequest(r *http.Request) bool {
+	return r.Method == http.MethodGet && strings.Contains(r.Header.Get("Accept"), "text/html")
+}
+
+// findToken returns the token matching the given value. Tokens the value matched before are looked up first,
+// so requests with a valid value do not compute the slow hashes of the other tokens.
+func findToken(tokens []Token, value string) (Token, bool) {
+	for _, token := range tokens {
+		if token.verified(value) {
+			return token, true
+		}
+	}
+	for _, token := range tokens {
+		if token.Matches(value) {
+			return token, true
+		}
+	}
+	return Token{}, false
+}
+
+// findTokenByName returns the token with the given name.
+func findTokenByName(tokens []Token, name string) (Token, bool) {
+	for _, token := range tokens {
+		if token.Name == name {
+			return token, true
+		}
 	}
+	return Token{}, false
 }
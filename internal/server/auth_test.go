@@ -0,0 +1,189 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestToken(t *testing.T) {
+	t.Run("Admin is granted every scope", func(t *testing.T) {
+		token := Token{Scopes: []Scope{ScopeAdmin}}
+		for _, scope := range SupportedScopes {
+			assert.True(t, token.HasScope(scope))
+		}
+	})
+
+	t.Run("Write includes append but not read", func(t *testing.T) {
+		token := Token{Scopes: []Scope{ScopeWrite}}
+		assert.True(t, token.HasScope(ScopeWrite))
+		assert.True(t, token.HasScope(ScopeAppend))
+		assert.False(t, token.HasScope(ScopeRead))
+		assert.False(t, token.HasScope(ScopeAdmin))
+	})
+
+	t.Run("Append does not include write", func(t *testing.T) {
+		token := Token{Scopes: []Scope{ScopeAppend}}
+		assert.True(t, token.HasScope(ScopeAppend))
+		assert.False(t, token.HasScope(ScopeWrite))
+	})
+
+	t.Run("Bins restrict access", func(t *testing.T) {
+		assert.True(t, Token{}.AllowsBin("logs"))
+		assert.True(t, Token{Bins: []string{"logs"}}.AllowsBin("logs"))
+		assert.False(t, Token{Bins: []string{"logs"}}.AllowsBin("default"))
+	})
+}
+
+// login logs in with a token and returns the session cookie.
+func login(t *testing.T, router http.Handler, token string) *http.Cookie {
+	t.Helper()
+
+	request := httptest.NewRequest("POST", "/login", strings.NewReader(url.Values{"token": {token}, "next": {"/b/notes"}}.Encode()))
+	request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	recorder := httptest.NewRecorder()
+	router.ServeHTTP(recorder, request)
+
+	assert.Equal(t, http.StatusSeeOther, recorder.Code)
+	assert.Equal(t, "/b/notes", recorder.Header().Get("Location"))
+
+	cookies := recorder.Result().Cookies()
+	if !assert.Len(t, cookies, 1) {
+		t.FailNow()
+	}
+	return cookies[0]
+}
+
+func TestSessions(t *testing.T) {
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(TokenName(r) + " " + CSRFToken(r)))
+	}
+
+	Handlers = []Handler{
+		{Path: "/page", Handler: okHandler, Methods: []string{"GET"}, Scope: ScopeNone},
+		{Path: "/save", Handler: okHandler, Methods: []string{"POST"}, Scope: ScopeWrite},
+	}
+	auth := Auth{
+		Tokens:     []Token{{Name: "editor", Value: "editor-token", Scopes: []Scope{ScopeRead, ScopeWrite}}},
+		SessionTTL: time.Hour,
+	}
+	router := newRouter(auth, false)
+
+	t.Run("Login sets an HttpOnly SameSite cookie", func(t *testing.T) {
+		cookie := login(t, router, "editor-token")
+		assert.Equal(t, SessionCookieName, cookie.Name)
+		assert.True(t, cookie.HttpOnly)
+		assert.Equal(t, http.SameSiteStrictMode, cookie.SameSite)
+		assert.Equal(t, 3600, cookie.MaxAge)
+	})
+
+	t.Run("Login with invalid token fails", func(t *testing.T) {
+		request := httptest.NewRequest("POST", "/login", strings.NewReader("token=wrong"))
+		request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+		assert.Empty(t, recorder.Result().Cookies())
+	})
+
+	t.Run("Login only redirects to this server", func(t *testing.T) {
+		assert.Equal(t, "/", safeRedirect("https://example.com"))
+		assert.Equal(t, "/", safeRedirect("//example.com"))
+		assert.Equal(t, "/b/notes", safeRedirect("/b/notes"))
+	})
+
+	t.Run("Saves with session need the CSRF token", func(t *testing.T) {
+		cookie := login(t, router, "editor-token")
+
+		// Public pages know the session and its CSRF token
+		request := httptest.NewRequest("GET", "/page", nil)
+		request.AddCookie(cookie)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		name, csrf, _ := strings.Cut(recorder.Body.String(), " ")
+		assert.Equal(t, "editor", name)
+		assert.NotEmpty(t, csrf)
+
+		request = httptest.NewRequest("POST", "/save", nil)
+		request.AddCookie(cookie)
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+
+		request = httptest.NewRequest("POST", "/save", nil)
+		request.AddCookie(cookie)
+		request.Header.Set(CSRFHeader, csrf)
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("Logout ends the session", func(t *testing.T) {
+		cookie := login(t, router, "editor-token")
+		current, ok := sessions.get(cookie.Value)
+		assert.True(t, ok)
+
+		request := httptest.NewRequest("POST", "/logout", nil)
+		request.AddCookie(cookie)
+		request.Header.Set(CSRFHeader, current.csrf)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusSeeOther, recorder.Code)
+
+		request = httptest.NewRequest("POST", "/save", nil)
+		request.AddCookie(cookie)
+		request.Header.Set(CSRFHeader, current.csrf)
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("Sessions end when the token value changes", func(t *testing.T) {
+		cookie := login(t, router, "editor-token")
+
+		// Reloading the configuration replaces the router
+		changed := auth
+		changed.Tokens = []Token{{Name: "editor", Value: "new-editor-token", Scopes: []Scope{ScopeRead, ScopeWrite}}}
+		changed.RequireAuth = true
+
+		request := httptest.NewRequest("GET", "/page", nil)
+		request.AddCookie(cookie)
+		recorder := httptest.NewRecorder()
+		newRouter(changed, false).ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+
+		// Changing only the scopes keeps the session
+		changed.Tokens = []Token{{Name: "editor", Value: "editor-token", Scopes: []Scope{ScopeRead}}}
+
+		request = httptest.NewRequest("GET", "/page", nil)
+		request.AddCookie(cookie)
+		recorder = httptest.NewRecorder()
+		newRouter(changed, false).ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+
+	t.Run("Required authentication redirects browsers to the login page", func(t *testing.T) {
+		auth := auth
+		auth.RequireAuth = true
+		router := newRouter(auth, false)
+
+		request := httptest.NewRequest("GET", "/page", nil)
+		request.Header.Set("Accept", "text/html")
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusSeeOther, recorder.Code)
+		assert.Equal(t, "/login?next=%2Fpage", recorder.Header().Get("Location"))
+
+		request = httptest.NewRequest("GET", "/page", nil)
+		request.AddCookie(login(t, router, "editor-token"))
+		recorder = httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		assert.Equal(t, http.StatusOK, recorder.Code)
+	})
+}
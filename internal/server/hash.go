@@ -0,0 +1,274 @@
+package server
+
+import (
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"runtime"
+	"strings"
+	"sync"
+
+	"golang.org/x/crypto/argon2"
+	"golang.org/x/crypto/bcrypt"
+)
+
+// Algorithms API tokens can be hashed with.
+const (
+	HashArgon2id = "argon2id" // HashArgon2id is a slow, memory-hard hash. It is the default.
+	HashBcrypt   = "bcrypt"   // HashBcrypt is a slow hash. It only supports tokens of up to 72 bytes.
+	HashSHA256   = "sha256"   // HashSHA256 is a fast hash, only suitable for long random tokens like the generated ones.
+)
+
+// SupportedHashes is a list of all algorithms API tokens can be hashed with.
+var SupportedHashes = []string{HashArgon2id, HashBcrypt, HashSHA256}
+
+// Parameters of new argon2id hashes, as recommended by OWASP.
+const (
+	argon2idMemory   = 19 * 1024 // argon2idMemory is the memory used to compute a hash in KiB.
+	argon2idTime     = 2         // argon2idTime is the number of passes over the memory.
+	argon2idThreads  = 1         // argon2idThreads is the number of threads used to compute a hash.
+	argon2idSaltSize = 16        // argon2idSaltSize is the length of the random salt in bytes.
+	argon2idKeySize  = 32        // argon2idKeySize is the length of the hash in bytes.
+)
+
+// Prefixes identifying the algorithm of a hashed token.
+const (
+	argon2idPrefix = "$argon2id$"
+	sha256Prefix   = "sha256:"
+)
+
+// bcryptPrefixes identify bcrypt hashes of the different bcrypt versions.
+var bcryptPrefixes = []string{"$2a$", "$2b$", "$2y$"}
+
+// verifiedTokens caches which hashed tokens matched a value, so the slow hashes are computed once per
+// value and not on every request. The keys contain the SHA-256 hash of the value, never the value itself.
+var verifiedTokens sync.Map
+
+// hashSlots limits how many argon2id and bcrypt hashes are computed at the same time, so clients sending invalid
+// tokens cannot use up the CPU and memory of the server. Values found in verifiedTokens need no slot.
+var hashSlots = make(chan struct{}, max(runtime.NumCPU()/2, 1))
+
+// argon2idHash is a parsed argon2id hash in the PHC string format.
+type argon2idHash struct {
+	memory  uint32
+	time    uint32
+	threads uint8
+	salt    []byte
+	key     []byte
+}
+
+// HashToken hashes an API token, so only the hash needs to be stored in the config file.
+//
+// Parameters:
+//   - value: string
+//     The API token.
+//   - algorithm: string
+//     The algorithm to hash the token with, one of SupportedHashes.
+//
+// Returns:
+//   - string
+//     The hash, e.g. '$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>', '$2a$10$<salt and hash>' or 'sha256:<hex>'.
+//   - error
+//     An error if the token is empty, the algorithm is not supported or the hash cannot be computed.
+func HashToken(value, algorithm string) (string, error) {
+	if value == "" {
+		return "", errors.New("Unable to hash an empty token")
+	}
+
+	switch algorithm {
+	case HashArgon2id:
+		salt := make([]byte, argon2idSaltSize)
+		if _, err := rand.Read(salt); err != nil {
+			return "", fmt.Errorf("Unable to generate salt: %s", err)
+		}
+		key := argon2.IDKey([]byte(value), salt, argon2idTime, argon2idMemory, argon2idThreads, argon2idKeySize)
+		return fmt.Sprintf("%sv=%d$m=%d,t=%d,p=%d$%s$%s", argon2idPrefix, argon2.Version, argon2idMemory, argon2idTime, argon2idThreads,
+			base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+	case HashBcrypt:
+		hash, err := bcrypt.GenerateFromPassword([]byte(value), bcrypt.DefaultCost)
+		if err != nil {
+			return "", fmt.Errorf("Unable to hash token: %s", err)
+		}
+		return string(hash), nil
+	case HashSHA256:
+		digest := sha256.Sum256([]byte(value))
+		return sha256Prefix + hex.EncodeToString(digest[:]), nil
+	default:
+		return "", fmt.Errorf("Unsupported hash algorithm '%s'. Supported algorithms are: %s", algorithm, SupportedHashes)
+	}
+}
+
+// HashAlgorithm determines the algorithm of a hashed token.
+//
+// Parameters:
+//   - value: string
+//     The configured token, either the token itself or its hash.
+//
+// Returns:
+//   - string
+//     The algorithm the token was hashed with, or an empty string if the token is not hashed.
+func HashAlgorithm(value string) string {
+	switch {
+	case strings.HasPrefix(value, argon2idPrefix):
+		return HashArgon2id
+	case strings.HasPrefix(value, sha256Prefix):
+		return HashSHA256
+	}
+	for _, prefix := range bcryptPrefixes {
+		if strings.HasPrefix(value, prefix) {
+			return HashBcrypt
+		}
+	}
+	return ""
+}
+
+// ValidateTokenHash checks that a hashed token can be compared against.
+//
+// Parameters:
+//   - value: string
+//     The configured token, either the token itself or its hash.
+//
+// Returns:
+//   - error
+//     An error if the token looks like a hash, but is malformed. Tokens which are not hashed are valid.
+func ValidateTokenHash(value string) error {
+	switch HashAlgorithm(value) {
+	case HashArgon2id:
+		_, err := parseArgon2id(value)
+		return err
+	case HashBcrypt:
+		if _, err := bcrypt.Cost([]byte(value)); err != nil {
+			return fmt.Errorf("Invalid bcrypt hash: %s", err)
+		}
+	case HashSHA256:
+		if digest, err := hex.DecodeString(strings.TrimPrefix(value, sha256Prefix)); err != nil || len(digest) != sha256.Size {
+			return errors.New("Invalid sha256 hash: must be 64 hexadecimal characters")
+		}
+	}
+	return nil
+}
+
+// Matches checks if a value sent by a client is the token.
+//
+// Tokens which are not hashed and SHA-256 hashes are compared in constant time. Argon2id and bcrypt
+// hashes are computed once per value, later requests with the same value are looked up in a cache.
+// Only a few hashes are computed at the same time, further requests wait for them.
+//
+// Parameters:
+//   - value: string
+//     The value sent by the client.
+//
+// Returns:
+//   - bool
+//     True if the value is the token or matches its hash.
+func (t Token) Matches(value string) bool {
+	if value == "" || t.Value == "" {
+		return false
+	}
+
+	digest := sha256.Sum256([]byte(value))
+
+	switch HashAlgorithm(t.Value) {
+	case "":
+		// Comparing the hashes keeps the length of the token secret, too
+		expected := sha256.Sum256([]byte(t.Value))
+		return subtle.ConstantTimeCompare(digest[:], expected[:]) == 1
+	case HashSHA256:
+		expected, err := hex.DecodeString(strings.TrimPrefix(t.Value, sha256Prefix))
+		return err == nil && subtle.ConstantTimeCompare(digest[:], expected) == 1
+	}
+
+	if t.verified(value) {
+		return true
+	}
+
+	hashSlots <- struct{}{}
+	defer func() { <-hashSlots }()
+
+	var matches bool
+	switch HashAlgorithm(t.Value) {
+	case HashArgon2id:
+		hash, err := parseArgon2id(t.Value)
+		if err != nil {
+			return false
+		}
+		computed := argon2.IDKey([]byte(value), hash.salt, hash.time, hash.memory, hash.threads, uint32(len(hash.key)))
+		matches = subtle.ConstantTimeCompare(computed, hash.key) == 1
+	case HashBcrypt:
+		matches = bcrypt.CompareHashAndPassword([]byte(t.Value), []byte(value)) == nil
+	}
+
+	if matches {
+		verifiedTokens.Store(verifiedKey(t.Value, value), struct{}{})
+	}
+	return matches
+}
+
+// verified checks if a value matched the argon2id or bcrypt hash of the token before, without computing the hash.
+//
+// Parameters:
+//   - value: string
+//     The value sent by the client.
+//
+// Returns:
+//   - bool
+//     True if the value is cached as matching the token.
+func (t Token) verified(value string) bool {
+	if value == "" || t.Value == "" {
+		return false
+	}
+
+	_, ok := verifiedTokens.Load(verifiedKey(t.Value, value))
+	return ok
+}
+
+// verifiedKey returns the key of a value matching a hashed token in verifiedTokens.
+func verifiedKey(hash, value string) string {
+	digest := sha256.Sum256([]byte(value))
+	return hash + "\x00" + hex.EncodeToString(digest[:])
+}
+
+// parseArgon2id parses an argon2id hash in the PHC string format.
+//
+// Parameters:
+//   - value: string
+//     The hash, e.g. '$argon2id$v=19$m=19456,t=2,p=1$<salt>$<hash>'.
+//
+// Returns:
+//   - argon2idHash
+//     The parameters, salt and key of the hash.
+//   - error
+//     An error if the hash is malformed or of another argon2 version.
+func parseArgon2id(value string) (argon2idHash, error) {
+	parts := strings.Split(value, "$")
+	if len(parts) != 6 {
+		return argon2idHash{}, errors.New("Invalid argon2id hash: expected '$argon2id$v=19$m=<memory>,t=<time>,p=<threads>$<salt>$<hash>'")
+	}
+
+	var version int
+	if _, err := fmt.Sscanf(parts[2], "v=%d", &version); err != nil || version != argon2.Version {
+		return argon2idHash{}, fmt.Errorf("Invalid argon2id hash: unsupported version '%s'", parts[2])
+	}
+
+	var hash argon2idHash
+	if _, err := fmt.Sscanf(parts[3], "m=%d,t=%d,p=%d", &hash.memory, &hash.time, &hash.threads); err != nil {
+		return argon2idHash{}, fmt.Errorf("Invalid argon2id hash: invalid parameters '%s'", parts[3])
+	}
+	if hash.time == 0 || hash.threads == 0 {
+		return argon2idHash{}, fmt.Errorf("Invalid argon2id hash: invalid parameters '%s'", parts[3])
+	}
+
+	var err error
+	if hash.salt, err = base64.RawStdEncoding.DecodeString(parts[4]); err != nil {
+		return argon2idHash{}, fmt.Errorf("Invalid argon2id hash: invalid salt: %s", err)
+	}
+	if hash.key, err = base64.RawStdEncoding.DecodeString(parts[5]); err != nil || len(hash.key) == 0 {
+		return argon2idHash{}, errors.New("Invalid argon2id hash: invalid hash")
+	}
+
+	return hash, nil
+}
@@ -0,0 +1,132 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestHashToken(t *testing.T) {
+	t.Run("Hashes match the token only", func(t *testing.T) {
+		for _, algorithm := range SupportedHashes {
+			hash, err := HashToken("secret-token", algorithm)
+			assert.NoError(t, err)
+			assert.NotContains(t, hash, "secret-token")
+			assert.Equal(t, algorithm, HashAlgorithm(hash))
+			assert.NoError(t, ValidateTokenHash(hash))
+
+			token := Token{Value: hash}
+			assert.True(t, token.Matches("secret-token"), algorithm)
+			assert.True(t, token.Matches("secret-token"), algorithm) // cached
+			assert.False(t, token.Matches("other-token"), algorithm)
+			assert.False(t, token.Matches(""), algorithm)
+		}
+	})
+
+	t.Run("Hashes are salted", func(t *testing.T) {
+		for _, algorithm := range []string{HashArgon2id, HashBcrypt} {
+			first, _ := HashToken("secret-token", algorithm)
+			second, _ := HashToken("secret-token", algorithm)
+			assert.NotEqual(t, first, second)
+		}
+	})
+
+	t.Run("Known hashes", func(t *testing.T) {
+		assert.True(t, Token{Value: "sha256:2bb80d537b1da3e38bd30361aa855686bde0eacd7162fef6a25fe97bf527a25b"}.Matches("secret"))
+		assert.True(t, Token{Value: "$2a$04$RYqRlxLPGIRXYTecfdDuKu9TJJqgY5hA0YfFWYmIjMYCtus6I.F/S"}.Matches("secret"))
+	})
+
+	t.Run("Errors", func(t *testing.T) {
+		_, err := HashToken("", HashSHA256)
+		assert.EqualError(t, err, "Unable to hash an empty token")
+
+		_, err = HashToken("secret-token", "md5")
+		assert.EqualError(t, err, "Unsupported hash algorithm 'md5'. Supported algorithms are: [argon2id bcrypt sha256]")
+
+		_, err = HashToken(strings.Repeat("a", 73), HashBcrypt)
+		assert.Error(t, err)
+	})
+
+	t.Run("Tokens which are not hashed", func(t *testing.T) {
+		assert.Equal(t, "", HashAlgorithm("secret-token"))
+		assert.NoError(t, ValidateTokenHash("secret-token"))
+		assert.True(t, Token{Value: "secret-token"}.Matches("secret-token"))
+		assert.False(t, Token{Value: "secret-token"}.Matches("secret"))
+		assert.False(t, Token{}.Matches(""))
+	})
+
+	t.Run("Malformed hashes", func(t *testing.T) {
+		for _, hash := range []string{
+			"sha256:abc",
+			"sha256:" + strings.Repeat("x", 64),
+			"$argon2id$v=19$m=19456,t=2,p=1$c2FsdA",
+			"$argon2id$v=16$m=19456,t=2,p=1$c2FsdA$aGFzaA",
+			"$argon2id$v=19$m=19456,t=0,p=1$c2FsdA$aGFzaA",
+			"$argon2id$v=19$m=19456,t=2,p=1$c2FsdA$",
+			"$2a$10$short",
+		} {
+			assert.Error(t, ValidateTokenHash(hash), hash)
+			assert.False(t, Token{Value: hash}.Matches("secret"), hash)
+		}
+	})
+}
+
+func TestHashedTokenMiddleware(t *testing.T) {
+	hash, err := HashToken("secret-token", HashArgon2id)
+	assert.NoError(t, err)
+
+	tokens := []Token{{Name: "ci", Value: hash, Scopes: []Scope{ScopeRead}}}
+	handler := ApiTokenMiddleware(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(TokenName(r)))
+	}, tokens, ScopeRead)
+
+	for value, code := range map[string]int{"secret-token": http.StatusOK, hash: http.StatusUnauthorized, "other": http.StatusUnauthorized} {
+		request := httptest.NewRequest("GET", "/fetch", nil)
+		request.Header.Set("X-API-Token", value)
+		recorder := httptest.NewRecorder()
+		handler(recorder, request)
+		assert.Equal(t, code, recorder.Code, value)
+	}
+}
+
+func TestHashSlots(t *testing.T) {
+	hash, err := HashToken("secret-token", HashBcrypt)
+	assert.NoError(t, err)
+	token := Token{Name: "ci", Value: hash}
+	assert.True(t, token.Matches("secret-token"))
+
+	// Occupy all slots, like many clients sending invalid tokens
+	for range cap(hashSlots) {
+		hashSlots <- struct{}{}
+	}
+	released := false
+	release := func() {
+		if !released {
+			released = true
+			for range cap(hashSlots) {
+				<-hashSlots
+			}
+		}
+	}
+	t.Cleanup(release)
+
+	// Verified values need no slot
+	found, ok := findToken([]Token{{Name: "other", Value: "$2a$10$invalid"}, token}, "secret-token")
+	assert.True(t, ok)
+	assert.Equal(t, "ci", found.Name)
+
+	done := make(chan bool)
+	go func() { done <- token.Matches("invalid-token") }()
+	select {
+	case <-done:
+		t.Fatal("Hash computed without a free slot")
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	release()
+	assert.False(t, <-done)
+}
@@ -0,0 +1,135 @@
+package server
+
+import (
+	"encoding/json"
+	"net/http"
+	"sync"
+	"sync/atomic"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Statuses reported by the health and readiness endpoints.
+const (
+	StatusOK      = "ok"      // StatusOK means the server or check is healthy.
+	StatusFailing = "failing" // StatusFailing means the server or check is not ready.
+)
+
+// ReadinessCheck is a check which must pass before the server receives traffic.
+type ReadinessCheck struct {
+	Name  string       // Name identifies the check in the response of '/readyz'.
+	Check func() error // Check returns an error if the server is not ready.
+}
+
+var (
+	readinessChecks []ReadinessCheck // readinessChecks are the checks run by '/readyz'.
+	checksMutex     sync.Mutex       // checksMutex protects readinessChecks.
+	shuttingDown    atomic.Bool      // shuttingDown is set when the server starts shutting down.
+)
+
+// CheckResult is the result of a single readiness check.
+type CheckResult struct {
+	Status string `json:"status"`          // Status is StatusOK or StatusFailing.
+	Error  string `json:"error,omitempty"` // Error describes why the check failed.
+}
+
+// Readiness is the response of '/readyz'.
+type Readiness struct {
+	Status string                 `json:"status"` // Status is StatusOK if all checks passed, StatusFailing otherwise.
+	Checks map[string]CheckResult `json:"checks"` // Checks are the results of the checks by name.
+}
+
+// RegisterReadinessCheck adds a check to '/readyz'.
+//
+// Parameters:
+//   - name: string
+//     The name of the check.
+//   - check: func() error
+//     The check. It must be safe for concurrent use and return quickly, since it runs on every probe.
+func RegisterReadinessCheck(name string, check func() error) {
+	checksMutex.Lock()
+	defer checksMutex.Unlock()
+	readinessChecks = append(readinessChecks, ReadinessCheck{Name: name, Check: check})
+}
+
+// ready runs all readiness checks.
+//
+// Returns:
+//   - Readiness
+//     The result of every check. The 'shutdown' check fails once the server is shutting down.
+func ready() Readiness {
+	checksMutex.Lock()
+	checks := append([]ReadinessCheck{}, readinessChecks...)
+	checksMutex.Unlock()
+
+	readiness := Readiness{Status: StatusOK, Checks: map[string]CheckResult{}}
+
+	fail := func(name, msg string) {
+		readiness.Status = StatusFailing
+		readiness.Checks[name] = CheckResult{Status: StatusFailing, Error: msg}
+	}
+
+	if shuttingDown.Load() {
+		fail("shutdown", "Server is shutting down")
+	} else {
+		readiness.Checks["shutdown"] = CheckResult{Status: StatusOK}
+	}
+
+	for _, check := range checks {
+		if err := check.Check(); err != nil {
+			fail(check.Name, err.Error())
+			continue
+		}
+		readiness.Checks[check.Name] = CheckResult{Status: StatusOK}
+	}
+
+	return readiness
+}
+
+// healthHandler handles HTTP requests for '/healthz'. It reports the process is alive,
+// so it always responds with an HTTP 200 OK status.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+func healthHandler(w http.ResponseWriter, r *http.Request) {
+	writeProbe(w, http.StatusOK, map[string]string{"status": StatusOK})
+}
+
+// readinessHandler handles HTTP requests for '/readyz'.
+//
+// It responds with the result of every readiness check, with an HTTP 200 OK status if all checks
+// passed and an HTTP 503 Service Unavailable status otherwise.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+func readinessHandler(w http.ResponseWriter, r *http.Request) {
+	readiness := ready()
+
+	status := http.StatusOK
+	if readiness.Status != StatusOK {
+		status = http.StatusServiceUnavailable
+		for name, result := range readiness.Checks {
+			if result.Status != StatusOK {
+				log.Warn().Msgf("Readiness check '%s' failed: %s", name, result.Error)
+			}
+		}
+	}
+
+	writeProbe(w, status, readiness)
+}
+
+// writeProbe writes the JSON response of a probe.
+func writeProbe(w http.ResponseWriter, status int, body interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	if err := json.NewEncoder(w).Encode(body); err != nil {
+		log.Error().Msgf("Error writing probe response: %v", err)
+	}
+}
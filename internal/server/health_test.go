@@ -0,0 +1,72 @@
+package server
+
+import (
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestProbes(t *testing.T) {
+	// probe sends a request to a probe and decodes the response.
+	probe := func(router http.Handler, path string) (int, Readiness) {
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+
+		var readiness Readiness
+		assert.NoError(t, json.Unmarshal(recorder.Body.Bytes(), &readiness))
+		return recorder.Code, readiness
+	}
+
+	// resetChecks restores the registered readiness checks after a test.
+	resetChecks := func() {
+		checks := readinessChecks
+		t.Cleanup(func() {
+			readinessChecks = checks
+			shuttingDown.Store(false)
+		})
+	}
+
+	router := newRouter(Auth{RequireAuth: true}, false)
+
+	t.Run("Health does not require authentication", func(t *testing.T) {
+		code, health := probe(router, "/healthz")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, StatusOK, health.Status)
+	})
+
+	t.Run("Ready if all checks pass", func(t *testing.T) {
+		resetChecks()
+		RegisterReadinessCheck("storage", func() error { return nil })
+
+		code, readiness := probe(router, "/readyz")
+		assert.Equal(t, http.StatusOK, code)
+		assert.Equal(t, StatusOK, readiness.Status)
+		assert.Equal(t, CheckResult{Status: StatusOK}, readiness.Checks["storage"])
+		assert.Equal(t, CheckResult{Status: StatusOK}, readiness.Checks["shutdown"])
+	})
+
+	t.Run("Not ready if a check fails", func(t *testing.T) {
+		resetChecks()
+		RegisterReadinessCheck("storage", func() error { return nil })
+		RegisterReadinessCheck("content", func() error { return errors.New("Content differs") })
+
+		code, readiness := probe(router, "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, StatusFailing, readiness.Status)
+		assert.Equal(t, CheckResult{Status: StatusOK}, readiness.Checks["storage"])
+		assert.Equal(t, CheckResult{Status: StatusFailing, Error: "Content differs"}, readiness.Checks["content"])
+	})
+
+	t.Run("Not ready while shutting down", func(t *testing.T) {
+		resetChecks()
+		shuttingDown.Store(true)
+
+		code, readiness := probe(newAdminRouter(Limits{}), "/readyz")
+		assert.Equal(t, http.StatusServiceUnavailable, code)
+		assert.Equal(t, CheckResult{Status: StatusFailing, Error: "Server is shutting down"}, readiness.Checks["shutdown"])
+	})
+}
@@ -0,0 +1,149 @@
+package server
+
+import (
+	"crypto/subtle"
+	"html/template"
+	"net/http"
+	"strings"
+	"vimbin/internal/metrics"
+
+	"github.com/rs/zerolog/log"
+)
+
+// loginTemplate is the page on which browsers log in with an API token.
+var loginTemplate = template.Must(template.New("login").Parse(`<!doctype html>
+<html lang="en">
+  <head>
+    <meta charset="UTF-8" />
+    <meta name="viewport" content="width=device-width, initial-scale=1.0" />
+    <title>vimbin - login</title>
+    <style>
+      body { font-family: monospace; background: #303446; color: #c6d0f5; display: flex; justify-content: center; margin-top: 20vh; }
+      input { font-family: monospace; margin: 0.5em 0; padding: 0.4em; width: 100%; box-sizing: border-box; }
+      .error { color: #e78284; }
+    </style>
+  </head>
+  <body>
+    <form method="POST" action="/login">
+      <h2>vimbin</h2>
+      {{if .Error}}<p class="error">{{.Error}}</p>{{end}}
+      <label for="token">API token</label>
+      <input id="token" name="token" type="password" autocomplete="current-password" autofocus required />
+      <input name="next" type="hidden" value="{{.Next}}" />
+      <input type="submit" value="Log in" />
+    </form>
+  </body>
+</html>
+`))
+
+// loginPage holds the data rendered into the login page.
+type loginPage struct {
+	Error string // Error is shown if logging in failed.
+	Next  string // Next is the page to redirect to after logging in.
+}
+
+// loginHandler handles HTTP requests for the login page.
+//
+// GET requests render the login form. POST requests check the submitted API token, start a session
+// and set the session cookie. The session cookie is HttpOnly and SameSite=Strict, so it can neither
+// be read by scripts nor be sent along with requests from other sites.
+//
+// Parameters:
+//   - auth: Auth
+//     The authentication configuration of the server.
+//
+// Returns:
+//   - http.HandlerFunc
+//     The handler for the login page.
+func loginHandler(auth Auth) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		next := safeRedirect(r.FormValue("next"))
+
+		if r.Method != http.MethodPost {
+			renderLogin(w, http.StatusOK, loginPage{Next: next})
+			return
+		}
+
+		token, ok := findToken(auth.Tokens, r.PostFormValue("token"))
+		if !ok {
+			metrics.AuthFailures.WithLabelValues(metrics.ReasonLogin).Inc()
+			reportAuthFailure(r)
+			log.Error().Msgf("Failed login from %s", ClientIP(r))
+			renderLogin(w, http.StatusUnauthorized, loginPage{Error: "Invalid API token", Next: next})
+			return
+		}
+
+		id, _, err := sessions.create(token, auth.SessionTTL)
+		if err != nil {
+			log.Error().Msgf("Unable to create session: %v", err)
+			http.Error(w, "Unable to create session", http.StatusInternalServerError)
+			return
+		}
+
+		http.SetCookie(w, &http.Cookie{
+			Name:     SessionCookieName,
+			Value:    id,
+			Path:     "/",
+			MaxAge:   int(auth.SessionTTL.Seconds()),
+			HttpOnly: true,
+			Secure:   r.TLS != nil,
+			SameSite: http.SameSiteStrictMode,
+		})
+
+		log.Debug().Msgf("Token '%s' logged in from %s", token.Name, ClientIP(r))
+		http.Redirect(w, r, next, http.StatusSeeOther)
+	}
+}
+
+// logoutHandler handles HTTP requests to end the session of a browser.
+//
+// The CSRF token of the session must be sent in the 'X-CSRF-Token' header or the 'csrf' form field.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - r: *http.Request
+//     The HTTP request being processed.
+func logoutHandler(w http.ResponseWriter, r *http.Request) {
+	if cookie, err := r.Cookie(SessionCookieName); err == nil {
+		current, ok := sessions.get(cookie.Value)
+		csrf := r.Header.Get(CSRFHeader)
+		if csrf == "" {
+			csrf = r.PostFormValue("csrf")
+		}
+		if ok && subtle.ConstantTimeCompare([]byte(csrf), []byte(current.csrf)) != 1 {
+			http.Error(w, errInvalidCSRFToken.Error(), http.StatusForbidden)
+			return
+		}
+		sessions.delete(cookie.Value)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     SessionCookieName,
+		Path:     "/",
+		MaxAge:   -1,
+		HttpOnly: true,
+		Secure:   r.TLS != nil,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	http.Redirect(w, r, "/login", http.StatusSeeOther)
+}
+
+// renderLogin writes the login page with the given status code.
+func renderLogin(w http.ResponseWriter, status int, page loginPage) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	if err := loginTemplate.Execute(w, page); err != nil {
+		log.Error().Msgf("Error writing login page: %v", err)
+	}
+}
+
+// safeRedirect returns the target if it is a path on this server and '/' otherwise,
+// so the login page cannot be used to redirect to other sites.
+func safeRedirect(target string) string {
+	if !strings.HasPrefix(target, "/") || strings.HasPrefix(target, "//") || strings.HasPrefix(target, "/\\") {
+		return "/"
+	}
+	return target
+}
@@ -0,0 +1,374 @@
+package server
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net"
+	"net/http"
+	"net/netip"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+	"vimbin/internal/metrics"
+
+	"github.com/rs/zerolog/log"
+)
+
+// RateLimit limits the requests of every client IP to a route with a token bucket.
+type RateLimit struct {
+	RequestsPerSecond float64 // RequestsPerSecond is the rate at which the bucket refills. Zero or less disables the limit.
+	Burst             int     // Burst is the size of the bucket, i.e. the number of requests which can be made at once.
+}
+
+// Enabled checks if the limit applies.
+//
+// Returns:
+//   - bool
+//     True if requests are limited.
+func (l RateLimit) Enabled() bool {
+	return l.RequestsPerSecond > 0
+}
+
+// Lockout locks out client IPs which repeatedly fail to authenticate.
+type Lockout struct {
+	MaxFailures int           // MaxFailures is the number of failed authentications within Window which locks out a client. Zero or less disables lockouts.
+	Window      time.Duration // Window is the time in which failed authentications are counted.
+	Duration    time.Duration // Duration is how long a client is locked out.
+}
+
+// Limits configures rate limiting and the lockout after failed authentications.
+type Limits struct {
+	Default        RateLimit            // Default is the limit of routes without their own limit.
+	Routes         map[string]RateLimit // Routes are the limits of single routes, keyed by the path they were registered with.
+	Lockout        Lockout              // Lockout configures the lockout after failed authentications.
+	TrustedProxies []netip.Prefix       // TrustedProxies are the proxies whose 'X-Forwarded-For' header is used to determine the client IP.
+}
+
+// routeLimit returns the limit of a route.
+func (l Limits) routeLimit(route string) RateLimit {
+	if limit, ok := l.Routes[route]; ok {
+		return limit
+	}
+	return l.Default
+}
+
+// clientIPKey is the context key for the IP of the client which made the request.
+const clientIPKey contextKey = "clientIP"
+
+// authFailureKey is the context key for the flag set by reportAuthFailure.
+const authFailureKey contextKey = "authFailure"
+
+// bucket is the token bucket of a client for a route.
+type bucket struct {
+	tokens  float64   // tokens is the number of requests left.
+	updated time.Time // updated is the time tokens was last computed.
+}
+
+// failures are the failed authentications of a client.
+type failures struct {
+	count       int       // count is the number of failed authentications since first.
+	first       time.Time // first is the time of the first failed authentication counted.
+	lockedUntil time.Time // lockedUntil is the time until which the client is locked out.
+}
+
+// limiter keeps the token buckets and failed authentications of all clients in memory.
+type limiter struct {
+	buckets  map[string]*bucket   // buckets are keyed by route and client IP.
+	failures map[string]*failures // failures are keyed by client IP.
+	mutex    sync.Mutex
+}
+
+// limits holds the state of the rate limits of all clients. It is kept when the configuration is reloaded.
+var limits = &limiter{buckets: map[string]*bucket{}, failures: map[string]*failures{}}
+
+// idleTimeout is how long the state of a client is kept after its last request.
+const idleTimeout = time.Hour
+
+func init() {
+	RegisterTask("ratelimit", time.Minute, func() { limits.prune(time.Now()) })
+}
+
+// allow takes a request from the bucket of a client for a route.
+//
+// Parameters:
+//   - key: string
+//     The key of the bucket, made of the route and the client IP.
+//   - limit: RateLimit
+//     The limit of the route.
+//   - now: time.Time
+//     The current time.
+//
+// Returns:
+//   - bool
+//     True if the request may be served.
+//   - time.Duration
+//     How long the client has to wait for the next request if it may not be served.
+func (l *limiter) allow(key string, limit RateLimit, now time.Time) (bool, time.Duration) {
+	burst := float64(max(limit.Burst, 1))
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	current, ok := l.buckets[key]
+	if !ok {
+		current = &bucket{tokens: burst, updated: now}
+		l.buckets[key] = current
+	}
+	current.tokens = math.Min(burst, current.tokens+now.Sub(current.updated).Seconds()*limit.RequestsPerSecond)
+	current.updated = now
+
+	if current.tokens < 1 {
+		return false, time.Duration((1 - current.tokens) / limit.RequestsPerSecond * float64(time.Second))
+	}
+	current.tokens--
+	return true, 0
+}
+
+// lockedOut checks if a client is locked out.
+//
+// Parameters:
+//   - ip: string
+//     The IP of the client.
+//   - now: time.Time
+//     The current time.
+//
+// Returns:
+//   - time.Duration
+//     How long the client stays locked out, or zero if it is not locked out.
+func (l *limiter) lockedOut(ip string, now time.Time) time.Duration {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	current, ok := l.failures[ip]
+	if !ok || !now.Before(current.lockedUntil) {
+		return 0
+	}
+	return current.lockedUntil.Sub(now)
+}
+
+// fail counts a failed authentication of a client and locks it out once it failed too often.
+//
+// Parameters:
+//   - ip: string
+//     The IP of the client.
+//   - lockout: Lockout
+//     The lockout settings.
+//   - now: time.Time
+//     The current time.
+//
+// Returns:
+//   - bool
+//     True if the client was locked out by this failure.
+func (l *limiter) fail(ip string, lockout Lockout, now time.Time) bool {
+	if lockout.MaxFailures <= 0 {
+		return false
+	}
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	current, ok := l.failures[ip]
+	if !ok || now.Sub(current.first) > lockout.Window {
+		current = &failures{first: now}
+		l.failures[ip] = current
+	}
+	current.count++
+
+	if current.count < lockout.MaxFailures {
+		return false
+	}
+
+	// Count the failures anew once the lockout ends
+	current.count = 0
+	current.first = now.Add(lockout.Duration)
+	current.lockedUntil = now.Add(lockout.Duration)
+	return true
+}
+
+// prune drops the state of clients which made no requests for a while, so the limiter does not grow forever.
+//
+// Parameters:
+//   - now: time.Time
+//     The current time.
+func (l *limiter) prune(now time.Time) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for key, current := range l.buckets {
+		if now.Sub(current.updated) > idleTimeout {
+			delete(l.buckets, key)
+		}
+	}
+	for ip, current := range l.failures {
+		if now.After(current.lockedUntil) && now.Sub(current.first) > idleTimeout {
+			delete(l.failures, ip)
+		}
+	}
+}
+
+// rateLimitMiddleware refuses requests of clients which exceeded the rate limit of the route or are
+// locked out after failed authentications with an HTTP 429 Too Many Requests status and a 'Retry-After'
+// header. The IP of the client is stored in the request context, see ClientIP.
+//
+// Parameters:
+//   - next: http.Handler
+//     The next HTTP handler in the chain.
+//   - route: string
+//     The route of the handler, e.g. '/save'.
+//   - config: Limits
+//     The rate limits and lockout settings.
+//
+// Returns:
+//   - http.Handler
+//     The rate limited handler.
+func rateLimitMiddleware(next http.Handler, route string, config Limits) http.Handler {
+	limit := config.routeLimit(route)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ip := clientIP(r, config.TrustedProxies)
+		now := time.Now()
+
+		if wait := limits.lockedOut(ip, now); wait > 0 {
+			metrics.RateLimited.WithLabelValues(route, metrics.LimitLockout).Inc()
+			tooManyRequests(w, wait, "Too many failed authentications, try again later")
+			return
+		}
+
+		if limit.Enabled() {
+			if ok, wait := limits.allow(route+"\x00"+ip, limit, now); !ok {
+				metrics.RateLimited.WithLabelValues(route, metrics.LimitRate).Inc()
+				log.Debug().Msgf("Rate limit of '%s' exceeded by %s", route, ip)
+				tooManyRequests(w, wait, "Too many requests, try again later")
+				return
+			}
+		}
+
+		failed := new(bool)
+		ctx := context.WithValue(r.Context(), clientIPKey, ip)
+		next.ServeHTTP(w, r.WithContext(context.WithValue(ctx, authFailureKey, failed)))
+
+		if *failed && limits.fail(ip, config.Lockout, now) {
+			metrics.Lockouts.Inc()
+			log.Warn().Msgf("Locked out %s for %s after %d failed authentications", ip, config.Lockout.Duration, config.Lockout.MaxFailures)
+		}
+	})
+}
+
+// tooManyRequests responds with an HTTP 429 Too Many Requests status.
+//
+// Parameters:
+//   - w: http.ResponseWriter
+//     The HTTP response writer.
+//   - wait: time.Duration
+//     How long the client has to wait, sent in the 'Retry-After' header in whole seconds.
+//   - msg: string
+//     The message of the response.
+func tooManyRequests(w http.ResponseWriter, wait time.Duration, msg string) {
+	w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+	http.Error(w, msg, http.StatusTooManyRequests)
+}
+
+// reportAuthFailure marks a request as failed authentication, which counts towards the lockout of the client.
+func reportAuthFailure(r *http.Request) {
+	if failed, ok := r.Context().Value(authFailureKey).(*bool); ok {
+		*failed = true
+	}
+}
+
+// ClientIP returns the IP of the client which made the request. Behind a trusted proxy, it is taken
+// from the 'X-Forwarded-For' header.
+//
+// Parameters:
+//   - r: *http.Request
+//     The HTTP request being processed.
+//
+// Returns:
+//   - string
+//     The IP of the client.
+func ClientIP(r *http.Request) string {
+	if ip, ok := r.Context().Value(clientIPKey).(string); ok {
+		return ip
+	}
+	return clientIP(r, nil)
+}
+
+// clientIP determines the IP of the client which made the request.
+//
+// The 'X-Forwarded-For' header is only used if the request was made by a trusted proxy. It is read from
+// right to left, skipping trusted proxies, as clients can send the header with arbitrary entries themselves.
+//
+// Parameters:
+//   - r: *http.Request
+//     The HTTP request being processed.
+//   - trustedProxies: []netip.Prefix
+//     The proxies whose 'X-Forwarded-For' header is used.
+//
+// Returns:
+//   - string
+//     The IP of the client.
+func clientIP(r *http.Request, trustedProxies []netip.Prefix) string {
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	addr, err := netip.ParseAddr(host)
+	if err != nil || !isTrustedProxy(addr, trustedProxies) {
+		return host
+	}
+
+	forwarded := strings.Split(strings.Join(r.Header.Values("X-Forwarded-For"), ","), ",")
+	for i := len(forwarded) - 1; i >= 0; i-- {
+		hop, err := netip.ParseAddr(strings.TrimSpace(forwarded[i]))
+		if err != nil {
+			break
+		}
+		addr = hop.Unmap()
+		if !isTrustedProxy(addr, trustedProxies) {
+			break
+		}
+	}
+
+	return addr.String()
+}
+
+// isTrustedProxy checks if an IP belongs to a trusted proxy.
+func isTrustedProxy(addr netip.Addr, trustedProxies []netip.Prefix) bool {
+	addr = addr.Unmap()
+	for _, prefix := range trustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// ParseTrustedProxy parses the IP or CIDR range of a trusted proxy.
+//
+// Parameters:
+//   - value: string
+//     An IP like '10.0.0.1' or a CIDR range like '10.0.0.0/8'.
+//
+// Returns:
+//   - netip.Prefix
+//     The range of IPs of the proxy.
+//   - error
+//     An error if the value is neither an IP nor a CIDR range.
+func ParseTrustedProxy(value string) (netip.Prefix, error) {
+	if strings.Contains(value, "/") {
+		prefix, err := netip.ParsePrefix(value)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("Invalid trusted proxy '%s': %s", value, err)
+		}
+		return prefix.Masked(), nil
+	}
+
+	addr, err := netip.ParseAddr(value)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("Invalid trusted proxy '%s': %s", value, err)
+	}
+	addr = addr.Unmap()
+	return netip.PrefixFrom(addr, addr.BitLen()), nil
+}
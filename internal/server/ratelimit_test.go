@@ -0,0 +1,230 @@
+package server
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// resetLimits drops the state of the rate limits for a test.
+func resetLimits(t *testing.T) {
+	t.Helper()
+	previous := limits
+	limits = &limiter{buckets: map[string]*bucket{}, failures: map[string]*failures{}}
+	t.Cleanup(func() { limits = previous })
+}
+
+func TestLimiter(t *testing.T) {
+	now := time.Now()
+
+	t.Run("Bursts are allowed and refilled over time", func(t *testing.T) {
+		resetLimits(t)
+		limit := RateLimit{RequestsPerSecond: 2, Burst: 3}
+
+		for i := 0; i < 3; i++ {
+			ok, _ := limits.allow("key", limit, now)
+			assert.True(t, ok)
+		}
+		ok, wait := limits.allow("key", limit, now)
+		assert.False(t, ok)
+		assert.Equal(t, 500*time.Millisecond, wait)
+
+		ok, _ = limits.allow("key", limit, now.Add(500*time.Millisecond))
+		assert.True(t, ok)
+		ok, _ = limits.allow("other", limit, now)
+		assert.True(t, ok)
+	})
+
+	t.Run("Clients are locked out after too many failures", func(t *testing.T) {
+		resetLimits(t)
+		lockout := Lockout{MaxFailures: 3, Window: time.Minute, Duration: 10 * time.Minute}
+
+		assert.False(t, limits.fail("10.0.0.1", lockout, now))
+		assert.False(t, limits.fail("10.0.0.1", lockout, now.Add(time.Second)))
+		assert.Zero(t, limits.lockedOut("10.0.0.1", now))
+		assert.True(t, limits.fail("10.0.0.1", lockout, now.Add(2*time.Second)))
+
+		assert.Equal(t, 10*time.Minute, limits.lockedOut("10.0.0.1", now.Add(2*time.Second)))
+		assert.Zero(t, limits.lockedOut("10.0.0.2", now))
+		assert.Zero(t, limits.lockedOut("10.0.0.1", now.Add(11*time.Minute)))
+	})
+
+	t.Run("Failures outside of the window are forgotten", func(t *testing.T) {
+		resetLimits(t)
+		lockout := Lockout{MaxFailures: 2, Window: time.Minute, Duration: time.Minute}
+
+		assert.False(t, limits.fail("10.0.0.1", lockout, now))
+		assert.False(t, limits.fail("10.0.0.1", lockout, now.Add(2*time.Minute)))
+		assert.False(t, limits.fail("10.0.0.1", Lockout{}, now.Add(2*time.Minute)))
+	})
+
+	t.Run("Idle clients are pruned", func(t *testing.T) {
+		resetLimits(t)
+		limits.allow("key", RateLimit{RequestsPerSecond: 1}, now)
+		limits.fail("10.0.0.1", Lockout{MaxFailures: 5, Window: time.Minute}, now)
+
+		limits.prune(now.Add(time.Minute))
+		assert.Len(t, limits.buckets, 1)
+		assert.Len(t, limits.failures, 1)
+
+		limits.prune(now.Add(2 * idleTimeout))
+		assert.Empty(t, limits.buckets)
+		assert.Empty(t, limits.failures)
+	})
+}
+
+func TestClientIP(t *testing.T) {
+	proxies := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	for name, test := range map[string]struct {
+		remoteAddr string
+		forwarded  []string
+		expected   string
+	}{
+		"Direct client":                     {"203.0.113.7:1234", nil, "203.0.113.7"},
+		"Untrusted client sending a header": {"203.0.113.7:1234", []string{"198.51.100.1"}, "203.0.113.7"},
+		"Trusted proxy":                     {"10.0.0.1:1234", []string{"198.51.100.1"}, "198.51.100.1"},
+		"Chain of proxies":                  {"10.0.0.1:1234", []string{"198.51.100.1, 10.0.0.2"}, "198.51.100.1"},
+		"Spoofed entries are ignored":       {"10.0.0.1:1234", []string{"192.0.2.1, 198.51.100.1"}, "198.51.100.1"},
+		"Several headers":                   {"10.0.0.1:1234", []string{"192.0.2.1", "198.51.100.1"}, "198.51.100.1"},
+		"Proxy without header":              {"10.0.0.1:1234", nil, "10.0.0.1"},
+		"Malformed entry":                   {"10.0.0.1:1234", []string{"garbage"}, "10.0.0.1"},
+		"IPv6 client":                       {"[2001:db8::1]:1234", nil, "2001:db8::1"},
+	} {
+		t.Run(name, func(t *testing.T) {
+			request := httptest.NewRequest("GET", "/", nil)
+			request.RemoteAddr = test.remoteAddr
+			for _, value := range test.forwarded {
+				request.Header.Add("X-Forwarded-For", value)
+			}
+			assert.Equal(t, test.expected, clientIP(request, proxies))
+		})
+	}
+}
+
+func TestParseTrustedProxy(t *testing.T) {
+	prefix, err := ParseTrustedProxy("10.1.2.3/8")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.0/8", prefix.String())
+
+	prefix, err = ParseTrustedProxy("::ffff:10.0.0.1")
+	assert.NoError(t, err)
+	assert.Equal(t, "10.0.0.1/32", prefix.String())
+
+	_, err = ParseTrustedProxy("proxy.local")
+	assert.Error(t, err)
+}
+
+func TestRateLimitMiddleware(t *testing.T) {
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(ClientIP(r)))
+	}
+	Handlers = []Handler{
+		{Path: "/fetch", Handler: okHandler, Methods: []string{"GET"}, Scope: ScopeRead},
+		{Path: "/page", Handler: okHandler, Methods: []string{"GET"}, Scope: ScopeNone},
+	}
+	auth := Auth{
+		Tokens:     []Token{{Name: "reader", Value: "reader-token", Scopes: []Scope{ScopeRead}}},
+		SessionTTL: time.Hour,
+		Limits: Limits{
+			Default:        RateLimit{RequestsPerSecond: 100, Burst: 100},
+			Routes:         map[string]RateLimit{"/page": {RequestsPerSecond: 0.1, Burst: 2}},
+			Lockout:        Lockout{MaxFailures: 3, Window: time.Minute, Duration: time.Minute},
+			TrustedProxies: []netip.Prefix{netip.MustParsePrefix("192.0.2.0/24")},
+		},
+	}
+	router := newRouter(auth, false)
+
+	serve := func(method, path, token, forwardedFor string) *httptest.ResponseRecorder {
+		var request *http.Request
+		if method == "POST" {
+			request = httptest.NewRequest(method, path, strings.NewReader(url.Values{"token": {token}}.Encode()))
+			request.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+		} else {
+			request = httptest.NewRequest(method, path, nil)
+			request.Header.Set("X-API-Token", token)
+		}
+		request.Header.Set("X-Forwarded-For", forwardedFor)
+		recorder := httptest.NewRecorder()
+		router.ServeHTTP(recorder, request)
+		return recorder
+	}
+
+	t.Run("Routes have their own limits", func(t *testing.T) {
+		resetLimits(t)
+
+		assert.Equal(t, http.StatusOK, serve("GET", "/page", "", "").Code)
+		assert.Equal(t, http.StatusOK, serve("GET", "/page", "", "").Code)
+		recorder := serve("GET", "/page", "", "")
+		assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+		assert.Equal(t, "10", recorder.Header().Get("Retry-After"))
+
+		assert.Equal(t, http.StatusOK, serve("GET", "/fetch", "reader-token", "").Code)
+	})
+
+	t.Run("Clients behind a trusted proxy are identified by X-Forwarded-For", func(t *testing.T) {
+		resetLimits(t)
+
+		recorder := serve("GET", "/fetch", "reader-token", "198.51.100.1")
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "198.51.100.1", recorder.Body.String()) // httptest requests are made by the proxy 192.0.2.1
+
+		for i := 0; i < 2; i++ {
+			assert.Equal(t, http.StatusOK, serve("GET", "/page", "", "198.51.100.1").Code)
+		}
+		assert.Equal(t, http.StatusTooManyRequests, serve("GET", "/page", "", "198.51.100.1").Code)
+	})
+
+	t.Run("Failed authentications lock out the client", func(t *testing.T) {
+		resetLimits(t)
+
+		assert.Equal(t, http.StatusUnauthorized, serve("GET", "/fetch", "guess-1", "198.51.100.1").Code)
+		assert.Equal(t, http.StatusUnauthorized, serve("POST", "/login", "guess-2", "198.51.100.1").Code)
+		assert.Equal(t, http.StatusUnauthorized, serve("GET", "/fetch", "guess-3", "198.51.100.1").Code)
+
+		// Even the valid token is refused while locked out
+		recorder := serve("GET", "/fetch", "reader-token", "198.51.100.1")
+		assert.Equal(t, http.StatusTooManyRequests, recorder.Code)
+		assert.Equal(t, "60", recorder.Header().Get("Retry-After"))
+		assert.Equal(t, http.StatusTooManyRequests, serve("POST", "/login", "reader-token", "198.51.100.1").Code)
+
+		// Other clients are not affected
+		assert.Equal(t, http.StatusOK, serve("GET", "/fetch", "reader-token", "198.51.100.2").Code)
+	})
+
+	t.Run("Probes and static files are limited", func(t *testing.T) {
+		resetLimits(t)
+		limits.failures["192.0.2.1"] = &failures{lockedUntil: time.Now().Add(time.Hour)}
+
+		for _, path := range []string{"/healthz", "/readyz", "/static/missing.css", "/page"} {
+			assert.Equal(t, http.StatusTooManyRequests, serve("GET", path, "", "").Code, path)
+		}
+	})
+
+	t.Run("Admin routes are limited", func(t *testing.T) {
+		resetLimits(t)
+		admin := newAdminRouter(Limits{
+			Default: RateLimit{RequestsPerSecond: 0.1, Burst: 1},
+			Routes:  map[string]RateLimit{"/healthz": {RequestsPerSecond: -1}},
+		})
+
+		serveAdmin := func(path string) int {
+			recorder := httptest.NewRecorder()
+			admin.ServeHTTP(recorder, httptest.NewRequest("GET", path, nil))
+			return recorder.Code
+		}
+		assert.Equal(t, http.StatusOK, serveAdmin("/metrics"))
+		assert.Equal(t, http.StatusTooManyRequests, serveAdmin("/metrics"))
+
+		// Routes can be exempted with a negative limit
+		for i := 0; i < 3; i++ {
+			assert.Equal(t, http.StatusOK, serveAdmin("/healthz"))
+		}
+	})
+}
@@ -7,7 +7,7 @@ type Handler struct {
 	Path        string                                   // Path is the URL route for the handler.
 	Description string                                   // Description provides a brief explanation of the handler's purpose.
 	Handler     func(http.ResponseWriter, *http.Request) // Handler is the function that handles HTTP requests for the route.
-	NeedsToken  bool                                     // NeedsToken indicates if the handler requires a valid API token.
+	Scope       Scope                                    // Scope is the scope an API token needs for the handler, or ScopeNone if no token is needed.
 	Methods     []string                                 // Methods is a list of HTTP methods supported by the handler.
 }
 
@@ -27,17 +27,18 @@ var Handlers = []Handler{}
 //     The function that handles HTTP requests for the route.
 //   - description: string
 //     A brief explanation of the handler's purpose.
-//   - needsToken: bool
-//     Indicates if the handler requires a valid API token.
+//   - scope: Scope
+//     The scope an API token needs to use the handler. ScopeNone if the handler does not require a token,
+//     unless authentication is required for all routes.
 //   - methods: ...string
 //     Optional list of HTTP methods supported by the handler.
-func Register(path, description string, needsToken bool, handler func(http.ResponseWriter, *http.Request), methods ...string) {
+func Register(path, description string, scope Scope, handler func(http.ResponseWriter, *http.Request), methods ...string) {
 	Handlers = append(Handlers,
 		Handler{
 			Path:        path,
 			Description: description,
 			Handler:     handler,
-			NeedsToken:  needsToken,
+			Scope:       scope,
 			Methods:     methods,
 		})
 }
@@ -17,7 +17,7 @@ func TestRegister(t *testing.T) {
 		description := "Example handler description"
 		handlerFunc := func(http.ResponseWriter, *http.Request) {}
 
-		Register(path, description, false, handlerFunc)
+		Register(path, description, ScopeNone, handlerFunc)
 
 		// Check if the handler is registered correctly
 		assert.Len(t, Handlers, 1)
@@ -36,7 +36,7 @@ func TestRegister(t *testing.T) {
 		handlerFunc := func(http.ResponseWriter, *http.Request) {}
 		methods := []string{"GET", "POST"}
 
-		Register(path, description, false, handlerFunc, methods...)
+		Register(path, description, ScopeNone, handlerFunc, methods...)
 
 		// Check if the handler is registered correctly
 		assert.Len(t, Handlers, 1)
@@ -46,7 +46,7 @@ func TestRegister(t *testing.T) {
 		assert.Equal(t, methods, Handlers[0].Methods)
 	})
 
-	t.Run("Register a handler token set to true", func(t *testing.T) {
+	t.Run("Register a handler which requires a scope", func(t *testing.T) {
 		// Clear existing handlers
 		Handlers = nil
 
@@ -54,10 +54,11 @@ func TestRegister(t *testing.T) {
 		description := "Example handler description"
 		handlerFunc := func(http.ResponseWriter, *http.Request) {}
 
-		Register(path, description, true, handlerFunc)
+		Register(path, description, ScopeWrite, handlerFunc)
 
 		// Check if the handler is registered correctly
 		assert.Len(t, Handlers, 1)
+		assert.Equal(t, ScopeWrite, Handlers[0].Scope)
 		assert.Equal(t, path, Handlers[0].Path)
 		assert.Equal(t, description, Handlers[0].Description)
 		assert.Equal(t, reflect.ValueOf(handlerFunc).Pointer(), reflect.ValueOf(Handlers[0].Handler).Pointer())
@@ -83,9 +84,9 @@ func TestRegister(t *testing.T) {
 		handlerFunc3 := func(http.ResponseWriter, *http.Request) {}
 		methods3 := []string{"GET", "POST"}
 
-		Register(path1, description1, false, handlerFunc1, methods1...)
-		Register(path2, description2, false, handlerFunc2, methods2...)
-		Register(path3, description3, true, handlerFunc3, methods3...)
+		Register(path1, description1, ScopeNone, handlerFunc1, methods1...)
+		Register(path2, description2, ScopeRead, handlerFunc2, methods2...)
+		Register(path3, description3, ScopeAdmin, handlerFunc3, methods3...)
 
 		// Check if both handlers are registered correctly
 		assert.Len(t, Handlers, 3)
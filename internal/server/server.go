@@ -4,11 +4,15 @@ import (
 	"context"
 	"embed"
 	"io/fs"
+	"net"
 	"net/http"
 	"os"
 	"os/signal"
+	"slices"
+	"sync/atomic"
 	"syscall"
 	"time"
+	"vimbin/internal/metrics"
 
 	"github.com/gorilla/mux"
 	"github.com/rs/zerolog/log"
@@ -17,63 +21,210 @@ import (
 // StaticFS is the embedded filesystem for static files.
 var StaticFS embed.FS
 
+// shutdownTimeout is how long in-flight requests may take to finish on shutdown.
+const shutdownTimeout = 8 * time.Second
+
+// builtinRoutes are the routes served besides the registered handlers, which can be rate limited as well.
+var builtinRoutes = []string{"/static/", "/login", "/logout", "/healthz", "/readyz", "/metrics"}
+
 // Run starts the HTTP server.
 //
 // Parameters:
 //   - listenAddress: string
 //     The address on which the server should listen (e.g., ":8080").
-func Run(listenAddress string, token string) {
+//   - auth: Auth
+//     The authentication configuration of the server.
+//   - tlsFiles: TLS
+//     The certificate to serve HTTPS with. Plain HTTP is served if no certificate is configured.
+//   - adminAddress: string
+//     The address on which '/metrics' is served. If empty, '/metrics' is served on listenAddress.
+//   - shutdownDelay: time.Duration
+//     How long '/readyz' fails before the listener closes on shutdown, so load balancers stop sending traffic.
+//   - reload: func() (Auth, error)
+//     Reloads the configuration on SIGHUP or RequestReload and returns the new authentication configuration.
+//     If it fails, the current configuration stays active. If nil, the configuration is never reloaded.
+func Run(listenAddress string, auth Auth, tlsFiles TLS, adminAddress string, shutdownDelay time.Duration, reload func() (Auth, error)) {
 	// Use a buffered channel for runChan to prevent signal drops
 	runChan := make(chan os.Signal, 1)
 	signal.Notify(runChan, os.Interrupt, syscall.SIGTERM)
+	hangupChan := make(chan os.Signal, 1)
+	signal.Notify(hangupChan, syscall.SIGHUP)
+	defer signal.Stop(hangupChan)
 
 	// Create a cancelable context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
-	// Create the router and configure routes
-	router := newRouter(token)
+	// Report readiness until a signal is received
+	shuttingDown.Store(false)
+
+	// Run background tasks like removing expired bins until shutdown
+	startTasks(ctx)
+
+	// Create the router and configure routes. Reloading the configuration replaces the router.
+	var router atomic.Pointer[mux.Router]
+	router.Store(newRouter(auth, adminAddress == ""))
 
 	// Create the HTTP server
 	server := &http.Server{
-		Addr:         listenAddress,
-		Handler:      router,
+		Addr: listenAddress,
+		Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			router.Load().ServeHTTP(w, r)
+		}),
+		// Streaming requests, like following a bin, end when the context is canceled on shutdown
+		BaseContext:  func(net.Listener) context.Context { return ctx },
 		ReadTimeout:  15 * time.Second,
 		WriteTimeout: 10 * time.Second,
 		IdleTimeout:  5 * time.Second,
 	}
 
+	if tlsFiles.Enabled() {
+		tlsConfig, err := newTLSConfig(tlsFiles)
+		if err != nil {
+			log.Fatal().Msgf("Unable to configure TLS: %v", err)
+		}
+		server.TLSConfig = tlsConfig
+	}
+
 	// Run the server in a new goroutine
 	go func() {
-		log.Info().Msgf("Server is starting on %s", server.Addr)
-		if err := server.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+		var err error
+		if server.TLSConfig != nil {
+			log.Info().Msgf("Server is starting on %s with TLS", server.Addr)
+			// The certificate is served by TLSConfig.GetCertificate
+			err = server.ListenAndServeTLS("", "")
+		} else {
+			log.Info().Msgf("Server is starting on %s", server.Addr)
+			err = server.ListenAndServe()
+		}
+		if err != nil && err != http.ErrServerClosed {
 			log.Fatal().Msgf("Server failed to start: %v", err)
 		}
 	}()
 
-	// Wait for signals
-	sig := <-runChan
+	// Run the admin server for metrics on its own address
+	var adminServer *http.Server
+	var adminRouter atomic.Pointer[mux.Router]
+	adminRouter.Store(newAdminRouter(auth.Limits))
+	if adminAddress != "" {
+		adminServer = &http.Server{
+			Addr: adminAddress,
+			Handler: http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				adminRouter.Load().ServeHTTP(w, r)
+			}),
+			ReadTimeout:  15 * time.Second,
+			WriteTimeout: 10 * time.Second,
+			IdleTimeout:  5 * time.Second,
+		}
+		go func() {
+			log.Info().Msgf("Admin server is starting on %s", adminServer.Addr)
+			if err := adminServer.ListenAndServe(); err != nil && err != http.ErrServerClosed {
+				log.Fatal().Msgf("Admin server failed to start: %v", err)
+			}
+		}()
+	}
+
+	// Wait for signals, reloading the configuration until the server is stopped
+	var sig os.Signal
+	for sig == nil {
+		select {
+		case sig = <-runChan:
+		case <-hangupChan:
+			reloadRouter(&router, &adminRouter, reload, adminAddress == "")
+		case <-reloadRequests:
+			reloadRouter(&router, &adminRouter, reload, adminAddress == "")
+		}
+	}
 	log.Info().Msgf("Received signal: %v. Shutting down gracefully...", sig)
-	cancel() // Cancel context to trigger graceful shutdown
+
+	// Fail the readiness probe and keep serving until the traffic is drained
+	shuttingDown.Store(true)
+	if shutdownDelay > 0 {
+		log.Info().Msgf("Waiting %s for traffic to drain", shutdownDelay)
+		time.Sleep(shutdownDelay)
+	}
+
+	cancel() // Stop the background tasks and streaming requests
 	// Force shutdown after 10 seconds
 	time.AfterFunc(10*time.Second, func() {
 		log.Fatal().Msg("Timed out waiting for server to shut down")
 	})
 
-	// Shutdown the server and wait for it to finish
-	if err := server.Shutdown(ctx); err != nil {
+	// Shutdown the server and wait for in-flight requests to finish. ctx is already
+	// canceled, so waiting needs a context of its own.
+	shutdownCtx, shutdownCancel := context.WithTimeout(context.Background(), shutdownTimeout)
+	defer shutdownCancel()
+	if err := server.Shutdown(shutdownCtx); err != nil {
 		log.Error().Msgf("Error during server shutdown: %v", err)
 	}
+	if adminServer != nil {
+		if err := adminServer.Shutdown(shutdownCtx); err != nil {
+			log.Error().Msgf("Error during admin server shutdown: %v", err)
+		}
+	}
+
+	// Write pending changes, no request can make new ones anymore
+	runShutdownHooks()
 
 	log.Info().Msg("Server gracefully shut down")
 }
 
+// reloadRequests receives the requests to reload the configuration made with RequestReload.
+var reloadRequests = make(chan struct{}, 1)
+
+// RequestReload makes the running server reload its configuration like on SIGHUP.
+// Requests made while a reload is pending are merged.
+func RequestReload() {
+	select {
+	case reloadRequests <- struct{}{}:
+	default:
+	}
+}
+
+// reloadRouter reloads the configuration and replaces the routers with ones using the new authentication
+// configuration and rate limits. Requests being served keep using the previous routers.
+//
+// Parameters:
+//   - router: *atomic.Pointer[mux.Router]
+//     The router used by the HTTP server.
+//   - adminRouter: *atomic.Pointer[mux.Router]
+//     The router used by the admin server.
+//   - reload: func() (Auth, error)
+//     Reloads the configuration. If nil, nothing is reloaded.
+//   - serveMetrics: bool
+//     If true, '/metrics' is served like the editor, otherwise it is served by the admin router.
+func reloadRouter(router, adminRouter *atomic.Pointer[mux.Router], reload func() (Auth, error), serveMetrics bool) {
+	if reload == nil {
+		log.Warn().Msg("Reloading the configuration requires a config file")
+		return
+	}
+
+	log.Info().Msg("Reloading the configuration")
+	auth, err := reload()
+	if err != nil {
+		log.Error().Msgf("Keeping the current configuration, the new configuration is invalid: %v", err)
+		return
+	}
+
+	router.Store(newRouter(auth, serveMetrics))
+	adminRouter.Store(newAdminRouter(auth.Limits))
+}
+
 // newRouter generates the router used in the HTTP Server.
 //
+// Routes registered with ScopeNone require a token granted ScopeRead if authentication is required
+// or any token is restricted to specific bins.
+//
+// Parameters:
+//   - auth: Auth
+//     The authentication configuration of the server.
+//   - serveMetrics: bool
+//     If true, '/metrics' is served like the editor, otherwise it is served by the admin router.
+//
 // Returns:
 //   - *mux.Router
 //     A configured instance of the Gorilla Mux router.
-func newRouter(token string) *mux.Router {
+func newRouter(auth Auth, serveMetrics bool) *mux.Router {
 	router := mux.NewRouter()
 
 	// Handler for embed static files
@@ -81,16 +232,42 @@ func newRouter(token string) *mux.Router {
 	contentStatic, _ := fs.Sub(fsys, "web/static")
 	fs := http.FileServer(http.FS(contentStatic))
 	s := http.StripPrefix("/static/", fs)
-	router.PathPrefix("/static/").Handler(s)
+	router.PathPrefix("/static/").Handler(rateLimitMiddleware(s, "/static/", auth.Limits))
+
+	// Handlers for browser sessions
+	router.Handle("/login", rateLimitMiddleware(loginHandler(auth), "/login", auth.Limits)).Methods("GET", "POST")
+	router.Handle("/logout", rateLimitMiddleware(http.HandlerFunc(logoutHandler), "/logout", auth.Limits)).Methods("POST")
+
+	// Probes do not require authentication
+	router.Handle("/healthz", rateLimitMiddleware(http.HandlerFunc(healthHandler), "/healthz", auth.Limits)).Methods("GET")
+	router.Handle("/readyz", rateLimitMiddleware(http.HandlerFunc(readinessHandler), "/readyz", auth.Limits)).Methods("GET")
+
+	// Anonymous visitors must not open the editor of bins which only some tokens may access
+	requireAuth := auth.RequireAuth || slices.ContainsFunc(auth.Tokens, func(token Token) bool { return len(token.Bins) > 0 })
 
 	// Add the handlers to the router
-	for _, h := range Handlers {
-		if h.NeedsToken {
-			router.Handle(h.Path, ApiTokenMiddleware(h.Handler, token)).Methods(h.Methods...)
-			continue
+	handlers := append([]Handler{}, Handlers...)
+	if serveMetrics {
+		handlers = append(handlers, Handler{Path: "/metrics", Description: "Prometheus metrics", Handler: metrics.Handler().ServeHTTP, Methods: []string{"GET"}})
+	}
+	for _, h := range handlers {
+		var handler http.HandlerFunc
+		switch {
+		case h.Scope != ScopeNone:
+			handler = ApiTokenMiddleware(h.Handler, auth.Tokens, h.Scope)
+		case requireAuth:
+			handler = ApiTokenMiddleware(h.Handler, auth.Tokens, ScopeRead)
+		default:
+			handler = optionalAuthMiddleware(h.Handler, auth.Tokens)
 		}
-		router.HandleFunc(h.Path, h.Handler).Methods(h.Methods...)
+		router.Handle(h.Path, metrics.InstrumentHandler(h.Path, rateLimitMiddleware(handler, h.Path, auth.Limits))).Methods(h.Methods...)
+	}
 
+	// Limits of routes which do not exist are most likely typos
+	for route := range auth.Limits.Routes {
+		if !slices.ContainsFunc(handlers, func(h Handler) bool { return h.Path == route }) && !slices.Contains(builtinRoutes, route) {
+			log.Warn().Msgf("Rate limit configured for unknown route '%s'", route)
+		}
 	}
 
 	// Custom 404 handler
@@ -99,6 +276,24 @@ func newRouter(token string) *mux.Router {
 	return router
 }
 
+// newAdminRouter generates the router of the admin server.
+//
+// Parameters:
+//   - limits: Limits
+//     The rate limits of the routes.
+//
+// Returns:
+//   - *mux.Router
+//     A router serving '/metrics', '/healthz' and '/readyz'.
+func newAdminRouter(limits Limits) *mux.Router {
+	router := mux.NewRouter()
+	router.Handle("/metrics", rateLimitMiddleware(metrics.Handler(), "/metrics", limits)).Methods("GET")
+	router.Handle("/healthz", rateLimitMiddleware(http.HandlerFunc(healthHandler), "/healthz", limits)).Methods("GET")
+	router.Handle("/readyz", rateLimitMiddleware(http.HandlerFunc(readinessHandler), "/readyz", limits)).Methods("GET")
+	router.NotFoundHandler = http.HandlerFunc(notFoundHandler)
+	return router
+}
+
 // notFoundHandler handles 404 responses.
 //
 // Parameters:
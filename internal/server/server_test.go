@@ -1,13 +1,16 @@
 package server
 
 import (
+	"errors"
 	"net/http"
 	"net/http/httptest"
 	"os"
+	"sync/atomic"
 	"syscall"
 	"testing"
 	"time"
 
+	"github.com/gorilla/mux"
 	"github.com/stretchr/testify/assert"
 )
 
@@ -17,7 +20,7 @@ func TestRun(t *testing.T) {
 		testPort := "127.0.0.1:0"
 
 		// Run the server in a goroutine
-		go Run(testPort, "token")
+		go Run(testPort, Auth{Tokens: []Token{{Name: DefaultTokenName, Value: "token", Scopes: []Scope{ScopeAdmin}}}}, TLS{}, "", 0, nil)
 
 		// Allow some time for the server to start
 		time.Sleep(500 * time.Millisecond)
@@ -72,20 +75,30 @@ func TestNewRouter(t *testing.T) {
 			Path:        "/mock",
 			Handler:     mockHandler,
 			Methods:     []string{"GET"},
-			NeedsToken:  false,
+			Scope:       ScopeNone,
 			Description: "Mock handler without token",
 		},
 		{
 			Path:        "/mock-with-token",
 			Handler:     mockHandler,
 			Methods:     []string{"GET"},
-			NeedsToken:  true,
+			Scope:       ScopeRead,
 			Description: "Mock handler with token",
 		},
+		{
+			Path:        "/mock-with-write-token",
+			Handler:     mockHandler,
+			Methods:     []string{"POST"},
+			Scope:       ScopeWrite,
+			Description: "Mock handler with write token",
+		},
 	}
 
 	// Set up the router
-	router := newRouter("mock-token")
+	router := newRouter(Auth{Tokens: []Token{
+		{Name: DefaultTokenName, Value: "mock-token", Scopes: []Scope{ScopeAdmin}},
+		{Name: "dashboard", Value: "read-token", Scopes: []Scope{ScopeRead}},
+	}}, true)
 
 	// Test handler without token
 	t.Run("Handler without token", func(t *testing.T) {
@@ -122,6 +135,61 @@ func TestNewRouter(t *testing.T) {
 		assert.Equal(t, http.StatusUnauthorized, responseRecorder.Code)
 	})
 
+	// Test handler with a token missing the scope
+	t.Run("Handler with token missing the scope", func(t *testing.T) {
+		request := httptest.NewRequest("POST", "/mock-with-write-token", nil)
+		request.Header.Set("X-API-Token", "read-token")
+		responseRecorder := httptest.NewRecorder()
+
+		router.ServeHTTP(responseRecorder, request)
+
+		// Check the status code of the response
+		assert.Equal(t, http.StatusForbidden, responseRecorder.Code)
+	})
+
+	// Test handler with a token granted the scope
+	t.Run("Handler with token granted the scope", func(t *testing.T) {
+		request := httptest.NewRequest("GET", "/mock-with-token", nil)
+		request.Header.Set("X-API-Token", "read-token")
+		responseRecorder := httptest.NewRecorder()
+
+		router.ServeHTTP(responseRecorder, request)
+
+		// Check the status code of the response
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+	})
+
+	// Test metrics
+	t.Run("Metrics", func(t *testing.T) {
+		request := httptest.NewRequest("GET", "/metrics", nil)
+		responseRecorder := httptest.NewRecorder()
+
+		router.ServeHTTP(responseRecorder, request)
+
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+		assert.Contains(t, responseRecorder.Body.String(), `vimbin_http_requests_total{code="200",method="get",route="/mock"}`)
+		assert.Contains(t, responseRecorder.Body.String(), `vimbin_auth_failures_total{reason="invalid"}`)
+	})
+
+	// Test handler without token if a token is restricted to specific bins
+	t.Run("Handler without token requires a token if tokens are restricted to bins", func(t *testing.T) {
+		router := newRouter(Auth{Tokens: []Token{
+			{Name: DefaultTokenName, Value: "mock-token", Scopes: []Scope{ScopeAdmin}},
+			{Name: "ci", Value: "ci-token", Scopes: []Scope{ScopeRead}, Bins: []string{"logs"}},
+		}}, false)
+
+		request := httptest.NewRequest("GET", "/mock", nil)
+		responseRecorder := httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, request)
+		assert.Equal(t, http.StatusUnauthorized, responseRecorder.Code)
+
+		request = httptest.NewRequest("GET", "/mock", nil)
+		request.Header.Set("X-API-Token", "ci-token")
+		responseRecorder = httptest.NewRecorder()
+		router.ServeHTTP(responseRecorder, request)
+		assert.Equal(t, http.StatusOK, responseRecorder.Code)
+	})
+
 	// Test 404 handler
 	t.Run("404 handler", func(t *testing.T) {
 		request := httptest.NewRequest("GET", "/non-existent", nil)
@@ -133,3 +201,39 @@ func TestNewRouter(t *testing.T) {
 		assert.Equal(t, http.StatusNotFound, responseRecorder.Code)
 	})
 }
+
+func TestReloadRouter(t *testing.T) {
+	registered := Handlers
+	t.Cleanup(func() { Handlers = registered })
+	Handlers = []Handler{{
+		Path:    "/mock",
+		Handler: func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) },
+		Methods: []string{"GET"},
+		Scope:   ScopeRead,
+	}}
+
+	// status returns the status code of a request with the given token.
+	status := func(router *atomic.Pointer[mux.Router], token string) int {
+		request := httptest.NewRequest("GET", "/mock", nil)
+		request.Header.Set("X-API-Token", token)
+		responseRecorder := httptest.NewRecorder()
+		router.Load().ServeHTTP(responseRecorder, request)
+		return responseRecorder.Code
+	}
+
+	var router, adminRouter atomic.Pointer[mux.Router]
+	router.Store(newRouter(Auth{Tokens: []Token{{Name: DefaultTokenName, Value: "old-token", Scopes: []Scope{ScopeAdmin}}}}, false))
+
+	t.Run("Invalid configuration keeps the current router", func(t *testing.T) {
+		reloadRouter(&router, &adminRouter, func() (Auth, error) { return Auth{}, errors.New("invalid") }, false)
+		assert.Equal(t, http.StatusOK, status(&router, "old-token"))
+	})
+
+	t.Run("Tokens are replaced", func(t *testing.T) {
+		reloadRouter(&router, &adminRouter, func() (Auth, error) {
+			return Auth{Tokens: []Token{{Name: DefaultTokenName, Value: "new-token", Scopes: []Scope{ScopeAdmin}}}}, nil
+		}, false)
+		assert.Equal(t, http.StatusUnauthorized, status(&router, "old-token"))
+		assert.Equal(t, http.StatusOK, status(&router, "new-token"))
+	})
+}
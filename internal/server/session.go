@@ -0,0 +1,131 @@
+package server
+
+import (
+	"crypto/sha256"
+	"sync"
+	"time"
+	"vimbin/internal/utils"
+)
+
+// SessionCookieName is the name of the cookie holding the session of a browser.
+const SessionCookieName = "vimbin_session"
+
+// CSRFHeader is the header in which browsers send the CSRF token of their session.
+const CSRFHeader = "X-CSRF-Token"
+
+// session is a browser session created by logging in with an API token.
+type session struct {
+	token   string            // token is the name of the token used to log in.
+	digest  [sha256.Size]byte // digest is the hash of the token value used to log in.
+	csrf    string            // csrf is the token which must accompany every modifying request of the session.
+	expires time.Time         // expires is the time after which the session is no longer valid.
+}
+
+// matches checks if the session was created with the current value of a token.
+// Sessions end when the value of their token is changed or rotated.
+//
+// Parameters:
+//   - token: Token
+//     The token named like the token of the session.
+//
+// Returns:
+//   - bool
+//     True if the token still has the value used to log in.
+func (s session) matches(token Token) bool {
+	return token.Name == s.token && sha256.Sum256([]byte(token.Value)) == s.digest
+}
+
+// sessionStore keeps the sessions of all logged in browsers in memory.
+type sessionStore struct {
+	sessions map[string]session
+	mutex    sync.Mutex
+}
+
+// sessions holds the sessions of all logged in browsers.
+var sessions = &sessionStore{sessions: map[string]session{}}
+
+// create starts a new session for a token.
+//
+// Parameters:
+//   - token: Token
+//     The token used to log in.
+//   - ttl: time.Duration
+//     How long the session is valid.
+//
+// Returns:
+//   - string
+//     The ID of the session, stored in the session cookie.
+//   - session
+//     The new session.
+//   - error
+//     An error if no random ID or CSRF token could be generated.
+func (s *sessionStore) create(token Token, ttl time.Duration) (string, session, error) {
+	id, err := utils.GenerateRandomToken(43)
+	if err != nil {
+		return "", session{}, err
+	}
+	csrf, err := utils.GenerateRandomToken(43)
+	if err != nil {
+		return "", session{}, err
+	}
+
+	created := session{
+		token:   token.Name,
+		digest:  sha256.Sum256([]byte(token.Value)),
+		csrf:    csrf,
+		expires: time.Now().Add(ttl),
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	// Drop expired sessions, so the store does not grow forever
+	now := time.Now()
+	for key, existing := range s.sessions {
+		if now.After(existing.expires) {
+			delete(s.sessions, key)
+		}
+	}
+	s.sessions[id] = created
+
+	return id, created, nil
+}
+
+// get returns a session which has not expired yet.
+//
+// Parameters:
+//   - id: string
+//     The ID of the session.
+//
+// Returns:
+//   - session
+//     The session.
+//   - bool
+//     False if there is no such session or it expired.
+func (s *sessionStore) get(id string) (session, bool) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	existing, ok := s.sessions[id]
+	if !ok {
+		return session{}, false
+	}
+	if time.Now().After(existing.expires) {
+		delete(s.sessions, id)
+		return session{}, false
+	}
+
+	return existing, true
+}
+
+// delete ends a session.
+//
+// Parameters:
+//   - id: string
+//     The ID of the session.
+func (s *sessionStore) delete(id string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	delete(s.sessions, id)
+}
@@ -0,0 +1,121 @@
+package server
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// Task is work done periodically in the background while the server runs.
+type Task struct {
+	Name     string        // Name identifies the task in the logs.
+	Interval time.Duration // Interval is the time between two runs.
+	Run      func()        // Run does the work. It must be safe for concurrent use with the handlers.
+}
+
+// Service is work running in the background for as long as the server runs, like watching for changes.
+type Service struct {
+	Name string                    // Name identifies the service in the logs.
+	Run  func(ctx context.Context) // Run does the work until the context is canceled. It must be safe for concurrent use with the handlers.
+}
+
+// ShutdownHook is work done once the server stopped serving requests, like writing pending changes.
+type ShutdownHook struct {
+	Name string // Name identifies the hook in the logs.
+	Run  func() // Run does the work.
+}
+
+var (
+	tasks         []Task         // tasks are the tasks started by Run.
+	services      []Service      // services are the services started by Run.
+	shutdownHooks []ShutdownHook // shutdownHooks are run by Run after the server shut down.
+	tasksMutex    sync.Mutex     // tasksMutex protects tasks, services and shutdownHooks.
+)
+
+// RegisterTask adds a task which Run starts in the background.
+//
+// Parameters:
+//   - name: string
+//     The name of the task.
+//   - interval: time.Duration
+//     The time between two runs. The first run happens after one interval.
+//   - run: func()
+//     The work to do.
+func RegisterTask(name string, interval time.Duration, run func()) {
+	tasksMutex.Lock()
+	defer tasksMutex.Unlock()
+	tasks = append(tasks, Task{Name: name, Interval: interval, Run: run})
+}
+
+// RegisterService adds a service which Run starts in the background.
+//
+// Parameters:
+//   - name: string
+//     The name of the service.
+//   - run: func(context.Context)
+//     The work to do until the context is canceled.
+func RegisterService(name string, run func(ctx context.Context)) {
+	tasksMutex.Lock()
+	defer tasksMutex.Unlock()
+	services = append(services, Service{Name: name, Run: run})
+}
+
+// RegisterShutdownHook adds a hook which Run runs after the server shut down.
+// Hooks run in the order they were registered.
+//
+// Parameters:
+//   - name: string
+//     The name of the hook.
+//   - run: func()
+//     The work to do.
+func RegisterShutdownHook(name string, run func()) {
+	tasksMutex.Lock()
+	defer tasksMutex.Unlock()
+	shutdownHooks = append(shutdownHooks, ShutdownHook{Name: name, Run: run})
+}
+
+// runShutdownHooks runs every registered shutdown hook, one after another.
+func runShutdownHooks() {
+	tasksMutex.Lock()
+	defer tasksMutex.Unlock()
+
+	for _, hook := range shutdownHooks {
+		log.Debug().Msgf("Running shutdown hook '%s'", hook.Name)
+		hook.Run()
+	}
+}
+
+// startTasks runs every registered task and service in its own goroutine until the context is canceled.
+//
+// Parameters:
+//   - ctx: context.Context
+//     The context which stops the tasks when canceled.
+func startTasks(ctx context.Context) {
+	tasksMutex.Lock()
+	defer tasksMutex.Unlock()
+
+	for _, task := range tasks {
+		go func(task Task) {
+			log.Debug().Msgf("Running task '%s' every %s", task.Name, task.Interval)
+
+			ticker := time.NewTicker(task.Interval)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					task.Run()
+				case <-ctx.Done():
+					return
+				}
+			}
+		}(task)
+	}
+
+	for _, service := range services {
+		log.Debug().Msgf("Starting service '%s'", service.Name)
+		go service.Run(ctx)
+	}
+}
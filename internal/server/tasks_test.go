@@ -0,0 +1,67 @@
+package server
+
+import (
+	"context"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTasks(t *testing.T) {
+	t.Run("Tasks run until the context is canceled", func(t *testing.T) {
+		registered := tasks
+		t.Cleanup(func() { tasks = registered })
+		tasks = nil
+
+		var runs atomic.Int32
+		RegisterTask("count", 5*time.Millisecond, func() { runs.Add(1) })
+
+		ctx, cancel := context.WithCancel(context.Background())
+		startTasks(ctx)
+
+		assert.Eventually(t, func() bool { return runs.Load() >= 2 }, time.Second, time.Millisecond)
+
+		cancel()
+		time.Sleep(20 * time.Millisecond)
+		stopped := runs.Load()
+		time.Sleep(20 * time.Millisecond)
+		assert.Equal(t, stopped, runs.Load())
+	})
+
+	t.Run("Shutdown hooks run in order", func(t *testing.T) {
+		registered := shutdownHooks
+		t.Cleanup(func() { shutdownHooks = registered })
+		shutdownHooks = nil
+
+		order := []string{}
+		RegisterShutdownHook("first", func() { order = append(order, "first") })
+		RegisterShutdownHook("second", func() { order = append(order, "second") })
+
+		runShutdownHooks()
+		assert.Equal(t, []string{"first", "second"}, order)
+	})
+
+	t.Run("Services run until the context is canceled", func(t *testing.T) {
+		registeredTasks, registeredServices := tasks, services
+		t.Cleanup(func() { tasks, services = registeredTasks, registeredServices })
+		tasks, services = nil, nil
+
+		stopped := make(chan struct{})
+		RegisterService("wait", func(ctx context.Context) {
+			<-ctx.Done()
+			close(stopped)
+		})
+
+		ctx, cancel := context.WithCancel(context.Background())
+		startTasks(ctx)
+		cancel()
+
+		select {
+		case <-stopped:
+		case <-time.After(time.Second):
+			t.Fatal("Service was not stopped")
+		}
+	})
+}
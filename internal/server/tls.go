@@ -0,0 +1,192 @@
+package server
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/rs/zerolog/log"
+)
+
+// certificateCheckInterval is the minimum interval in which the certificate files are checked for changes.
+const certificateCheckInterval = 5 * time.Second
+
+// TLS configures serving HTTPS.
+type TLS struct {
+	CertFile          string // CertFile is the path to the PEM encoded certificate (chain).
+	KeyFile           string // KeyFile is the path to the PEM encoded private key.
+	ClientCAFile      string // ClientCAFile is the path to the PEM encoded CA bundle client certificates are verified against.
+	RequireClientCert bool   // RequireClientCert rejects connections without a valid client certificate.
+}
+
+// Enabled checks if HTTPS should be served.
+//
+// Returns:
+//   - bool
+//     True if a certificate and a key are configured.
+func (t TLS) Enabled() bool {
+	return t.CertFile != "" && t.KeyFile != ""
+}
+
+// newTLSConfig creates the TLS configuration of the HTTP server.
+//
+// The certificate is reloaded when the certificate or key file changes. If a client CA bundle is
+// configured, client certificates are verified against it. They are optional unless RequireClientCert is set.
+//
+// Parameters:
+//   - t: TLS
+//     The TLS settings.
+//
+// Returns:
+//   - *tls.Config
+//     The TLS configuration.
+//   - error
+//     An error if the certificate, the key or the CA bundle cannot be loaded.
+func newTLSConfig(t TLS) (*tls.Config, error) {
+	reloader, err := newCertificateReloader(t.CertFile, t.KeyFile)
+	if err != nil {
+		return nil, err
+	}
+
+	config := &tls.Config{
+		MinVersion:     tls.VersionTLS12,
+		GetCertificate: reloader.GetCertificate,
+	}
+
+	if t.ClientCAFile != "" {
+		bundle, err := os.ReadFile(t.ClientCAFile)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to read client CA bundle: %s", err)
+		}
+		pool := x509.NewCertPool()
+		if !pool.AppendCertsFromPEM(bundle) {
+			return nil, fmt.Errorf("No certificates found in client CA bundle '%s'", t.ClientCAFile)
+		}
+
+		config.ClientCAs = pool
+		config.ClientAuth = tls.VerifyClientCertIfGiven
+		if t.RequireClientCert {
+			config.ClientAuth = tls.RequireAndVerifyClientCert
+		}
+	}
+
+	return config, nil
+}
+
+// certificateReloader serves a certificate and reloads it when its files change on disk,
+// e.g. when cert-manager rotates the certificate.
+type certificateReloader struct {
+	certFile    string
+	keyFile     string
+	certificate *tls.Certificate
+	modTimes    [2]time.Time // modTimes are the modification times of the loaded certificate and key file.
+	lastCheck   time.Time
+	mutex       sync.Mutex
+}
+
+// newCertificateReloader loads a certificate and its key.
+//
+// Parameters:
+//   - certFile: string
+//     The path to the PEM encoded certificate (chain).
+//   - keyFile: string
+//     The path to the PEM encoded private key.
+//
+// Returns:
+//   - *certificateReloader
+//     The reloader serving the certificate.
+//   - error
+//     An error if the certificate or the key cannot be loaded.
+func newCertificateReloader(certFile, keyFile string) (*certificateReloader, error) {
+	reloader := &certificateReloader{certFile: certFile, keyFile: keyFile}
+	if err := reloader.load(); err != nil {
+		return nil, err
+	}
+	return reloader, nil
+}
+
+// GetCertificate returns the current certificate. It is used as tls.Config.GetCertificate.
+//
+// Parameters:
+//   - hello: *tls.ClientHelloInfo
+//     The ClientHello of the connection.
+//
+// Returns:
+//   - *tls.Certificate
+//     The current certificate.
+//   - error
+//     Always nil. If reloading fails, the previous certificate is served.
+func (c *certificateReloader) GetCertificate(hello *tls.ClientHelloInfo) (*tls.Certificate, error) {
+	c.mutex.Lock()
+	check := time.Since(c.lastCheck) >= certificateCheckInterval
+	c.mutex.Unlock()
+
+	if check {
+		c.reloadIfChanged()
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	return c.certificate, nil
+}
+
+// reloadIfChanged reloads the certificate if the modification time of the certificate or key file changed.
+func (c *certificateReloader) reloadIfChanged() {
+	modTimes, err := c.stat()
+
+	c.mutex.Lock()
+	c.lastCheck = time.Now()
+	changed := err == nil && modTimes != c.modTimes
+	c.mutex.Unlock()
+
+	if err != nil {
+		log.Error().Msgf("Unable to check certificate files: %v", err)
+		return
+	}
+	if !changed {
+		return
+	}
+
+	if err := c.load(); err != nil {
+		log.Error().Msgf("Unable to reload certificate, keeping the previous one: %v", err)
+		return
+	}
+	log.Info().Msgf("Reloaded certificate '%s'", c.certFile)
+}
+
+// load reads the certificate and key file.
+func (c *certificateReloader) load() error {
+	modTimes, err := c.stat()
+	if err != nil {
+		return err
+	}
+
+	certificate, err := tls.LoadX509KeyPair(c.certFile, c.keyFile)
+	if err != nil {
+		return fmt.Errorf("Unable to load certificate: %s", err)
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+	c.certificate = &certificate
+	c.modTimes = modTimes
+	c.lastCheck = time.Now()
+
+	return nil
+}
+
+// stat returns the modification times of the certificate and key file.
+func (c *certificateReloader) stat() ([2]time.Time, error) {
+	var modTimes [2]time.Time
+	for i, file := range []string{c.certFile, c.keyFile} {
+		info, err := os.Stat(file)
+		if err != nil {
+			return modTimes, fmt.Errorf("Unable to read certificate file: %s", err)
+		}
+		modTimes[i] = info.ModTime()
+	}
+	return modTimes, nil
+}
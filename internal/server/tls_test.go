@@ -0,0 +1,186 @@
+package server
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// writeCertificate writes a self-signed certificate with the given common name and serial number
+// to cert.pem and key.pem in the directory and returns the parsed certificate.
+func writeCertificate(t *testing.T, dir, commonName string, serial int64) *x509.Certificate {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	assert.NoError(t, err)
+
+	template := &x509.Certificate{
+		SerialNumber:          big.NewInt(serial),
+		Subject:               pkix.Name{CommonName: commonName},
+		DNSNames:              []string{"localhost"},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature | x509.KeyUsageCertSign,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth, x509.ExtKeyUsageClientAuth},
+		BasicConstraintsValid: true,
+		IsCA:                  true,
+	}
+	der, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	assert.NoError(t, err)
+	keyDER, err := x509.MarshalECPrivateKey(key)
+	assert.NoError(t, err)
+
+	assert.NoError(t, os.WriteFile(path.Join(dir, "cert.pem"), pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: der}), 0600))
+	assert.NoError(t, os.WriteFile(path.Join(dir, "key.pem"), pem.EncodeToMemory(&pem.Block{Type: "EC PRIVATE KEY", Bytes: keyDER}), 0600))
+
+	certificate, err := x509.ParseCertificate(der)
+	assert.NoError(t, err)
+	return certificate
+}
+
+func TestCertificateReloader(t *testing.T) {
+	t.Run("Certificate is reloaded when the files change", func(t *testing.T) {
+		dir := t.TempDir()
+		writeCertificate(t, dir, "localhost", 1)
+
+		reloader, err := newCertificateReloader(path.Join(dir, "cert.pem"), path.Join(dir, "key.pem"))
+		assert.NoError(t, err)
+		assert.Equal(t, int64(1), serialNumber(t, reloader))
+
+		writeCertificate(t, dir, "localhost", 2)
+		later := time.Now().Add(time.Minute)
+		assert.NoError(t, os.Chtimes(path.Join(dir, "cert.pem"), later, later))
+
+		reloader.reloadIfChanged()
+		assert.Equal(t, int64(2), serialNumber(t, reloader))
+	})
+
+	t.Run("Previous certificate is kept if the new one is invalid", func(t *testing.T) {
+		dir := t.TempDir()
+		writeCertificate(t, dir, "localhost", 1)
+
+		reloader, err := newCertificateReloader(path.Join(dir, "cert.pem"), path.Join(dir, "key.pem"))
+		assert.NoError(t, err)
+
+		assert.NoError(t, os.WriteFile(path.Join(dir, "key.pem"), []byte("garbage"), 0600))
+		later := time.Now().Add(time.Minute)
+		assert.NoError(t, os.Chtimes(path.Join(dir, "key.pem"), later, later))
+
+		reloader.reloadIfChanged()
+		assert.Equal(t, int64(1), serialNumber(t, reloader))
+	})
+
+	t.Run("Missing files fail", func(t *testing.T) {
+		_, err := newCertificateReloader("missing.pem", "missing-key.pem")
+		assert.Error(t, err)
+	})
+}
+
+// serialNumber returns the serial number of the certificate currently served.
+func serialNumber(t *testing.T, reloader *certificateReloader) int64 {
+	t.Helper()
+
+	certificate, err := reloader.GetCertificate(nil)
+	assert.NoError(t, err)
+	parsed, err := x509.ParseCertificate(certificate.Certificate[0])
+	assert.NoError(t, err)
+	return parsed.SerialNumber.Int64()
+}
+
+func TestClientCertificates(t *testing.T) {
+	okHandler := func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte(TokenName(r)))
+	}
+	tokens := []Token{{Name: "ci", Value: "ci-token", Scopes: []Scope{ScopeAppend}}}
+	middleware := ApiTokenMiddleware(okHandler, tokens, ScopeAppend)
+
+	// newRequest creates a request made with a verified client certificate.
+	newRequest := func(commonName string) *http.Request {
+		request := httptest.NewRequest("POST", "/append", nil)
+		certificate := &x509.Certificate{Subject: pkix.Name{CommonName: commonName}}
+		request.TLS = &tls.ConnectionState{
+			PeerCertificates: []*x509.Certificate{certificate},
+			VerifiedChains:   [][]*x509.Certificate{{certificate}},
+		}
+		return request
+	}
+
+	t.Run("Certificate authenticates as the token with its common name", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		middleware(recorder, newRequest("ci"))
+
+		assert.Equal(t, http.StatusOK, recorder.Code)
+		assert.Equal(t, "ci", recorder.Body.String())
+	})
+
+	t.Run("Certificate without a token is rejected", func(t *testing.T) {
+		recorder := httptest.NewRecorder()
+		middleware(recorder, newRequest("unknown"))
+
+		assert.Equal(t, http.StatusUnauthorized, recorder.Code)
+	})
+
+	t.Run("Requests from other sites are rejected", func(t *testing.T) {
+		request := newRequest("ci")
+		request.Header.Set("Origin", "https://evil.example.com")
+		recorder := httptest.NewRecorder()
+		middleware(recorder, request)
+
+		assert.Equal(t, http.StatusForbidden, recorder.Code)
+	})
+
+	t.Run("HTTPS with client certificates", func(t *testing.T) {
+		dir := t.TempDir()
+		writeCertificate(t, dir, "ci", 1)
+
+		config, err := newTLSConfig(TLS{
+			CertFile:          path.Join(dir, "cert.pem"),
+			KeyFile:           path.Join(dir, "key.pem"),
+			ClientCAFile:      path.Join(dir, "cert.pem"),
+			RequireClientCert: true,
+		})
+		assert.NoError(t, err)
+
+		httpServer := httptest.NewUnstartedServer(middleware)
+		httpServer.TLS = config
+		httpServer.StartTLS()
+		defer httpServer.Close()
+
+		// The self-signed certificate is used as server, client and CA certificate
+		clientCertificate, err := tls.LoadX509KeyPair(path.Join(dir, "cert.pem"), path.Join(dir, "key.pem"))
+		assert.NoError(t, err)
+		pool := x509.NewCertPool()
+		caBundle, err := os.ReadFile(path.Join(dir, "cert.pem"))
+		assert.NoError(t, err)
+		pool.AppendCertsFromPEM(caBundle)
+
+		client := &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{
+			RootCAs:      pool,
+			ServerName:   "localhost",
+			Certificates: []tls.Certificate{clientCertificate},
+		}}}
+		response, err := client.Post(httpServer.URL, "text/plain", nil)
+		if assert.NoError(t, err) {
+			defer response.Body.Close()
+			assert.Equal(t, http.StatusOK, response.StatusCode)
+		}
+
+		// Without a client certificate, the handshake fails
+		client = &http.Client{Transport: &http.Transport{TLSClientConfig: &tls.Config{RootCAs: pool, ServerName: "localhost"}}}
+		_, err = client.Post(httpServer.URL, "text/plain", nil)
+		assert.Error(t, err)
+	})
+}
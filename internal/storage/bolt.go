@@ -0,0 +1,282 @@
+package storage
+
+import (
+	"encoding/binary"
+	"encoding/json"
+	"fmt"
+	"time"
+
+	bolt "go.etcd.io/bbolt"
+)
+
+// Bucket names used by the bolt storage backend.
+var (
+	boltContentBucket  = []byte("bins")     // boltContentBucket maps the bin name to its content.
+	boltModTimeBucket  = []byte("modTimes") // boltModTimeBucket maps the bin name to its modification time.
+	boltHistoryBucket  = []byte("history")  // boltHistoryBucket contains a nested bucket with the revisions of every bin.
+	boltMetadataBucket = []byte("metadata") // boltMetadataBucket maps the bin name to its metadata.
+)
+
+// boltRevision is the stored representation of a revision.
+type boltRevision struct {
+	Revision Revision `json:"revision"` // Revision is the metadata of the revision.
+	Content  string   `json:"content"`  // Content is the content of the revision.
+}
+
+// Bolt is a storage backend which stores all bins in an embedded bbolt database.
+type Bolt struct {
+	db *bolt.DB // db is the opened database.
+}
+
+// NewBolt opens or creates a bbolt database.
+//
+// Parameters:
+//   - filePath: string
+//     The path to the database file.
+//
+// Returns:
+//   - *Bolt
+//     The bolt storage backend.
+//   - error
+//     An error if the database cannot be opened.
+func NewBolt(filePath string) (*Bolt, error) {
+	db, err := bolt.Open(filePath, filePermission, &bolt.Options{Timeout: 5 * time.Second})
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open bolt database '%s': %s", filePath, err)
+	}
+
+	if err := db.Update(func(tx *bolt.Tx) error {
+		for _, bucket := range [][]byte{boltContentBucket, boltModTimeBucket, boltHistoryBucket, boltMetadataBucket} {
+			if _, err := tx.CreateBucketIfNotExists(bucket); err != nil {
+				return err
+			}
+		}
+		return nil
+	}); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Unable to initialize bolt database '%s': %s", filePath, err)
+	}
+
+	return &Bolt{db: db}, nil
+}
+
+// Close closes the database.
+func (b *Bolt) Close() error {
+	return b.db.Close()
+}
+
+// Get returns the content of a bin.
+func (b *Bolt) Get(name string) (content string, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltContentBucket).Get([]byte(name))
+		if value == nil {
+			return ErrNotFound
+		}
+		content = string(value)
+		return nil
+	})
+
+	return content, err
+}
+
+// Put replaces the content of a bin.
+func (b *Bolt) Put(name, content string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		return b.write(tx, name, []byte(content))
+	})
+}
+
+// Append appends content to a bin.
+func (b *Bolt) Append(name, content string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		existing := tx.Bucket(boltContentBucket).Get([]byte(name))
+		value := make([]byte, 0, len(existing)+len(content))
+		value = append(append(value, existing...), content...)
+		return b.write(tx, name, value)
+	})
+}
+
+// write stores the content and the modification time of a bin within a transaction.
+func (b *Bolt) write(tx *bolt.Tx, name string, content []byte) error {
+	if err := tx.Bucket(boltContentBucket).Put([]byte(name), content); err != nil {
+		return err
+	}
+	modTime, err := time.Now().MarshalBinary()
+	if err != nil {
+		return err
+	}
+
+	return tx.Bucket(boltModTimeBucket).Put([]byte(name), modTime)
+}
+
+// Delete removes a bin.
+func (b *Bolt) Delete(name string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if tx.Bucket(boltContentBucket).Get([]byte(name)) == nil {
+			return ErrNotFound
+		}
+		if err := tx.Bucket(boltContentBucket).Delete([]byte(name)); err != nil {
+			return err
+		}
+		return tx.Bucket(boltModTimeBucket).Delete([]byte(name))
+	})
+}
+
+// List returns the names of all bins. Keys are stored sorted by bbolt.
+func (b *Bolt) List() ([]string, error) {
+	names := []string{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		return tx.Bucket(boltContentBucket).ForEach(func(key, _ []byte) error {
+			names = append(names, string(key))
+			return nil
+		})
+	})
+
+	return names, err
+}
+
+// Stat returns information about a bin.
+func (b *Bolt) Stat(name string) (info Info, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltContentBucket).Get([]byte(name))
+		if value == nil {
+			return ErrNotFound
+		}
+		info = Info{Name: name, Size: int64(len(value))}
+		if modTime := tx.Bucket(boltModTimeBucket).Get([]byte(name)); modTime != nil {
+			return info.ModTime.UnmarshalBinary(modTime)
+		}
+		return nil
+	})
+
+	return info, err
+}
+
+// revisionKey returns the key of a revision. Keys are big endian, so they are sorted by ID.
+func revisionKey(id int) []byte {
+	key := make([]byte, 8)
+	binary.BigEndian.PutUint64(key, uint64(id))
+	return key
+}
+
+// AddRevision stores a new revision of a bin.
+func (b *Bolt) AddRevision(name string, revision Revision, content string) (Revision, error) {
+	err := b.db.Update(func(tx *bolt.Tx) error {
+		bucket, err := tx.Bucket(boltHistoryBucket).CreateBucketIfNotExists([]byte(name))
+		if err != nil {
+			return err
+		}
+
+		revision.ID = 1
+		if key, _ := bucket.Cursor().Last(); key != nil {
+			revision.ID = int(binary.BigEndian.Uint64(key)) + 1
+		}
+
+		value, err := json.Marshal(boltRevision{Revision: revision, Content: content})
+		if err != nil {
+			return err
+		}
+
+		return bucket.Put(revisionKey(revision.ID), value)
+	})
+
+	return revision, err
+}
+
+// Revisions returns all revisions of a bin.
+func (b *Bolt) Revisions(name string) ([]Revision, error) {
+	revisions := []Revision{}
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltHistoryBucket).Bucket([]byte(name))
+		if bucket == nil {
+			return nil
+		}
+		return bucket.ForEach(func(_, value []byte) error {
+			var stored boltRevision
+			if err := json.Unmarshal(value, &stored); err != nil {
+				return err
+			}
+			revisions = append(revisions, stored.Revision)
+			return nil
+		})
+	})
+
+	return revisions, err
+}
+
+// Revision returns a revision and its content.
+func (b *Bolt) Revision(name string, id int) (Revision, string, error) {
+	var stored boltRevision
+	err := b.db.View(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltHistoryBucket).Bucket([]byte(name))
+		if bucket == nil {
+			return ErrRevisionNotFound
+		}
+		value := bucket.Get(revisionKey(id))
+		if value == nil {
+			return ErrRevisionNotFound
+		}
+		return json.Unmarshal(value, &stored)
+	})
+
+	return stored.Revision, stored.Content, err
+}
+
+// DeleteRevision removes a revision.
+func (b *Bolt) DeleteRevision(name string, id int) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		bucket := tx.Bucket(boltHistoryBucket).Bucket([]byte(name))
+		if bucket == nil || bucket.Get(revisionKey(id)) == nil {
+			return ErrRevisionNotFound
+		}
+		return bucket.Delete(revisionKey(id))
+	})
+}
+
+// GetMetadata returns the metadata of a bin.
+func (b *Bolt) GetMetadata(name string) (metadata Metadata, err error) {
+	err = b.db.View(func(tx *bolt.Tx) error {
+		value := tx.Bucket(boltMetadataBucket).Get([]byte(name))
+		if value == nil {
+			return nil
+		}
+		return json.Unmarshal(value, &metadata)
+	})
+
+	return metadata, err
+}
+
+// PutMetadata replaces the metadata of a bin.
+func (b *Bolt) PutMetadata(name string, metadata Metadata) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		if metadata.IsZero() {
+			return tx.Bucket(boltMetadataBucket).Delete([]byte(name))
+		}
+		value, err := json.Marshal(metadata)
+		if err != nil {
+			return err
+		}
+		return tx.Bucket(boltMetadataBucket).Put([]byte(name), value)
+	})
+}
+
+// Purge removes a bin together with its metadata and revisions in a single transaction.
+func (b *Bolt) Purge(name string) error {
+	return b.db.Update(func(tx *bolt.Tx) error {
+		key := []byte(name)
+		history := tx.Bucket(boltHistoryBucket)
+		if tx.Bucket(boltContentBucket).Get(key) == nil && tx.Bucket(boltMetadataBucket).Get(key) == nil && history.Bucket(key) == nil {
+			return ErrNotFound
+		}
+
+		for _, bucket := range [][]byte{boltContentBucket, boltModTimeBucket, boltMetadataBucket} {
+			if err := tx.Bucket(bucket).Delete(key); err != nil {
+				return err
+			}
+		}
+		if history.Bucket(key) != nil {
+			return history.DeleteBucket(key)
+		}
+		return nil
+	})
+}
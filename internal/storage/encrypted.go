@@ -0,0 +1,360 @@
+package storage
+
+import (
+	"context"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+)
+
+// Encrypted content is a sequence of records, so appends only add a record instead of rewriting the content.
+// Every record is a line starting with the magic, followed by the base64 encoded ID of the key, a random nonce
+// and the AES-256-GCM ciphertext and tag. Records are text, so every backend can store them like plain content.
+// The name of the bin is authenticated as well, so records cannot be moved to another bin.
+const (
+	encryptionMagic     = "vimbin:aead:v1:" // encryptionMagic starts every encrypted record.
+	encryptionKeySize   = 32                // encryptionKeySize is the size of keys in bytes, selecting AES-256.
+	encryptionKeyIDSize = 4                 // encryptionKeyIDSize is the size of the key ID, the start of the SHA-256 hash of the key.
+	encryptionHashLabel = "vimbin:hash:v1"  // encryptionHashLabel derives the key of content hashes from the first key.
+)
+
+// ErrUnknownKey is returned if content was encrypted with a key which is not configured.
+var ErrUnknownKey = errors.New("Content was encrypted with an unknown key")
+
+// encryptionKey is a key used to encrypt content at rest.
+type encryptionKey struct {
+	id   string      // id identifies the key in the records encrypted with it.
+	aead cipher.AEAD // aead encrypts and decrypts records.
+}
+
+// Encrypted is a storage backend which encrypts the content and revisions of bins before they are written
+// to another backend. Metadata, like the expiry of bins, is stored unencrypted. Hashes of the content, like the
+// hash of revisions, are keyed, so they cannot be used to guess the content.
+//
+// The first key encrypts, all keys decrypt. Content which is not encrypted or encrypted with another key is
+// encrypted with the first key the next time it is written, so keys can be rotated by adding a new key in
+// front and removing the old key once all bins were written.
+type Encrypted struct {
+	Storage                 // Storage is the backend storing the encrypted content.
+	keys    []encryptionKey // keys are the configured keys. The first key encrypts.
+	hashKey []byte          // hashKey keys the hashes of the content, derived from the first key.
+}
+
+// NewEncrypted creates a storage backend which encrypts the content of another backend.
+//
+// Parameters:
+//   - store: Storage
+//     The backend storing the encrypted content.
+//   - keys: [][]byte
+//     The keys, as returned by ParseEncryptionKeys. The first key encrypts.
+//
+// Returns:
+//   - *Encrypted
+//     The encrypting storage backend.
+//   - error
+//     An error if no key is given or a key is invalid.
+func NewEncrypted(store Storage, keys [][]byte) (*Encrypted, error) {
+	if len(keys) == 0 {
+		return nil, errors.New("No encryption key configured")
+	}
+
+	encrypted := &Encrypted{Storage: store}
+	for _, key := range keys {
+		if len(key) != encryptionKeySize {
+			return nil, fmt.Errorf("Invalid encryption key size %d, expected %d bytes", len(key), encryptionKeySize)
+		}
+		block, err := aes.NewCipher(key)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to create cipher: %s", err)
+		}
+		aead, err := cipher.NewGCM(block)
+		if err != nil {
+			return nil, fmt.Errorf("Unable to create cipher: %s", err)
+		}
+		hash := sha256.Sum256(key)
+		encrypted.keys = append(encrypted.keys, encryptionKey{id: string(hash[:encryptionKeyIDSize]), aead: aead})
+	}
+
+	mac := hmac.New(sha256.New, keys[0])
+	mac.Write([]byte(encryptionHashLabel))
+	encrypted.hashKey = mac.Sum(nil)
+
+	return encrypted, nil
+}
+
+// ParseEncryptionKeys parses keys to encrypt the storage with.
+//
+// Keys are 32 random bytes encoded as base64, e.g. created with 'head -c 32 /dev/urandom | base64'.
+// They are separated by newlines or commas. Empty lines and lines starting with '#' are ignored.
+//
+// Parameters:
+//   - value: string
+//     The encoded keys, e.g. the content of a key file.
+//
+// Returns:
+//   - [][]byte
+//     The keys in the given order.
+//   - error
+//     An error if a key is invalid.
+func ParseEncryptionKeys(value string) ([][]byte, error) {
+	var keys [][]byte
+	for _, line := range strings.Split(value, "\n") {
+		line = strings.TrimSpace(line)
+		if strings.HasPrefix(line, "#") {
+			continue
+		}
+		for _, encoded := range strings.Split(line, ",") {
+			encoded = strings.TrimSpace(encoded)
+			if encoded == "" {
+				continue
+			}
+			key, err := base64.StdEncoding.DecodeString(encoded)
+			if err != nil {
+				key, err = base64.RawURLEncoding.DecodeString(strings.TrimRight(encoded, "="))
+			}
+			if err != nil || len(key) != encryptionKeySize {
+				return nil, fmt.Errorf("Invalid encryption key %d. Keys are %d random bytes encoded as base64", len(keys)+1, encryptionKeySize)
+			}
+			keys = append(keys, key)
+		}
+	}
+
+	return keys, nil
+}
+
+// IsEncrypted reports whether content read from a storage backend was encrypted at rest.
+//
+// Parameters:
+//   - content: string
+//     The content as stored in the backend.
+//
+// Returns:
+//   - bool
+//     True if the content starts with an encrypted record.
+func IsEncrypted(content string) bool {
+	return strings.HasPrefix(content, encryptionMagic)
+}
+
+// Get returns the decrypted content of a bin.
+func (e *Encrypted) Get(name string) (string, error) {
+	data, err := e.Storage.Get(name)
+	if err != nil {
+		return "", err
+	}
+
+	content, _, err := e.open(name, data)
+	return content, err
+}
+
+// Put encrypts and replaces the content of a bin.
+func (e *Encrypted) Put(name, content string) error {
+	return e.Storage.Put(name, e.seal(name, content))
+}
+
+// Append encrypts and appends content to a bin.
+//
+// If the existing content is not encrypted with the first key, the whole content is encrypted again
+// with it and replaced instead.
+func (e *Encrypted) Append(name, content string) error {
+	data, err := e.Storage.Get(name)
+	if err != nil && !errors.Is(err, ErrNotFound) {
+		return err
+	}
+
+	existing, current, err := e.open(name, data)
+	if err != nil {
+		return err
+	}
+	if !current {
+		return e.Storage.Put(name, e.seal(name, existing+content))
+	}
+
+	return e.Storage.Append(name, e.seal(name, content))
+}
+
+// Stat returns information about a bin. The size is the size of the decrypted content.
+func (e *Encrypted) Stat(name string) (Info, error) {
+	info, err := e.Storage.Stat(name)
+	if err != nil {
+		return info, err
+	}
+
+	content, err := e.Get(name)
+	if err != nil {
+		return info, err
+	}
+	info.Size = int64(len(content))
+
+	return info, nil
+}
+
+// AddRevision encrypts and stores a new revision of a bin. The hash of the revision is replaced with a keyed hash.
+func (e *Encrypted) AddRevision(name string, revision Revision, content string) (Revision, error) {
+	revision.Hash = e.HashContent(content)
+	return e.Storage.AddRevision(name, revision, e.seal(name, content))
+}
+
+// HashContent returns the hex encoded HMAC-SHA256 of the content, keyed with a key derived from the first key.
+// Unlike a plain hash it cannot be used to confirm a guess of the content without the key.
+//
+// Parameters:
+//   - content: string
+//     The content to hash.
+//
+// Returns:
+//   - string
+//     The hex encoded keyed hash.
+func (e *Encrypted) HashContent(content string) string {
+	mac := hmac.New(sha256.New, e.hashKey)
+	mac.Write([]byte(content))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// Revision returns a revision and its decrypted content.
+func (e *Encrypted) Revision(name string, id int) (Revision, string, error) {
+	revision, data, err := e.Storage.Revision(name, id)
+	if err != nil {
+		return revision, "", err
+	}
+
+	content, _, err := e.open(name, data)
+	return revision, content, err
+}
+
+// RevisionIDs returns the IDs of the revisions of the wrapped backend.
+func (e *Encrypted) RevisionIDs(name string) ([]int, error) {
+	return RevisionIDs(e.Storage, name)
+}
+
+// RevisionInfo returns a revision of the wrapped backend without decrypting its content.
+func (e *Encrypted) RevisionInfo(name string, id int) (Revision, error) {
+	return RevisionInfo(e.Storage, name, id)
+}
+
+// Recover cleans up after a crash if the wrapped backend supports it.
+func (e *Encrypted) Recover() (int, error) {
+	if recoverer, ok := e.Storage.(Recoverer); ok {
+		return recoverer.Recover()
+	}
+	return 0, nil
+}
+
+// Watch watches the wrapped backend if it supports it. Backends which cannot be changed by other
+// programs are not watched.
+func (e *Encrypted) Watch(ctx context.Context, changed func(name string)) error {
+	if watcher, ok := e.Storage.(Watcher); ok {
+		return watcher.Watch(ctx, changed)
+	}
+	return nil
+}
+
+// Close closes the wrapped backend if it holds resources, like an open database.
+func (e *Encrypted) Close() error {
+	if closer, ok := e.Storage.(io.Closer); ok {
+		return closer.Close()
+	}
+	return nil
+}
+
+// Check decrypts the content of every bin, so keys which do not match the stored content are noticed
+// before anything is written.
+//
+// Returns:
+//   - error
+//     An error naming the first bin which cannot be decrypted.
+func (e *Encrypted) Check() error {
+	names, err := e.Storage.List()
+	if err != nil {
+		return err
+	}
+
+	for _, name := range names {
+		if _, err := e.Get(name); err != nil && !errors.Is(err, ErrNotFound) {
+			return fmt.Errorf("Unable to decrypt bin '%s': %w", name, err)
+		}
+	}
+
+	return nil
+}
+
+// seal encrypts content with the first key into a single record. Empty content stays empty.
+func (e *Encrypted) seal(name, content string) string {
+	if content == "" {
+		return ""
+	}
+
+	key := e.keys[0]
+	data := make([]byte, encryptionKeyIDSize+key.aead.NonceSize(), encryptionKeyIDSize+key.aead.NonceSize()+len(content)+key.aead.Overhead())
+	copy(data, key.id)
+	nonce := data[encryptionKeyIDSize:]
+	_, _ = rand.Read(nonce) // Never returns an error, see crypto/rand
+	data = key.aead.Seal(data, nonce, []byte(content), []byte(name))
+
+	return encryptionMagic + base64.StdEncoding.EncodeToString(data) + "\n"
+}
+
+// open decrypts all records of the content of a bin. Content which was stored before encryption was
+// enabled is returned as is.
+//
+// Returns:
+//   - string
+//     The decrypted content.
+//   - bool
+//     True if the content is encrypted with the first key, false if it must be encrypted again.
+//   - error
+//     An error if a record is invalid, was encrypted with an unknown key or was changed.
+func (e *Encrypted) open(name, data string) (string, bool, error) {
+	if data == "" {
+		return "", true, nil
+	}
+	if !IsEncrypted(data) {
+		return data, false, nil
+	}
+
+	var content strings.Builder
+	current := true
+	for i, record := range strings.SplitAfter(strings.TrimSuffix(data, "\n"), "\n") {
+		encoded, ok := strings.CutPrefix(strings.TrimSuffix(record, "\n"), encryptionMagic)
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if !ok || err != nil || len(decoded) < encryptionKeyIDSize {
+			return "", false, fmt.Errorf("Invalid encrypted record %d", i+1)
+		}
+
+		index := e.keyIndex(string(decoded[:encryptionKeyIDSize]))
+		if index < 0 {
+			return "", false, ErrUnknownKey
+		}
+		current = current && index == 0
+
+		aead := e.keys[index].aead
+		sealed := decoded[encryptionKeyIDSize:]
+		if len(sealed) < aead.NonceSize()+aead.Overhead() {
+			return "", false, fmt.Errorf("Invalid encrypted record %d", i+1)
+		}
+		plain, err := aead.Open(nil, sealed[:aead.NonceSize()], sealed[aead.NonceSize():], []byte(name))
+		if err != nil {
+			return "", false, errors.New("Unable to decrypt content. It was changed or encrypted for another bin")
+		}
+		content.Write(plain)
+	}
+
+	return content.String(), current, nil
+}
+
+// keyIndex returns the index of the key with the given ID, or -1 if it is not configured.
+func (e *Encrypted) keyIndex(id string) int {
+	for i, key := range e.keys {
+		if key.id == id {
+			return i
+		}
+	}
+	return -1
+}
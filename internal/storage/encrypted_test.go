@@ -0,0 +1,180 @@
+package storage
+
+import (
+	"crypto/rand"
+	"encoding/base64"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+// newKey returns a random encryption key.
+func newKey(t *testing.T) []byte {
+	key := make([]byte, encryptionKeySize)
+	_, err := rand.Read(key)
+	assert.NoError(t, err)
+	return key
+}
+
+func TestEncrypted(t *testing.T) {
+	for _, backend := range SupportedBackends {
+		t.Run(backend, func(t *testing.T) {
+			inner, err := New(backend, t.TempDir(), ".vimbin")
+			assert.NoError(t, err)
+			store, err := NewEncrypted(inner, [][]byte{newKey(t)})
+			assert.NoError(t, err)
+
+			assert.NoError(t, store.Put("secret", "password"))
+			assert.NoError(t, store.Append("secret", "\nmore"))
+
+			content, err := store.Get("secret")
+			assert.NoError(t, err)
+			assert.Equal(t, "password\nmore", content)
+
+			// Only ciphertext reaches the wrapped backend
+			data, err := inner.Get("secret")
+			assert.NoError(t, err)
+			assert.True(t, IsEncrypted(data))
+			assert.NotContains(t, data, "password")
+
+			info, err := store.Stat("secret")
+			assert.NoError(t, err)
+			assert.Equal(t, int64(len("password\nmore")), info.Size)
+
+			revision, err := store.AddRevision("secret", NewRevision("password", "ci"), "password")
+			assert.NoError(t, err)
+			_, data, err = inner.Revision("secret", revision.ID)
+			assert.NoError(t, err)
+			assert.NotContains(t, data, "password")
+			assert.Equal(t, store.HashContent("password"), revision.Hash)
+			assert.NotEqual(t, Hash("password"), revision.Hash)
+			_, content, err = store.Revision("secret", revision.ID)
+			assert.NoError(t, err)
+			assert.Equal(t, "password", content)
+
+			assert.NoError(t, store.Check())
+		})
+	}
+}
+
+func TestEncryptedKeys(t *testing.T) {
+	t.Run("Plain content is encrypted on next write", func(t *testing.T) {
+		inner := NewMemory()
+		assert.NoError(t, inner.Put("notes", "plain"))
+		store, _ := NewEncrypted(inner, [][]byte{newKey(t)})
+
+		content, err := store.Get("notes")
+		assert.NoError(t, err)
+		assert.Equal(t, "plain", content)
+
+		assert.NoError(t, store.Append("notes", " text"))
+		data, _ := inner.Get("notes")
+		assert.True(t, IsEncrypted(data))
+		content, _ = store.Get("notes")
+		assert.Equal(t, "plain text", content)
+	})
+
+	t.Run("Rotation encrypts with the new key on next write", func(t *testing.T) {
+		oldKey, newKey := newKey(t), newKey(t)
+		inner := NewMemory()
+		old, _ := NewEncrypted(inner, [][]byte{oldKey})
+		assert.NoError(t, old.Put("notes", "first"))
+
+		rotated, _ := NewEncrypted(inner, [][]byte{newKey, oldKey})
+		assert.NoError(t, rotated.Check())
+		assert.NoError(t, rotated.Append("notes", " second"))
+
+		// The old key is no longer needed
+		current, _ := NewEncrypted(inner, [][]byte{newKey})
+		content, err := current.Get("notes")
+		assert.NoError(t, err)
+		assert.Equal(t, "first second", content)
+
+		_, err = old.Get("notes")
+		assert.ErrorIs(t, err, ErrUnknownKey)
+	})
+
+	t.Run("Wrong key", func(t *testing.T) {
+		inner := NewMemory()
+		store, _ := NewEncrypted(inner, [][]byte{newKey(t)})
+		assert.NoError(t, store.Put("notes", "content"))
+
+		other, _ := NewEncrypted(inner, [][]byte{newKey(t)})
+		assert.ErrorIs(t, other.Check(), ErrUnknownKey)
+		assert.ErrorIs(t, other.Append("notes", "more"), ErrUnknownKey)
+
+		content, err := store.Get("notes")
+		assert.NoError(t, err)
+		assert.Equal(t, "content", content)
+	})
+
+	t.Run("Records cannot be moved to another bin", func(t *testing.T) {
+		inner := NewMemory()
+		store, _ := NewEncrypted(inner, [][]byte{newKey(t)})
+		assert.NoError(t, store.Put("a", "content"))
+		data, _ := inner.Get("a")
+		assert.NoError(t, inner.Put("b", data))
+
+		_, err := store.Get("b")
+		assert.Error(t, err)
+	})
+
+	t.Run("Hashes are keyed with the first key", func(t *testing.T) {
+		key := newKey(t)
+		store, _ := NewEncrypted(NewMemory(), [][]byte{key})
+		rotated, _ := NewEncrypted(NewMemory(), [][]byte{newKey(t), key})
+		same, _ := NewEncrypted(NewMemory(), [][]byte{key})
+
+		assert.Len(t, store.HashContent("content"), 64)
+		assert.Equal(t, store.HashContent("content"), same.HashContent("content"))
+		assert.NotEqual(t, store.HashContent("content"), rotated.HashContent("content"))
+		assert.NotEqual(t, store.HashContent("content"), store.HashContent("other"))
+		assert.Equal(t, store.HashContent("content"), HashContent(store, "content"))
+		assert.Equal(t, Hash("content"), HashContent(NewMemory(), "content"))
+	})
+
+	t.Run("Changed content", func(t *testing.T) {
+		inner := NewMemory()
+		store, _ := NewEncrypted(inner, [][]byte{newKey(t)})
+		assert.NoError(t, store.Put("notes", "content"))
+		data, _ := inner.Get("notes")
+
+		for name, changed := range map[string]string{
+			"flipped bit": data[:len(data)-1] + string(data[len(data)-1]^1),
+			"truncated":   data[:len(data)-3],
+			"appended":    data + "plain",
+		} {
+			assert.NoError(t, inner.Put("notes", changed))
+			_, err := store.Get("notes")
+			assert.Error(t, err, name)
+		}
+	})
+}
+
+func TestParseEncryptionKeys(t *testing.T) {
+	first := base64.StdEncoding.EncodeToString(newKey(t))
+	second := base64.RawURLEncoding.EncodeToString(newKey(t))
+
+	t.Run("Key file with comments", func(t *testing.T) {
+		keys, err := ParseEncryptionKeys("# current key\n" + first + "\n\n# old key\n" + second + "\n")
+		assert.NoError(t, err)
+		assert.Len(t, keys, 2)
+	})
+
+	t.Run("Comma separated", func(t *testing.T) {
+		keys, err := ParseEncryptionKeys(first + ", " + second)
+		assert.NoError(t, err)
+		assert.Len(t, keys, 2)
+	})
+
+	t.Run("Invalid key", func(t *testing.T) {
+		_, err := ParseEncryptionKeys(first + "\n" + strings.Repeat("A", 12))
+		assert.ErrorContains(t, err, "key 2")
+	})
+
+	t.Run("No keys", func(t *testing.T) {
+		_, err := NewEncrypted(NewMemory(), nil)
+		assert.Error(t, err)
+	})
+}
@@ -0,0 +1,655 @@
+package storage
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// File is a storage backend which stores every bin in its own file.
+//
+// The default bin is stored in the file '<directory>/<name>', every other bin
+// is stored next to it in a file named '<directory>/<name>.<bin>'. Revisions are stored
+// in '<directory>/<name>.history.d/<bin>/', which no bin name can collide with.
+type File struct {
+	Directory string // Directory is the directory containing the storage files.
+	Name      string // Name is the name of the storage file of the default bin.
+}
+
+// NewFile creates a file storage backend.
+//
+// Parameters:
+//   - directory: string
+//     The directory containing the storage files.
+//   - name: string
+//     The name of the storage file of the default bin.
+//
+// Returns:
+//   - *File
+//     The file storage backend.
+func NewFile(directory, name string) *File {
+	return &File{Directory: directory, Name: name}
+}
+
+// Path returns the full path to the storage file of a bin.
+//
+// Parameters:
+//   - name: string
+//     The name of the bin.
+//
+// Returns:
+//   - string
+//     The full path to the storage file of the bin.
+func (f *File) Path(name string) string {
+	if name == DefaultBin {
+		return path.Join(f.Directory, f.Name)
+	}
+	return path.Join(f.Directory, fmt.Sprintf("%s.%s", f.Name, name))
+}
+
+// Get returns the content of a bin.
+func (f *File) Get(name string) (string, error) {
+	content, err := os.ReadFile(f.Path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("Cannot read storage file. %s", err)
+	}
+
+	return string(content), nil
+}
+
+// Put replaces the content of a bin.
+//
+// The content is written atomically, so a crash or a full disk never leaves a truncated storage file.
+func (f *File) Put(name, content string) error {
+	return writeFileAtomic(f.Path(name), []byte(content))
+}
+
+// Append appends content to a bin.
+//
+// If the content cannot be written completely, the storage file is truncated to its previous size,
+// so a partial write never ends up in the storage file.
+func (f *File) Append(name, content string) error {
+	file, err := os.OpenFile(f.Path(name), os.O_APPEND|os.O_CREATE|os.O_WRONLY, filePermission)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	info, err := file.Stat()
+	if err != nil {
+		return err
+	}
+	previousSize := info.Size()
+
+	written, err := io.WriteString(file, content)
+	if err == nil && written != len(content) {
+		err = io.ErrShortWrite
+	}
+	if err == nil {
+		err = file.Sync()
+	}
+	if err != nil {
+		// Roll back the partial write
+		if truncateErr := file.Truncate(previousSize); truncateErr != nil {
+			return fmt.Errorf("Unable to append to storage file: %s. Rolling back failed: %s", err, truncateErr)
+		}
+		_ = file.Sync()
+		return fmt.Errorf("Unable to append to storage file: %s", err)
+	}
+
+	return nil
+}
+
+// Delete removes the storage file of a bin.
+func (f *File) Delete(name string) error {
+	err := os.Remove(f.Path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return ErrNotFound
+	}
+	if err != nil {
+		return err
+	}
+
+	return syncDirectory(f.Directory)
+}
+
+// Recover cleans up temporary files left behind by writes which were interrupted by a crash.
+// History directories of the previous layout are moved into the history root first.
+//
+// A temporary file is only renamed over its target once it was written completely, so a leftover
+// temporary file belongs to a write which did not complete. It is removed, even if its target does
+// not exist, as its content may be incomplete. Temporary files of revisions are removed as well.
+//
+// Returns:
+//   - int
+//     The number of removed temporary files.
+//   - error
+//     An error if the storage directory cannot be read or a temporary file cannot be removed.
+func (f *File) Recover() (int, error) {
+	if err := f.migrateHistory(); err != nil {
+		return 0, err
+	}
+
+	recovered, err := removeTempFiles(f.Directory, func(fileName string) bool {
+		_, ok := f.tempFileTarget(fileName)
+		return ok
+	})
+	if err != nil {
+		return recovered, err
+	}
+
+	entries, err := os.ReadDir(f.historyRoot())
+	if errors.Is(err, os.ErrNotExist) {
+		return recovered, nil
+	}
+	if err != nil {
+		return recovered, fmt.Errorf("Unable to read history directory: %s", err)
+	}
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		removed, err := removeTempFiles(path.Join(f.historyRoot(), entry.Name()), isRevisionTempFile)
+		recovered += removed
+		if err != nil {
+			return recovered, err
+		}
+	}
+
+	return recovered, nil
+}
+
+// removeTempFiles removes the temporary files in a directory.
+//
+// Parameters:
+//   - directory: string
+//     The directory to clean up.
+//   - isTempFile: func(string) bool
+//     Reports whether a file, given by its name, is a temporary file.
+//
+// Returns:
+//   - int
+//     The number of removed temporary files.
+//   - error
+//     An error if the directory cannot be read or a temporary file cannot be removed.
+func removeTempFiles(directory string, isTempFile func(string) bool) (int, error) {
+	entries, err := os.ReadDir(directory)
+	if err != nil {
+		return 0, fmt.Errorf("Unable to read directory '%s': %s", directory, err)
+	}
+
+	removed := 0
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() || !isTempFile(entry.Name()) {
+			continue
+		}
+		if err := os.Remove(path.Join(directory, entry.Name())); err != nil {
+			return removed, fmt.Errorf("Unable to remove temporary file '%s': %s", entry.Name(), err)
+		}
+		removed++
+	}
+
+	if removed > 0 {
+		return removed, syncDirectory(directory)
+	}
+
+	return 0, nil
+}
+
+// List returns the names of all bins with a storage file in the storage directory.
+func (f *File) List() ([]string, error) {
+	entries, err := os.ReadDir(f.Directory)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read storage directory: %s", err)
+	}
+
+	names := []string{}
+	for _, entry := range entries {
+		if !entry.Type().IsRegular() {
+			continue
+		}
+		if name, ok := f.binName(entry.Name()); ok {
+			names = append(names, name)
+		}
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// binName returns the name of the bin stored in a file of the storage directory.
+//
+// Parameters:
+//   - fileName: string
+//     The name of the file, without directory.
+//
+// Returns:
+//   - string
+//     The name of the bin.
+//   - bool
+//     False if the file is not the storage file of a bin, e.g. a temporary or metadata file.
+func (f *File) binName(fileName string) (string, bool) {
+	if fileName == f.Name {
+		return DefaultBin, true
+	}
+
+	name, ok := strings.CutPrefix(fileName, f.Name+".")
+	if !ok || !IsValidBinName(name) || name == DefaultBin {
+		return "", false
+	}
+
+	return name, true
+}
+
+// Stat returns information about the storage file of a bin.
+func (f *File) Stat(name string) (Info, error) {
+	fileInfo, err := os.Stat(f.Path(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return Info{}, ErrNotFound
+	}
+	if err != nil {
+		return Info{}, err
+	}
+
+	return Info{Name: name, Size: fileInfo.Size(), ModTime: fileInfo.ModTime()}, nil
+}
+
+// historyRoot returns the directory containing the history directories of all bins.
+func (f *File) historyRoot() string {
+	return path.Join(f.Directory, f.Name+historyRootSuffix)
+}
+
+// historyDirectory returns the directory containing the revisions of a bin.
+func (f *File) historyDirectory(name string) string {
+	return path.Join(f.historyRoot(), name)
+}
+
+// migrateHistory moves history directories of the previous layout, '<path>.history' next to the storage
+// file of each bin, into the history root. The history directory of the default bin had the same path as
+// the storage file of a bin named 'history'.
+//
+// Returns:
+//   - error
+//     An error if the storage directory cannot be read or a history directory cannot be moved.
+func (f *File) migrateHistory() error {
+	entries, err := os.ReadDir(f.Directory)
+	if err != nil {
+		return fmt.Errorf("Unable to read storage directory: %s", err)
+	}
+
+	for _, entry := range entries {
+		if !entry.IsDir() {
+			continue
+		}
+		fileName, ok := strings.CutSuffix(entry.Name(), legacyHistorySuffix)
+		if !ok {
+			continue
+		}
+		name, ok := f.binName(fileName)
+		if !ok {
+			continue
+		}
+
+		if err := os.MkdirAll(f.historyRoot(), 0755); err != nil {
+			return fmt.Errorf("Unable to create history directory: %s", err)
+		}
+		if _, err := os.Stat(f.historyDirectory(name)); err == nil {
+			return fmt.Errorf("Unable to move history directory '%s', the history of bin '%s' exists already", entry.Name(), name)
+		}
+		if err := os.Rename(path.Join(f.Directory, entry.Name()), f.historyDirectory(name)); err != nil {
+			return fmt.Errorf("Unable to move history directory '%s': %s", entry.Name(), err)
+		}
+	}
+
+	return nil
+}
+
+// AddRevision stores a new revision of a bin as '<id>' (content) and '<id>.json' (metadata)
+// in the history directory of the bin.
+func (f *File) AddRevision(name string, revision Revision, content string) (Revision, error) {
+	directory := f.historyDirectory(name)
+	if err := os.MkdirAll(directory, 0755); err != nil {
+		return Revision{}, fmt.Errorf("Unable to create history directory: %s", err)
+	}
+
+	// Revision numbers are never reused, so the next one follows the highest one
+	ids, err := f.RevisionIDs(name)
+	if err != nil {
+		return Revision{}, err
+	}
+	revision.ID = 1
+	if len(ids) > 0 {
+		revision.ID = ids[len(ids)-1] + 1
+	}
+
+	metadata, err := json.Marshal(revision)
+	if err != nil {
+		return Revision{}, err
+	}
+
+	// The metadata is written last, so incomplete revisions are never listed
+	contentPath := path.Join(directory, strconv.Itoa(revision.ID))
+	if err := writeFileAtomic(contentPath, []byte(content)); err != nil {
+		return Revision{}, err
+	}
+	if err := writeFileAtomic(contentPath+".json", metadata); err != nil {
+		return Revision{}, err
+	}
+
+	return revision, nil
+}
+
+// Revisions returns all revisions of a bin.
+func (f *File) Revisions(name string) ([]Revision, error) {
+	entries, err := os.ReadDir(f.historyDirectory(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return []Revision{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read history directory: %s", err)
+	}
+
+	revisions := []Revision{}
+	for _, entry := range entries {
+		if !strings.HasSuffix(entry.Name(), ".json") {
+			continue
+		}
+		metadata, err := os.ReadFile(path.Join(f.historyDirectory(name), entry.Name()))
+		if err != nil {
+			return nil, err
+		}
+		var revision Revision
+		if err := json.Unmarshal(metadata, &revision); err != nil {
+			return nil, fmt.Errorf("Unable to parse revision '%s': %s", entry.Name(), err)
+		}
+		revisions = append(revisions, revision)
+	}
+	sort.Slice(revisions, func(i, j int) bool { return revisions[i].ID < revisions[j].ID })
+
+	return revisions, nil
+}
+
+// RevisionIDs returns the IDs of all revisions of a bin from the names of their metadata files, without reading them.
+func (f *File) RevisionIDs(name string) ([]int, error) {
+	entries, err := os.ReadDir(f.historyDirectory(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return []int{}, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("Unable to read history directory: %s", err)
+	}
+
+	ids := []int{}
+	for _, entry := range entries {
+		idName, ok := strings.CutSuffix(entry.Name(), ".json")
+		if !ok {
+			continue
+		}
+		if id, err := strconv.Atoi(idName); err == nil {
+			ids = append(ids, id)
+		}
+	}
+	sort.Ints(ids)
+
+	return ids, nil
+}
+
+// RevisionInfo returns a revision from its metadata file, without reading its content.
+func (f *File) RevisionInfo(name string, id int) (Revision, error) {
+	metadata, err := os.ReadFile(path.Join(f.historyDirectory(name), strconv.Itoa(id)+".json"))
+	if errors.Is(err, os.ErrNotExist) {
+		return Revision{}, ErrRevisionNotFound
+	}
+	if err != nil {
+		return Revision{}, err
+	}
+
+	var revision Revision
+	if err := json.Unmarshal(metadata, &revision); err != nil {
+		return Revision{}, fmt.Errorf("Unable to parse revision '%d': %s", id, err)
+	}
+
+	return revision, nil
+}
+
+// Revision returns a revision and its content.
+func (f *File) Revision(name string, id int) (Revision, string, error) {
+	revision, err := f.RevisionInfo(name, id)
+	if err != nil {
+		return Revision{}, "", err
+	}
+
+	content, err := os.ReadFile(path.Join(f.historyDirectory(name), strconv.Itoa(id)))
+	if err != nil {
+		return Revision{}, "", err
+	}
+
+	return revision, string(content), nil
+}
+
+// DeleteRevision removes a revision.
+func (f *File) DeleteRevision(name string, id int) error {
+	contentPath := path.Join(f.historyDirectory(name), strconv.Itoa(id))
+
+	if err := os.Remove(contentPath + ".json"); errors.Is(err, os.ErrNotExist) {
+		return ErrRevisionNotFound
+	} else if err != nil {
+		return err
+	}
+
+	return os.Remove(contentPath)
+}
+
+// metadataPath returns the path to the metadata file of a bin.
+func (f *File) metadataPath(name string) string {
+	return f.Path(name) + metadataSuffix
+}
+
+// GetMetadata returns the metadata of a bin, stored as JSON in '<path>.meta.json'.
+func (f *File) GetMetadata(name string) (Metadata, error) {
+	data, err := os.ReadFile(f.metadataPath(name))
+	if errors.Is(err, os.ErrNotExist) {
+		return Metadata{}, nil
+	}
+	if err != nil {
+		return Metadata{}, fmt.Errorf("Unable to read metadata file: %s", err)
+	}
+
+	var metadata Metadata
+	if err := json.Unmarshal(data, &metadata); err != nil {
+		return Metadata{}, fmt.Errorf("Unable to parse metadata of bin '%s': %s", name, err)
+	}
+
+	return metadata, nil
+}
+
+// PutMetadata replaces the metadata of a bin.
+func (f *File) PutMetadata(name string, metadata Metadata) error {
+	if metadata.IsZero() {
+		if err := os.Remove(f.metadataPath(name)); err != nil && !errors.Is(err, os.ErrNotExist) {
+			return fmt.Errorf("Unable to remove metadata file: %s", err)
+		}
+		return nil
+	}
+
+	data, err := json.Marshal(metadata)
+	if err != nil {
+		return err
+	}
+
+	return writeFileAtomic(f.metadataPath(name), data)
+}
+
+// Purge removes the storage file of a bin together with its metadata file and history directory.
+//
+// The storage file is removed first, so the content is gone even if removing the rest fails.
+func (f *File) Purge(name string) error {
+	found := false
+	for _, filePath := range []string{f.Path(name), f.metadataPath(name), f.historyDirectory(name)} {
+		if _, err := os.Lstat(filePath); errors.Is(err, os.ErrNotExist) {
+			continue
+		}
+		found = true
+		if err := os.RemoveAll(filePath); err != nil {
+			return fmt.Errorf("Unable to remove '%s': %s", filePath, err)
+		}
+	}
+	if !found {
+		return ErrNotFound
+	}
+
+	return syncDirectory(f.Directory)
+}
+
+// tempFileSuffix is the suffix of temporary files created by writeFileAtomic.
+const tempFileSuffix = ".tmp"
+
+// metadataSuffix is the suffix of the metadata file of a bin, appended to its storage file.
+const metadataSuffix = ".meta.json"
+
+// historyRootSuffix is appended to the storage file of the default bin to name the directory containing
+// the history directories. Bin names cannot contain dots, so no storage file of a bin has this name.
+const historyRootSuffix = ".history.d"
+
+// legacyHistorySuffix is the suffix of history directories next to the storage files, used before the
+// history root was introduced.
+const legacyHistorySuffix = ".history"
+
+// tempFileTarget returns the name of the file a temporary file was created for.
+//
+// Temporary files are named '<target>.<random>.tmp' by os.CreateTemp, where the random part only
+// contains digits. Only temporary files of storage and metadata files of bins are recognized, so
+// other files, like the storage file of a bin named 'tmp', are never taken for temporary files.
+//
+// Parameters:
+//   - fileName: string
+//     The name of the file, without directory.
+//
+// Returns:
+//   - string
+//     The name of the storage or metadata file the temporary file was created for.
+//   - bool
+//     False if the file is not a temporary file created by writeFileAtomic for a bin.
+func (f *File) tempFileTarget(fileName string) (string, bool) {
+	trimmed, ok := strings.CutSuffix(fileName, tempFileSuffix)
+	if !ok {
+		return "", false
+	}
+
+	index := strings.LastIndex(trimmed, ".")
+	if index <= 0 || !isDigits(trimmed[index+1:]) {
+		return "", false
+	}
+
+	target := trimmed[:index]
+	if _, ok := f.binName(strings.TrimSuffix(target, metadataSuffix)); !ok {
+		return "", false
+	}
+
+	return target, true
+}
+
+// isRevisionTempFile reports whether a file in a history directory is a temporary file of a revision,
+// named '<id>.<random>.tmp' or '<id>.json.<random>.tmp'.
+func isRevisionTempFile(fileName string) bool {
+	trimmed, ok := strings.CutSuffix(fileName, tempFileSuffix)
+	if !ok {
+		return false
+	}
+
+	index := strings.LastIndex(trimmed, ".")
+	if index <= 0 || !isDigits(trimmed[index+1:]) {
+		return false
+	}
+
+	return isDigits(strings.TrimSuffix(trimmed[:index], ".json"))
+}
+
+// isDigits reports whether a string is not empty and only contains the digits 0-9.
+func isDigits(value string) bool {
+	if value == "" {
+		return false
+	}
+	for _, char := range value {
+		if char < '0' || char > '9' {
+			return false
+		}
+	}
+	return true
+}
+
+// writeFileAtomic writes data to a file without ever exposing a partially written file.
+//
+// The data is written to a temporary file in the same directory, synced to disk and renamed
+// over the target file. Finally the directory is synced, so the rename survives a crash.
+//
+// Parameters:
+//   - filePath: string
+//     The path to the file to write.
+//   - data: []byte
+//     The data to write.
+//
+// Returns:
+//   - error
+//     An error if any step fails. The target file is left untouched in this case.
+func writeFileAtomic(filePath string, data []byte) error {
+	directory := path.Dir(filePath)
+
+	temp, err := os.CreateTemp(directory, path.Base(filePath)+".*"+tempFileSuffix)
+	if err != nil {
+		return fmt.Errorf("Unable to create temporary file: %s", err)
+	}
+	tempPath := temp.Name()
+
+	// Remove the temporary file if anything goes wrong
+	success := false
+	defer func() {
+		if !success {
+			temp.Close()
+			os.Remove(tempPath)
+		}
+	}()
+
+	if _, err := temp.Write(data); err != nil {
+		return fmt.Errorf("Unable to write temporary file: %s", err)
+	}
+	if err := temp.Chmod(filePermission); err != nil {
+		return fmt.Errorf("Unable to set permissions of temporary file: %s", err)
+	}
+	if err := temp.Sync(); err != nil {
+		return fmt.Errorf("Unable to sync temporary file: %s", err)
+	}
+	if err := temp.Close(); err != nil {
+		return fmt.Errorf("Unable to close temporary file: %s", err)
+	}
+	if err := os.Rename(tempPath, filePath); err != nil {
+		return fmt.Errorf("Unable to replace file: %s", err)
+	}
+	success = true
+
+	return syncDirectory(directory)
+}
+
+// syncDirectory syncs a directory to disk, so renames and removals in it are durable.
+func syncDirectory(directory string) error {
+	dir, err := os.Open(directory)
+	if err != nil {
+		return fmt.Errorf("Unable to open directory: %s", err)
+	}
+	defer dir.Close()
+
+	if err := dir.Sync(); err != nil {
+		return fmt.Errorf("Unable to sync directory: %s", err)
+	}
+
+	return nil
+}
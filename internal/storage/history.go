@@ -0,0 +1,227 @@
+package storage
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"sort"
+	"time"
+)
+
+// ErrRevisionNotFound is returned when a revision does not exist.
+var ErrRevisionNotFound = errors.New("Revision not found")
+
+// Revision describes an immutable snapshot of the content of a bin.
+type Revision struct {
+	ID     int       `json:"id"`     // ID is the revision number, starting at 1 for every bin.
+	Time   time.Time `json:"time"`   // Time is the time the revision was created.
+	Size   int64     `json:"size"`   // Size is the size of the content in bytes.
+	Hash   string    `json:"hash"`   // Hash is the hex encoded SHA-256 hash of the content, keyed if the storage is encrypted.
+	Author string    `json:"author"` // Author is the name of the token which created the revision.
+}
+
+// History is the interface implemented by all storage backends to keep revisions of bins.
+type History interface {
+	// AddRevision stores a new revision of a bin. The ID of the given revision is ignored
+	// and the next free revision number is assigned.
+	AddRevision(name string, revision Revision, content string) (Revision, error)
+	// Revisions returns all revisions of a bin, ordered by ID.
+	Revisions(name string) ([]Revision, error)
+	// Revision returns a revision and its content or ErrRevisionNotFound if it does not exist.
+	Revision(name string, id int) (Revision, string, error)
+	// DeleteRevision removes a revision or returns ErrRevisionNotFound if it does not exist.
+	DeleteRevision(name string, id int) error
+}
+
+// NewRevision creates the metadata of a revision for the given content.
+//
+// Parameters:
+//   - content: string
+//     The content of the revision.
+//   - author: string
+//     The name of the token which created the revision.
+//
+// Returns:
+//   - Revision
+//     The revision metadata without an ID.
+func NewRevision(content, author string) Revision {
+	return Revision{
+		Time:   time.Now().UTC(),
+		Size:   int64(len(content)),
+		Hash:   Hash(content),
+		Author: author,
+	}
+}
+
+// Hash returns the hex encoded SHA-256 hash of the content.
+//
+// Parameters:
+//   - content: string
+//     The content to hash.
+//
+// Returns:
+//   - string
+//     The hex encoded hash.
+func Hash(content string) string {
+	sum := sha256.Sum256([]byte(content))
+	return hex.EncodeToString(sum[:])
+}
+
+// ContentHasher is implemented by storage backends which record keyed hashes of the content, like Encrypted.
+type ContentHasher interface {
+	// HashContent returns the hex encoded hash of the content as recorded by the backend.
+	HashContent(content string) string
+}
+
+// HashContent returns the hash of the content as recorded by a storage backend. It is the keyed hash of backends
+// implementing ContentHasher and the SHA-256 hash otherwise.
+//
+// Parameters:
+//   - store: Storage
+//     The storage backend, may be nil.
+//   - content: string
+//     The content to hash.
+//
+// Returns:
+//   - string
+//     The hex encoded hash.
+func HashContent(store Storage, content string) string {
+	if hasher, ok := store.(ContentHasher); ok {
+		return hasher.HashContent(content)
+	}
+
+	return Hash(content)
+}
+
+// RevisionIndex is implemented by history backends which can look up revisions without reading all of them, like File.
+type RevisionIndex interface {
+	// RevisionIDs returns the IDs of all revisions of a bin in ascending order.
+	RevisionIDs(name string) ([]int, error)
+	// RevisionInfo returns a revision without its content or ErrRevisionNotFound if it does not exist.
+	RevisionInfo(name string, id int) (Revision, error)
+}
+
+// RevisionIDs returns the IDs of all revisions of a bin. Backends implementing RevisionIndex list them
+// without reading the revisions.
+//
+// Parameters:
+//   - history: History
+//     The history backend.
+//   - name: string
+//     The name of the bin.
+//
+// Returns:
+//   - []int
+//     The IDs in ascending order.
+//   - error
+//     An error if the revisions cannot be listed.
+func RevisionIDs(history History, name string) ([]int, error) {
+	if index, ok := history.(RevisionIndex); ok {
+		return index.RevisionIDs(name)
+	}
+
+	revisions, err := history.Revisions(name)
+	if err != nil {
+		return nil, err
+	}
+	ids := make([]int, 0, len(revisions))
+	for _, revision := range revisions {
+		ids = append(ids, revision.ID)
+	}
+	sort.Ints(ids)
+
+	return ids, nil
+}
+
+// RevisionInfo returns a revision without its content. Backends implementing RevisionIndex do not read the content.
+//
+// Parameters:
+//   - history: History
+//     The history backend.
+//   - name: string
+//     The name of the bin.
+//   - id: int
+//     The ID of the revision.
+//
+// Returns:
+//   - Revision
+//     The revision.
+//   - error
+//     ErrRevisionNotFound if the revision does not exist, or an error if it cannot be read.
+func RevisionInfo(history History, name string, id int) (Revision, error) {
+	if index, ok := history.(RevisionIndex); ok {
+		return index.RevisionInfo(name, id)
+	}
+
+	revision, _, err := history.Revision(name, id)
+	return revision, err
+}
+
+// PruneRevisions removes old revisions of a bin.
+//
+// The latest revision is always kept. A value of zero disables the corresponding limit. Revisions are created
+// in order, so only the revisions which are pruned and the first one which is kept are read.
+//
+// Parameters:
+//   - history: History
+//     The history to prune.
+//   - name: string
+//     The name of the bin.
+//   - maxRevisions: int
+//     The maximum number of revisions to keep.
+//   - maxAge: time.Duration
+//     The maximum age of revisions to keep.
+//
+// Returns:
+//   - int
+//     The number of removed revisions.
+//   - error
+//     An error if the revisions cannot be listed or removed.
+func PruneRevisions(history History, name string, maxRevisions int, maxAge time.Duration) (int, error) {
+	ids, err := RevisionIDs(history, name)
+	if err != nil {
+		return 0, err
+	}
+	if len(ids) <= 1 {
+		return 0, nil
+	}
+
+	pruned := 0
+	cutoff := time.Now().Add(-maxAge)
+	for i, id := range ids[:len(ids)-1] {
+		tooMany := maxRevisions > 0 && len(ids)-i > maxRevisions
+		if !tooMany {
+			if maxAge <= 0 {
+				break
+			}
+			revision, err := RevisionInfo(history, name, id)
+			if errors.Is(err, ErrRevisionNotFound) {
+				continue
+			}
+			if err != nil {
+				return pruned, err
+			}
+			// All later revisions are younger
+			if !revision.Time.Before(cutoff) {
+				break
+			}
+		}
+		if err := history.DeleteRevision(name, id); err != nil {
+			return pruned, err
+		}
+		pruned++
+	}
+
+	return pruned, nil
+}
+
+// nextRevisionID returns the next free revision number.
+func nextRevisionID(revisions []Revision) int {
+	next := 1
+	for _, revision := range revisions {
+		if revision.ID >= next {
+			next = revision.ID + 1
+		}
+	}
+	return next
+}
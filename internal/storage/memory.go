@@ -0,0 +1,213 @@
+package storage
+
+import (
+	"sort"
+	"sync"
+	"time"
+)
+
+// memoryRevision holds a revision of a bin stored in memory.
+type memoryRevision struct {
+	revision Revision // revision is the metadata of the revision.
+	content  string   // content is the content of the revision.
+}
+
+// memoryBin holds the content of a bin stored in memory.
+type memoryBin struct {
+	content string    // content is the stored content.
+	modTime time.Time // modTime is the time of the last modification.
+}
+
+// Memory is a storage backend which keeps all bins in memory.
+//
+// Content is lost when the process exits. It is mainly useful for tests and ephemeral instances.
+type Memory struct {
+	bins      map[string]memoryBin        // bins maps the bin name to its content.
+	revisions map[string][]memoryRevision // revisions maps the bin name to its revisions.
+	metadata  map[string]Metadata         // metadata maps the bin name to its metadata.
+	mutex     sync.RWMutex                // mutex is a read-write mutex for concurrent access control.
+}
+
+// NewMemory creates an empty in-memory storage backend.
+//
+// Returns:
+//   - *Memory
+//     The in-memory storage backend.
+func NewMemory() *Memory {
+	return &Memory{
+		bins:      make(map[string]memoryBin),
+		revisions: make(map[string][]memoryRevision),
+		metadata:  make(map[string]Metadata),
+	}
+}
+
+// Get returns the content of a bin.
+func (m *Memory) Get(name string) (string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	bin, ok := m.bins[name]
+	if !ok {
+		return "", ErrNotFound
+	}
+
+	return bin.content, nil
+}
+
+// Put replaces the content of a bin.
+func (m *Memory) Put(name, content string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.bins[name] = memoryBin{content: content, modTime: time.Now()}
+
+	return nil
+}
+
+// Append appends content to a bin.
+func (m *Memory) Append(name, content string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.bins[name] = memoryBin{content: m.bins[name].content + content, modTime: time.Now()}
+
+	return nil
+}
+
+// Delete removes a bin.
+func (m *Memory) Delete(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if _, ok := m.bins[name]; !ok {
+		return ErrNotFound
+	}
+	delete(m.bins, name)
+
+	return nil
+}
+
+// List returns the names of all bins.
+func (m *Memory) List() ([]string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	names := make([]string, 0, len(m.bins))
+	for name := range m.bins {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	return names, nil
+}
+
+// Stat returns information about a bin.
+func (m *Memory) Stat(name string) (Info, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	bin, ok := m.bins[name]
+	if !ok {
+		return Info{}, ErrNotFound
+	}
+
+	return Info{Name: name, Size: int64(len(bin.content)), ModTime: bin.modTime}, nil
+}
+
+// AddRevision stores a new revision of a bin.
+func (m *Memory) AddRevision(name string, revision Revision, content string) (Revision, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	revision.ID = nextRevisionID(m.revisionList(name))
+	m.revisions[name] = append(m.revisions[name], memoryRevision{revision: revision, content: content})
+
+	return revision, nil
+}
+
+// Revisions returns all revisions of a bin.
+func (m *Memory) Revisions(name string) ([]Revision, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.revisionList(name), nil
+}
+
+// revisionList returns the metadata of all revisions of a bin. The caller must hold the mutex.
+func (m *Memory) revisionList(name string) []Revision {
+	revisions := make([]Revision, 0, len(m.revisions[name]))
+	for _, stored := range m.revisions[name] {
+		revisions = append(revisions, stored.revision)
+	}
+	return revisions
+}
+
+// Revision returns a revision and its content.
+func (m *Memory) Revision(name string, id int) (Revision, string, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	for _, stored := range m.revisions[name] {
+		if stored.revision.ID == id {
+			return stored.revision, stored.content, nil
+		}
+	}
+
+	return Revision{}, "", ErrRevisionNotFound
+}
+
+// DeleteRevision removes a revision.
+func (m *Memory) DeleteRevision(name string, id int) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	for i, stored := range m.revisions[name] {
+		if stored.revision.ID == id {
+			m.revisions[name] = append(m.revisions[name][:i], m.revisions[name][i+1:]...)
+			return nil
+		}
+	}
+
+	return ErrRevisionNotFound
+}
+
+// GetMetadata returns the metadata of a bin.
+func (m *Memory) GetMetadata(name string) (Metadata, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	return m.metadata[name], nil
+}
+
+// PutMetadata replaces the metadata of a bin.
+func (m *Memory) PutMetadata(name string, metadata Metadata) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	if metadata.IsZero() {
+		delete(m.metadata, name)
+		return nil
+	}
+	m.metadata[name] = metadata
+
+	return nil
+}
+
+// Purge removes a bin together with its metadata and revisions.
+func (m *Memory) Purge(name string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	_, hasContent := m.bins[name]
+	_, hasMetadata := m.metadata[name]
+	_, hasRevisions := m.revisions[name]
+	if !hasContent && !hasMetadata && !hasRevisions {
+		return ErrNotFound
+	}
+
+	delete(m.bins, name)
+	delete(m.metadata, name)
+	delete(m.revisions, name)
+
+	return nil
+}
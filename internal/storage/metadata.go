@@ -0,0 +1,40 @@
+package storage
+
+import "time"
+
+// Metadata describes how long a bin is kept and how its content is stored.
+type Metadata struct {
+	ExpiresAt     time.Time `json:"expiresAt,omitempty"`     // ExpiresAt is the time the bin is removed. The zero time keeps the bin forever.
+	BurnAfterRead bool      `json:"burnAfterRead,omitempty"` // BurnAfterRead removes the bin after its content was fetched once.
+	Encrypted     bool      `json:"encrypted,omitempty"`     // Encrypted indicates the content was encrypted by the client and is opaque to the server.
+}
+
+// IsZero reports whether the metadata keeps a plain bin forever, so it does not have to be stored.
+func (m Metadata) IsZero() bool {
+	return m.ExpiresAt.IsZero() && !m.BurnAfterRead && !m.Encrypted
+}
+
+// Expired reports whether the bin has expired at the given time.
+//
+// Parameters:
+//   - now: time.Time
+//     The time to check.
+//
+// Returns:
+//   - bool
+//     True if an expiry is set and lies before now.
+func (m Metadata) Expired(now time.Time) bool {
+	return !m.ExpiresAt.IsZero() && !now.Before(m.ExpiresAt)
+}
+
+// MetadataStore is the interface implemented by all storage backends to keep the metadata of bins
+// next to their content.
+type MetadataStore interface {
+	// GetMetadata returns the metadata of a bin. Bins without metadata return the zero value.
+	GetMetadata(name string) (Metadata, error)
+	// PutMetadata replaces the metadata of a bin. The zero value removes the metadata.
+	PutMetadata(name string, metadata Metadata) error
+	// Purge removes a bin together with its metadata and all its revisions.
+	// Returns ErrNotFound if none of them exists.
+	Purge(name string) error
+}
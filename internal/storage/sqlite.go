@@ -0,0 +1,279 @@
+package storage
+
+import (
+	"database/sql"
+	"errors"
+	"fmt"
+	"time"
+
+	_ "modernc.org/sqlite" // Registers the pure Go "sqlite" driver
+)
+
+// sqliteSchema creates the tables used by the sqlite storage backend.
+const sqliteSchema = `CREATE TABLE IF NOT EXISTS bins (
+	name     TEXT PRIMARY KEY,
+	content  BLOB NOT NULL,
+	mod_time INTEGER NOT NULL
+);
+CREATE TABLE IF NOT EXISTS revisions (
+	bin     TEXT NOT NULL,
+	id      INTEGER NOT NULL,
+	time    INTEGER NOT NULL,
+	size    INTEGER NOT NULL,
+	hash    TEXT NOT NULL,
+	author  TEXT NOT NULL,
+	content BLOB NOT NULL,
+	PRIMARY KEY (bin, id)
+);
+CREATE TABLE IF NOT EXISTS metadata (
+	name            TEXT PRIMARY KEY,
+	expires_at      INTEGER NOT NULL,
+	burn_after_read INTEGER NOT NULL,
+	encrypted       INTEGER NOT NULL DEFAULT 0
+)`
+
+// Sqlite is a storage backend which stores all bins in an embedded SQLite database.
+type Sqlite struct {
+	db *sql.DB // db is the opened database.
+}
+
+// NewSqlite opens or creates a SQLite database.
+//
+// Parameters:
+//   - filePath: string
+//     The path to the database file.
+//
+// Returns:
+//   - *Sqlite
+//     The sqlite storage backend.
+//   - error
+//     An error if the database cannot be opened.
+func NewSqlite(filePath string) (*Sqlite, error) {
+	db, err := sql.Open("sqlite", filePath)
+	if err != nil {
+		return nil, fmt.Errorf("Unable to open sqlite database '%s': %s", filePath, err)
+	}
+	db.SetMaxOpenConns(1) // SQLite only supports a single writer
+
+	if _, err := db.Exec(sqliteSchema); err != nil {
+		db.Close()
+		return nil, fmt.Errorf("Unable to initialize sqlite database '%s': %s", filePath, err)
+	}
+
+	return &Sqlite{db: db}, nil
+}
+
+// Close closes the database.
+func (s *Sqlite) Close() error {
+	return s.db.Close()
+}
+
+// Get returns the content of a bin.
+func (s *Sqlite) Get(name string) (string, error) {
+	var content []byte
+	err := s.db.QueryRow(`SELECT content FROM bins WHERE name = ?`, name).Scan(&content)
+	if errors.Is(err, sql.ErrNoRows) {
+		return "", ErrNotFound
+	}
+
+	return string(content), err
+}
+
+// Put replaces the content of a bin.
+func (s *Sqlite) Put(name, content string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO bins (name, content, mod_time) VALUES (?, ?, ?)
+		 ON CONFLICT(name) DO UPDATE SET content = excluded.content, mod_time = excluded.mod_time`,
+		name, []byte(content), time.Now().UnixNano())
+
+	return err
+}
+
+// Append appends content to a bin.
+func (s *Sqlite) Append(name, content string) error {
+	_, err := s.db.Exec(
+		`INSERT INTO bins (name, content, mod_time) VALUES (?, ?, ?)
+		 ON CONFLICT(name) DO UPDATE SET content = content || excluded.content, mod_time = excluded.mod_time`,
+		name, []byte(content), time.Now().UnixNano())
+
+	return err
+}
+
+// Delete removes a bin.
+func (s *Sqlite) Delete(name string) error {
+	result, err := s.db.Exec(`DELETE FROM bins WHERE name = ?`, name)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrNotFound
+	}
+
+	return nil
+}
+
+// List returns the names of all bins.
+func (s *Sqlite) List() ([]string, error) {
+	rows, err := s.db.Query(`SELECT name FROM bins ORDER BY name`)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	names := []string{}
+	for rows.Next() {
+		var name string
+		if err := rows.Scan(&name); err != nil {
+			return nil, err
+		}
+		names = append(names, name)
+	}
+
+	return names, rows.Err()
+}
+
+// Stat returns information about a bin.
+func (s *Sqlite) Stat(name string) (Info, error) {
+	var size, modTime int64
+	err := s.db.QueryRow(`SELECT length(content), mod_time FROM bins WHERE name = ?`, name).Scan(&size, &modTime)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Info{}, ErrNotFound
+	}
+	if err != nil {
+		return Info{}, err
+	}
+
+	return Info{Name: name, Size: size, ModTime: time.Unix(0, modTime)}, nil
+}
+
+// AddRevision stores a new revision of a bin.
+func (s *Sqlite) AddRevision(name string, revision Revision, content string) (Revision, error) {
+	err := s.db.QueryRow(
+		`INSERT INTO revisions (bin, id, time, size, hash, author, content)
+		 VALUES (?, (SELECT COALESCE(MAX(id), 0) + 1 FROM revisions WHERE bin = ?), ?, ?, ?, ?, ?)
+		 RETURNING id`,
+		name, name, revision.Time.UnixNano(), revision.Size, revision.Hash, revision.Author, []byte(content),
+	).Scan(&revision.ID)
+
+	return revision, err
+}
+
+// Revisions returns all revisions of a bin.
+func (s *Sqlite) Revisions(name string) ([]Revision, error) {
+	rows, err := s.db.Query(`SELECT id, time, size, hash, author FROM revisions WHERE bin = ? ORDER BY id`, name)
+	if err != nil {
+		return nil, err
+	}
+	defer rows.Close()
+
+	revisions := []Revision{}
+	for rows.Next() {
+		var revision Revision
+		var revisionTime int64
+		if err := rows.Scan(&revision.ID, &revisionTime, &revision.Size, &revision.Hash, &revision.Author); err != nil {
+			return nil, err
+		}
+		revision.Time = time.Unix(0, revisionTime).UTC()
+		revisions = append(revisions, revision)
+	}
+
+	return revisions, rows.Err()
+}
+
+// Revision returns a revision and its content.
+func (s *Sqlite) Revision(name string, id int) (Revision, string, error) {
+	var revision Revision
+	var revisionTime int64
+	var content []byte
+	err := s.db.QueryRow(
+		`SELECT id, time, size, hash, author, content FROM revisions WHERE bin = ? AND id = ?`, name, id,
+	).Scan(&revision.ID, &revisionTime, &revision.Size, &revision.Hash, &revision.Author, &content)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Revision{}, "", ErrRevisionNotFound
+	}
+	if err != nil {
+		return Revision{}, "", err
+	}
+	revision.Time = time.Unix(0, revisionTime).UTC()
+
+	return revision, string(content), nil
+}
+
+// DeleteRevision removes a revision.
+func (s *Sqlite) DeleteRevision(name string, id int) error {
+	result, err := s.db.Exec(`DELETE FROM revisions WHERE bin = ? AND id = ?`, name, id)
+	if err != nil {
+		return err
+	}
+	if affected, err := result.RowsAffected(); err == nil && affected == 0 {
+		return ErrRevisionNotFound
+	}
+
+	return nil
+}
+
+// GetMetadata returns the metadata of a bin.
+func (s *Sqlite) GetMetadata(name string) (Metadata, error) {
+	var metadata Metadata
+	var expiresAt int64
+	err := s.db.QueryRow(`SELECT expires_at, burn_after_read, encrypted FROM metadata WHERE name = ?`, name).Scan(&expiresAt, &metadata.BurnAfterRead, &metadata.Encrypted)
+	if errors.Is(err, sql.ErrNoRows) {
+		return Metadata{}, nil
+	}
+	if err != nil {
+		return Metadata{}, err
+	}
+	if expiresAt != 0 {
+		metadata.ExpiresAt = time.Unix(0, expiresAt).UTC()
+	}
+
+	return metadata, nil
+}
+
+// PutMetadata replaces the metadata of a bin.
+func (s *Sqlite) PutMetadata(name string, metadata Metadata) error {
+	if metadata.IsZero() {
+		_, err := s.db.Exec(`DELETE FROM metadata WHERE name = ?`, name)
+		return err
+	}
+
+	var expiresAt int64
+	if !metadata.ExpiresAt.IsZero() {
+		expiresAt = metadata.ExpiresAt.UnixNano()
+	}
+	_, err := s.db.Exec(
+		`INSERT INTO metadata (name, expires_at, burn_after_read, encrypted) VALUES (?, ?, ?, ?)
+		 ON CONFLICT(name) DO UPDATE SET expires_at = excluded.expires_at, burn_after_read = excluded.burn_after_read, encrypted = excluded.encrypted`,
+		name, expiresAt, metadata.BurnAfterRead, metadata.Encrypted)
+
+	return err
+}
+
+// Purge removes a bin together with its metadata and revisions in a single transaction.
+func (s *Sqlite) Purge(name string) error {
+	tx, err := s.db.Begin()
+	if err != nil {
+		return err
+	}
+	defer tx.Rollback() // Rolling back a committed transaction is a no-op
+
+	var affected int64
+	for _, statement := range []string{
+		`DELETE FROM bins WHERE name = ?`,
+		`DELETE FROM metadata WHERE name = ?`,
+		`DELETE FROM revisions WHERE bin = ?`,
+	} {
+		result, err := tx.Exec(statement, name)
+		if err != nil {
+			return err
+		}
+		if rows, err := result.RowsAffected(); err == nil {
+			affected += rows
+		}
+	}
+	if affected == 0 {
+		return ErrNotFound
+	}
+
+	return tx.Commit()
+}
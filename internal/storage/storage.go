@@ -0,0 +1,124 @@
+package storage
+
+import (
+	"errors"
+	"fmt"
+	"path"
+	"regexp"
+	"time"
+)
+
+// DefaultBin is the name of the bin served on the root routes ("/", "/save", "/append" and "/fetch").
+const DefaultBin = "default"
+
+// filePermission represents the default file permission used in the application.
+const filePermission = 0644
+
+// Supported storage backends.
+const (
+	BackendFile   = "file"
+	BackendBolt   = "bolt"
+	BackendSqlite = "sqlite"
+	BackendMemory = "memory"
+)
+
+// SupportedBackends is a list of all supported storage backends.
+var SupportedBackends = []string{BackendFile, BackendBolt, BackendSqlite, BackendMemory}
+
+// ErrNotFound is returned when a bin does not exist in the storage.
+var ErrNotFound = errors.New("Bin not found")
+
+// binNameRegex defines which characters are allowed in a bin name.
+var binNameRegex = regexp.MustCompile(`^[a-zA-Z0-9_-]{1,64}$`)
+
+// IsValidBinName checks if the given name can be used as a bin name.
+//
+// Parameters:
+//   - name: string
+//     The bin name to check.
+//
+// Returns:
+//   - bool
+//     True if the name only contains letters, digits, '-' or '_' and is at most 64 characters long.
+func IsValidBinName(name string) bool {
+	return binNameRegex.MatchString(name)
+}
+
+// Info describes a bin stored in a storage backend.
+type Info struct {
+	Name    string    `json:"name"`    // Name is the name of the bin.
+	Size    int64     `json:"size"`    // Size is the size of the content in bytes.
+	ModTime time.Time `json:"modTime"` // ModTime is the time of the last modification.
+}
+
+// Storage is the interface implemented by all storage backends.
+//
+// Implementations must be safe for concurrent use. Bin names passed to a backend
+// are expected to be validated with IsValidBinName.
+type Storage interface {
+	// Get returns the content of a bin or ErrNotFound if the bin does not exist.
+	Get(name string) (string, error)
+	// Put replaces the content of a bin, creating the bin if it does not exist.
+	Put(name, content string) error
+	// Append appends content to a bin, creating the bin if it does not exist.
+	Append(name, content string) error
+	// Delete removes a bin or returns ErrNotFound if the bin does not exist.
+	Delete(name string) error
+	// List returns the sorted names of all stored bins.
+	List() ([]string, error)
+	// Stat returns information about a bin or ErrNotFound if the bin does not exist.
+	Stat(name string) (Info, error)
+
+	History       // History keeps the revisions of every bin.
+	MetadataStore // MetadataStore keeps the metadata of every bin.
+}
+
+// Recoverer is implemented by storage backends which can clean up after a crash.
+type Recoverer interface {
+	// Recover detects and cleans up leftovers of interrupted writes and returns the number of removed files.
+	Recover() (int, error)
+}
+
+// New creates the storage backend with the given name.
+//
+// Parameters:
+//   - backend: string
+//     The name of the backend. Must be one of SupportedBackends.
+//   - directory: string
+//     The storage directory used by the file based backends.
+//   - name: string
+//     The name of the storage file. The bolt and sqlite backends store their database
+//     as '<name>.db' in the storage directory.
+//
+// Returns:
+//   - Storage
+//     The storage backend.
+//   - error
+//     An error if the backend is unknown or cannot be opened.
+func New(backend, directory, name string) (Storage, error) {
+	switch backend {
+	case BackendFile, "":
+		return NewFile(directory, name), nil
+	case BackendBolt:
+		store, err := NewBolt(databasePath(directory, name))
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case BackendSqlite:
+		store, err := NewSqlite(databasePath(directory, name))
+		if err != nil {
+			return nil, err
+		}
+		return store, nil
+	case BackendMemory:
+		return NewMemory(), nil
+	default:
+		return nil, fmt.Errorf("Unsupported storage backend '%s'. Supported backends are: %v", backend, SupportedBackends)
+	}
+}
+
+// databasePath returns the path to the database file used by the embedded database backends.
+func databasePath(directory, name string) string {
+	return path.Join(directory, name+".db")
+}
@@ -0,0 +1,513 @@
+package storage
+
+import (
+	"context"
+	"os"
+	"path"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestIsValidBinName(t *testing.T) {
+	t.Run("Valid bin names", func(t *testing.T) {
+		for _, name := range []string{"default", "notes", "my-bin_2", "A"} {
+			assert.True(t, IsValidBinName(name), name)
+		}
+	})
+
+	t.Run("Invalid bin names", func(t *testing.T) {
+		for _, name := range []string{"", "../etc", "with space", "dot.ted", string(make([]byte, 65))} {
+			assert.False(t, IsValidBinName(name), name)
+		}
+	})
+}
+
+func TestNew(t *testing.T) {
+	for _, backend := range SupportedBackends {
+		t.Run("Create "+backend+" backend", func(t *testing.T) {
+			store, err := New(backend, t.TempDir(), ".vimbin")
+			assert.NoError(t, err)
+			assert.NotNil(t, store)
+		})
+	}
+
+	t.Run("Unsupported backend", func(t *testing.T) {
+		store, err := New("tape", t.TempDir(), ".vimbin")
+		assert.Nil(t, store)
+		assert.EqualError(t, err, "Unsupported storage backend 'tape'. Supported backends are: [file bolt sqlite memory]")
+	})
+}
+
+func TestBackends(t *testing.T) {
+	for _, backend := range SupportedBackends {
+		t.Run(backend, func(t *testing.T) {
+			store, err := New(backend, t.TempDir(), ".vimbin")
+			assert.NoError(t, err)
+
+			_, err = store.Get("notes")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			_, err = store.Stat("notes")
+			assert.ErrorIs(t, err, ErrNotFound)
+
+			assert.NoError(t, store.Put("notes", "hello"))
+			assert.NoError(t, store.Append("notes", " world"))
+			assert.NoError(t, store.Append("log", "first line"))
+			assert.NoError(t, store.Put(DefaultBin, "default content"))
+
+			content, err := store.Get("notes")
+			assert.NoError(t, err)
+			assert.Equal(t, "hello world", content)
+
+			info, err := store.Stat("notes")
+			assert.NoError(t, err)
+			assert.Equal(t, "notes", info.Name)
+			assert.Equal(t, int64(11), info.Size)
+			assert.False(t, info.ModTime.IsZero())
+
+			names, err := store.List()
+			assert.NoError(t, err)
+			assert.Equal(t, []string{DefaultBin, "log", "notes"}, names)
+
+			assert.NoError(t, store.Delete("notes"))
+			assert.ErrorIs(t, store.Delete("notes"), ErrNotFound)
+
+			names, err = store.List()
+			assert.NoError(t, err)
+			assert.Equal(t, []string{DefaultBin, "log"}, names)
+		})
+	}
+}
+
+func TestFile(t *testing.T) {
+	t.Run("Bin paths", func(t *testing.T) {
+		store := NewFile("/data", ".vimbin")
+
+		assert.Equal(t, "/data/.vimbin", store.Path(DefaultBin))
+		assert.Equal(t, "/data/.vimbin.notes", store.Path("notes"))
+	})
+
+	t.Run("List ignores unrelated files", func(t *testing.T) {
+		tempDir := t.TempDir()
+		store := NewFile(tempDir, ".vimbin")
+
+		for _, name := range []string{".vimbin", ".vimbin.notes", ".vimbin.not.a.bin", "other"} {
+			assert.NoError(t, os.WriteFile(path.Join(tempDir, name), []byte("content"), filePermission))
+		}
+		assert.NoError(t, os.Mkdir(path.Join(tempDir, ".vimbin.dir"), 0755))
+
+		names, err := store.List()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{DefaultBin, "notes"}, names)
+	})
+
+	t.Run("History of the default bin and a bin named history are separate", func(t *testing.T) {
+		store := NewFile(t.TempDir(), ".vimbin")
+
+		assert.NoError(t, store.Put("history", "content"))
+		_, err := store.AddRevision(DefaultBin, NewRevision("default", "ci"), "default")
+		assert.NoError(t, err)
+		_, err = store.AddRevision("history", NewRevision("content", "ci"), "content")
+		assert.NoError(t, err)
+
+		assert.NoError(t, store.Purge("history"))
+		revisions, err := store.Revisions(DefaultBin)
+		assert.NoError(t, err)
+		assert.Len(t, revisions, 1)
+		names, err := store.List()
+		assert.NoError(t, err)
+		assert.Equal(t, []string{}, names)
+	})
+
+	t.Run("Recover moves history directories of the previous layout", func(t *testing.T) {
+		tempDir := t.TempDir()
+		store := NewFile(tempDir, ".vimbin")
+
+		for _, name := range []string{".vimbin.history", ".vimbin.notes.history"} {
+			assert.NoError(t, os.Mkdir(path.Join(tempDir, name), 0755))
+			assert.NoError(t, os.WriteFile(path.Join(tempDir, name, "1"), []byte(name), filePermission))
+			assert.NoError(t, os.WriteFile(path.Join(tempDir, name, "1.json"), []byte(`{"id":1}`), filePermission))
+		}
+
+		_, err := store.Recover()
+		assert.NoError(t, err)
+
+		for name, content := range map[string]string{DefaultBin: ".vimbin.history", "notes": ".vimbin.notes.history"} {
+			_, stored, err := store.Revision(name, 1)
+			assert.NoError(t, err)
+			assert.Equal(t, content, stored)
+			assert.NoDirExists(t, path.Join(tempDir, content))
+		}
+	})
+}
+
+func TestHistory(t *testing.T) {
+	for _, backend := range SupportedBackends {
+		t.Run(backend, func(t *testing.T) {
+			store, err := New(backend, t.TempDir(), ".vimbin")
+			assert.NoError(t, err)
+
+			revisions, err := store.Revisions("notes")
+			assert.NoError(t, err)
+			assert.Empty(t, revisions)
+
+			first, err := store.AddRevision("notes", NewRevision("first", "ci"), "first")
+			assert.NoError(t, err)
+			assert.Equal(t, 1, first.ID)
+
+			second, err := store.AddRevision("notes", NewRevision("second", "admin"), "second")
+			assert.NoError(t, err)
+			assert.Equal(t, 2, second.ID)
+
+			_, err = store.AddRevision("other", NewRevision("other", "ci"), "other")
+			assert.NoError(t, err)
+
+			revisions, err = store.Revisions("notes")
+			assert.NoError(t, err)
+			assert.Len(t, revisions, 2)
+			assert.Equal(t, "ci", revisions[0].Author)
+			assert.Equal(t, Hash("second"), revisions[1].Hash)
+			assert.Equal(t, int64(6), revisions[1].Size)
+
+			revision, content, err := store.Revision("notes", 1)
+			assert.NoError(t, err)
+			assert.Equal(t, "first", content)
+			assert.Equal(t, first.Hash, revision.Hash)
+			assert.True(t, first.Time.Equal(revision.Time))
+
+			_, _, err = store.Revision("notes", 42)
+			assert.ErrorIs(t, err, ErrRevisionNotFound)
+
+			info, err := RevisionInfo(store, "notes", 2)
+			assert.NoError(t, err)
+			assert.Equal(t, second.Hash, info.Hash)
+			_, err = RevisionInfo(store, "notes", 42)
+			assert.ErrorIs(t, err, ErrRevisionNotFound)
+
+			assert.NoError(t, store.DeleteRevision("notes", 1))
+			assert.ErrorIs(t, store.DeleteRevision("notes", 1), ErrRevisionNotFound)
+
+			ids, err := RevisionIDs(store, "notes")
+			assert.NoError(t, err)
+			assert.Equal(t, []int{2}, ids)
+
+			// Revision numbers are never reused
+			third, err := store.AddRevision("notes", NewRevision("third", "ci"), "third")
+			assert.NoError(t, err)
+			assert.Equal(t, 3, third.ID)
+		})
+	}
+}
+
+func TestMetadata(t *testing.T) {
+	for _, backend := range SupportedBackends {
+		t.Run(backend, func(t *testing.T) {
+			store, err := New(backend, t.TempDir(), ".vimbin")
+			assert.NoError(t, err)
+
+			metadata, err := store.GetMetadata("secret")
+			assert.NoError(t, err)
+			assert.True(t, metadata.IsZero())
+
+			expiresAt := time.Now().Add(time.Hour).UTC().Truncate(time.Second)
+			assert.NoError(t, store.Put("secret", "password"))
+			assert.NoError(t, store.PutMetadata("secret", Metadata{ExpiresAt: expiresAt, BurnAfterRead: true, Encrypted: true}))
+			_, err = store.AddRevision("secret", NewRevision("password", "ci"), "password")
+			assert.NoError(t, err)
+
+			metadata, err = store.GetMetadata("secret")
+			assert.NoError(t, err)
+			assert.True(t, metadata.BurnAfterRead)
+			assert.True(t, metadata.Encrypted)
+			assert.True(t, expiresAt.Equal(metadata.ExpiresAt))
+
+			// Metadata is not listed as bin
+			names, err := store.List()
+			assert.NoError(t, err)
+			assert.Equal(t, []string{"secret"}, names)
+
+			assert.NoError(t, store.Purge("secret"))
+			assert.ErrorIs(t, store.Purge("secret"), ErrNotFound)
+
+			_, err = store.Get("secret")
+			assert.ErrorIs(t, err, ErrNotFound)
+			revisions, err := store.Revisions("secret")
+			assert.NoError(t, err)
+			assert.Empty(t, revisions)
+			metadata, err = store.GetMetadata("secret")
+			assert.NoError(t, err)
+			assert.True(t, metadata.IsZero())
+
+			// The zero value removes the metadata
+			assert.NoError(t, store.PutMetadata("notes", Metadata{BurnAfterRead: true}))
+			assert.NoError(t, store.PutMetadata("notes", Metadata{}))
+			metadata, err = store.GetMetadata("notes")
+			assert.NoError(t, err)
+			assert.True(t, metadata.IsZero())
+		})
+	}
+
+	t.Run("Expired", func(t *testing.T) {
+		now := time.Now()
+		assert.False(t, Metadata{}.Expired(now))
+		assert.False(t, Metadata{ExpiresAt: now.Add(time.Second)}.Expired(now))
+		assert.True(t, Metadata{ExpiresAt: now}.Expired(now))
+	})
+}
+
+func TestPruneRevisions(t *testing.T) {
+	addRevisions := func(store *Memory, ages ...time.Duration) {
+		for _, age := range ages {
+			revision := NewRevision("content", "ci")
+			revision.Time = time.Now().Add(-age)
+			_, _ = store.AddRevision("notes", revision, "content")
+		}
+	}
+
+	ids := func(store *Memory) []int {
+		revisions, _ := store.Revisions("notes")
+		result := []int{}
+		for _, revision := range revisions {
+			result = append(result, revision.ID)
+		}
+		return result
+	}
+
+	t.Run("Keep maximum number of revisions", func(t *testing.T) {
+		store := NewMemory()
+		addRevisions(store, 0, 0, 0, 0, 0)
+
+		pruned, err := PruneRevisions(store, "notes", 2, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 3, pruned)
+		assert.Equal(t, []int{4, 5}, ids(store))
+	})
+
+	t.Run("Remove revisions older than maximum age", func(t *testing.T) {
+		store := NewMemory()
+		addRevisions(store, 72*time.Hour, 48*time.Hour, time.Hour)
+
+		pruned, err := PruneRevisions(store, "notes", 0, 24*time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, pruned)
+		assert.Equal(t, []int{3}, ids(store))
+	})
+
+	t.Run("Latest revision is always kept", func(t *testing.T) {
+		store := NewMemory()
+		addRevisions(store, 72*time.Hour, 48*time.Hour)
+
+		pruned, err := PruneRevisions(store, "notes", 0, time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, pruned)
+		assert.Equal(t, []int{2}, ids(store))
+	})
+
+	t.Run("No limits", func(t *testing.T) {
+		store := NewMemory()
+		addRevisions(store, 72*time.Hour, 0)
+
+		pruned, err := PruneRevisions(store, "notes", 0, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 0, pruned)
+	})
+
+	t.Run("File revisions which are kept are not read", func(t *testing.T) {
+		store := NewFile(t.TempDir(), ".vimbin")
+		for _, age := range []time.Duration{72 * time.Hour, 48 * time.Hour, time.Hour, time.Hour, 0} {
+			revision := NewRevision("content", "ci")
+			revision.Time = time.Now().Add(-age)
+			_, err := store.AddRevision("notes", revision, "content")
+			assert.NoError(t, err)
+		}
+		assert.NoError(t, os.WriteFile(path.Join(store.historyDirectory("notes"), "4.json"), []byte("invalid"), 0644))
+
+		pruned, err := PruneRevisions(store, "notes", 0, 24*time.Hour)
+		assert.NoError(t, err)
+		assert.Equal(t, 2, pruned)
+
+		pruned, err = PruneRevisions(store, "notes", 2, 0)
+		assert.NoError(t, err)
+		assert.Equal(t, 1, pruned)
+
+		ids, err := store.RevisionIDs("notes")
+		assert.NoError(t, err)
+		assert.Equal(t, []int{4, 5}, ids)
+	})
+}
+
+func TestFileAtomicWrites(t *testing.T) {
+	t.Run("Put leaves no temporary files", func(t *testing.T) {
+		tempDir := t.TempDir()
+		store := NewFile(tempDir, ".vimbin")
+
+		assert.NoError(t, store.Put("notes", "first"))
+		assert.NoError(t, store.Put("notes", "second"))
+
+		entries, err := os.ReadDir(tempDir)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 1)
+
+		info, err := os.Stat(store.Path("notes"))
+		assert.NoError(t, err)
+		assert.Equal(t, os.FileMode(filePermission), info.Mode().Perm())
+	})
+
+	t.Run("Failed Put keeps the old content", func(t *testing.T) {
+		tempDir := t.TempDir()
+		store := NewFile(tempDir, ".vimbin")
+		assert.NoError(t, store.Put("notes", "old"))
+
+		// Renaming over a directory fails after the temporary file was written
+		assert.NoError(t, os.Mkdir(store.Path("dir"), 0755))
+		assert.Error(t, store.Put("dir", "new"))
+
+		entries, err := os.ReadDir(tempDir)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+
+		content, err := store.Get("notes")
+		assert.NoError(t, err)
+		assert.Equal(t, "old", content)
+	})
+
+	t.Run("Recover removes leftover temporary files", func(t *testing.T) {
+		tempDir := t.TempDir()
+		store := NewFile(tempDir, ".vimbin")
+		assert.NoError(t, store.Put(DefaultBin, "intact"))
+
+		assert.NoError(t, os.WriteFile(path.Join(tempDir, ".vimbin.123.tmp"), []byte("trunc"), filePermission))
+		assert.NoError(t, os.WriteFile(path.Join(tempDir, "unrelated.tmp"), []byte("other"), filePermission))
+
+		recovered, err := store.Recover()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, recovered)
+
+		content, err := store.Get(DefaultBin)
+		assert.NoError(t, err)
+		assert.Equal(t, "intact", content)
+
+		_, err = os.Stat(path.Join(tempDir, ".vimbin.123.tmp"))
+		assert.ErrorIs(t, err, os.ErrNotExist)
+		_, err = os.Stat(path.Join(tempDir, "unrelated.tmp"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Recover removes temporary file of missing bin", func(t *testing.T) {
+		tempDir := t.TempDir()
+		store := NewFile(tempDir, ".vimbin")
+
+		// The write never completed, so the content may be truncated
+		assert.NoError(t, os.WriteFile(path.Join(tempDir, ".vimbin.notes.456.tmp"), []byte("trunc"), filePermission))
+
+		recovered, err := store.Recover()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, recovered)
+
+		_, err = store.Get("notes")
+		assert.ErrorIs(t, err, ErrNotFound)
+		assert.NoFileExists(t, path.Join(tempDir, ".vimbin.notes.456.tmp"))
+	})
+
+	t.Run("Recover removes temporary files of revisions", func(t *testing.T) {
+		tempDir := t.TempDir()
+		store := NewFile(tempDir, ".vimbin")
+		_, err := store.AddRevision("notes", NewRevision("first", "ci"), "first")
+		assert.NoError(t, err)
+
+		directory := store.historyDirectory("notes")
+		for _, name := range []string{"2.789.tmp", "2.json.790.tmp"} {
+			assert.NoError(t, os.WriteFile(path.Join(directory, name), []byte("trunc"), filePermission))
+		}
+
+		recovered, err := store.Recover()
+		assert.NoError(t, err)
+		assert.Equal(t, 2, recovered)
+
+		entries, err := os.ReadDir(directory)
+		assert.NoError(t, err)
+		assert.Len(t, entries, 2)
+		revisions, err := store.Revisions("notes")
+		assert.NoError(t, err)
+		assert.Len(t, revisions, 1)
+	})
+
+	t.Run("Recover keeps bins named like temporary files", func(t *testing.T) {
+		tempDir := t.TempDir()
+		store := NewFile(tempDir, ".vimbin")
+		assert.NoError(t, store.Put("tmp", "not temporary"))
+		assert.NoError(t, store.PutMetadata("tmp", Metadata{BurnAfterRead: true}))
+		assert.NoError(t, os.WriteFile(path.Join(tempDir, ".vimbin.other.abc.tmp"), []byte("other"), filePermission))
+		assert.NoError(t, os.WriteFile(path.Join(tempDir, ".vimbin.tmp.meta.json.789.tmp"), []byte("{}"), filePermission))
+
+		recovered, err := store.Recover()
+		assert.NoError(t, err)
+		assert.Equal(t, 1, recovered)
+
+		content, err := store.Get("tmp")
+		assert.NoError(t, err)
+		assert.Equal(t, "not temporary", content)
+		metadata, err := store.GetMetadata("tmp")
+		assert.NoError(t, err)
+		assert.True(t, metadata.BurnAfterRead)
+
+		_, err = store.Get(DefaultBin)
+		assert.ErrorIs(t, err, ErrNotFound)
+		_, err = os.Stat(path.Join(tempDir, ".vimbin.other.abc.tmp"))
+		assert.NoError(t, err)
+	})
+
+	t.Run("Temporary files are not listed as bins", func(t *testing.T) {
+		tempDir := t.TempDir()
+		store := NewFile(tempDir, ".vimbin")
+
+		assert.NoError(t, os.WriteFile(path.Join(tempDir, ".vimbin.notes.456.tmp"), []byte("content"), filePermission))
+
+		names, err := store.List()
+		assert.NoError(t, err)
+		assert.Empty(t, names)
+	})
+}
+
+func TestFileWatch(t *testing.T) {
+	t.Run("Changes of storage files are reported", func(t *testing.T) {
+		tempDir := t.TempDir()
+		store := NewFile(tempDir, ".vimbin")
+
+		changed := make(chan string, 10)
+		ctx, cancel := context.WithCancel(context.Background())
+		defer cancel()
+		assert.NoError(t, store.Watch(ctx, func(name string) { changed <- name }))
+
+		// Unrelated files are ignored
+		assert.NoError(t, os.WriteFile(path.Join(tempDir, "other"), []byte("content"), filePermission))
+		assert.NoError(t, store.PutMetadata("notes", Metadata{BurnAfterRead: true}))
+
+		assert.NoError(t, os.WriteFile(path.Join(tempDir, ".vimbin.notes"), []byte("content"), filePermission))
+		assert.NoError(t, store.Put(DefaultBin, "content"))
+
+		reported := []string{}
+		for len(reported) < 2 {
+			select {
+			case name := <-changed:
+				reported = append(reported, name)
+			case <-time.After(5 * time.Second):
+				t.Fatal("Change was not reported")
+			}
+		}
+		assert.ElementsMatch(t, []string{DefaultBin, "notes"}, reported)
+
+		select {
+		case name := <-changed:
+			t.Fatalf("Unexpected change of bin '%s'", name)
+		case <-time.After(3 * watchDelay):
+		}
+	})
+
+	t.Run("Missing storage directory", func(t *testing.T) {
+		store := NewFile(path.Join(t.TempDir(), "missing"), ".vimbin")
+		assert.Error(t, store.Watch(context.Background(), func(string) {}))
+	})
+}
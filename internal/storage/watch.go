@@ -0,0 +1,94 @@
+package storage
+
+import (
+	"context"
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// watchDelay is how long the file backend waits for further changes of a storage file before reporting it,
+// so a file written in several steps is only read once it is complete.
+const watchDelay = 100 * time.Millisecond
+
+// Watcher is implemented by storage backends whose content can be changed by other programs.
+type Watcher interface {
+	// Watch calls changed with the name of a bin whenever its content may have been changed, until the
+	// context is canceled. Changes made through the backend itself are reported as well. It returns an
+	// error if watching cannot be started.
+	Watch(ctx context.Context, changed func(name string)) error
+}
+
+// Watch watches the storage directory for changes of storage files, e.g. by a text editor or by
+// restoring a backup. Temporary, metadata and history files are ignored.
+//
+// If the operating system drops events, every bin is reported as changed, so no change is missed.
+//
+// Parameters:
+//   - ctx: context.Context
+//     The context which stops watching when canceled.
+//   - changed: func(string)
+//     The function called with the name of a changed bin. It is called from other goroutines.
+//
+// Returns:
+//   - error
+//     An error if the storage directory cannot be watched.
+func (f *File) Watch(ctx context.Context, changed func(name string)) error {
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("Unable to create file watcher: %s", err)
+	}
+	if err := watcher.Add(f.Directory); err != nil {
+		watcher.Close()
+		return fmt.Errorf("Unable to watch storage directory: %s", err)
+	}
+
+	go func() {
+		defer watcher.Close()
+
+		// Report every bin once its storage file was not changed for watchDelay
+		timers := make(map[string]*time.Timer)
+		report := func(name string) {
+			if timer, ok := timers[name]; ok {
+				timer.Reset(watchDelay)
+				return
+			}
+			timers[name] = time.AfterFunc(watchDelay, func() { changed(name) })
+		}
+		defer func() {
+			for _, timer := range timers {
+				timer.Stop()
+			}
+		}()
+
+		for {
+			select {
+			case event, ok := <-watcher.Events:
+				if !ok {
+					return
+				}
+				// Atomic writes create the storage file by renaming a temporary file over it
+				if !event.Has(fsnotify.Write) && !event.Has(fsnotify.Create) {
+					continue
+				}
+				if name, ok := f.binName(path.Base(event.Name)); ok {
+					report(name)
+				}
+			case _, ok := <-watcher.Errors:
+				if !ok {
+					return
+				}
+				names, _ := f.List()
+				for _, name := range names {
+					report(name)
+				}
+			case <-ctx.Done():
+				return
+			}
+		}
+	}()
+
+	return nil
+}
@@ -9,6 +9,8 @@ import (
 	"net/http"
 	"os"
 	"strconv"
+	"strings"
+	"unicode"
 )
 
 // Environment variable names
@@ -103,6 +105,52 @@ func ExtractHostAndPort(address string) (string, int, error) {
 	return host, port, nil
 }
 
+// sizeUnits maps the supported size suffixes to their number of bytes.
+var sizeUnits = map[string]int64{
+	"":    1,
+	"B":   1,
+	"K":   1 << 10,
+	"KB":  1 << 10,
+	"KIB": 1 << 10,
+	"M":   1 << 20,
+	"MB":  1 << 20,
+	"MIB": 1 << 20,
+	"G":   1 << 30,
+	"GB":  1 << 30,
+	"GIB": 1 << 30,
+}
+
+// ParseSize parses a size like '512', '64KB' or '10MiB' into bytes.
+//
+// Units are case-insensitive and binary, so '1KB' and '1KiB' are both 1024 bytes.
+//
+// Parameters:
+//   - size: string
+//     The size to parse.
+//
+// Returns:
+//   - int64
+//     The size in bytes.
+//   - error
+//     An error if the size is negative, not a number or has an unknown unit.
+func ParseSize(size string) (int64, error) {
+	size = strings.TrimSpace(size)
+	number := strings.TrimRightFunc(size, unicode.IsLetter)
+	unit := strings.ToUpper(strings.TrimSpace(size[len(number):]))
+
+	multiplier, ok := sizeUnits[unit]
+	if !ok {
+		return 0, fmt.Errorf("Unknown size unit '%s' in '%s'", unit, size)
+	}
+
+	value, err := strconv.ParseInt(strings.TrimSpace(number), 10, 64)
+	if err != nil || value < 0 {
+		return 0, fmt.Errorf("Invalid size '%s'", size)
+	}
+
+	return value * multiplier, nil
+}
+
 // CreateHTTPClient creates an HTTP client with optional insecure skip verify.
 //
 // Parameters:
@@ -145,7 +193,7 @@ func GenerateRandomToken(length int) (string, error) {
 		return "", fmt.Errorf("Invalid token length '%d'. Must be at minimum 1", length)
 	}
 	// Calculate the number of bytes needed to create the token
-	numBytes := (length * 3) / 4
+	numBytes := (length*3 + 3) / 4
 
 	// Generate random bytes
 	randomBytes := make([]byte, numBytes)
@@ -137,6 +137,36 @@ func TestExtractHostAndPort(t *testing.T) {
 	})
 }
 
+func TestParseSize(t *testing.T) {
+	t.Run("Valid sizes", func(t *testing.T) {
+		for size, expected := range map[string]int64{
+			"0":      0,
+			"512":    512,
+			"512B":   512,
+			"64kb":   64 << 10,
+			"10MiB":  10 << 20,
+			"10 MB":  10 << 20,
+			"2G":     2 << 30,
+			" 1gib ": 1 << 30,
+		} {
+			value, err := ParseSize(size)
+			assert.NoError(t, err, size)
+			assert.Equal(t, expected, value, size)
+		}
+	})
+
+	t.Run("Invalid sizes", func(t *testing.T) {
+		_, err := ParseSize("10TB")
+		assert.EqualError(t, err, "Unknown size unit 'TB' in '10TB'")
+
+		_, err = ParseSize("MB")
+		assert.EqualError(t, err, "Invalid size 'MB'")
+
+		_, err = ParseSize("-1")
+		assert.EqualError(t, err, "Invalid size '-1'")
+	})
+}
+
 func TestCreateHTTPClient(t *testing.T) {
 	t.Run("Create HTTP client without insecure skip verify", func(t *testing.T) {
 		client := CreateHTTPClient(false)
@@ -175,6 +205,15 @@ func TestGenerateRandomToken(t *testing.T) {
 		assert.Equal(t, 64, len(token))
 	})
 
+	t.Run("Generate random tokens with lengths not divisible by 4", func(t *testing.T) {
+		for length := 1; length <= 8; length++ {
+			token, err := GenerateRandomToken(length)
+
+			assert.Nil(t, err)
+			assert.Equal(t, length, len(token))
+		}
+	})
+
 	t.Run("Error on token generation with invalid length", func(t *testing.T) {
 		_, err := GenerateRandomToken(-1)
 
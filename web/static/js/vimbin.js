@@ -76,26 +76,89 @@ document.addEventListener("DOMContentLoaded", function () {
     );
   }
 
-  // Function to save the content
-  async function saveContent() {
+  // Function to show a message in the status bar
+  function setStatus(status, { isError = false, noChanges = false, startTimer = true } = {}) {
     const statusElement = document.getElementById("status");
     clearTimeout(statusElement.timerId); // Clear the existing timer before setting a new one
 
+    statusElement.innerText = status;
+    statusElement.classList.remove("isError", "noChanges");
+
+    if (isError) {
+      statusElement.classList.add("isError");
+    }
+
+    if (noChanges) {
+      statusElement.classList.add("noChanges");
+    }
+
+    if (startTimer) {
+      const delay = 5000;
+
+      // Set a new timer
+      statusElement.timerId = setTimeout(() => {
+        statusElement.innerText = "";
+        statusElement.classList.remove("isError", "noChanges");
+      }, delay);
+    }
+  }
+
+  // Function to save the content
+  // Without a connection for collaborative editing, the content is saved over the API.
+  // If force is false, the save only succeeds if the content on the server was not changed
+  // since it was loaded into the editor.
+  async function saveContent(force = false) {
+    if (!canEdit) {
+      setStatus("ERROR: Read-only. Use :login to log in with a token allowed to write.", {
+        isError: true,
+        startTimer: false,
+      });
+      return;
+    }
+
+    // While editing collaboratively, there are no conflicts, the server writes its content
+    if (connected) {
+      saveRequested = true;
+      sendPending();
+      return;
+    }
+
     let status = "No changes were made.";
     let isError = false;
     let noChanges = true;
     let startTimer = true;
 
     try {
-      const response = await fetch("/save", {
+      const headers = {
+        "Content-Type": "application/json",
+        "X-CSRF-Token": csrfToken,
+      };
+      if (!force) {
+        headers["If-Match"] = etag;
+      }
+
+      const savedContent = editor.getValue();
+      const response = await fetch(`/api/bins/${encodeURIComponent(bin)}`, {
         method: "POST",
-        headers: {
-          "Content-Type": "application/json",
-          "X-API-Token": apiToken,
-        },
-        body: JSON.stringify({ content: editor.getValue() }),
+        headers: headers,
+        body: JSON.stringify({
+          content: encrypted ? await encryptContent(savedContent) : savedContent,
+        }),
       });
 
+      if (response.status === 412) {
+        const conflict = await response.json();
+        serverContent = await decryptContent(conflict.content);
+        serverETag = response.headers.get("ETag");
+        throw new Error(
+          "Content was changed on the server. Use :x! to overwrite or :e! to load it.",
+        );
+      }
+
+      if (response.status === 401) {
+        throw new Error("Session expired. Use :login to log in again.");
+      }
+
       if (!response.ok) {
         throw new Error(`Save failed. Reason: ${response.statusText}`);
       }
@@ -108,6 +171,11 @@ document.addEventListener("DOMContentLoaded", function () {
         throw new Error("Response was not JSON");
       }
 
+      etag = response.headers.get("ETag") || etag;
+      // Our edits are saved, start over with the content of the server on reconnect
+      pending = null;
+      pendingText = savedContent;
+      revision = null;
       const changesResponse = await response.json();
 
       if (changesResponse.status !== "no changes") {
@@ -121,28 +189,514 @@ document.addEventListener("DOMContentLoaded", function () {
       status = `ERROR: ${error.message}`;
     }
 
-    statusElement.innerText = status;
-    statusElement.classList.remove("isError", "noChanges");
+    setStatus(status, { isError, noChanges, startTimer });
+  }
 
-    if (isError) {
-      statusElement.classList.add("isError");
+  // Function to open the login page, returning to this bin afterwards
+  function login() {
+    window.location.href = `/login?next=${encodeURIComponent(window.location.pathname)}`;
+  }
+
+  // Function to end the session of the browser
+  async function logout() {
+    try {
+      const response = await fetch("/logout", {
+        method: "POST",
+        headers: { "X-CSRF-Token": csrfToken },
+      });
+      if (!response.ok) {
+        throw new Error(`Logout failed. Reason: ${response.statusText}`);
+      }
+      window.location.reload();
+    } catch (error) {
+      setStatus(`ERROR: ${error.message}`, { isError: true, startTimer: false });
     }
+  }
 
-    if (noChanges) {
-      statusElement.classList.add("noChanges");
+  // Function to replace the editor content with the content on the server
+  async function reloadContent() {
+    // While editing collaboratively, reconnect to receive the content of the server
+    if (connected) {
+      revision = null;
+      socket.close();
+      return;
     }
 
-    if (startTimer) {
-      const delay = 5000;
+    try {
+      if (serverContent === null) {
+        const response = await fetch(`/api/bins/${encodeURIComponent(bin)}`);
+        if (!response.ok && response.status !== 204) {
+          throw new Error(`Reload failed. Reason: ${response.statusText}`);
+        }
+        serverContent = await decryptContent(await response.text());
+        serverETag = response.headers.get("ETag");
+      }
 
-      // Set a new timer
-      statusElement.timerId = setTimeout(() => {
-        statusElement.innerText = "";
-        statusElement.classList.remove("isError", "noChanges");
-      }, delay);
+      applyContent(serverContent, serverETag);
+      revision = null;
+
+      setStatus("Reloaded content from server.", { noChanges: true });
+    } catch (error) {
+      setStatus(`ERROR: ${error.message}`, { isError: true, startTimer: false });
+    }
+  }
+
+  // Content of end-to-end encrypted bins is 'vimbin:e2e:v1:' followed by the base64 encoded
+  // AES-GCM nonce and ciphertext. The key is kept in the URL fragment, which is never sent to the server.
+
+  // Function to read the key of an encrypted bin from the URL fragment
+  async function importKey() {
+    const value = new URLSearchParams(window.location.hash.slice(1)).get("key");
+    if (!value) {
+      return null;
+    }
+    const raw = decodeBase64(value.replace(/-/g, "+").replace(/_/g, "/"));
+    return crypto.subtle.importKey("raw", raw, "AES-GCM", false, ["encrypt", "decrypt"]);
+  }
+
+  // Function to decode base64 to bytes
+  function decodeBase64(value) {
+    return Uint8Array.from(atob(value.trim()), (c) => c.charCodeAt(0));
+  }
+
+  // Function to encode bytes as base64, in chunks to not exceed the maximum number of arguments
+  function encodeBase64(bytes) {
+    let binary = "";
+    for (let i = 0; i < bytes.length; i += 0x8000) {
+      binary += String.fromCharCode(...bytes.subarray(i, i + 0x8000));
+    }
+    return btoa(binary);
+  }
+
+  // Function to decrypt the content of an encrypted bin. Plain content is returned as is.
+  async function decryptContent(content) {
+    if (!encrypted || !content?.startsWith(encryptedPrefix)) {
+      return content;
+    }
+    const data = decodeBase64(content.slice(encryptedPrefix.length));
+    const plaintext = await crypto.subtle.decrypt(
+      { name: "AES-GCM", iv: data.subarray(0, 12) },
+      encryptionKey,
+      data.subarray(12),
+    );
+    return new TextDecoder().decode(plaintext);
+  }
+
+  // Function to encrypt content before it is sent to the server
+  async function encryptContent(content) {
+    const iv = crypto.getRandomValues(new Uint8Array(12));
+    const ciphertext = new Uint8Array(
+      await crypto.subtle.encrypt({ name: "AES-GCM", iv }, encryptionKey, new TextEncoder().encode(content)),
+    );
+    const data = new Uint8Array(iv.length + ciphertext.length);
+    data.set(iv);
+    data.set(ciphertext, iv.length);
+    return encryptedPrefix + encodeBase64(data);
+  }
+
+  // Function to open an encrypted bin, which is edited without collaborative editing
+  // since the server cannot merge changes of content it cannot read
+  async function openEncrypted() {
+    // Browsers only provide the Web Crypto API to pages served over HTTPS or from localhost
+    if (!window.crypto?.subtle) {
+      setStatus("ERROR: Encrypted bins can only be decrypted over HTTPS.", {
+        isError: true,
+        startTimer: false,
+      });
+      return;
+    }
+
+    try {
+      encryptionKey = await importKey();
+      if (encryptionKey === null) {
+        setStatus("Encrypted. Open the link containing its key to read it.", {
+          isError: true,
+          startTimer: false,
+        });
+        return;
+      }
+      applyContent(await decryptContent(editor.getValue()), etag);
+    } catch (error) {
+      setStatus("ERROR: Unable to decrypt. The key in the link is wrong.", {
+        isError: true,
+        startTimer: false,
+      });
+      return;
+    }
+
+    editor.setOption("readOnly", !canEdit);
+    if (canEdit) {
+      setStatus("Decrypted locally. Saved content is encrypted before it leaves the browser.", {
+        noChanges: true,
+      });
+    }
+  }
+
+  // Function to stop editing when another client encrypted the bin
+  function stopIfEncrypted() {
+    if (editor.getValue().startsWith(encryptedPrefix)) {
+      editor.setOption("readOnly", true);
+      setStatus("The bin was encrypted. Open the link containing its key to read it.", {
+        isError: true,
+        startTimer: false,
+      });
+    }
+  }
+
+  // Function to replace the editor content, keeping the cursor position
+  function applyContent(content, newETag) {
+    // Reset the collaborative state first, so the new content is not sent as local edit
+    pending = null;
+    pendingText = content;
+
+    const cursor = editor.getCursor();
+    const scroll = editor.getScrollInfo();
+    editor.setValue(content);
+    editor.setCursor(cursor);
+    editor.scrollTo(scroll.left, scroll.top);
+    etag = newETag;
+    serverContent = null;
+    serverETag = null;
+  }
+
+  // Operations are arrays like in ot.js: a positive number retains, a negative number
+  // deletes and a string inserts characters. Lengths are JavaScript string lengths,
+  // which the server counts the same way.
+
+  // Function to apply an operation to a text
+  function applyOperation(text, operation) {
+    let result = "";
+    let index = 0;
+    for (const component of operation) {
+      if (typeof component === "string") {
+        result += component;
+      } else if (component > 0) {
+        result += text.slice(index, index + component);
+        index += component;
+      } else {
+        index -= component;
+      }
+    }
+    return result;
+  }
+
+  // Function to apply an operation to the editor
+  function applyOperationToEditor(operation) {
+    editor.operation(() => {
+      let index = 0;
+      for (const component of operation) {
+        if (typeof component === "string") {
+          editor.replaceRange(component, editor.posFromIndex(index));
+          index += component.length;
+        } else if (component > 0) {
+          index += component;
+        } else {
+          editor.replaceRange(
+            "",
+            editor.posFromIndex(index),
+            editor.posFromIndex(index - component),
+          );
+        }
+      }
+    });
+  }
+
+  // Function to append a component to an operation, merging it with the last component
+  function pushComponent(operation, component) {
+    if (component === 0 || component === "") {
+      return;
+    }
+    const last = operation[operation.length - 1];
+    if (typeof component === "string" && typeof last === "string") {
+      operation[operation.length - 1] = last + component;
+    } else if (typeof component === "string" && last < 0) {
+      // Inserts always come before deletes, like on the server
+      const beforeLast = operation[operation.length - 2];
+      if (typeof beforeLast === "string") {
+        operation[operation.length - 2] = beforeLast + component;
+      } else {
+        operation.splice(operation.length - 1, 0, component);
+      }
+    } else if (
+      typeof component === "number" &&
+      typeof last === "number" &&
+      Math.sign(component) === Math.sign(last)
+    ) {
+      operation[operation.length - 1] = last + component;
+    } else {
+      operation.push(component);
+    }
+  }
+
+  // Function to transform two concurrent operations a and b into a' and b',
+  // so applying a and b' results in the same text as applying b and a'.
+  // If both insert at the same position, the insert of a comes first.
+  function transformOperations(a, b) {
+    const aPrime = [];
+    const bPrime = [];
+    let ia = 0;
+    let ib = 0;
+    let ca = a[ia++];
+    let cb = b[ib++];
+
+    while (ca !== undefined || cb !== undefined) {
+      if (typeof ca === "string") {
+        pushComponent(aPrime, ca);
+        pushComponent(bPrime, ca.length);
+        ca = a[ia++];
+        continue;
+      }
+      if (typeof cb === "string") {
+        pushComponent(aPrime, cb.length);
+        pushComponent(bPrime, cb);
+        cb = b[ib++];
+        continue;
+      }
+      if (ca === undefined || cb === undefined) {
+        throw new Error("Operations do not apply to the same text");
+      }
+
+      const n = Math.min(Math.abs(ca), Math.abs(cb));
+      if (ca > 0 && cb > 0) {
+        pushComponent(aPrime, n);
+        pushComponent(bPrime, n);
+      } else if (ca < 0 && cb > 0) {
+        pushComponent(aPrime, -n);
+      } else if (ca > 0 && cb < 0) {
+        pushComponent(bPrime, -n);
+      }
+      // If both delete, the text is already gone on both sides
+
+      ca = Math.sign(ca) * (Math.abs(ca) - n) || a[ia++];
+      cb = Math.sign(cb) * (Math.abs(cb) - n) || b[ib++];
+    }
+
+    return [aPrime, bPrime];
+  }
+
+  // Function to create an operation which changes one text into another
+  function diffOperation(from, to) {
+    let start = 0;
+    while (start < from.length && start < to.length && from[start] === to[start]) {
+      start++;
+    }
+    let end = 0;
+    while (
+      end < from.length - start &&
+      end < to.length - start &&
+      from[from.length - 1 - end] === to[to.length - 1 - end]
+    ) {
+      end++;
+    }
+
+    const operation = [];
+    pushComponent(operation, start);
+    pushComponent(operation, to.slice(start, to.length - end));
+    pushComponent(operation, -(from.length - start - end));
+    pushComponent(operation, end);
+    return operation;
+  }
+
+  // Function to check if an operation changes the text
+  function isNoop(operation) {
+    return operation.every((component) => typeof component === "number" && component > 0);
+  }
+
+  // Function to send local edits to the server
+  // Only one operation is sent at a time. Edits made until the server confirmed it
+  // are sent together afterwards.
+  function sendPending() {
+    if (!canEdit || !connected || pending !== null) {
+      return;
+    }
+
+    const content = editor.getValue();
+    const unsent = diffOperation(pendingText, content);
+    if (!isNoop(unsent)) {
+      pending = unsent;
+      pendingText = content;
+      socket.send(JSON.stringify({ type: "operation", revision, operation: pending }));
+      return;
+    }
+
+    if (saveRequested) {
+      saveRequested = false;
+      socket.send(JSON.stringify({ type: "save" }));
+    }
+  }
+
+  // Function to send the cursor position to the other editors
+  function sendCursor() {
+    if (!connected) {
+      return;
+    }
+    const selection = {
+      anchor: editor.indexFromPos(editor.getCursor("anchor")),
+      head: editor.indexFromPos(editor.getCursor("head")),
+    };
+    socket.send(JSON.stringify({ type: "cursor", revision, selection }));
+  }
+
+  // Function to show the cursor of another editor
+  function showRemoteCursor(client, selection) {
+    removeRemoteCursor(client);
+
+    const widget = document.createElement("span");
+    widget.className = "remote-cursor";
+    widget.title = client;
+    const position = Math.min(selection.head, editor.getValue().length);
+    remoteCursors[client] = editor.setBookmark(editor.posFromIndex(position), {
+      widget,
+      insertLeft: true,
+    });
+  }
+
+  // Function to remove the cursor of another editor
+  function removeRemoteCursor(client) {
+    if (remoteCursors[client]) {
+      remoteCursors[client].clear();
+      delete remoteCursors[client];
+    }
+  }
+
+  // Function to handle an event sent by the server
+  function handleEvent(event) {
+    switch (event.type) {
+      case "content": {
+        // The server sent its complete content, so local edits cannot be merged anymore
+        const discarded = !isNoop(diffOperation(pendingText, editor.getValue())) || pending !== null;
+        if (editor.getValue() !== (event.content || "")) {
+          applyContent(event.content || "", event.etag);
+        }
+        pending = null;
+        pendingText = editor.getValue();
+        revision = event.revision;
+        etag = event.etag || etag;
+        stopIfEncrypted();
+        if (discarded) {
+          setStatus("Local changes were discarded, the content was reloaded from the server.", {
+            isError: true,
+            startTimer: false,
+          });
+        } else if (event.author) {
+          setStatus(`Content was updated by '${event.author}'.`, { noChanges: true });
+        }
+        break;
+      }
+      case "operation": {
+        if (event.revision <= revision) {
+          break; // Already received while catching up
+        }
+        revision = event.revision;
+        etag = event.etag || etag;
+
+        if (event.client === clientId) {
+          // The server confirmed our operation
+          pending = null;
+          sendPending();
+          break;
+        }
+
+        const unsent = diffOperation(pendingText, editor.getValue());
+        let operation = event.operation;
+        if (pending !== null) {
+          [pending, operation] = transformOperations(pending, operation);
+        }
+        pendingText = applyOperation(pendingText, operation);
+        [, operation] = transformOperations(unsent, operation);
+        applyOperationToEditor(operation);
+        stopIfEncrypted();
+
+        if (event.author) {
+          setStatus(`Content was updated by '${event.author}'.`, { noChanges: true });
+        }
+        break;
+      }
+      case "sync":
+        // The server sent all changes we missed. If our operation was not confirmed, it got lost.
+        if (pending !== null) {
+          socket.send(JSON.stringify({ type: "operation", revision, operation: pending }));
+        }
+        sendPending();
+        sendCursor();
+        break;
+      case "saved":
+        etag = event.etag || etag;
+        if (event.client === clientId) {
+          if (event.written) {
+            setStatus(`${event.written}B written`);
+          } else {
+            setStatus("No changes were made.", { noChanges: true });
+          }
+        }
+        break;
+      case "cursor":
+        if (event.client !== clientId && event.selection) {
+          showRemoteCursor(event.client, event.selection);
+        }
+        break;
+      case "leave":
+        removeRemoteCursor(event.client);
+        break;
+    }
+  }
+
+  // Function to edit collaboratively over a WebSocket, reconnecting with backoff
+  function connect(delay = 1000) {
+    const protocol = window.location.protocol === "https:" ? "wss:" : "ws:";
+    const path = bin === "default" ? "/ws" : `/b/${encodeURIComponent(bin)}/ws`;
+    const query = new URLSearchParams({ client: clientId });
+    if (revision !== null) {
+      query.set("revision", revision);
     }
+    socket = new WebSocket(`${protocol}//${window.location.host}${path}?${query}`);
+
+    socket.onopen = () => {
+      connected = true;
+      delay = 1000;
+    };
+    socket.onmessage = (message) => {
+      try {
+        handleEvent(JSON.parse(message.data));
+      } catch (error) {
+        // Start over with the content of the server
+        console.log(`Error handling event: ${error.message}`);
+        revision = null;
+        socket.close();
+      }
+    };
+    socket.onclose = (event) => {
+      connected = false;
+      for (const client of Object.keys(remoteCursors)) {
+        removeRemoteCursor(client);
+      }
+      if (event.reason === "bin removed") {
+        setStatus("ERROR: The bin was removed.", { isError: true, startTimer: false });
+        return;
+      }
+      const nextDelay = Math.min(delay * 2, 30000);
+      console.log(`Connection to server lost, reconnecting in ${delay}ms`);
+      setTimeout(() => connect(nextDelay), delay);
+    };
   }
 
+  // Prefix of encrypted content and the key of an encrypted bin
+  const encryptedPrefix = "vimbin:e2e:v1:";
+  var encryptionKey = null;
+
+  // Content and ETag of the server after a conflict
+  var serverContent = null;
+  var serverETag = null;
+
+  // State of the collaborative editing
+  var clientId = Math.random().toString(36).slice(2, 14);
+  var socket = null;
+  var connected = false;
+  var pending = null; // operation sent to the server, awaiting confirmation
+  var pendingText = null; // content after the pending operation, without unsent edits
+  var saveRequested = false;
+  var remoteCursors = {};
+
   var editor = CodeMirror.fromTextArea(document.getElementById("code"), {
     lineNumbers: true,
     mode: "text/x-csrc",
@@ -151,21 +705,34 @@ document.addEventListener("DOMContentLoaded", function () {
     showCursorWhenSelecting: true,
     theme: getPreferredTheme(),
     lineWrapping: true,
+    readOnly: !canEdit || encrypted, // Encrypted content can only be edited once it is decrypted
   });
 
   editor.on("cursorActivity", showRelativeLines);
 
+  // Send local edits and cursor movements to the other editors
+  pendingText = editor.getValue();
+  editor.on("changes", sendPending);
+  editor.on("cursorActivity", sendCursor);
+
   // Custom vim Ex commands
-  CodeMirror.Vim.defineEx("x", "", function () {
-    saveContent();
+  CodeMirror.Vim.defineEx("x", "", function (cm, params) {
+    saveContent(params.argString === "!");
   });
+  CodeMirror.Vim.defineEx("edit", "e", function (cm, params) {
+    if (params.argString === "!") {
+      reloadContent();
+    }
+  });
+  CodeMirror.Vim.defineEx("login", "", login);
+  CodeMirror.Vim.defineEx("logout", "", logout);
 
   var vimMode = document.getElementById("vim-mode");
   CodeMirror.on(editor, "vim-mode-change", function (e) {
     updateVimMode(e, vimMode);
   });
 
-  CodeMirror.commands.save = saveContent;
+  CodeMirror.commands.save = () => saveContent();
 
   // Listen for changes in the prefers-color-scheme media query
   window
@@ -175,6 +742,19 @@ document.addEventListener("DOMContentLoaded", function () {
     .matchMedia("(prefers-color-scheme: light)")
     .addListener(setThemeBasedOnColorScheme);
 
+  if (user === "") {
+    setStatus("Read-only. Use :login to edit.", { noChanges: true, startTimer: false });
+  } else if (!canEdit) {
+    setStatus(`Read-only. Token '${user}' may not write.`, { noChanges: true, startTimer: false });
+  }
+
+  // Edit together with other editors. Encrypted content is decrypted and saved without them.
+  if (encrypted) {
+    openEncrypted();
+  } else {
+    connect();
+  }
+
   // Focus editor
   editor.focus();
 });